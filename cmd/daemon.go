@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/remote"
+	"github.com/opencode-ai/opencode/internal/server"
+	"github.com/spf13/cobra"
+)
+
+const (
+	socketFilename    = "opencode.sock"
+	daemonLogFilename = "daemon.log"
+	daemonStartWait   = 10 * time.Second
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run OpenCode in the background and serve it over a Unix socket",
+	Long: `Start OpenCode without the TUI and keep sessions, agent runs and LSP clients
+alive in the background. Connect to it with "opencode attach"; runs keep going
+when the TUI is closed.`,
+	Example: `
+  # Run the daemon in the foreground
+  opencode daemon
+
+  # Start the daemon in the background and attach to it
+  opencode daemon --detach
+  opencode attach
+
+  # Stop the daemon
+  opencode daemon stop
+  `,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		detach, _ := cmd.Flags().GetBool("detach")
+
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		path, err := socketPath(cmd)
+		if err != nil {
+			return err
+		}
+		if err := removeStaleSocket(path); err != nil {
+			return err
+		}
+		if detach {
+			return startDetachedDaemon(cmd, path)
+		}
+
+		// Connect DB, this will also run migrations
+		conn, err := db.Connect()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		app, err := app.New(ctx, conn)
+		if err != nil {
+			logging.Error("Failed to create app: %v", err)
+			return err
+		}
+		defer app.Shutdown()
+
+		ln, err := listenUnix(path)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", path, err)
+		}
+		defer os.Remove(path)
+		if err := os.Chmod(path, 0o600); err != nil {
+			ln.Close()
+			return fmt.Errorf("failed to restrict access to %s: %w", path, err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Listening on %s\n", path)
+
+		srv := server.New(ctx, app, server.Options{Shutdown: stop})
+		return srv.Serve(ctx, ln)
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:          "stop",
+	Short:        "Stop the daemon of the project",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		path, err := socketPath(cmd)
+		if err != nil {
+			return err
+		}
+		client, err := dialDaemon(cmd.Context(), path)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if err := client.Shutdown(cmd.Context()); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Daemon stopped")
+		return nil
+	},
+}
+
+var attachCmd = &cobra.Command{
+	Use:   "attach",
+	Short: "Open the TUI on top of a running daemon",
+	Long: `Connect the TUI to the daemon of the project started with "opencode daemon".
+Quitting the TUI leaves the daemon and its agent runs running.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		path, err := socketPath(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		client, err := dialDaemon(ctx, path)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		app := app.NewClient(client.Sessions, client.Messages, client.History, client.Permissions, client.CoderAgent)
+		return runTUI(ctx, app, true)
+	},
+}
+
+// socketPath returns the --socket flag or the default socket in the data
+// directory of the project.
+func socketPath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("socket"); path != "" {
+		return filepath.Abs(path)
+	}
+	return filepath.Abs(filepath.Join(config.Get().Data.Directory, socketFilename))
+}
+
+func dialDaemon(ctx context.Context, path string) (*remote.Client, error) {
+	client, err := remote.Dial(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("no daemon is listening on %s, start one with `opencode daemon --detach`: %w", path, err)
+	}
+	return client, nil
+}
+
+// removeStaleSocket removes a socket left behind by a daemon that didn't
+// shut down cleanly, and fails if a daemon is still listening on it.
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
+
+// startDetachedDaemon starts the daemon in a new process that outlives the
+// terminal and waits until it accepts connections.
+func startDetachedDaemon(cmd *cobra.Command, path string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the opencode executable: %w", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current working directory: %v", err)
+	}
+	dataDir := config.Get().Data.Directory
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	logPath := filepath.Join(dataDir, daemonLogFilename)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open daemon log: %w", err)
+	}
+	defer logFile.Close()
+
+	args := []string{"daemon", "--socket", path, "--cwd", cwd}
+	if debug, _ := cmd.Flags().GetBool("debug"); debug {
+		args = append(args, "--debug")
+	}
+	child := exec.Command(exe, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.SysProcAttr = detachedProcAttr()
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- child.Wait()
+	}()
+
+	deadline := time.After(daemonStartWait)
+	for {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			fmt.Fprintf(cmd.OutOrStdout(), "Daemon started (pid %d), listening on %s\n", child.Process.Pid, path)
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("daemon exited during startup, see %s: %v", logPath, err)
+		case <-deadline:
+			return fmt.Errorf("daemon did not start within %s, see %s", daemonStartWait, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func init() {
+	daemonCmd.PersistentFlags().String("socket", "", "Path of the Unix socket (defaults to opencode.sock in the data directory)")
+	daemonCmd.Flags().Bool("detach", false, "Start the daemon in the background and return")
+	attachCmd.Flags().String("socket", "", "Path of the Unix socket (defaults to opencode.sock in the data directory)")
+
+	daemonCmd.AddCommand(daemonStopCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(attachCmd)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"net"
+	"syscall"
+)
+
+// detachedProcAttr starts the daemon in its own session so it survives the
+// terminal it was started from.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// listenUnix listens on a socket only its owner can connect to from the
+// moment it is created.
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0o077)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package cmd
+
+import (
+	"net"
+	"syscall"
+)
+
+// detachedProcAttr starts the daemon in its own process group so console
+// signals sent to the terminal don't reach it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// listenUnix listens on a socket in the data directory, which Windows
+// already restricts to its owner.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
+	"github.com/opencode-ai/opencode/internal/mcpserver"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Work with the Model Context Protocol",
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Publish the built-in tools as an MCP server",
+	Long: `Publish the read-only built-in tools (view, grep, glob, ls and diagnostics)
+as an MCP server over stdio, or over streamable HTTP with --http. With
+--allow-write, edit, write, patch and bash are published too; their permission
+requests are granted, so every client can change files and run commands.
+Edits are recorded in the file history of a session per MCP client, and
+diagnostics come from the LSP clients configured for the project.
+
+HTTP clients must send a bearer token, a random one printed at startup
+unless --token or $OPENCODE_SERVER_TOKEN sets it.`,
+	Example: `
+  # Serve over stdio, for clients that start the server themselves
+  opencode mcp serve
+
+  # Let the client edit files and run commands too
+  opencode mcp serve --allow-write
+
+  # Serve a few tools over streamable HTTP at http://127.0.0.1:4097/mcp
+  opencode mcp serve --http 127.0.0.1:4097 --tools view,grep
+  `,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("http")
+		toolNames, _ := cmd.Flags().GetStringSlice("tools")
+		token, _ := cmd.Flags().GetString("token")
+		hosts, _ := cmd.Flags().GetStringSlice("allow-host")
+		allowWrite, _ := cmd.Flags().GetBool("allow-write")
+		if token == "" {
+			token = os.Getenv("OPENCODE_SERVER_TOKEN")
+		}
+		generated := addr != "" && token == ""
+		if generated {
+			var err error
+			if token, err = newServerToken(); err != nil {
+				return err
+			}
+		}
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		app := app.NewWithoutAgent(ctx, conn)
+		defer app.Shutdown()
+
+		srv, err := mcpserver.New(app, mcpserver.Options{Tools: toolNames, AllowWrite: allowWrite, Token: token, Hosts: hosts})
+		if err != nil {
+			return err
+		}
+
+		if addr == "" {
+			return srv.ServeStdio(ctx, cmd.InOrStdin(), cmd.OutOrStdout())
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Listening on http://%s%s\n", ln.Addr(), mcpserver.Endpoint)
+		if generated {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Token: %s\n", token)
+		}
+		return srv.Serve(ctx, ln)
+	},
+}
+
+var mcpListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "Show the connection state of the configured MCP servers",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		servers := config.Get().MCPServers
+		if len(servers) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No MCP servers configured")
+			return nil
+		}
+		return withMCPServers(cmd, servers, func(manager *mcpclient.Manager) error {
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tTYPE\tSTATE\tPROTOCOL\tTOOLS\tERROR")
+			for _, status := range manager.Status() {
+				errText := ""
+				if status.Err != nil {
+					errText = status.Err.Error()
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+					status.Name,
+					status.Type,
+					status.State,
+					valueOr(status.ProtocolVersion, "-"),
+					status.Tools,
+					errText,
+				)
+			}
+			return tw.Flush()
+		})
+	},
+}
+
+var mcpToolsCmd = &cobra.Command{
+	Use:          "tools [server]",
+	Short:        "List the tools the MCP servers offer to the agent",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		servers := config.Get().MCPServers
+		if len(args) == 1 {
+			server, ok := servers[args[0]]
+			if !ok {
+				return fmt.Errorf("unknown MCP server %q", args[0])
+			}
+			servers = map[string]config.MCPServer{args[0]: server}
+		}
+		return withMCPServers(cmd, servers, func(manager *mcpclient.Manager) error {
+			for _, status := range manager.Status() {
+				if status.State != mcpclient.StateConnected {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s is %s\n", status.Name, status.State)
+				}
+			}
+			tools := manager.Tools()
+			if len(tools) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No tools found")
+				return nil
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SERVER\tTOOL\tDESCRIPTION")
+			for _, tool := range tools {
+				description, _, _ := strings.Cut(tool.Tool.Description, "\n")
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", tool.Server, tool.Tool.Name, description)
+			}
+			return tw.Flush()
+		})
+	},
+}
+
+var mcpTestCmd = &cobra.Command{
+	Use:   "test <server>",
+	Short: "Connect to an MCP server and report what went wrong",
+	Long: `Connect to an MCP server, even when it is disabled, and show its protocol
+version, what it offers, the last error and the end of its standard error.
+Exits with an error when the server can't be connected to.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		name := args[0]
+		server, ok := config.Get().MCPServers[name]
+		if !ok {
+			return fmt.Errorf("unknown MCP server %q", name)
+		}
+		server.Enabled = nil
+		return withMCPServers(cmd, map[string]config.MCPServer{name: server}, func(manager *mcpclient.Manager) error {
+			status := manager.Status()[0]
+			writeMCPStatus(cmd.OutOrStdout(), status, manager.Tools())
+			if status.State != mcpclient.StateConnected {
+				return fmt.Errorf("failed to connect to %s", name)
+			}
+			return nil
+		})
+	},
+}
+
+// withMCPServers connects to the given servers, waits for the first
+// connection attempt of each and calls fn.
+func withMCPServers(cmd *cobra.Command, servers map[string]config.MCPServer, fn func(*mcpclient.Manager) error) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	manager := mcpclient.NewManager(servers)
+	manager.Start(ctx)
+	defer manager.Close()
+	manager.WaitReady(ctx)
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return fn(manager)
+}
+
+func writeMCPStatus(w io.Writer, status mcpclient.ServerStatus, tools []mcpclient.Tool) {
+	fmt.Fprintf(w, "Server:    %s (%s)\n", status.Name, status.Type)
+	fmt.Fprintf(w, "State:     %s\n", status.State)
+	if status.State == mcpclient.StateConnected {
+		fmt.Fprintf(w, "Protocol:  %s\n", status.ProtocolVersion)
+		fmt.Fprintf(w, "Reports:   %s %s\n", status.ServerInfo.Name, status.ServerInfo.Version)
+		fmt.Fprintf(w, "Tools:     %d\n", status.Tools)
+		for _, tool := range tools {
+			fmt.Fprintf(w, "           %s\n", tool.Tool.Name)
+		}
+		fmt.Fprintf(w, "Resources: %d\n", status.Resources)
+		fmt.Fprintf(w, "Prompts:   %d\n", status.Prompts)
+	}
+	if status.Err != nil {
+		fmt.Fprintf(w, "Error:     %v\n", status.Err)
+	}
+	if len(status.Stderr) > 0 {
+		fmt.Fprintln(w, "Stderr:")
+		for _, line := range status.Stderr {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func init() {
+	mcpServeCmd.Flags().String("http", "", "Serve streamable HTTP on this address instead of stdio")
+	mcpServeCmd.Flags().StringSlice("tools", nil, "Only publish these tools")
+	mcpServeCmd.Flags().Bool("allow-write", false, "Also publish edit, write, patch and bash, which any client can then use")
+	mcpServeCmd.Flags().String("token", "", "Bearer token HTTP clients must send (defaults to $OPENCODE_SERVER_TOKEN, or a random one)")
+	mcpServeCmd.Flags().StringSlice("allow-host", nil, "Host names HTTP clients may reach the server by besides localhost")
+
+	mcpCmd.AddCommand(mcpServeCmd, mcpListCmd, mcpToolsCmd, mcpTestCmd)
+	rootCmd.AddCommand(mcpCmd)
+}
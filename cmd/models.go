@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/spf13/cobra"
+)
+
+// modelCapabilities are the capabilities models can be filtered by.
+var modelCapabilities = map[string]func(models.Model) bool{
+	"reasoning":   func(m models.Model) bool { return m.CanReason },
+	"attachments": func(m models.Model) bool { return m.SupportsAttachments },
+	"tools":       func(m models.Model) bool { return !m.NoTools },
+}
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Work with the catalog of models",
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the supported models",
+	Long: `List the built-in models together with the ones of the catalog at catalog.url,
+of the user catalog and of the providers in the config. With --json the list
+is in the catalog format, to start a user catalog from.`,
+	Example: `
+  # Models of Anthropic and OpenRouter
+  opencode models list --provider anthropic,openrouter
+
+  # Models that reason and read images
+  opencode models list --capability reasoning,attachments
+  `,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providers, _ := cmd.Flags().GetStringSlice("provider")
+		capabilities, _ := cmd.Flags().GetStringSlice("capability")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		for _, capability := range capabilities {
+			if _, ok := modelCapabilities[capability]; !ok {
+				return fmt.Errorf("unknown capability %q, expected reasoning, attachments or tools", capability)
+			}
+		}
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+
+		list := filterModels(providers, capabilities)
+		if asJSON {
+			return writeJSON(cmd.OutOrStdout(), map[string][]models.Model{"models": list})
+		}
+		if len(list) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No models found")
+			return nil
+		}
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tPROVIDER\tCONTEXT\tINPUT $/1M\tOUTPUT $/1M\tCAPABILITIES")
+		for _, m := range list {
+			var caps []string
+			for _, capability := range []string{"reasoning", "attachments", "tools"} {
+				if modelCapabilities[capability](m) {
+					caps = append(caps, capability)
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f\t%.2f\t%s\n",
+				m.ID,
+				m.Provider,
+				m.ContextWindow,
+				m.CostPer1MIn,
+				m.CostPer1MOut,
+				valueOr(strings.Join(caps, ","), "-"),
+			)
+		}
+		return tw.Flush()
+	},
+}
+
+var modelsRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Download the catalog at catalog.url",
+	Long: `Download the catalog at catalog.url into the data directory now, rather than
+once a day at startup. Its models are available the next time OpenCode starts.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		loaded, err := config.RefreshCatalog(cmd.Context())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Loaded %d models from %s\n", len(loaded), config.Get().Catalog.URL)
+		return nil
+	},
+}
+
+// filterModels returns the supported models of the providers given, all of
+// them when none is, that have every capability given. They are ordered by
+// the popularity of their provider, then by ID.
+func filterModels(providers, capabilities []string) []models.Model {
+	var list []models.Model
+	for _, m := range models.SupportedModels {
+		if m.Provider == models.ProviderMock {
+			continue
+		}
+		if len(providers) > 0 && !slices.Contains(providers, string(m.Provider)) {
+			continue
+		}
+		if !slices.ContainsFunc(capabilities, func(capability string) bool { return !modelCapabilities[capability](m) }) {
+			list = append(list, m)
+		}
+	}
+	slices.SortFunc(list, func(a, b models.Model) int {
+		return cmp.Or(
+			cmp.Compare(providerRank(a.Provider), providerRank(b.Provider)),
+			cmp.Compare(a.Provider, b.Provider),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return list
+}
+
+// providerRank orders providers by popularity, unranked ones last.
+func providerRank(provider models.ModelProvider) int {
+	if rank, ok := models.ProviderPopularity[provider]; ok {
+		return rank
+	}
+	return math.MaxInt
+}
+
+func init() {
+	modelsListCmd.Flags().StringSlice("provider", nil, "Only list the models of these providers")
+	modelsListCmd.Flags().StringSlice("capability", nil, "Only list models with these capabilities (reasoning, attachments, tools)")
+	modelsListCmd.Flags().Bool("json", false, "Output the models as a catalog in JSON")
+
+	modelsCmd.AddCommand(modelsListCmd)
+	modelsCmd.AddCommand(modelsRefreshCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/spf13/cobra"
+)
+
+var ollamaCmd = &cobra.Command{
+	Use:   "ollama",
+	Short: "Work with the models of an Ollama server",
+}
+
+var ollamaPullCmd = &cobra.Command{
+	Use:   "pull <model>",
+	Short: "Download a model to the Ollama server",
+	Long: `Download a model to the Ollama server configured with providers.ollama.baseURL
+or OLLAMA_HOST, showing the progress. The model is available as
+ollama.<model> the next time OpenCode starts.`,
+	Example: `
+  opencode ollama pull qwen3:8b
+  `,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		baseURL := config.Get().Providers[models.ProviderOllama].BaseURL
+		if baseURL == "" {
+			baseURL = models.OllamaURL(os.Getenv("OLLAMA_HOST"))
+		}
+		if baseURL == "" {
+			baseURL = models.OllamaURL("127.0.0.1")
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		out := cmd.OutOrStdout()
+		status := ""
+		err := models.PullOllamaModel(ctx, baseURL, args[0], func(p models.OllamaPullProgress) {
+			if p.Total > 0 {
+				fmt.Fprintf(out, "\r%s %s/%s (%d%%)", p.Status, formatBytes(p.Completed), formatBytes(p.Total), p.Completed*100/p.Total)
+				status = p.Status
+				return
+			}
+			if status != "" {
+				fmt.Fprintln(out)
+			}
+			status = ""
+			fmt.Fprintln(out, p.Status)
+		})
+		if status != "" {
+			fmt.Fprintln(out)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to pull %s: %w", args[0], err)
+		}
+		return nil
+	},
+}
+
+// formatBytes formats a size with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	ollamaCmd.AddCommand(ollamaPullCmd)
+	rootCmd.AddCommand(ollamaCmd)
+}
@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,7 +17,6 @@ import (
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/db"
 	"github.com/opencode-ai/opencode/internal/format"
-	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 	"github.com/opencode-ai/opencode/internal/tui"
@@ -24,6 +27,9 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "opencode",
 	Short: "Terminal-based AI assistant for software development",
+	// Failed runs exit with a code scripts check, the usage would only
+	// bury the error
+	SilenceUsage: true,
 	Long: `OpenCode is a powerful terminal-based AI assistant that helps with software development tasks.
 It provides an interactive chat interface with AI capabilities, code analysis, and LSP integration
 to assist developers in writing, debugging, and understanding code directly from the terminal.`,
@@ -45,6 +51,18 @@ to assist developers in writing, debugging, and understanding code directly from
 
   # Run a single non-interactive prompt with JSON output format
   opencode -p "Explain the use of context in Go" -f json
+
+  # Ask a follow-up question in the most recent session
+  opencode -p "Now add tests for it" --continue
+
+  # Review piped input
+  git diff | opencode -p "Review this change"
+
+  # Attach an image to the prompt
+  opencode -p "What is wrong with this layout?" --attach screenshot.png
+
+  # Bound the run and require a JSON response matching a schema
+  opencode -p "List the TODOs in this project" --max-turns 10 --timeout 5m --output-schema todos.schema.json
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// If the help flag is set, show the help message
@@ -64,12 +82,57 @@ to assist developers in writing, debugging, and understanding code directly from
 		outputFormat, _ := cmd.Flags().GetString("output-format")
 		quiet, _ := cmd.Flags().GetBool("quiet")
 		restoreLastSession, _ := cmd.Flags().GetBool("restore-last-session")
+		sessionID, _ := cmd.Flags().GetString("session")
+		continueSession, _ := cmd.Flags().GetBool("continue")
+		title, _ := cmd.Flags().GetString("title")
+		attachPaths, _ := cmd.Flags().GetStringArray("attach")
+		maxTurns, _ := cmd.Flags().GetInt("max-turns")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		outputSchema, _ := cmd.Flags().GetString("output-schema")
 
 		// Validate format option
 		if !format.IsValid(outputFormat) {
 			return fmt.Errorf("invalid format option: %s\n%s", outputFormat, format.GetHelpText())
 		}
 
+		if sessionID != "" && continueSession {
+			return fmt.Errorf("--session and --continue cannot be used together")
+		}
+
+		if prompt != "" {
+			var err error
+			waitForStdin, _ := cmd.Flags().GetBool("stdin")
+			prompt, err = readPrompt(prompt, os.Stdin, waitForStdin, cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+		} else if len(attachPaths) > 0 {
+			return fmt.Errorf("--attach can only be used together with --prompt")
+		}
+
+		if maxTurns < 0 {
+			return fmt.Errorf("--max-turns must not be negative")
+		}
+		if timeout < 0 {
+			return fmt.Errorf("--timeout must not be negative")
+		}
+
+		// Resolve paths before --cwd changes the working directory
+		for i, path := range attachPaths {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return fmt.Errorf("failed to resolve attachment %s: %v", path, err)
+			}
+			attachPaths[i] = abs
+		}
+		if outputSchema != "" {
+			abs, err := filepath.Abs(outputSchema)
+			if err != nil {
+				return fmt.Errorf("failed to resolve output schema %s: %v", outputSchema, err)
+			}
+			outputSchema = abs
+		}
+
 		if cwd != "" {
 			err := os.Chdir(cwd)
 			if err != nil {
@@ -98,6 +161,19 @@ to assist developers in writing, debugging, and understanding code directly from
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		nonInteractiveOpts := app.NonInteractiveOptions{
+			Prompt:       prompt,
+			OutputFormat: outputFormat,
+			Quiet:        quiet,
+			SessionID:    sessionID,
+			Continue:     continueSession,
+			Title:        title,
+			Attachments:  attachPaths,
+			MaxTurns:     maxTurns,
+			Timeout:      timeout,
+			OutputSchema: outputSchema,
+		}
+
 		app, err := app.New(ctx, conn)
 		if err != nil {
 			logging.Error("Failed to create app: %v", err)
@@ -106,82 +182,19 @@ to assist developers in writing, debugging, and understanding code directly from
 		// Defer shutdown here so it runs for both interactive and non-interactive modes
 		defer app.Shutdown()
 
-		// Initialize MCP tools early for both modes
-		initMCPTools(ctx, app)
-
 		// Non-interactive mode
 		if prompt != "" {
 			// Run non-interactive flow using the App method
-			return app.RunNonInteractive(ctx, prompt, outputFormat, quiet)
-		}
-
-		// Interactive mode
-		// Set up the TUI
-		zone.NewGlobal()
-		program := tea.NewProgram(
-			tui.New(app, restoreLastSession),
-			tea.WithAltScreen(),
-			tea.WithMouseAllMotion(),
-		)
-
-		// Setup the subscriptions, this will send services events to the TUI
-		ch, cancelSubs := setupSubscriptions(app, ctx)
-
-		// Create a context for the TUI message handler
-		tuiCtx, tuiCancel := context.WithCancel(ctx)
-		var tuiWg sync.WaitGroup
-		tuiWg.Add(1)
-
-		// Set up message handling for the TUI
-		go func() {
-			defer tuiWg.Done()
-			defer logging.RecoverPanic("TUI-message-handler", func() {
-				attemptTUIRecovery(program)
-			})
-
-			for {
-				select {
-				case <-tuiCtx.Done():
-					logging.Info("TUI message handler shutting down")
-					return
-				case msg, ok := <-ch:
-					if !ok {
-						logging.Info("TUI message channel closed")
-						return
-					}
-					program.Send(msg)
-				}
+			err := app.RunNonInteractive(ctx, nonInteractiveOpts)
+			if isExitError(err) && format.OutputFormat(outputFormat) == format.StreamJSON {
+				// The result event of the stream already reported it
+				cmd.SilenceErrors = true
 			}
-		}()
-
-		// Cleanup function for when the program exits
-		cleanup := func() {
-			// Shutdown the app
-			app.Shutdown()
-
-			// Cancel subscriptions first
-			cancelSubs()
-
-			// Then cancel TUI message handler
-			tuiCancel()
-
-			// Wait for TUI message handler to finish
-			tuiWg.Wait()
-
-			logging.Info("All goroutines cleaned up")
-		}
-
-		// Run the TUI
-		result, err := program.Run()
-		cleanup()
-
-		if err != nil {
-			logging.Error("TUI error: %v", err)
-			return fmt.Errorf("TUI error: %v", err)
+			return err
 		}
 
-		logging.Info("TUI exited with result: %v", result)
-		return nil
+		// Interactive mode
+		return runTUI(ctx, app, restoreLastSession)
 	},
 }
 
@@ -194,20 +207,6 @@ func attemptTUIRecovery(program *tea.Program) {
 	program.Quit()
 }
 
-func initMCPTools(ctx context.Context, app *app.App) {
-	go func() {
-		defer logging.RecoverPanic("MCP-goroutine", nil)
-
-		// Create a context with timeout for the initial MCP tools fetch
-		ctxWithTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-
-		// Set this up once with proper error handling
-		agent.GetMcpTools(ctxWithTimeout, app.Permissions)
-		logging.Info("MCP message handling goroutine exiting")
-	}()
-}
-
 func setupSubscriber[T any](
 	ctx context.Context,
 	wg *sync.WaitGroup,
@@ -248,6 +247,76 @@ func setupSubscriber[T any](
 	}()
 }
 
+// runTUI runs the interactive TUI on top of the app until the user quits.
+func runTUI(ctx context.Context, app *app.App, restoreLastSession bool) error {
+	// Set up the TUI
+	zone.NewGlobal()
+	program := tea.NewProgram(
+		tui.New(app, restoreLastSession),
+		tea.WithAltScreen(),
+		tea.WithMouseAllMotion(),
+	)
+
+	// Setup the subscriptions, this will send services events to the TUI
+	ch, cancelSubs := setupSubscriptions(app, ctx)
+
+	// Create a context for the TUI message handler
+	tuiCtx, tuiCancel := context.WithCancel(ctx)
+	var tuiWg sync.WaitGroup
+	tuiWg.Add(1)
+
+	// Set up message handling for the TUI
+	go func() {
+		defer tuiWg.Done()
+		defer logging.RecoverPanic("TUI-message-handler", func() {
+			attemptTUIRecovery(program)
+		})
+
+		for {
+			select {
+			case <-tuiCtx.Done():
+				logging.Info("TUI message handler shutting down")
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					logging.Info("TUI message channel closed")
+					return
+				}
+				program.Send(msg)
+			}
+		}
+	}()
+
+	// Cleanup function for when the program exits
+	cleanup := func() {
+		// Shutdown the app
+		app.Shutdown()
+
+		// Cancel subscriptions first
+		cancelSubs()
+
+		// Then cancel TUI message handler
+		tuiCancel()
+
+		// Wait for TUI message handler to finish
+		tuiWg.Wait()
+
+		logging.Info("All goroutines cleaned up")
+	}
+
+	// Run the TUI
+	result, err := program.Run()
+	cleanup()
+
+	if err != nil {
+		logging.Error("TUI error: %v", err)
+		return fmt.Errorf("TUI error: %v", err)
+	}
+
+	logging.Info("TUI exited with result: %v", result)
+	return nil
+}
+
 func setupSubscriptions(app *app.App, parentCtx context.Context) (chan tea.Msg, func()) {
 	ch := make(chan tea.Msg, 100)
 
@@ -259,6 +328,9 @@ func setupSubscriptions(app *app.App, parentCtx context.Context) (chan tea.Msg,
 	setupSubscriber(ctx, &wg, "messages", app.Messages.Subscribe, ch)
 	setupSubscriber(ctx, &wg, "permissions", app.Permissions.Subscribe, ch)
 	setupSubscriber(ctx, &wg, "coderAgent", app.CoderAgent.Subscribe, ch)
+	if app.MCP != nil {
+		setupSubscriber(ctx, &wg, "mcp", app.MCP.Subscribe, ch)
+	}
 
 	cleanupFunc := func() {
 		logging.Info("Cancelling all subscriptions")
@@ -283,27 +355,139 @@ func setupSubscriptions(app *app.App, parentCtx context.Context) (chan tea.Msg,
 	return ch, cleanupFunc
 }
 
+// pipedInputWait is how long readPrompt waits for the first bytes of a pipe
+// before running without them. Runners and subprocesses often leave stdin
+// an open pipe nothing is ever written to.
+var pipedInputWait = time.Second
+
+// readPrompt resolves the prompt for non-interactive mode. A prompt of "-"
+// is read entirely from stdin; otherwise input redirected from a file, or
+// piped, is appended to the prompt so `git diff | opencode -p "review this"`
+// sends both. A pipe is read to the end with waitForStdin, and otherwise
+// ignored with a warning when nothing arrives within pipedInputWait.
+func readPrompt(prompt string, stdin *os.File, waitForStdin bool, warnings io.Writer) (string, error) {
+	if prompt == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt from stdin: %v", err)
+		}
+		prompt = strings.TrimSpace(string(data))
+		if prompt == "" {
+			return "", fmt.Errorf("prompt read from stdin is empty")
+		}
+		return prompt, nil
+	}
+
+	info, err := stdin.Stat()
+	if err != nil {
+		return prompt, nil
+	}
+	var data []byte
+	switch {
+	case info.Mode().IsRegular():
+		data, err = io.ReadAll(stdin)
+	case info.Mode()&os.ModeNamedPipe != 0 && waitForStdin:
+		data, err = io.ReadAll(stdin)
+	case info.Mode()&os.ModeNamedPipe != 0:
+		data, err = readPipe(stdin, pipedInputWait)
+		if errors.Is(err, errPipeIdle) {
+			fmt.Fprintf(warnings, "Warning: nothing was piped to stdin within %s, running without it; pass --stdin to wait for it\n", pipedInputWait)
+			return prompt, nil
+		}
+	default:
+		// Terminals, sockets and devices are never read
+		return prompt, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %v", err)
+	}
+	input := strings.TrimRight(string(data), "\n")
+	if strings.TrimSpace(input) == "" {
+		return prompt, nil
+	}
+	return prompt + "\n\n" + input, nil
+}
+
+// errPipeIdle is returned by readPipe when a pipe is still open but sent
+// nothing.
+var errPipeIdle = errors.New("nothing was piped to stdin")
+
+// readPipe reads a pipe to the end, or returns errPipeIdle when no bytes
+// arrive within wait. The read is then left blocked, nothing else reads
+// stdin.
+func readPipe(r io.Reader, wait time.Duration) ([]byte, error) {
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	first := make(chan chunk, 1)
+	go func() {
+		buf := make([]byte, 32*1024)
+		n, err := r.Read(buf)
+		first <- chunk{buf[:n], err}
+	}()
+
+	select {
+	case c := <-first:
+		if c.err == io.EOF {
+			return c.data, nil
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		rest, err := io.ReadAll(r)
+		return append(c.data, rest...), err
+	case <-time.After(wait):
+		return nil, errPipeIdle
+	}
+}
+
+// isExitError reports whether err ended a non-interactive run, rather than
+// kept it from starting.
+func isExitError(err error) bool {
+	var exitErr *app.ExitError
+	return errors.As(err, &exitErr)
+}
+
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		var exitErr *app.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+		os.Exit(app.ExitFailure)
 	}
 }
 
 func init() {
 	rootCmd.Flags().BoolP("help", "h", false, "Help")
 	rootCmd.Flags().BoolP("version", "v", false, "Version")
-	rootCmd.Flags().BoolP("debug", "d", false, "Debug")
-	rootCmd.Flags().StringP("cwd", "c", "", "Current working directory")
+	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	rootCmd.PersistentFlags().StringP("cwd", "c", "", "Current working directory")
 	rootCmd.Flags().StringP("prompt", "p", "", "Prompt to run in non-interactive mode")
+	rootCmd.Flags().Bool("stdin", false, "Wait for input piped to stdin however long it takes to arrive")
 
 	// Add format flag with validation logic
 	rootCmd.Flags().StringP("output-format", "f", format.Text.String(),
-		"Output format for non-interactive mode (text, json)")
+		"Output format for non-interactive mode (text, json, stream-json)")
 
 	// Add quiet flag to hide spinner in non-interactive mode
 	rootCmd.Flags().BoolP("quiet", "q", false, "Hide spinner in non-interactive mode")
 
+	// Add flags to choose the session used in non-interactive mode
+	rootCmd.Flags().StringP("session", "s", "", "Continue the given session in non-interactive mode")
+	rootCmd.Flags().Bool("continue", false, "Continue the most recent session in non-interactive mode")
+	rootCmd.Flags().String("title", "", "Title of the session created in non-interactive mode")
+
+	// Add attach flag to send files along with the prompt
+	rootCmd.Flags().StringArray("attach", nil, "Attach an image to the prompt in non-interactive mode (repeatable)")
+
+	// Add flags to bound non-interactive runs and validate their output
+	rootCmd.Flags().Int("max-turns", 0, "Maximum number of model requests in non-interactive mode (0 for no limit)")
+	rootCmd.Flags().Duration("timeout", 0, "Maximum duration of a non-interactive run, e.g. 5m (0 for no limit)")
+	rootCmd.Flags().String("output-schema", "", "JSON Schema file the final response must match in non-interactive mode")
+
 	// Add restore-last-session flag
 	rootCmd.Flags().Bool("restore-last-session", false, "Restore the last session on startup")
 
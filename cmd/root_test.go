@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRootCmdSilencesUsage(t *testing.T) {
+	// Failed runs only print their error, not the usage
+	assert.True(t, rootCmd.SilenceUsage)
+	assert.True(t, isExitError(fmt.Errorf("run: %w", &app.ExitError{Code: app.ExitTimeout, Err: errors.New("timed out")})))
+	assert.False(t, isExitError(errors.New("invalid flag")))
+}
+
+func TestReadPrompt(t *testing.T) {
+	pipedInputWait = 100 * time.Millisecond
+
+	// file is stdin redirected from a file
+	file := func(content string) func(t *testing.T) *os.File {
+		return func(t *testing.T) *os.File {
+			path := filepath.Join(t.TempDir(), "stdin")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			t.Cleanup(func() { f.Close() })
+			return f
+		}
+	}
+	// slowPipe is stdin piped from a writer, closed once it wrote content
+	// after delay unless it is left open
+	slowPipe := func(content string, leaveOpen bool, delay time.Duration) func(t *testing.T) *os.File {
+		return func(t *testing.T) *os.File {
+			r, w, err := os.Pipe()
+			require.NoError(t, err)
+			t.Cleanup(func() { r.Close(); w.Close() })
+			go func() {
+				time.Sleep(delay)
+				if content != "" {
+					w.WriteString(content)
+				}
+				if !leaveOpen {
+					w.Close()
+				}
+			}()
+			return r
+		}
+	}
+	pipe := func(content string, leaveOpen bool) func(t *testing.T) *os.File {
+		return slowPipe(content, leaveOpen, 0)
+	}
+
+	tests := []struct {
+		name        string
+		prompt      string
+		stdin       func(t *testing.T) *os.File
+		wait        bool
+		want        string
+		wantWarning bool
+		wantErr     bool
+	}{
+		{name: "redirected file", prompt: "review", stdin: file("diff\n"), want: "review\n\ndiff"},
+		{name: "empty file", prompt: "review", stdin: file(""), want: "review"},
+		{name: "piped", prompt: "review", stdin: pipe("diff\n\n", false), want: "review\n\ndiff"},
+		{name: "closed pipe", prompt: "review", stdin: pipe("", false), want: "review"},
+		{name: "pipe left open", prompt: "review", stdin: pipe("", true), want: "review", wantWarning: true},
+		{name: "slow pipe", prompt: "review", stdin: slowPipe("diff", false, 300*time.Millisecond), want: "review", wantWarning: true},
+		{name: "slow pipe waited for", prompt: "review", stdin: slowPipe("diff", false, 300*time.Millisecond), wait: true, want: "review\n\ndiff"},
+		{name: "prompt from file", prompt: "-", stdin: file("  explain this\n"), want: "explain this"},
+		{name: "prompt from pipe", prompt: "-", stdin: pipe("explain this", false), want: "explain this"},
+		{name: "empty prompt from stdin", prompt: "-", stdin: file("\n"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings strings.Builder
+			got, err := readPrompt(tt.prompt, tt.stdin(t), tt.wait, &warnings)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			if tt.wantWarning {
+				assert.Contains(t, warnings.String(), "nothing was piped to stdin")
+			} else {
+				assert.Empty(t, warnings.String())
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/llm/models"
@@ -121,6 +122,34 @@ func generateSchema() map[string]any {
 		},
 	}
 
+	schema["properties"].(map[string]any)["catalog"] = map[string]any{
+		"type":        "object",
+		"description": "Catalog of models that adds to the built-in models or overrides them",
+		"properties": map[string]any{
+			"url": map[string]any{
+				"type":        "string",
+				"description": "URL of a catalog refreshed into the data directory once a day, in the catalog format or the one of the OpenRouter models API",
+			},
+			"file": map[string]any{
+				"type":        "string",
+				"description": "Catalog file of the user, applied after the one at url",
+				"default":     "~/.config/opencode/models.json",
+			},
+		},
+	}
+
+	schema["properties"].(map[string]any)["tokenizer"] = map[string]any{
+		"type":        "object",
+		"description": "How the tokens of requests are estimated",
+		"properties": map[string]any{
+			"disableDownload": map[string]any{
+				"type":        "boolean",
+				"description": "Don't download the tokenizer of OpenAI models, estimates are approximated unless it is already in the data directory",
+				"default":     false,
+			},
+		},
+	}
+
 	// Add MCP servers
 	schema["properties"].(map[string]any)["mcpServers"] = map[string]any{
 		"type":        "object",
@@ -135,7 +164,7 @@ func generateSchema() map[string]any {
 				},
 				"env": map[string]any{
 					"type":        "array",
-					"description": "Environment variables for the MCP server",
+					"description": "Environment variables for the MCP server as KEY=VALUE, values may reference environment variables as $VAR or ${VAR}",
 					"items": map[string]any{
 						"type": "string",
 					},
@@ -150,22 +179,78 @@ func generateSchema() map[string]any {
 				"type": map[string]any{
 					"type":        "string",
 					"description": "Type of MCP server",
-					"enum":        []string{"stdio", "sse"},
+					"enum":        []string{"stdio", "sse", "http"},
 					"default":     "stdio",
 				},
 				"url": map[string]any{
 					"type":        "string",
-					"description": "URL for SSE type MCP servers",
+					"description": "URL for SSE and streamable HTTP type MCP servers",
 				},
 				"headers": map[string]any{
 					"type":        "object",
-					"description": "HTTP headers for SSE type MCP servers",
+					"description": "HTTP headers for SSE and streamable HTTP type MCP servers, values may reference environment variables as $VAR or ${VAR}",
 					"additionalProperties": map[string]any{
 						"type": "string",
 					},
 				},
+				"enabled": map[string]any{
+					"type":        "boolean",
+					"description": "Whether to connect to the MCP server",
+					"default":     true,
+				},
+				"timeout": map[string]any{
+					"type":        "integer",
+					"description": "Timeout in seconds for every request to the MCP server, including tool calls",
+					"minimum":     0,
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory of stdio MCP servers, relative to the project",
+				},
+				"allowedTools": map[string]any{
+					"type":        "array",
+					"description": "Only offer these tools of the MCP server to the agent",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"deniedTools": map[string]any{
+					"type":        "array",
+					"description": "Never offer these tools of the MCP server to the agent",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"roots": map[string]any{
+					"type":        "array",
+					"description": "Directories offered to the MCP server as roots besides the project, relative to the project",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"sampling": map[string]any{
+					"type":        "object",
+					"description": "How completions requested by the MCP server are answered",
+					"properties": map[string]any{
+						"enabled": map[string]any{
+							"type":        "boolean",
+							"description": "Whether the MCP server may request completions",
+							"default":     false,
+						},
+						"agent": map[string]any{
+							"type":        "string",
+							"description": "Agent whose model answers the requests",
+							"default":     string(config.AgentTask),
+						},
+						"tokenBudget": map[string]any{
+							"type":        "integer",
+							"description": "Tokens the MCP server may use for completions while OpenCode runs",
+							"default":     config.DefaultSamplingTokenBudget,
+							"minimum":     0,
+						},
+					},
+				},
 			},
-			"required": []string{"command"},
 		},
 	}
 
@@ -186,6 +271,185 @@ func generateSchema() map[string]any {
 					"description": "Whether the provider is disabled",
 					"default":     false,
 				},
+				"baseURL": map[string]any{
+					"type":        "string",
+					"description": "URL of the provider API",
+				},
+				"numCtx": map[string]any{
+					"type":        "integer",
+					"description": "Context window requested from Ollama, capped at the window of the model",
+					"default":     models.DefaultOllamaContext,
+					"minimum":     0,
+				},
+				"keepAlive": map[string]any{
+					"type":        "string",
+					"description": "How long Ollama keeps a model loaded after a request, as a duration like 30m or a number of seconds",
+				},
+				"region": map[string]any{
+					"type":        "string",
+					"description": "AWS region Bedrock is called in, the region of AWS_REGION or of the profile when unset",
+				},
+				"profile": map[string]any{
+					"type":        "string",
+					"description": "AWS profile Bedrock authenticates with, AWS_PROFILE or the default profile when unset",
+				},
+				"inferenceProfile": map[string]any{
+					"type":        "string",
+					"description": "Cross-region inference profile Bedrock models are called through, like us, eu, apac or global. The geography of the region when unset, none calls the models in the region only",
+				},
+				"type": map[string]any{
+					"type":        "string",
+					"description": "API spoken by a custom provider at baseURL",
+					"enum": []string{
+						string(config.ProviderOpenAICompatible),
+						string(config.ProviderAnthropicCompatible),
+					},
+				},
+				"headers": map[string]any{
+					"type":        "object",
+					"description": "Headers sent with every request, values may reference environment variables as $VAR",
+					"additionalProperties": map[string]any{
+						"type": "string",
+					},
+				},
+				"apiKeyEnv": map[string]any{
+					"type":        "string",
+					"description": "Environment variable holding the API key",
+				},
+				"models": map[string]any{
+					"type":        "array",
+					"description": "Models added to the provider, used as <provider>.<id>",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"id": map[string]any{
+								"type":        "string",
+								"description": "ID of the model within the provider",
+							},
+							"name": map[string]any{
+								"type":        "string",
+								"description": "Name shown in the model dialog",
+							},
+							"apiModel": map[string]any{
+								"type":        "string",
+								"description": "Name of the model in the API, the id when unset",
+							},
+							"contextWindow": map[string]any{
+								"type":        "integer",
+								"description": "Context window of the model",
+								"default":     config.DefaultCustomContextWindow,
+								"minimum":     1,
+							},
+							"maxTokens": map[string]any{
+								"type":        "integer",
+								"description": "Default maximum output tokens, a quarter of the context window when unset",
+								"minimum":     1,
+							},
+							"costPer1MIn": map[string]any{
+								"type":        "number",
+								"description": "Cost of 1M input tokens in USD",
+								"minimum":     0,
+							},
+							"costPer1MOut": map[string]any{
+								"type":        "number",
+								"description": "Cost of 1M output tokens in USD",
+								"minimum":     0,
+							},
+							"costPer1MInCached": map[string]any{
+								"type":        "number",
+								"description": "Cost of 1M cache write tokens in USD",
+								"minimum":     0,
+							},
+							"costPer1MOutCached": map[string]any{
+								"type":        "number",
+								"description": "Cost of 1M cache read tokens in USD",
+								"minimum":     0,
+							},
+							"canReason": map[string]any{
+								"type":        "boolean",
+								"description": "Whether the model reasons",
+								"default":     false,
+							},
+							"supportsAttachments": map[string]any{
+								"type":        "boolean",
+								"description": "Whether the model takes images",
+								"default":     false,
+							},
+							"responsesAPI": map[string]any{
+								"type":        "boolean",
+								"description": "Whether openai-compatible providers answer the model through the Responses API rather than Chat Completions",
+								"default":     false,
+							},
+						},
+						"required": []string{"id"},
+					},
+				},
+				"retry": map[string]any{
+					"type":        "object",
+					"description": "How failed requests to the provider are retried",
+					"properties": map[string]any{
+						"maxRetries": map[string]any{
+							"type":        "integer",
+							"description": "How often a request is retried, 0 to never retry",
+							"default":     8,
+							"minimum":     0,
+						},
+						"statusCodes": map[string]any{
+							"type":        "array",
+							"description": "HTTP statuses worth retrying",
+							"default":     []int{408, 429, 500, 502, 503, 504, 529},
+							"items": map[string]any{
+								"type": "integer",
+							},
+						},
+						"initialDelay": map[string]any{
+							"type":        "integer",
+							"description": "Seconds before the first retry, doubled for every retry after it, unless the provider sends Retry-After",
+							"default":     2,
+							"minimum":     1,
+						},
+						"maxDelay": map[string]any{
+							"type":        "integer",
+							"description": "Maximum seconds between retries, requests the provider asks to retry later fail right away",
+							"default":     60,
+							"minimum":     1,
+						},
+						"jitter": map[string]any{
+							"type":        "number",
+							"description": "Fraction of the delay randomly added to it",
+							"default":     0.2,
+							"minimum":     0,
+						},
+					},
+				},
+				"timeout": map[string]any{
+					"type":        "integer",
+					"description": "Seconds a request may wait for the provider to answer",
+					"default":     600,
+					"minimum":     0,
+				},
+				"streamIdleTimeout": map[string]any{
+					"type":        "integer",
+					"description": "Seconds a streamed answer may pause before it is treated as stalled and retried",
+					"default":     120,
+					"minimum":     0,
+				},
+				"rateLimit": map[string]any{
+					"type":        "object",
+					"description": "Token bucket limiting the requests sent to the provider",
+					"properties": map[string]any{
+						"requestsPerMinute": map[string]any{
+							"type":        "integer",
+							"description": "Requests allowed per minute",
+							"minimum":     1,
+						},
+						"burst": map[string]any{
+							"type":        "integer",
+							"description": "Requests that may be sent at once, requestsPerMinute when unset",
+							"minimum":     1,
+						},
+					},
+				},
 			},
 		},
 	}
@@ -200,6 +464,7 @@ func generateSchema() map[string]any {
 		string(models.ProviderBedrock),
 		string(models.ProviderAzure),
 		string(models.ProviderVertexAI),
+		string(models.ProviderOllama),
 	}
 
 	providerSchema["additionalProperties"].(map[string]any)["properties"].(map[string]any)["provider"] = map[string]any{
@@ -232,6 +497,39 @@ func generateSchema() map[string]any {
 					"description": "Reasoning effort for models that support it (OpenAI, Anthropic)",
 					"enum":        []string{"low", "medium", "high"},
 				},
+				"fallbacks": map[string]any{
+					"type":        "array",
+					"description": "Models tried in order when the model fails",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"fallbackOn": map[string]any{
+					"type":        "array",
+					"description": "Errors that switch to the next fallback model",
+					"items": map[string]any{
+						"type": "string",
+						"enum": config.FallbackConditions,
+					},
+					"default": config.DefaultFallbackOn,
+				},
+				"cache": map[string]any{
+					"type":        "object",
+					"description": "Prompt caching of the agent",
+					"properties": map[string]any{
+						"enabled": map[string]any{
+							"type":        "boolean",
+							"description": "Cache the system prompt, tools and recent messages",
+							"default":     true,
+						},
+						"ttl": map[string]any{
+							"type":        "integer",
+							"description": "Lifetime in seconds of the Gemini cached content",
+							"default":     3600,
+							"minimum":     60,
+						},
+					},
+				},
 			},
 			"required": []string{"model"},
 		},
@@ -242,7 +540,15 @@ func generateSchema() map[string]any {
 	for modelID := range models.SupportedModels {
 		modelEnum = append(modelEnum, string(modelID))
 	}
-	agentSchema["additionalProperties"].(map[string]any)["properties"].(map[string]any)["model"].(map[string]any)["enum"] = modelEnum
+	// Sorted, so the schema only changes with the models
+	slices.Sort(modelEnum)
+	// Models declared in the config are valid too
+	modelIDs := []map[string]any{
+		{"enum": modelEnum},
+		{"pattern": `^[^.]+\..+$`},
+	}
+	agentSchema["additionalProperties"].(map[string]any)["properties"].(map[string]any)["model"].(map[string]any)["anyOf"] = modelIDs
+	agentSchema["additionalProperties"].(map[string]any)["properties"].(map[string]any)["fallbacks"].(map[string]any)["items"].(map[string]any)["anyOf"] = modelIDs
 
 	// Add specific agent properties
 	agentProperties := map[string]any{}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run OpenCode headless and expose it over a local HTTP API",
+	Long: `Start OpenCode without the TUI and serve sessions, messages, agent runs,
+permissions and file history over HTTP. Changes are streamed as server-sent
+events from /events.
+
+Clients must send a bearer token, a random one printed at startup unless
+--token or $OPENCODE_SERVER_TOKEN sets it. Requests from browsers and for
+hosts other than localhost and --allow-host are rejected.`,
+	Example: `
+  # Serve on the default address with a random token
+  opencode serve
+
+  # Serve on another port with a token of your own
+  opencode serve --port 8080 --token secret
+  `,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetInt("port")
+		token, _ := cmd.Flags().GetString("token")
+		hosts, _ := cmd.Flags().GetStringSlice("allow-host")
+		if token == "" {
+			token = os.Getenv("OPENCODE_SERVER_TOKEN")
+		}
+		generated := token == ""
+		if generated {
+			var err error
+			if token, err = newServerToken(); err != nil {
+				return err
+			}
+		}
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		app, err := app.New(ctx, conn)
+		if err != nil {
+			logging.Error("Failed to create app: %v", err)
+			return err
+		}
+		defer app.Shutdown()
+
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Listening on http://%s\n", ln.Addr())
+		if generated {
+			fmt.Fprintf(cmd.OutOrStdout(), "Token: %s\n", token)
+		}
+
+		srv := server.New(ctx, app, server.Options{Token: token, Hosts: hosts})
+		return srv.Serve(ctx, ln)
+	},
+}
+
+// newServerToken returns a random token for servers started without one.
+func newServerToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func init() {
+	serveCmd.Flags().String("host", "127.0.0.1", "Address to listen on")
+	serveCmd.Flags().IntP("port", "P", 4096, "Port to listen on")
+	serveCmd.Flags().String("token", "", "Bearer token clients must send (defaults to $OPENCODE_SERVER_TOKEN, or a random one)")
+	serveCmd.Flags().StringSlice("allow-host", nil, "Host names clients may reach the server by besides localhost")
+
+	rootCmd.AddCommand(serveCmd)
+}
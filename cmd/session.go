@@ -0,0 +1,774 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/archive"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var sessionCmd = &cobra.Command{
+	Use:     "session",
+	Aliases: []string{"sessions"},
+	Short:   "Manage sessions stored for the current project",
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session as Markdown or JSON",
+	Example: `
+  # Print a readable transcript of a session
+  opencode session export 5f1c... --format md
+
+  # Archive a session so it can be imported elsewhere
+  opencode session export 5f1c... --format json -o session.json
+  `,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		formatStr, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		exportFormat, err := archive.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		svc := newSessionServices(conn)
+		a, err := archive.Export(cmd.Context(), svc.sessions, svc.messages, svc.files, args[0])
+		if err != nil {
+			return err
+		}
+
+		if output == "" || output == "-" {
+			return a.Write(cmd.OutOrStdout(), exportFormat)
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		return a.Write(f, exportFormat)
+	},
+}
+
+var sessionImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session previously exported as JSON",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer f.Close()
+
+		a, err := archive.Read(f)
+		if err != nil {
+			return err
+		}
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		id, err := archive.Import(cmd.Context(), conn, a)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Imported session %q as %s\n", a.Session.Title, id)
+		return nil
+	},
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	Example: `
+  # Sessions from the last week that cost more than 10 cents
+  opencode sessions list --since 7d --min-cost 0.10
+
+  # Task sessions spawned by a given session, as JSON
+  opencode sessions list --parent 5f1c... --json
+  `,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := sessionFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		svc := newSessionServices(conn)
+		all, err := svc.sessions.ListAll(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		sizes, err := svc.sessions.Sizes(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to compute session sizes: %w", err)
+		}
+
+		infos := make([]sessionInfo, 0, len(all))
+		for _, sess := range all {
+			if filter.matches(sess) {
+				infos = append(infos, newSessionInfo(sess, sizes[sess.ID]))
+			}
+		}
+
+		if asJSON {
+			return writeJSON(cmd.OutOrStdout(), infos)
+		}
+		if len(infos) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No sessions found")
+			return nil
+		}
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tUPDATED\tMESSAGES\tCOST\tSIZE\tTITLE")
+		for _, info := range infos {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t$%.4f\t%s\t%s\n",
+				info.ID,
+				time.Unix(info.UpdatedAt, 0).Format("2006-01-02 15:04"),
+				info.MessageCount,
+				info.Cost,
+				formatSize(info.Size),
+				info.Title,
+			)
+		}
+		return tw.Flush()
+	},
+}
+
+var sessionShowCmd = &cobra.Command{
+	Use:          "show <session-id>",
+	Short:        "Show a session's metadata and messages",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		ctx := cmd.Context()
+		svc := newSessionServices(conn)
+		sess, err := svc.sessions.Get(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get session %s: %w", args[0], err)
+		}
+		all, err := svc.sessions.ListAll(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		sizes, err := svc.sessions.Sizes(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to compute session sizes: %w", err)
+		}
+		msgs, err := svc.messages.List(ctx, sess.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+
+		details := sessionDetails{
+			Session:  newSessionInfo(sess, sizes[sess.ID]),
+			Children: []sessionInfo{},
+			Messages: make([]messageInfo, 0, len(msgs)),
+		}
+		for _, child := range all {
+			if child.ParentSessionID == sess.ID {
+				details.Children = append(details.Children, newSessionInfo(child, sizes[child.ID]))
+			}
+		}
+		for _, msg := range msgs {
+			details.Messages = append(details.Messages, messageInfo{
+				ID:        msg.ID,
+				Role:      string(msg.Role),
+				Model:     string(msg.Model),
+				Text:      msg.Content().String(),
+				ToolCalls: len(msg.ToolCalls()),
+				CreatedAt: msg.CreatedAt,
+			})
+		}
+
+		if asJSON {
+			return writeJSON(cmd.OutOrStdout(), details)
+		}
+
+		out := cmd.OutOrStdout()
+		info := details.Session
+		fmt.Fprintf(out, "Title:    %s\n", info.Title)
+		fmt.Fprintf(out, "ID:       %s\n", info.ID)
+		if info.ParentSessionID != "" {
+			fmt.Fprintf(out, "Parent:   %s\n", info.ParentSessionID)
+		}
+		fmt.Fprintf(out, "Created:  %s\n", time.Unix(info.CreatedAt, 0).Format(time.RFC3339))
+		fmt.Fprintf(out, "Updated:  %s\n", time.Unix(info.UpdatedAt, 0).Format(time.RFC3339))
+		fmt.Fprintf(out, "Messages: %d\n", info.MessageCount)
+		fmt.Fprintf(out, "Tokens:   %d prompt, %d completion\n", info.PromptTokens, info.CompletionTokens)
+		fmt.Fprintf(out, "Cost:     $%.4f\n", info.Cost)
+		fmt.Fprintf(out, "Size:     %s\n", formatSize(info.Size))
+
+		if len(details.Children) > 0 {
+			fmt.Fprintln(out, "\nChild sessions:")
+			for _, child := range details.Children {
+				fmt.Fprintf(out, "  %s  %s\n", child.ID, child.Title)
+			}
+		}
+
+		if len(details.Messages) > 0 {
+			fmt.Fprintln(out, "\nMessages:")
+			for _, msg := range details.Messages {
+				preview := strings.Join(strings.Fields(msg.Text), " ")
+				if len(preview) > 80 {
+					preview = preview[:77] + "..."
+				}
+				if preview == "" && msg.ToolCalls > 0 {
+					preview = fmt.Sprintf("(%d tool calls)", msg.ToolCalls)
+				}
+				fmt.Fprintf(out, "  %s  %-9s  %s\n",
+					time.Unix(msg.CreatedAt, 0).Format("15:04:05"),
+					msg.Role,
+					preview,
+				)
+			}
+		}
+		return nil
+	},
+}
+
+var sessionDeleteCmd = &cobra.Command{
+	Use:          "delete <session-id>...",
+	Short:        "Delete sessions together with their messages, files and child sessions",
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		ctx := cmd.Context()
+		svc := newSessionServices(conn)
+		all, err := svc.sessions.ListAll(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		children := app.ChildSessions(all)
+
+		for _, id := range args {
+			if _, err := svc.sessions.Get(ctx, id); err != nil {
+				return fmt.Errorf("failed to get session %s: %w", id, err)
+			}
+			if err := deleteSessionTree(ctx, conn, children, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted session %s\n", id)
+		}
+		return nil
+	},
+}
+
+var sessionRenameCmd = &cobra.Command{
+	Use:          "rename <session-id> <title>",
+	Short:        "Change the title of a session",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		title := strings.TrimSpace(args[1])
+		if title == "" {
+			return fmt.Errorf("title cannot be empty")
+		}
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		svc := newSessionServices(conn)
+		sess, err := svc.sessions.Get(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get session %s: %w", args[0], err)
+		}
+		sess.Title = title
+		if _, err := svc.sessions.Save(cmd.Context(), sess); err != nil {
+			return fmt.Errorf("failed to rename session: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Renamed session %s to %q\n", sess.ID, title)
+		return nil
+	},
+}
+
+var sessionPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old sessions or keep the database under a size budget",
+	Long: `Delete sessions that have not been updated within the given age, then delete
+the least recently updated sessions until the total size of the remaining ones
+fits the size budget. Child sessions, messages and file history are removed with
+their session and the database is vacuumed afterwards.`,
+	Example: `
+  # Remove sessions without activity in the last 30 days
+  opencode sessions prune --older-than 30d
+
+  # Keep at most 200MB of session data, showing what would be removed
+  opencode sessions prune --max-size 200MB --dry-run
+  `,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThanStr, _ := cmd.Flags().GetString("older-than")
+		maxSizeStr, _ := cmd.Flags().GetString("max-size")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		if olderThanStr == "" && maxSizeStr == "" {
+			return fmt.Errorf("at least one of --older-than or --max-size is required")
+		}
+		var cutoff time.Time
+		if olderThanStr != "" {
+			age, err := parseAge(olderThanStr)
+			if err != nil {
+				return err
+			}
+			cutoff = time.Now().Add(-age)
+		}
+		maxSize := int64(-1)
+		if maxSizeStr != "" {
+			size, err := parseSize(maxSizeStr)
+			if err != nil {
+				return err
+			}
+			maxSize = size
+		}
+
+		conn, err := connectDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		pruned, total, err := pruneSessions(cmd.Context(), conn, cutoff, maxSize, dryRun)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return writeJSON(cmd.OutOrStdout(), struct {
+				DryRun        bool          `json:"dry_run"`
+				Pruned        []sessionInfo `json:"pruned"`
+				RemainingSize int64         `json:"remaining_size"`
+			}{
+				DryRun:        dryRun,
+				Pruned:        append([]sessionInfo{}, pruned...),
+				RemainingSize: total,
+			})
+		}
+
+		out := cmd.OutOrStdout()
+		verb := "Deleted"
+		if dryRun {
+			verb = "Would delete"
+		}
+		for _, info := range pruned {
+			fmt.Fprintf(out, "%s %s  %s  %s\n", verb, info.ID, formatSize(info.Size), info.Title)
+		}
+		fmt.Fprintf(out, "%s %d sessions, %s of session data remaining\n", verb, len(pruned), formatSize(total))
+		return nil
+	},
+}
+
+// pruneSessions deletes the top-level sessions last updated before cutoff,
+// then the least recently updated ones until the rest fits maxSize, which
+// is ignored when negative. It returns the sessions deleted, or that would
+// be with dryRun, and the size of the remaining ones.
+func pruneSessions(ctx context.Context, conn *sql.DB, cutoff time.Time, maxSize int64, dryRun bool) ([]sessionInfo, int64, error) {
+	svc := newSessionServices(conn)
+	all, err := svc.sessions.ListAll(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	sizes, err := svc.sessions.Sizes(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to compute session sizes: %w", err)
+	}
+	children := app.ChildSessions(all)
+	pruned, total := selectPruned(all, children, sizes, cutoff, maxSize)
+	if dryRun || len(pruned) == 0 {
+		return pruned, total, nil
+	}
+	for _, info := range pruned {
+		if err := deleteSessionTree(ctx, conn, children, info.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+	return pruned, total, db.Vacuum(ctx, conn)
+}
+
+// deleteSessionTree deletes a session with its child sessions, messages and
+// files in one transaction, so a failure leaves none of them half deleted.
+func deleteSessionTree(ctx context.Context, conn *sql.DB, children map[string][]string, id string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := db.New(conn).WithTx(tx)
+	if err := app.DeleteSessionTree(ctx, session.NewService(q), message.NewService(q), history.NewService(q, conn), children, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// selectPruned picks the sessions pruneSessions deletes. Only top-level
+// sessions are candidates; their size includes every task session spawned
+// from them.
+func selectPruned(all []session.Session, children map[string][]string, sizes map[string]int64, cutoff time.Time, maxSize int64) ([]sessionInfo, int64) {
+	var candidates []sessionInfo
+	var total int64
+	for _, sess := range all {
+		if sess.ParentSessionID != "" {
+			continue
+		}
+		info := newSessionInfo(sess, treeSize(children, sizes, sess.ID))
+		candidates = append(candidates, info)
+		total += info.Size
+	}
+	slices.SortFunc(candidates, func(a, b sessionInfo) int {
+		return cmp.Compare(a.UpdatedAt, b.UpdatedAt)
+	})
+
+	var pruned []sessionInfo
+	remaining := candidates[:0:0]
+	for _, info := range candidates {
+		if !cutoff.IsZero() && time.Unix(info.UpdatedAt, 0).Before(cutoff) {
+			pruned = append(pruned, info)
+			total -= info.Size
+			continue
+		}
+		remaining = append(remaining, info)
+	}
+	for _, info := range remaining {
+		if maxSize < 0 || total <= maxSize {
+			break
+		}
+		pruned = append(pruned, info)
+		total -= info.Size
+	}
+	return pruned, total
+}
+
+// sessionInfo is the machine-readable representation of a session used by
+// the session subcommands.
+type sessionInfo struct {
+	ID               string  `json:"id"`
+	ParentSessionID  string  `json:"parent_session_id,omitempty"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	Size             int64   `json:"size"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+}
+
+func newSessionInfo(sess session.Session, size int64) sessionInfo {
+	return sessionInfo{
+		ID:               sess.ID,
+		ParentSessionID:  sess.ParentSessionID,
+		Title:            sess.Title,
+		MessageCount:     sess.MessageCount,
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+		Size:             size,
+		CreatedAt:        sess.CreatedAt,
+		UpdatedAt:        sess.UpdatedAt,
+	}
+}
+
+type messageInfo struct {
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	Model     string `json:"model,omitempty"`
+	Text      string `json:"text"`
+	ToolCalls int    `json:"tool_calls"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type sessionDetails struct {
+	Session  sessionInfo   `json:"session"`
+	Children []sessionInfo `json:"children"`
+	Messages []messageInfo `json:"messages"`
+}
+
+// sessionFilter holds the criteria accepted by `sessions list`.
+type sessionFilter struct {
+	since    time.Time
+	until    time.Time
+	minCost  float64
+	maxCost  float64
+	title    string
+	parent   string
+	children bool
+}
+
+func sessionFilterFromFlags(cmd *cobra.Command) (sessionFilter, error) {
+	var f sessionFilter
+	var err error
+
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+	if since != "" {
+		if f.since, err = parseTimeBound(since); err != nil {
+			return f, err
+		}
+	}
+	if until != "" {
+		if f.until, err = parseTimeBound(until); err != nil {
+			return f, err
+		}
+	}
+	f.minCost, _ = cmd.Flags().GetFloat64("min-cost")
+	f.maxCost, _ = cmd.Flags().GetFloat64("max-cost")
+	title, _ := cmd.Flags().GetString("title")
+	f.title = strings.ToLower(title)
+	f.parent, _ = cmd.Flags().GetString("parent")
+	f.children, _ = cmd.Flags().GetBool("all")
+	return f, nil
+}
+
+func (f sessionFilter) matches(sess session.Session) bool {
+	if f.parent != "" {
+		if sess.ParentSessionID != f.parent {
+			return false
+		}
+	} else if !f.children && sess.ParentSessionID != "" {
+		return false
+	}
+	created := time.Unix(sess.CreatedAt, 0)
+	if !f.since.IsZero() && created.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && created.After(f.until) {
+		return false
+	}
+	if f.minCost > 0 && sess.Cost < f.minCost {
+		return false
+	}
+	if f.maxCost > 0 && sess.Cost > f.maxCost {
+		return false
+	}
+	if f.title != "" && !strings.Contains(strings.ToLower(sess.Title), f.title) {
+		return false
+	}
+	return true
+}
+
+func treeSize(children map[string][]string, sizes map[string]int64, id string) int64 {
+	size := sizes[id]
+	for _, child := range children[id] {
+		size += treeSize(children, sizes, child)
+	}
+	return size
+}
+
+type sessionServices struct {
+	sessions session.Service
+	messages message.Service
+	files    history.Service
+}
+
+func newSessionServices(conn *sql.DB) sessionServices {
+	q := db.New(conn)
+	return sessionServices{
+		sessions: session.NewService(q),
+		messages: message.NewService(q),
+		files:    history.NewService(q, conn),
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// parseAge parses durations such as "36h", "30d" or "2w".
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %s", s)
+	}
+	return d, nil
+}
+
+// parseTimeBound accepts a date ("2006-01-02"), an RFC 3339 timestamp, or an
+// age relative to now ("7d").
+func parseTimeBound(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	age, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date or age: %s", s)
+	}
+	return time.Now().Add(-age), nil
+}
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses sizes such as "512KB", "200MB" or "1.5GB".
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range sizeUnits {
+		if n, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			v, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid size: %s", s)
+			}
+			return int64(v * float64(unit.bytes)), nil
+		}
+	}
+	v, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return v, nil
+}
+
+func formatSize(size int64) string {
+	for _, unit := range sizeUnits {
+		if size >= unit.bytes && unit.bytes > 1 {
+			return fmt.Sprintf("%.1f%s", float64(size)/float64(unit.bytes), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// connectDB loads the configuration for the selected working directory and
+// opens the project database without starting the rest of the application.
+func connectDB(cmd *cobra.Command) (*sql.DB, error) {
+	if err := loadConfig(cmd); err != nil {
+		return nil, err
+	}
+	return db.Connect()
+}
+
+// loadConfig changes to the --cwd directory, if given, and loads the
+// configuration of the project.
+func loadConfig(cmd *cobra.Command) error {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, _ := cmd.Flags().GetString("cwd")
+
+	if cwd != "" {
+		if err := os.Chdir(cwd); err != nil {
+			return fmt.Errorf("failed to change directory: %v", err)
+		}
+	}
+	if cwd == "" {
+		c, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current working directory: %v", err)
+		}
+		cwd = c
+	}
+	_, err := config.Load(cwd, debug)
+	return err
+}
+
+func init() {
+	sessionExportCmd.Flags().String("format", string(archive.Markdown), "Export format (md, json)")
+	sessionExportCmd.Flags().StringP("output", "o", "", "Write the export to a file instead of stdout")
+	sessionExportCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return archive.SupportedFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	sessionListCmd.Flags().String("since", "", "Only sessions created after a date (2006-01-02) or age (7d)")
+	sessionListCmd.Flags().String("until", "", "Only sessions created before a date (2006-01-02) or age (7d)")
+	sessionListCmd.Flags().Float64("min-cost", 0, "Only sessions that cost at least this much")
+	sessionListCmd.Flags().Float64("max-cost", 0, "Only sessions that cost at most this much")
+	sessionListCmd.Flags().String("title", "", "Only sessions whose title contains this text")
+	sessionListCmd.Flags().String("parent", "", "Only child sessions of the given session")
+	sessionListCmd.Flags().Bool("all", false, "Include task and title sessions")
+	sessionListCmd.Flags().Bool("json", false, "Output as JSON")
+
+	sessionShowCmd.Flags().Bool("json", false, "Output as JSON")
+
+	sessionPruneCmd.Flags().String("older-than", "", "Delete sessions not updated within this age (e.g. 30d)")
+	sessionPruneCmd.Flags().String("max-size", "", "Delete the oldest sessions until the rest fit this size (e.g. 200MB)")
+	sessionPruneCmd.Flags().Bool("dry-run", false, "Only report what would be deleted")
+	sessionPruneCmd.Flags().Bool("json", false, "Output as JSON")
+
+	sessionCmd.AddCommand(
+		sessionListCmd,
+		sessionShowCmd,
+		sessionDeleteCmd,
+		sessionRenameCmd,
+		sessionPruneCmd,
+		sessionExportCmd,
+		sessionImportCmd,
+	)
+	rootCmd.AddCommand(sessionCmd)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAge(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "36h", want: 36 * time.Hour},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "30d", want: 30 * 24 * time.Hour},
+		{in: " 1.5d ", want: 36 * time.Hour},
+		{in: "2w", want: 14 * 24 * time.Hour},
+		{in: "0d", want: 0},
+		{in: "-1d", err: true},
+		{in: "-2h", err: true},
+		{in: "d", err: true},
+		{in: "30", err: true},
+		{in: "", err: true},
+		{in: "ten days", err: true},
+	} {
+		got, err := parseAge(tc.in)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "512KB", want: 512 << 10},
+		{in: "200mb", want: 200 << 20},
+		{in: "1.5GB", want: 3 << 29},
+		{in: " 2 MB ", want: 2 << 20},
+		{in: "0", want: 0},
+		{in: "-1MB", err: true},
+		{in: "-5", err: true},
+		{in: "MB", err: true},
+		{in: "10TB", err: true},
+		{in: "", err: true},
+	} {
+		got, err := parseSize(tc.in)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestSelectPruned(t *testing.T) {
+	day := int64(24 * 60 * 60)
+	now := time.Now().Unix()
+	all := []session.Session{
+		{ID: "recent", UpdatedAt: now},
+		{ID: "old", UpdatedAt: now - 40*day},
+		{ID: "middle", UpdatedAt: now - 10*day},
+		{ID: "task", ParentSessionID: "middle", UpdatedAt: now},
+	}
+	children := map[string][]string{"middle": {"task"}}
+	sizes := map[string]int64{"recent": 100, "old": 100, "middle": 100, "task": 300}
+
+	ids := func(infos []sessionInfo) []string {
+		var out []string
+		for _, info := range infos {
+			out = append(out, info.ID)
+		}
+		return out
+	}
+
+	pruned, total := selectPruned(all, children, sizes, time.Now().Add(-30*24*time.Hour), -1)
+	assert.Equal(t, []string{"old"}, ids(pruned))
+	assert.Equal(t, int64(500), total)
+
+	// Task sessions count towards their parent and go with it
+	pruned, total = selectPruned(all, children, sizes, time.Time{}, 200)
+	assert.Equal(t, []string{"old", "middle"}, ids(pruned))
+	assert.Equal(t, int64(100), total)
+
+	pruned, total = selectPruned(all, children, sizes, time.Time{}, 1000)
+	assert.Empty(t, pruned)
+	assert.Equal(t, int64(600), total)
+}
+
+func TestPruneSessions(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	svc := newSessionServices(conn)
+	parent, err := svc.sessions.Create(ctx, "Parent")
+	require.NoError(t, err)
+	task, err := svc.sessions.CreateTaskSession(ctx, "call-1", parent.ID, "Task")
+	require.NoError(t, err)
+	_, err = svc.sessions.Create(ctx, "Empty")
+	require.NoError(t, err)
+	for _, id := range []string{parent.ID, task.ID} {
+		_, err := svc.messages.Create(ctx, id, message.CreateMessageParams{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: strings.Repeat("x", 4096)}},
+		})
+		require.NoError(t, err)
+	}
+
+	// A dry run deletes nothing
+	pruned, total, err := pruneSessions(ctx, conn, time.Now().Add(time.Hour), -1, true)
+	require.NoError(t, err)
+	assert.Len(t, pruned, 2)
+	assert.Zero(t, total)
+	all, err := svc.sessions.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	// Sessions updated after the cutoff are kept
+	pruned, _, err = pruneSessions(ctx, conn, time.Now().Add(-time.Hour), -1, false)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+
+	// The parent takes its task session and their messages with it
+	pruned, _, err = pruneSessions(ctx, conn, time.Now().Add(time.Hour), -1, false)
+	require.NoError(t, err)
+	assert.Len(t, pruned, 2)
+	all, err = svc.sessions.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	msgs, err := svc.messages.List(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestDeleteSessionTreeRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	svc := newSessionServices(conn)
+	parent, err := svc.sessions.Create(ctx, "Parent")
+	require.NoError(t, err)
+	task, err := svc.sessions.CreateTaskSession(ctx, "call-1", parent.ID, "Task")
+	require.NoError(t, err)
+	_, err = svc.messages.Create(ctx, task.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Look around"}},
+	})
+	require.NoError(t, err)
+
+	// The task session is deleted before the missing one fails the delete,
+	// which must restore it
+	children := map[string][]string{parent.ID: {task.ID, "missing"}}
+	require.Error(t, deleteSessionTree(ctx, conn, children, parent.ID))
+	all, err := svc.sessions.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	msgs, err := svc.messages.List(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Len(t, msgs, 1)
+
+	children = map[string][]string{parent.ID: {task.ID}}
+	require.NoError(t, deleteSessionTree(ctx, conn, children, parent.ID))
+	all, err = svc.sessions.ListAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
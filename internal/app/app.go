@@ -3,9 +3,10 @@ package app
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"maps"
+	"os"
 	"sync"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/lsp"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
 	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/permission"
 	"github.com/opencode-ai/opencode/internal/session"
@@ -30,6 +32,10 @@ type App struct {
 
 	CoderAgent agent.Service
 
+	// MCP holds the connections to the configured MCP servers, it is nil
+	// for apps without an agent.
+	MCP *mcpclient.Manager
+
 	LSPClients map[string]*lsp.Client
 
 	clientsMutex sync.RWMutex
@@ -40,24 +46,13 @@ type App struct {
 }
 
 func New(ctx context.Context, conn *sql.DB) (*App, error) {
-	q := db.New(conn)
-	sessions := session.NewService(q)
-	messages := message.NewService(q)
-	files := history.NewService(q, conn)
-
-	app := &App{
-		Sessions:    sessions,
-		Messages:    messages,
-		History:     files,
-		Permissions: permission.NewPermissionService(),
-		LSPClients:  make(map[string]*lsp.Client),
-	}
-
-	// Initialize theme based on configuration
-	app.initTheme()
+	app := NewWithoutAgent(ctx, conn)
 
-	// Initialize LSP clients in the background
-	go app.initLSPClients(ctx)
+	// Connect to MCP servers in the background, their tools become available
+	// to the agent as soon as each server is connected
+	app.MCP = mcpclient.NewManager(config.Get().MCPServers)
+	app.MCP.SetSamplingHandler(agent.MCPSampling(app.Permissions))
+	app.MCP.Start(ctx)
 
 	var err error
 	app.CoderAgent, err = agent.NewAgent(
@@ -71,15 +66,57 @@ func New(ctx context.Context, conn *sql.DB) (*App, error) {
 			app.History,
 			app.LSPClients,
 		),
+		agent.MCPTools(app.MCP, app.Permissions),
 	)
 	if err != nil {
 		logging.Error("Failed to create coder agent", err)
+		app.MCP.Close()
 		return nil, err
 	}
 
 	return app, nil
 }
 
+// NewWithoutAgent creates an app with the services and LSP clients but no
+// coder agent, for front-ends that only run tools such as the MCP server.
+func NewWithoutAgent(ctx context.Context, conn *sql.DB) *App {
+	q := db.New(conn)
+	sessions := session.NewService(q)
+	messages := message.NewService(q)
+	files := history.NewService(q, conn)
+
+	app := &App{
+		Sessions:    sessions,
+		Messages:    messages,
+		History:     files,
+		Permissions: permission.NewPermissionService(),
+		LSPClients:  make(map[string]*lsp.Client),
+	}
+
+	// Initialize theme based on configuration
+	app.initTheme()
+
+	// Initialize LSP clients in the background
+	go app.initLSPClients(ctx)
+
+	return app
+}
+
+// NewClient creates an app on top of services owned by another process,
+// such as a daemon. It starts no agent or LSP clients of its own.
+func NewClient(sessions session.Service, messages message.Service, files history.Service, permissions permission.Service, coder agent.Service) *App {
+	app := &App{
+		Sessions:    sessions,
+		Messages:    messages,
+		History:     files,
+		Permissions: permissions,
+		CoderAgent:  coder,
+		LSPClients:  make(map[string]*lsp.Client),
+	}
+	app.initTheme()
+	return app
+}
+
 // initTheme sets the application theme based on the configuration
 func (app *App) initTheme() {
 	cfg := config.Get()
@@ -96,72 +133,258 @@ func (app *App) initTheme() {
 	}
 }
 
+// NonInteractiveOptions configures a single non-interactive run.
+type NonInteractiveOptions struct {
+	Prompt       string
+	OutputFormat string
+	Quiet        bool
+
+	// SessionID continues an existing session instead of creating a new one.
+	SessionID string
+	// Continue reuses the most recently updated session of the project.
+	Continue bool
+	// Title names the session created for this run.
+	Title string
+	// Attachments are files sent along with the prompt.
+	Attachments []string
+
+	// MaxTurns limits the number of model requests, zero means no limit.
+	MaxTurns int
+	// Timeout limits the duration of the run, zero means no limit.
+	Timeout time.Duration
+	// OutputSchema is the path of a JSON Schema the final response must match.
+	OutputSchema string
+}
+
 // RunNonInteractive handles the execution flow when a prompt is provided via CLI flag.
-func (a *App) RunNonInteractive(ctx context.Context, prompt string, outputFormat string, quiet bool) error {
+// Failed runs return an *ExitError carrying the exit code for the failure.
+func (a *App) RunNonInteractive(ctx context.Context, opts NonInteractiveOptions) error {
 	logging.Info("Running in non-interactive mode")
+	prompt := opts.Prompt
 
-	// Start spinner if not in quiet mode
+	// Start spinner if not in quiet mode. Streamed output is meant for other
+	// programs, so it never gets a spinner.
+	streaming := format.OutputFormat(opts.OutputFormat) == format.StreamJSON
 	var spinner *format.Spinner
-	if !quiet {
+	if !opts.Quiet && !streaming {
 		spinner = format.NewSpinner("Thinking...")
 		spinner.Start()
 		defer spinner.Stop()
 	}
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
+	attachments, err := a.LoadAttachments(opts.Attachments)
+	if err != nil {
+		return err
+	}
 
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
+	var schema *outputSchema
+	if opts.OutputSchema != "" {
+		schema, err = loadOutputSchema(opts.OutputSchema)
+		if err != nil {
+			return err
+		}
 	}
-	title := titlePrefix + titleSuffix
 
-	sess, err := a.Sessions.Create(ctx, title)
+	// Give MCP servers a chance to connect so their tools are available
+	// for the single prompt
+	if a.MCP != nil {
+		a.MCP.WaitReady(ctx)
+	}
+
+	sess, err := a.nonInteractiveSession(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+		return err
+	}
+	if opts.Title != "" {
+		ctx = agent.WithoutTitleGeneration(ctx)
+	}
+	if opts.MaxTurns > 0 {
+		ctx = agent.WithMaxTurns(ctx, opts.MaxTurns)
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
 	}
-	logging.Info("Created session for non-interactive run", "session_id", sess.ID)
 
 	// Automatically approve all permission requests for this non-interactive session
 	a.Permissions.AutoApproveSession(sess.ID)
 
-	done, err := a.CoderAgent.Run(ctx, sess.ID, prompt)
-	if err != nil {
-		return fmt.Errorf("failed to start agent processing stream: %w", err)
+	var streamer *eventStreamer
+	if streaming {
+		streamer = newEventStreamer(os.Stdout, a.Messages)
+		streamer.Start(ctx, a, sess)
 	}
 
-	result := <-done
-	if result.Error != nil {
-		if errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled) {
+	response, structured, err := a.runPrompt(ctx, sess.ID, prompt, attachments, schema)
+	if err != nil {
+		code := exitCode(ctx, err)
+		if code == ExitCancelled {
 			logging.Info("Agent processing cancelled", "session_id", sess.ID)
-			return nil
 		}
-		return fmt.Errorf("agent processing failed: %w", result.Error)
+		if streamer != nil {
+			streamer.Stop(streamResultEvent{Status: exitStatus(code), Error: err.Error(), ExitCode: code})
+		}
+		return &ExitError{Code: code, Err: err}
 	}
 
 	// Stop spinner before printing output
-	if !quiet && spinner != nil {
+	if spinner != nil {
 		spinner.Stop()
 	}
 
 	// Get the text content from the response
 	content := "No content available"
-	if result.Message.Content().String() != "" {
-		content = result.Message.Content().String()
+	if response.Content().String() != "" {
+		content = response.Content().String()
+	}
+	if structured != nil {
+		content = string(structured)
 	}
 
-	fmt.Println(format.FormatOutput(content, outputFormat))
+	if streamer != nil {
+		streamer.Stop(streamResultEvent{
+			Status:           exitStatus(ExitSuccess),
+			Response:         response.Content().String(),
+			StructuredOutput: structured,
+		})
+	} else {
+		fmt.Println(format.FormatOutput(format.Result{
+			Response:         content,
+			SessionID:        sess.ID,
+			StructuredOutput: structured,
+		}, opts.OutputFormat))
+	}
 
 	logging.Info("Non-interactive run completed", "session_id", sess.ID)
 
 	return nil
 }
 
+// runPrompt sends the prompt to the coder agent and waits for the final
+// response. With a schema, the response is validated and the agent is asked
+// to correct it up to maxSchemaRetries times before the run fails.
+func (a *App) runPrompt(ctx context.Context, sessionID, prompt string, attachments []message.Attachment, schema *outputSchema) (message.Message, json.RawMessage, error) {
+	if schema != nil {
+		prompt = schema.instructions(prompt)
+	}
+	for retries := 0; ; retries++ {
+		done, err := a.CoderAgent.Run(ctx, sessionID, prompt, attachments...)
+		if err != nil {
+			return message.Message{}, nil, fmt.Errorf("failed to start agent processing stream: %w", err)
+		}
+
+		result := <-done
+		if result.Error != nil {
+			return message.Message{}, nil, fmt.Errorf("agent processing failed: %w", result.Error)
+		}
+		if result.Message.FinishReason() == message.FinishReasonPermissionDenied {
+			return result.Message, nil, fmt.Errorf("agent stopped: %w", permission.ErrorPermissionDenied)
+		}
+		if schema == nil {
+			return result.Message, nil, nil
+		}
+
+		structured, err := schema.validate(result.Message.Content().Text)
+		if err == nil {
+			return result.Message, structured, nil
+		}
+		if retries == maxSchemaRetries {
+			return result.Message, nil, fmt.Errorf("response does not match the output schema after %d retries: %w", maxSchemaRetries, err)
+		}
+		logging.Info("Response does not match the output schema, retrying", "session_id", sessionID, "error", err)
+		prompt = schema.retryPrompt(err)
+		attachments = nil
+	}
+}
+
+// LoadAttachments reads the files attached to a prompt, checking that the
+// coder model accepts attachments.
+func (a *App) LoadAttachments(paths []string) ([]message.Attachment, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	model := a.CoderAgent.Model()
+	if !model.SupportsAttachments {
+		return nil, fmt.Errorf("model %s doesn't support attachments", model.Name)
+	}
+
+	attachments := make([]message.Attachment, 0, len(paths))
+	for _, path := range paths {
+		if !message.IsAttachmentSupported(path) {
+			return nil, fmt.Errorf("unsupported attachment %s: only jpg, png and webp images can be attached", path)
+		}
+		attachment, err := message.LoadAttachment(path, message.MaxAttachmentSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach %s: %w", path, err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// nonInteractiveSession resolves the session a non-interactive run should
+// use: the one given by ID, the most recently updated one when continuing,
+// or a new session.
+func (a *App) nonInteractiveSession(ctx context.Context, opts NonInteractiveOptions) (session.Session, error) {
+	if opts.SessionID != "" {
+		sess, err := a.Sessions.Get(ctx, opts.SessionID)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to get session %s: %w", opts.SessionID, err)
+		}
+		logging.Info("Continuing session for non-interactive run", "session_id", sess.ID)
+		return sess, nil
+	}
+
+	if opts.Continue {
+		sessions, err := a.Sessions.List(ctx)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(sessions) > 0 {
+			latest := sessions[0]
+			for _, sess := range sessions[1:] {
+				if sess.UpdatedAt > latest.UpdatedAt {
+					latest = sess
+				}
+			}
+			logging.Info("Continuing most recent session for non-interactive run", "session_id", latest.ID)
+			return latest, nil
+		}
+		logging.Info("No session to continue, creating a new one")
+	}
+
+	title := opts.Title
+	if title == "" {
+		const maxPromptLengthForTitle = 100
+		titlePrefix := "Non-interactive: "
+		var titleSuffix string
+
+		if len(opts.Prompt) > maxPromptLengthForTitle {
+			titleSuffix = opts.Prompt[:maxPromptLengthForTitle] + "..."
+		} else {
+			titleSuffix = opts.Prompt
+		}
+		title = titlePrefix + titleSuffix
+	}
+
+	sess, err := a.Sessions.Create(ctx, title)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	}
+	logging.Info("Created session for non-interactive run", "session_id", sess.ID)
+	return sess, nil
+}
+
 // Shutdown performs a clean shutdown of the application
 func (app *App) Shutdown() {
+	if app.MCP != nil {
+		app.MCP.Close()
+	}
+	if app.CoderAgent != nil {
+		app.CoderAgent.Close()
+	}
+
 	// Cancel all watcher goroutines
 	app.cancelFuncsMutex.Lock()
 	for _, cancel := range app.watcherCancelFuncs {
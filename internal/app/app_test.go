@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonInteractiveSession(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	a := NewWithoutAgent(ctx, conn)
+	defer a.Shutdown()
+
+	// Without a session to continue, one is created
+	sess, err := a.nonInteractiveSession(ctx, NonInteractiveOptions{Prompt: "Explain the build", Continue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "Non-interactive: Explain the build", sess.Title)
+	first := sess.ID
+
+	// Sessions are inserted with their timestamps, updates set them to now
+	insert := func(id, parent string, updatedAt, createdAt int64) {
+		var parentID any
+		if parent != "" {
+			parentID = parent
+		}
+		_, err := conn.ExecContext(ctx, `INSERT INTO sessions (id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at)
+			VALUES (?, ?, ?, 0, 0, 0, 0, ?, ?)`, id, parentID, id, updatedAt, createdAt)
+		require.NoError(t, err)
+	}
+	now := sess.CreatedAt
+	insert("updated", "", now+300, now+100)
+	insert("created", "", now+200, now+200)
+	insert("task", "created", now+500, now+500)
+	insert("title-created", "created", now+600, now+600)
+
+	// The most recently updated session is continued, not the most recently
+	// created one, nor a task or title session
+	sess, err = a.nonInteractiveSession(ctx, NonInteractiveOptions{Prompt: "Go on", Continue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "updated", sess.ID)
+
+	sess, err = a.nonInteractiveSession(ctx, NonInteractiveOptions{Prompt: "Go on", SessionID: first})
+	require.NoError(t, err)
+	assert.Equal(t, first, sess.ID)
+	_, err = a.nonInteractiveSession(ctx, NonInteractiveOptions{Prompt: "Go on", SessionID: "missing"})
+	assert.ErrorContains(t, err, "failed to get session missing")
+
+	sess, err = a.nonInteractiveSession(ctx, NonInteractiveOptions{Prompt: "Go on", Title: "Nightly review"})
+	require.NoError(t, err)
+	assert.Equal(t, "Nightly review", sess.Title)
+	assert.NotContains(t, []string{first, "updated", "created"}, sess.ID)
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/permission"
+)
+
+// Exit codes of non-interactive runs, so scripts can tell failures apart.
+// ExitFailure is for errors before the run starts, such as invalid flags or
+// configuration. Non-interactive runs grant every tool permission, so
+// ExitPermissionDenied only happens when a guardrail or content filter
+// stops the response.
+const (
+	ExitSuccess          = 0
+	ExitFailure          = 1
+	ExitProviderError    = 2
+	ExitPermissionDenied = 3
+	ExitTimeout          = 4
+	ExitBudgetExceeded   = 5
+	ExitAgentError       = 6
+	ExitCancelled        = 130
+)
+
+// ExitError is returned by RunNonInteractive when a run fails. Code is the
+// exit code the process should terminate with.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode classifies the error that ended a run. ctx is the context of the
+// run, which tells a timeout apart from other failures.
+func exitCode(ctx context.Context, err error) int {
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(err, context.Canceled), errors.Is(err, agent.ErrRequestCancelled):
+		return ExitCancelled
+	case errors.Is(err, agent.ErrMaxTurnsExceeded):
+		return ExitBudgetExceeded
+	case errors.Is(err, permission.ErrorPermissionDenied):
+		return ExitPermissionDenied
+	case errors.Is(err, agent.ErrProviderRequest):
+		return ExitProviderError
+	default:
+		return ExitAgentError
+	}
+}
+
+// exitStatus is the status reported in the result event of stream-json
+// output for an exit code.
+func exitStatus(code int) string {
+	switch code {
+	case ExitSuccess:
+		return "success"
+	case ExitCancelled:
+		return "cancelled"
+	case ExitTimeout:
+		return "timeout"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// maxSchemaRetries is how many times the agent is asked to correct a
+// response that doesn't match the output schema.
+const maxSchemaRetries = 2
+
+// outputSchema is a JSON Schema the final response of a non-interactive run
+// must conform to.
+type outputSchema struct {
+	raw      []byte
+	resolved *jsonschema.Resolved
+}
+
+func loadOutputSchema(path string) (*outputSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output schema: %w", err)
+	}
+	var schema jsonschema.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid output schema %s: %w", path, err)
+	}
+	resolved, err := schema.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output schema %s: %w", path, err)
+	}
+	return &outputSchema{raw: data, resolved: resolved}, nil
+}
+
+// instructions appends the schema to the prompt so the model knows what to
+// respond with.
+func (s *outputSchema) instructions(prompt string) string {
+	return fmt.Sprintf("%s\n\nWhen you are done, respond with only a JSON document that matches the following JSON Schema, without any other text:\n\n%s",
+		prompt, strings.TrimSpace(string(s.raw)))
+}
+
+// retryPrompt asks the model to fix a response that failed validation.
+func (s *outputSchema) retryPrompt(err error) string {
+	return fmt.Sprintf("Your response does not match the required JSON Schema: %v\n\nRespond again with only the corrected JSON document.", err)
+}
+
+// validate extracts the JSON document from response and checks it against
+// the schema. The document is returned compacted.
+func (s *outputSchema) validate(response string) (json.RawMessage, error) {
+	doc := []byte(extractJSON(response))
+	var instance any
+	if err := json.Unmarshal(doc, &instance); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if err := s.resolved.Validate(instance); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// extractJSON strips the markdown code fence models often wrap JSON in,
+// even when asked not to.
+func extractJSON(response string) string {
+	response = strings.TrimSpace(response)
+	if !strings.HasPrefix(response, "```") {
+		return response
+	}
+	start := strings.IndexByte(response, '\n')
+	end := strings.LastIndex(response, "```")
+	if start < 0 || end <= start {
+		return response
+	}
+	return strings.TrimSpace(response[start+1 : end])
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputSchemaValidate(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"type": "object",
+		"properties": {"count": {"type": "integer"}},
+		"required": ["count"]
+	}`), 0o644))
+
+	schema, err := loadOutputSchema(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{name: "plain", response: `{"count": 3}`, want: `{"count":3}`},
+		{name: "fenced", response: "```json\n{\"count\": 3}\n```", want: `{"count":3}`},
+		{name: "missing property", response: `{"total": 3}`, wantErr: true},
+		{name: "wrong type", response: `{"count": "three"}`, wantErr: true},
+		{name: "not json", response: "There are three.", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := schema.validate(tt.response)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
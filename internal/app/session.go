@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// ChildSessions indexes sessions by the ID of their parent.
+func ChildSessions(all []session.Session) map[string][]string {
+	children := make(map[string][]string)
+	for _, sess := range all {
+		if sess.ParentSessionID != "" {
+			children[sess.ParentSessionID] = append(children[sess.ParentSessionID], sess.ID)
+		}
+	}
+	return children
+}
+
+// DeleteSessionTree removes a session, its messages and file history, and
+// recursively every child session spawned from it. children is the index
+// built by ChildSessions.
+func DeleteSessionTree(ctx context.Context, sessions session.Service, messages message.Service, files history.Service, children map[string][]string, id string) error {
+	for _, child := range children[id] {
+		if err := DeleteSessionTree(ctx, sessions, messages, files, children, child); err != nil {
+			return err
+		}
+	}
+	if err := messages.DeleteSessionMessages(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete messages of session %s: %w", id, err)
+	}
+	if err := files.DeleteSessionFiles(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete files of session %s: %w", id, err)
+	}
+	if err := sessions.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+	return nil
+}
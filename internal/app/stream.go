@@ -0,0 +1,287 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// Event types written by the stream-json output format.
+const (
+	StreamEventInit          = "init"
+	StreamEventTextDelta     = "text_delta"
+	StreamEventThinkingDelta = "thinking_delta"
+	StreamEventToolCallStart = "tool_call_start"
+	StreamEventToolCallInput = "tool_call_input"
+	StreamEventToolResult    = "tool_result"
+	StreamEventPermission    = "permission"
+	StreamEventUsage         = "usage"
+	StreamEventResult        = "result"
+)
+
+type streamInitEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	Model     string `json:"model"`
+}
+
+type streamDeltaEvent struct {
+	Type      string `json:"type"`
+	MessageID string `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+type streamToolCallEvent struct {
+	Type       string `json:"type"`
+	MessageID  string `json:"message_id"`
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Input      string `json:"input,omitempty"`
+}
+
+type streamToolResultEvent struct {
+	Type       string `json:"type"`
+	MessageID  string `json:"message_id"`
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	Metadata   string `json:"metadata,omitempty"`
+	IsError    bool   `json:"is_error"`
+}
+
+type streamPermissionEvent struct {
+	Type        string `json:"type"`
+	ToolName    string `json:"tool_name"`
+	Action      string `json:"action"`
+	Description string `json:"description"`
+	Path        string `json:"path"`
+	Granted     bool   `json:"granted"`
+}
+
+type streamUsageEvent struct {
+	Type             string  `json:"type"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+type streamResultEvent struct {
+	Type             string          `json:"type"`
+	SessionID        string          `json:"session_id"`
+	Status           string          `json:"status"`
+	ExitCode         int             `json:"exit_code"`
+	Response         string          `json:"response"`
+	StructuredOutput json.RawMessage `json:"structured_output,omitempty"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// messageProgress tracks what has already been streamed for a message so
+// deltas can be derived from the full message published on every update.
+type messageProgress struct {
+	text     int
+	thinking int
+	started  map[string]bool
+	inputs   map[string]bool
+	results  map[string]bool
+}
+
+// eventStreamer turns the session, message and permission brokers into
+// newline-delimited JSON events for a single session. Events of one broker
+// keep their order, events of different brokers may interleave differently
+// than they were published.
+type eventStreamer struct {
+	sessionID string
+	enc       *json.Encoder
+	messages  message.Service
+
+	progress  map[string]*messageProgress
+	previous  map[string]bool
+	toolNames map[string]string
+	lastUsage streamUsageEvent
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newEventStreamer(w io.Writer, messages message.Service) *eventStreamer {
+	return &eventStreamer{
+		enc:       json.NewEncoder(w),
+		messages:  messages,
+		progress:  make(map[string]*messageProgress),
+		previous:  make(map[string]bool),
+		toolNames: make(map[string]string),
+	}
+}
+
+// Start subscribes to the brokers and streams events for the session until
+// Stop is called.
+func (s *eventStreamer) Start(ctx context.Context, a *App, sess session.Session) {
+	s.sessionID = sess.ID
+
+	// Messages from earlier runs of a continued session are not streamed.
+	msgs, err := s.messages.List(ctx, sess.ID)
+	if err != nil {
+		logging.Warn("Failed to list messages for stream-json output", "error", err)
+	}
+	for _, msg := range msgs {
+		s.previous[msg.ID] = true
+	}
+
+	s.write(streamInitEvent{
+		Type:      StreamEventInit,
+		SessionID: sess.ID,
+		Model:     string(a.CoderAgent.Model().ID),
+	})
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	sessionCh := a.Sessions.Subscribe(ctx)
+	messageCh := a.Messages.Subscribe(ctx)
+	decisionCh := a.Permissions.SubscribeDecisions(ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer logging.RecoverPanic("stream-json", nil)
+		for {
+			select {
+			case event, ok := <-messageCh:
+				if !ok {
+					return
+				}
+				s.handleMessage(event.Payload)
+			case event, ok := <-sessionCh:
+				if !ok {
+					return
+				}
+				s.handleSession(event.Payload)
+			case event, ok := <-decisionCh:
+				if !ok {
+					return
+				}
+				s.handleDecision(event.Payload)
+			}
+		}
+	}()
+}
+
+// Stop ends the subscriptions, streams anything that was dropped by the
+// brokers and writes the final result event.
+func (s *eventStreamer) Stop(result streamResultEvent) {
+	s.cancel()
+	s.wg.Wait()
+
+	// Brokers drop events for slow subscribers, so catch up from the stored
+	// messages before reporting the result.
+	msgs, err := s.messages.List(context.Background(), s.sessionID)
+	if err != nil {
+		logging.Warn("Failed to list messages for stream-json output", "error", err)
+	}
+	for _, msg := range msgs {
+		s.handleMessage(msg)
+	}
+
+	result.Type = StreamEventResult
+	result.SessionID = s.sessionID
+	s.write(result)
+}
+
+func (s *eventStreamer) handleMessage(msg message.Message) {
+	if msg.SessionID != s.sessionID || msg.Hidden || s.previous[msg.ID] {
+		return
+	}
+	p, ok := s.progress[msg.ID]
+	if !ok {
+		p = &messageProgress{
+			started: make(map[string]bool),
+			inputs:  make(map[string]bool),
+			results: make(map[string]bool),
+		}
+		s.progress[msg.ID] = p
+	}
+
+	switch msg.Role {
+	case message.Assistant:
+		if thinking := msg.ReasoningContent().Thinking; len(thinking) > p.thinking {
+			s.write(streamDeltaEvent{Type: StreamEventThinkingDelta, MessageID: msg.ID, Text: thinking[p.thinking:]})
+			p.thinking = len(thinking)
+		}
+		if text := msg.Content().Text; len(text) > p.text {
+			s.write(streamDeltaEvent{Type: StreamEventTextDelta, MessageID: msg.ID, Text: text[p.text:]})
+			p.text = len(text)
+		}
+		for _, tc := range msg.ToolCalls() {
+			s.toolNames[tc.ID] = tc.Name
+			if !p.started[tc.ID] {
+				p.started[tc.ID] = true
+				s.write(streamToolCallEvent{Type: StreamEventToolCallStart, MessageID: msg.ID, ToolCallID: tc.ID, Name: tc.Name})
+			}
+			if !p.inputs[tc.ID] && (tc.Finished || msg.IsFinished()) {
+				p.inputs[tc.ID] = true
+				s.write(streamToolCallEvent{Type: StreamEventToolCallInput, MessageID: msg.ID, ToolCallID: tc.ID, Name: tc.Name, Input: tc.Input})
+			}
+		}
+	case message.Tool:
+		for _, tr := range msg.ToolResults() {
+			if p.results[tr.ToolCallID] {
+				continue
+			}
+			p.results[tr.ToolCallID] = true
+			name := tr.Name
+			if name == "" {
+				name = s.toolNames[tr.ToolCallID]
+			}
+			s.write(streamToolResultEvent{
+				Type:       StreamEventToolResult,
+				MessageID:  msg.ID,
+				ToolCallID: tr.ToolCallID,
+				Name:       name,
+				Content:    tr.Text(),
+				Metadata:   tr.Metadata,
+				IsError:    tr.IsError,
+			})
+		}
+	}
+}
+
+func (s *eventStreamer) handleSession(sess session.Session) {
+	if sess.ID != s.sessionID {
+		return
+	}
+	usage := streamUsageEvent{
+		Type:             StreamEventUsage,
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+	}
+	if usage == s.lastUsage {
+		return
+	}
+	s.lastUsage = usage
+	s.write(usage)
+}
+
+func (s *eventStreamer) handleDecision(decision permission.PermissionDecision) {
+	if decision.Request.SessionID != s.sessionID {
+		return
+	}
+	s.write(streamPermissionEvent{
+		Type:        StreamEventPermission,
+		ToolName:    decision.Request.ToolName,
+		Action:      decision.Request.Action,
+		Description: decision.Request.Description,
+		Path:        decision.Request.Path,
+		Granted:     decision.Granted,
+	})
+}
+
+func (s *eventStreamer) write(event any) {
+	if err := s.enc.Encode(event); err != nil {
+		logging.Error("Failed to write stream-json event", "error", err)
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// permittedEchoTool asks for permission, then returns its input.
+type permittedEchoTool struct {
+	permissions permission.Service
+}
+
+func (permittedEchoTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name:       "echo",
+		Parameters: map[string]any{"text": map[string]any{"type": "string"}},
+		Required:   []string{"text"},
+	}
+}
+
+func (e permittedEchoTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	var params struct{ Text string }
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return tools.NewTextErrorResponse(err.Error()), nil
+	}
+	sessionID, _ := tools.GetContextValues(ctx)
+	if !e.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		ToolName:    "echo",
+		Action:      "execute",
+		Description: "Echo " + params.Text,
+		Path:        "/",
+	}) {
+		return tools.ToolResponse{}, permission.ErrorPermissionDenied
+	}
+	return tools.NewTextResponse(params.Text), nil
+}
+
+var uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// normalizeIDs replaces the IDs of a stream with ones numbered in order of
+// appearance.
+func normalizeIDs(stream []byte) []byte {
+	ids := map[string]string{}
+	return uuidPattern.ReplaceAllFunc(stream, func(id []byte) []byte {
+		if _, ok := ids[string(id)]; !ok {
+			ids[string(id)] = fmt.Sprintf("id-%d", len(ids)+1)
+		}
+		return []byte(ids[string(id)])
+	})
+}
+
+// TestEventStreamer compares the stream-json events of a run replayed from a
+// cassette with testdata/stream.golden. Run with -update to rewrite it.
+func TestEventStreamer(t *testing.T) {
+	t.Setenv(provider.CassetteEnv, "testdata/stream.yaml")
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	cfg.Providers[models.ProviderMock] = config.Provider{APIKey: "cassette"}
+	for _, name := range []config.AgentName{config.AgentCoder, config.AgentTitle, config.AgentSummarizer} {
+		cfg.Agents[name] = config.Agent{Model: models.MockModel, MaxTokens: 1000}
+	}
+
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := agent.WithoutTitleGeneration(context.Background())
+	a := NewWithoutAgent(ctx, conn)
+	a.CoderAgent, err = agent.NewAgent(config.AgentCoder, a.Sessions, a.Messages, []tools.BaseTool{permittedEchoTool{a.Permissions}}, nil)
+	require.NoError(t, err)
+
+	sess, err := a.Sessions.Create(ctx, "Stream")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	streamer := newEventStreamer(&out, a.Messages)
+	streamer.Start(ctx, a, sess)
+	response, _, err := a.runPrompt(ctx, sess.ID, "Echo hello", nil, nil)
+	require.NoError(t, err)
+	streamer.Stop(streamResultEvent{Status: exitStatus(ExitSuccess), Response: response.Content().String()})
+
+	got := normalizeIDs(out.Bytes())
+	if *update {
+		require.NoError(t, os.WriteFile("testdata/stream.golden", got, 0o644))
+	}
+	want, err := os.ReadFile("testdata/stream.golden")
+	require.NoError(t, err)
+
+	// Events of different brokers may interleave either way
+	wantLines := strings.Split(strings.TrimSpace(string(want)), "\n")
+	gotLines := strings.Split(strings.TrimSpace(string(got)), "\n")
+	require.Len(t, gotLines, len(wantLines))
+	assert.Equal(t, wantLines[0], gotLines[0])
+	assert.Equal(t, wantLines[len(wantLines)-1], gotLines[len(gotLines)-1])
+	for _, source := range []string{"message", StreamEventUsage, StreamEventPermission} {
+		assert.Equal(t, eventsOf(t, wantLines, source), eventsOf(t, gotLines, source), source)
+	}
+}
+
+// eventsOf returns the lines of a stream published by one broker: usage
+// events, permission events or the others, about messages.
+func eventsOf(t *testing.T, lines []string, source string) []string {
+	var events []string
+	for _, line := range lines {
+		var event struct{ Type string }
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		eventSource := event.Type
+		if eventSource != StreamEventUsage && eventSource != StreamEventPermission {
+			eventSource = "message"
+		}
+		if eventSource == source {
+			events = append(events, line)
+		}
+	}
+	return events
+}
@@ -0,0 +1,292 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// Version is the current version of the JSON archive format.
+const Version = 1
+
+// Format represents the file format of an exported session
+type Format string
+
+const (
+	// Markdown renders the session as a human-readable transcript.
+	Markdown Format = "md"
+
+	// JSON serializes the session so it can be imported again.
+	JSON Format = "json"
+)
+
+// SupportedFormats is a list of all supported export formats as strings
+var SupportedFormats = []string{
+	string(Markdown),
+	string(JSON),
+}
+
+// ParseFormat converts a string to a Format
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "md", "markdown":
+		return Markdown, nil
+	case "json":
+		return JSON, nil
+	default:
+		return "", fmt.Errorf("invalid export format: %s", s)
+	}
+}
+
+// Archive is a self-contained snapshot of a session, its messages and the
+// file history recorded while it was active.
+type Archive struct {
+	Version    int       `json:"version"`
+	ExportedAt int64     `json:"exported_at"`
+	Session    Session   `json:"session"`
+	Messages   []Message `json:"messages"`
+	Files      []File    `json:"files"`
+}
+
+type Session struct {
+	ID               string  `json:"id"`
+	ParentSessionID  string  `json:"parent_session_id,omitempty"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	SummaryMessageID string  `json:"summary_message_id,omitempty"`
+	Cost             float64 `json:"cost"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+}
+
+// Message holds the parts of a message exactly as they are persisted in the
+// database, so every content part type survives an export/import cycle.
+type Message struct {
+	ID         string          `json:"id"`
+	Role       string          `json:"role"`
+	Model      string          `json:"model,omitempty"`
+	Hidden     bool            `json:"hidden,omitempty"`
+	Parts      json.RawMessage `json:"parts"`
+	CreatedAt  int64           `json:"created_at"`
+	UpdatedAt  int64           `json:"updated_at"`
+	FinishedAt int64           `json:"finished_at,omitempty"`
+}
+
+type File struct {
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Version   string `json:"version"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Export collects everything stored for the given session into an Archive.
+func Export(
+	ctx context.Context,
+	sessions session.Service,
+	messages message.Service,
+	files history.Service,
+	sessionID string,
+) (*Archive, error) {
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	msgs, err := messages.List(ctx, sess.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	versions, err := files.ListBySession(ctx, sess.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	a := &Archive{
+		Version:    Version,
+		ExportedAt: time.Now().Unix(),
+		Session: Session{
+			ID:               sess.ID,
+			ParentSessionID:  sess.ParentSessionID,
+			Title:            sess.Title,
+			MessageCount:     sess.MessageCount,
+			PromptTokens:     sess.PromptTokens,
+			CompletionTokens: sess.CompletionTokens,
+			SummaryMessageID: sess.SummaryMessageID,
+			Cost:             sess.Cost,
+			CreatedAt:        sess.CreatedAt,
+			UpdatedAt:        sess.UpdatedAt,
+		},
+		Messages: make([]Message, 0, len(msgs)),
+		Files:    make([]File, 0, len(versions)),
+	}
+
+	for _, msg := range msgs {
+		parts, err := message.MarshalParts(msg.Parts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message %s: %w", msg.ID, err)
+		}
+		var finishedAt int64
+		if f := msg.FinishPart(); f != nil {
+			finishedAt = f.Time
+		}
+		a.Messages = append(a.Messages, Message{
+			ID:         msg.ID,
+			Role:       string(msg.Role),
+			Model:      string(msg.Model),
+			Hidden:     msg.Hidden,
+			Parts:      parts,
+			CreatedAt:  msg.CreatedAt,
+			UpdatedAt:  msg.UpdatedAt,
+			FinishedAt: finishedAt,
+		})
+	}
+
+	for _, f := range versions {
+		a.Files = append(a.Files, File{
+			ID:        f.ID,
+			Path:      f.Path,
+			Content:   f.Content,
+			Version:   f.Version,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		})
+	}
+
+	return a, nil
+}
+
+// Write encodes the archive to w in the given format.
+func (a *Archive) Write(w io.Writer, format Format) error {
+	switch format {
+	case JSON:
+		return a.WriteJSON(w)
+	case Markdown:
+		return a.WriteMarkdown(w)
+	default:
+		return fmt.Errorf("invalid export format: %s", format)
+	}
+}
+
+// WriteJSON encodes the archive as indented JSON.
+func (a *Archive) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// Read decodes a JSON archive and validates every message it contains.
+func Read(r io.Reader) (*Archive, error) {
+	var a Archive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, fmt.Errorf("failed to decode archive: %w", err)
+	}
+	if a.Version < 1 || a.Version > Version {
+		return nil, fmt.Errorf("unsupported archive version: %d", a.Version)
+	}
+	if a.Session.ID == "" {
+		return nil, fmt.Errorf("archive does not contain a session")
+	}
+	for _, msg := range a.Messages {
+		if _, err := message.UnmarshalParts(msg.Parts); err != nil {
+			return nil, fmt.Errorf("invalid parts in message %s: %w", msg.ID, err)
+		}
+	}
+	return &a, nil
+}
+
+// Import stores the archive as a new top-level session and returns its ID.
+// Sessions, messages and files get fresh IDs so an archive can be imported
+// into the database it was exported from without conflicts.
+func Import(ctx context.Context, conn *sql.DB, a *Archive) (string, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := db.New(tx)
+	sessionID := uuid.New().String()
+	if _, err := q.ImportSession(ctx, db.ImportSessionParams{
+		ID:               sessionID,
+		Title:            a.Session.Title,
+		PromptTokens:     a.Session.PromptTokens,
+		CompletionTokens: a.Session.CompletionTokens,
+		Cost:             a.Session.Cost,
+		UpdatedAt:        a.Session.UpdatedAt,
+		CreatedAt:        a.Session.CreatedAt,
+	}); err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	messageIDs := make(map[string]string, len(a.Messages))
+	for _, msg := range a.Messages {
+		parts, err := message.UnmarshalParts(msg.Parts)
+		if err != nil {
+			return "", fmt.Errorf("invalid parts in message %s: %w", msg.ID, err)
+		}
+		partsJSON, err := message.MarshalParts(parts)
+		if err != nil {
+			return "", err
+		}
+		id := uuid.New().String()
+		messageIDs[msg.ID] = id
+		if err := q.ImportMessage(ctx, db.ImportMessageParams{
+			ID:         id,
+			SessionID:  sessionID,
+			Role:       msg.Role,
+			Parts:      string(partsJSON),
+			Model:      sql.NullString{String: msg.Model, Valid: msg.Model != ""},
+			Hidden:     msg.Hidden,
+			CreatedAt:  msg.CreatedAt,
+			UpdatedAt:  msg.UpdatedAt,
+			FinishedAt: sql.NullInt64{Int64: msg.FinishedAt, Valid: msg.FinishedAt != 0},
+		}); err != nil {
+			return "", fmt.Errorf("failed to import message %s: %w", msg.ID, err)
+		}
+	}
+
+	for _, f := range a.Files {
+		if err := q.ImportFile(ctx, db.ImportFileParams{
+			ID:        uuid.New().String(),
+			SessionID: sessionID,
+			Path:      f.Path,
+			Content:   f.Content,
+			Version:   f.Version,
+			CreatedAt: f.CreatedAt,
+			UpdatedAt: f.UpdatedAt,
+		}); err != nil {
+			return "", fmt.Errorf("failed to import file %s: %w", f.Path, err)
+		}
+	}
+
+	if summaryID, ok := messageIDs[a.Session.SummaryMessageID]; ok {
+		if _, err := q.UpdateSession(ctx, db.UpdateSessionParams{
+			ID:               sessionID,
+			Title:            a.Session.Title,
+			PromptTokens:     a.Session.PromptTokens,
+			CompletionTokens: a.Session.CompletionTokens,
+			SummaryMessageID: sql.NullString{String: summaryID, Valid: true},
+			Cost:             a.Session.Cost,
+		}); err != nil {
+			return "", fmt.Errorf("failed to restore summary message: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return sessionID, nil
+}
@@ -0,0 +1,150 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	parts := []message.ContentPart{
+		message.ReasoningContent{Thinking: "let me look"},
+		message.TextContent{Text: "Here is the file"},
+		message.ImageURLContent{URL: "https://example.com/a.png", Detail: "low"},
+		message.BinaryContent{Path: "a.png", MIMEType: "image/png", Data: []byte{0x89, 0x50}},
+		message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`, Type: "function", Finished: true},
+		message.ToolResult{ToolCallID: "call_1", Name: "view", Content: "package main", Metadata: "{}"},
+		message.Finish{Reason: message.FinishReasonEndTurn, Time: 1700000000},
+	}
+	raw, err := message.MarshalParts(parts)
+	require.NoError(t, err)
+
+	a := &Archive{
+		Version:  Version,
+		Session:  Session{ID: "s1", Title: "Test", Cost: 0.5},
+		Messages: []Message{{ID: "m1", Role: string(message.Assistant), Parts: raw, FinishedAt: 1700000000}},
+		Files:    []File{{ID: "f1", Path: "main.go", Content: "package main", Version: "initial"}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, a.WriteJSON(&buf))
+
+	decoded, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, a.Session, decoded.Session)
+	assert.Equal(t, a.Files, decoded.Files)
+	require.Len(t, decoded.Messages, 1)
+
+	got, err := message.UnmarshalParts(decoded.Messages[0].Parts)
+	require.NoError(t, err)
+	assert.Equal(t, parts, got)
+}
+
+// TestExportImport imports an exported session into the database it came
+// from and checks the copy holds the same data under new IDs.
+func TestExportImport(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := context.Background()
+	q := db.New(conn)
+	sessions := session.NewService(q)
+	messages := message.NewService(q)
+	files := history.NewService(q, conn)
+
+	sess, err := sessions.Create(ctx, "Original")
+	require.NoError(t, err)
+	_, err = messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Fix the build"}},
+	})
+	require.NoError(t, err)
+	summary, err := messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "The build is fixed"},
+			message.Finish{Reason: message.FinishReasonEndTurn, Time: 1700000000},
+		},
+		Hidden: true,
+	})
+	require.NoError(t, err)
+	_, err = files.Create(ctx, sess.ID, "main.go", "package main")
+	require.NoError(t, err)
+
+	sess, err = sessions.Get(ctx, sess.ID)
+	require.NoError(t, err)
+	sess.PromptTokens = 100
+	sess.CompletionTokens = 20
+	sess.Cost = 0.25
+	sess.SummaryMessageID = summary.ID
+	_, err = sessions.Save(ctx, sess)
+	require.NoError(t, err)
+
+	exported, err := Export(ctx, sessions, messages, files, sess.ID)
+	require.NoError(t, err)
+	id, err := Import(ctx, conn, exported)
+	require.NoError(t, err)
+	assert.NotEqual(t, sess.ID, id)
+
+	imported, err := Export(ctx, sessions, messages, files, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Original", imported.Session.Title)
+	assert.Equal(t, int64(2), imported.Session.MessageCount)
+	assert.Equal(t, int64(100), imported.Session.PromptTokens)
+	assert.Equal(t, int64(20), imported.Session.CompletionTokens)
+	assert.Equal(t, 0.25, imported.Session.Cost)
+	assert.Equal(t, exported.Session.CreatedAt, imported.Session.CreatedAt)
+
+	require.Len(t, imported.Messages, 2)
+	for i, msg := range imported.Messages {
+		want := exported.Messages[i]
+		assert.NotEqual(t, want.ID, msg.ID)
+		assert.Equal(t, want.Role, msg.Role)
+		assert.Equal(t, want.Hidden, msg.Hidden)
+		assert.JSONEq(t, string(want.Parts), string(msg.Parts))
+		assert.Equal(t, want.CreatedAt, msg.CreatedAt)
+		assert.Equal(t, want.FinishedAt, msg.FinishedAt)
+	}
+	assert.Equal(t, imported.Messages[1].ID, imported.Session.SummaryMessageID)
+
+	require.Len(t, imported.Files, 1)
+	assert.NotEqual(t, exported.Files[0].ID, imported.Files[0].ID)
+	assert.Equal(t, "main.go", imported.Files[0].Path)
+	assert.Equal(t, "package main", imported.Files[0].Content)
+	assert.Equal(t, exported.Files[0].Version, imported.Files[0].Version)
+
+	// The original session is left alone
+	original, err := sessions.Get(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), original.MessageCount)
+}
+
+func TestReadRejectsUnknownVersion(t *testing.T) {
+	t.Parallel()
+
+	_, err := Read(strings.NewReader(`{"version": 99, "session": {"id": "s1"}}`))
+	assert.Error(t, err)
+}
+
+func TestCodeFence(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "```", codeFence("plain"))
+	assert.Equal(t, "````", codeFence("has ``` inside"))
+}
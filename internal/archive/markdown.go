@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// WriteMarkdown renders the archive as a readable transcript. Reasoning,
+// tool calls and tool results are wrapped in <details> blocks so they stay
+// collapsed when the file is viewed on GitHub or in an issue tracker.
+func (a *Archive) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+
+	title := a.Session.Title
+	if title == "" {
+		title = "Untitled session"
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "- **Session:** `%s`\n", a.Session.ID)
+	fmt.Fprintf(&sb, "- **Created:** %s\n", formatTime(a.Session.CreatedAt))
+	fmt.Fprintf(&sb, "- **Messages:** %d\n", len(a.Messages))
+	fmt.Fprintf(&sb, "- **Tokens:** %d prompt, %d completion\n", a.Session.PromptTokens, a.Session.CompletionTokens)
+	fmt.Fprintf(&sb, "- **Cost:** $%.4f\n\n", a.Session.Cost)
+
+	for _, msg := range a.Messages {
+		if msg.Hidden {
+			continue
+		}
+		parts, err := message.UnmarshalParts(msg.Parts)
+		if err != nil {
+			return fmt.Errorf("invalid parts in message %s: %w", msg.ID, err)
+		}
+		sb.WriteString("---\n\n")
+		writeMessage(&sb, msg, parts)
+	}
+
+	if len(a.Files) > 0 {
+		sb.WriteString("---\n\n## Files\n\n")
+		for _, f := range a.Files {
+			fmt.Fprintf(&sb, "- `%s` (%s, %s)\n", f.Path, f.Version, formatTime(f.CreatedAt))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeMessage(sb *strings.Builder, msg Message, parts []message.ContentPart) {
+	switch message.MessageRole(msg.Role) {
+	case message.User:
+		sb.WriteString("## User\n\n")
+	case message.Assistant:
+		if msg.Model != "" {
+			fmt.Fprintf(sb, "## Assistant (%s)\n\n", msg.Model)
+		} else {
+			sb.WriteString("## Assistant\n\n")
+		}
+	case message.Tool:
+		sb.WriteString("## Tool results\n\n")
+	default:
+		fmt.Fprintf(sb, "## %s\n\n", msg.Role)
+	}
+
+	for _, part := range parts {
+		switch p := part.(type) {
+		case message.ReasoningContent:
+			if p.Thinking != "" {
+				writeDetails(sb, "Reasoning", p.Thinking, "")
+			}
+		case message.TextContent:
+			if p.Text != "" {
+				sb.WriteString(p.Text)
+				sb.WriteString("\n\n")
+			}
+		case message.ImageURLContent:
+			fmt.Fprintf(sb, "![image](%s)\n\n", p.URL)
+		case message.BinaryContent:
+			writeAttachment(sb, p)
+		case message.ToolCall:
+			writeDetails(sb, fmt.Sprintf("Tool call: %s", p.Name), p.Input, "json")
+		case message.ToolResult:
+			summary := fmt.Sprintf("Result: %s", p.Name)
+			if p.IsError {
+				summary += " (error)"
+			}
+			writeDetails(sb, summary, p.Text(), "")
+			for _, image := range p.Images() {
+				writeAttachment(sb, image)
+			}
+		case message.Fallback:
+			fmt.Fprintf(sb, "_Fallback from %s (%s)_\n\n", p.From, strings.ReplaceAll(p.Reason, "_", " "))
+		case message.Finish:
+			switch p.Reason {
+			case message.FinishReasonCanceled, message.FinishReasonError, message.FinishReasonPermissionDenied:
+				fmt.Fprintf(sb, "_Finished: %s_\n\n", p.Reason)
+			}
+		}
+	}
+}
+
+func writeAttachment(sb *strings.Builder, attachment message.BinaryContent) {
+	name := attachment.Path
+	if name == "" {
+		name = "attachment"
+	}
+	fmt.Fprintf(sb, "_Attachment: %s (%s, %d bytes)_\n\n", name, attachment.MIMEType, len(attachment.Data))
+}
+
+func writeDetails(sb *strings.Builder, summary, body, lang string) {
+	fence := codeFence(body)
+	fmt.Fprintf(sb, "<details>\n<summary>%s</summary>\n\n", summary)
+	fmt.Fprintf(sb, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(body, "\n"), fence)
+	sb.WriteString("</details>\n\n")
+}
+
+// codeFence returns a backtick fence longer than any backtick run in s so the
+// content can't terminate the block early.
+func codeFence(s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+func formatTime(unix int64) string {
+	if unix == 0 {
+		return "unknown"
+	}
+	return time.Unix(unix, 0).Format(time.RFC3339)
+}
@@ -0,0 +1,138 @@
+package completions
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/tui/components/dialog"
+)
+
+const readResourceTimeout = 30 * time.Second
+
+type mcpResourcesContextGroup struct {
+	prefix  string
+	manager *mcpclient.Manager
+}
+
+func (cg *mcpResourcesContextGroup) GetId() string {
+	return cg.prefix
+}
+
+func (cg *mcpResourcesContextGroup) GetEntry() dialog.CompletionItemI {
+	return dialog.NewCompletionItem(dialog.CompletionItem{
+		Title: "MCP Resources",
+		Value: "mcp",
+	})
+}
+
+func (cg *mcpResourcesContextGroup) GetChildEntries(query string) ([]dialog.CompletionItemI, error) {
+	var items []dialog.CompletionItemI
+	for _, resource := range cg.manager.Resources() {
+		title := resourceTitle(resource)
+		if query != "" && !fuzzy.MatchFold(query, title) && !fuzzy.MatchFold(query, resource.Resource.URI) {
+			continue
+		}
+		items = append(items, &mcpResourceItem{
+			CompletionItemI: dialog.NewCompletionItem(dialog.CompletionItem{
+				Title: title,
+				Value: title,
+			}),
+			manager:  cg.manager,
+			resource: resource,
+		})
+	}
+	return items, nil
+}
+
+func resourceTitle(resource mcpclient.Resource) string {
+	name := resource.Resource.Name
+	if name == "" {
+		name = resource.Resource.URI
+	}
+	return fmt.Sprintf("%s:%s", resource.Server, name)
+}
+
+// mcpResourceItem attaches the contents of an MCP resource to the message
+// when selected.
+type mcpResourceItem struct {
+	dialog.CompletionItemI
+	manager  *mcpclient.Manager
+	resource mcpclient.Resource
+}
+
+func (i *mcpResourceItem) LoadAttachment() (message.Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readResourceTimeout)
+	defer cancel()
+
+	uri := i.resource.Resource.URI
+	result, err := i.manager.ReadResource(ctx, i.resource.Server, uri)
+	if err != nil {
+		return message.Attachment{}, fmt.Errorf("failed to read %s: %w", uri, err)
+	}
+	attachment, err := resourceAttachment(uri, i.resource.Resource.MIMEType, result.Contents)
+	if err != nil {
+		return message.Attachment{}, fmt.Errorf("failed to attach %s: %w", uri, err)
+	}
+	return attachment, nil
+}
+
+// resourceAttachment turns the contents of a resource into an attachment.
+// Text contents are joined, binary contents are only attached when they are
+// a single image.
+func resourceAttachment(uri, mimeType string, contents []mcp.ResourceContents) (message.Attachment, error) {
+	attachment := message.Attachment{
+		FilePath: uri,
+		FileName: path.Base(uri),
+		MimeType: mimeType,
+	}
+
+	var texts []string
+	for _, content := range contents {
+		switch content := content.(type) {
+		case mcp.TextResourceContents:
+			texts = append(texts, content.Text)
+			if attachment.MimeType == "" || !message.IsTextMIMEType(attachment.MimeType) {
+				attachment.MimeType = content.MIMEType
+			}
+		case mcp.BlobResourceContents:
+			if len(contents) > 1 || !strings.HasPrefix(content.MIMEType, "image/") {
+				return message.Attachment{}, fmt.Errorf("unsupported content type %q", content.MIMEType)
+			}
+			data, err := base64.StdEncoding.DecodeString(content.Blob)
+			if err != nil {
+				return message.Attachment{}, fmt.Errorf("invalid blob: %w", err)
+			}
+			attachment.MimeType = content.MIMEType
+			attachment.Content = data
+		}
+	}
+	if len(texts) > 0 {
+		if !message.IsTextMIMEType(attachment.MimeType) {
+			attachment.MimeType = "text/plain"
+		}
+		attachment.Content = []byte(strings.Join(texts, "\n\n"))
+	}
+	if len(attachment.Content) == 0 {
+		return message.Attachment{}, errors.New("resource is empty")
+	}
+	if int64(len(attachment.Content)) > message.MaxAttachmentSize {
+		return message.Attachment{}, fmt.Errorf("resource too large (%d bytes), max %d bytes", len(attachment.Content), message.MaxAttachmentSize)
+	}
+	return attachment, nil
+}
+
+func NewMCPResourcesContextGroup(manager *mcpclient.Manager) dialog.CompletionProvider {
+	return &mcpResourcesContextGroup{
+		prefix:  "mcp",
+		manager: manager,
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+const (
+	// catalogRefreshInterval is how old the catalog at catalog.url gets
+	// before it is downloaded again.
+	catalogRefreshInterval = 24 * time.Hour
+	// catalogRefreshTimeout bounds downloading the catalog.
+	catalogRefreshTimeout = 30 * time.Second
+)
+
+// CatalogCachePath returns where the catalog at catalog.url is kept.
+func CatalogCachePath() string {
+	return filepath.Join(cfg.Data.Directory, "models.json")
+}
+
+// UserCatalogPath returns the catalog file of the user, catalog.file or
+// models.json in the config directory.
+func UserCatalogPath() string {
+	if cfg.Catalog.File != "" {
+		return os.ExpandEnv(cfg.Catalog.File)
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, appName, "models.json")
+}
+
+// applyModelCatalog adds the models of the catalog downloaded from
+// catalog.url, then the ones of the user catalog, to the built-in models.
+// Later catalogs override earlier ones, and the models of the providers in
+// the config override both. A stale catalog is downloaded again in the
+// background, for the next start.
+func applyModelCatalog() {
+	for _, path := range []string{CatalogCachePath(), UserCatalogPath()} {
+		if path == "" {
+			continue
+		}
+		loaded, err := models.LoadCatalog(path)
+		if err != nil {
+			logging.Warn("Failed to load model catalog", "path", path, "error", err)
+			continue
+		}
+		if len(loaded) > 0 {
+			logging.Debug("Loaded model catalog", "path", path, "models", len(loaded))
+		}
+	}
+
+	if cfg.Catalog.URL == "" {
+		return
+	}
+	if info, err := os.Stat(CatalogCachePath()); err == nil && time.Since(info.ModTime()) < catalogRefreshInterval {
+		return
+	}
+	go func() {
+		defer logging.RecoverPanic("config.refreshCatalog", nil)
+		if err := downloadCatalog(context.Background()); err != nil {
+			logging.Debug("Failed to refresh model catalog", "url", cfg.Catalog.URL, "error", err)
+		}
+	}()
+}
+
+// RefreshCatalog downloads the catalog at catalog.url into the data
+// directory and adds its models to the supported ones.
+func RefreshCatalog(ctx context.Context) ([]models.Model, error) {
+	if cfg.Catalog.URL == "" {
+		return nil, fmt.Errorf("no catalog.url configured")
+	}
+	if err := downloadCatalog(ctx); err != nil {
+		return nil, fmt.Errorf("failed to refresh the catalog at %s: %w", cfg.Catalog.URL, err)
+	}
+	return models.LoadCatalog(CatalogCachePath())
+}
+
+func downloadCatalog(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, catalogRefreshTimeout)
+	defer cancel()
+	return models.FetchCatalog(ctx, cfg.Catalog.URL, CatalogCachePath())
+}
@@ -2,11 +2,13 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/opencode-ai/opencode/internal/llm/models"
@@ -21,9 +23,12 @@ type MCPType string
 const (
 	MCPStdio MCPType = "stdio"
 	MCPSse   MCPType = "sse"
+	MCPHttp  MCPType = "http"
 )
 
 // MCPServer defines the configuration for a Model Control Protocol server.
+// Values of Env and Headers may reference environment variables as $VAR or
+// ${VAR}, so secrets don't have to be stored in the config file.
 type MCPServer struct {
 	Command string            `json:"command"`
 	Env     []string          `json:"env"`
@@ -31,6 +36,51 @@ type MCPServer struct {
 	Type    MCPType           `json:"type"`
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
+	// Enabled is true when unset, see IsEnabled.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Timeout in seconds for every request to the server, including tool
+	// calls. When unset, connecting times out after 30 seconds and tool
+	// calls are not limited.
+	Timeout int `json:"timeout,omitempty"`
+	// Cwd is the working directory of stdio servers, relative to the
+	// project.
+	Cwd string `json:"cwd,omitempty"`
+	// AllowedTools, when set, limits the tools offered to the agent.
+	AllowedTools []string `json:"allowedTools,omitempty"`
+	// DeniedTools are never offered to the agent.
+	DeniedTools []string `json:"deniedTools,omitempty"`
+	// Roots are directories offered to the server besides the project,
+	// relative to the project.
+	Roots []string `json:"roots,omitempty"`
+	// Sampling controls the completions the server may request.
+	Sampling MCPSampling `json:"sampling,omitempty"`
+}
+
+// DefaultSamplingTokenBudget is how many tokens a server may use for
+// sampling unless configured otherwise.
+const DefaultSamplingTokenBudget = 50000
+
+// MCPSampling configures how the sampling requests of an MCP server are
+// answered.
+type MCPSampling struct {
+	// Enabled lets the server request completions. Sampling is off unless
+	// it is set.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Agent is the agent whose model answers, the task agent when unset.
+	Agent AgentName `json:"agent,omitempty"`
+	// TokenBudget caps the input and output tokens the server may use while
+	// OpenCode runs, DefaultSamplingTokenBudget when unset.
+	TokenBudget int64 `json:"tokenBudget,omitempty"`
+}
+
+// IsEnabled reports whether the server may request completions.
+func (s MCPSampling) IsEnabled() bool {
+	return s.Enabled != nil && *s.Enabled
+}
+
+// IsEnabled reports whether OpenCode should connect to the server.
+func (s MCPServer) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
 }
 
 type AgentName string
@@ -58,6 +108,65 @@ type Agent struct {
 	Model           models.ModelID    `json:"model"`
 	MaxTokens       int64             `json:"maxTokens"`
 	ReasoningEffort ReasoningEffort `json:"reasoningEffort,omitempty"` // low, medium, high
+	// Fallbacks are the models tried in order when the model fails with one
+	// of the FallbackOn errors.
+	Fallbacks []models.ModelID `json:"fallbacks,omitempty"`
+	// FallbackOn are the errors that switch to the next fallback,
+	// DefaultFallbackOn when empty.
+	FallbackOn []FallbackCondition `json:"fallbackOn,omitempty"`
+	// Cache controls prompt caching, on for every provider when unset.
+	Cache CacheConfig `json:"cache,omitempty"`
+}
+
+// CacheConfig controls how an agent caches the prompts it sends.
+type CacheConfig struct {
+	// Enabled is true when unset, see IsEnabled.
+	Enabled *bool `json:"enabled,omitempty"`
+	// TTL in seconds of the cached content Gemini keeps for the system
+	// prompt and the tools, an hour when unset.
+	TTL int `json:"ttl,omitempty"`
+}
+
+// IsEnabled reports whether the agent caches its prompts.
+func (c CacheConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// FallbackCondition is a class of provider errors that switches an agent to
+// its next fallback model.
+type FallbackCondition string
+
+const (
+	// FallbackOnRateLimit is a rate limit that outlasted the retries.
+	FallbackOnRateLimit FallbackCondition = "rate_limit"
+	// FallbackOnOverloaded is an overloaded or unavailable provider, after
+	// the retries for overloaded providers.
+	FallbackOnOverloaded FallbackCondition = "overloaded"
+	// FallbackOnServerError is any other server error.
+	FallbackOnServerError FallbackCondition = "server_error"
+	// FallbackOnConnection is a provider that can't be reached or doesn't
+	// answer in time.
+	FallbackOnConnection FallbackCondition = "connection"
+	// FallbackOnAuth is a rejected API key.
+	FallbackOnAuth FallbackCondition = "auth"
+)
+
+// FallbackConditions are all the fallback conditions.
+var FallbackConditions = []FallbackCondition{
+	FallbackOnRateLimit,
+	FallbackOnOverloaded,
+	FallbackOnServerError,
+	FallbackOnConnection,
+	FallbackOnAuth,
+}
+
+// DefaultFallbackOn are the conditions agents fall back on by default, the
+// errors of a provider that is down rather than of a bad configuration.
+var DefaultFallbackOn = []FallbackCondition{
+	FallbackOnRateLimit,
+	FallbackOnOverloaded,
+	FallbackOnServerError,
+	FallbackOnConnection,
 }
 
 // Provider defines configuration for an LLM provider.
@@ -66,6 +175,147 @@ type Provider struct {
 	BaseURL   string  `json:"baseURL,omitempty"`
 	Temperature float32 `json:"temperature,omitempty"`
 	Disabled  bool    `json:"disabled"`
+	// NumCtx is the context window requested from Ollama, the default is
+	// models.DefaultOllamaContext.
+	NumCtx int64 `json:"numCtx,omitempty"`
+	// KeepAlive is how long Ollama keeps a model loaded after a request.
+	KeepAlive string `json:"keepAlive,omitempty"`
+	// Region is the AWS region Bedrock is called in, the region of AWS_REGION
+	// or of the profile when unset.
+	Region string `json:"region,omitempty"`
+	// Profile is the AWS profile Bedrock authenticates with, AWS_PROFILE or
+	// the default profile when unset.
+	Profile string `json:"profile,omitempty"`
+	// InferenceProfile is the cross-region inference profile Bedrock models
+	// are called through, like "us" or "global". It is the geography of the
+	// region when unset, "none" calls the models in the region only.
+	InferenceProfile string `json:"inferenceProfile,omitempty"`
+	// Type makes a provider OpenCode doesn't know talk to an OpenAI or
+	// Anthropic compatible API at BaseURL.
+	Type ProviderType `json:"type,omitempty"`
+	// Headers are sent with every request, values may reference environment
+	// variables as $VAR or ${VAR}.
+	Headers map[string]string `json:"headers,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key.
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"`
+	// Models are added to the models of the provider, as <provider>.<id>.
+	Models []ModelConfig `json:"models,omitempty"`
+	// Retry is how failed requests to the provider are retried.
+	Retry RetryConfig `json:"retry,omitempty"`
+	// Timeout in seconds a request may wait for the provider to answer,
+	// 600 when unset.
+	Timeout int `json:"timeout,omitempty"`
+	// StreamIdleTimeout in seconds a streamed answer may pause before it is
+	// treated as stalled, 120 when unset.
+	StreamIdleTimeout int `json:"streamIdleTimeout,omitempty"`
+	// RateLimit limits the requests sent to the provider, across agents.
+	RateLimit RateLimit `json:"rateLimit,omitempty"`
+}
+
+// RetryConfig is the retry policy of a provider, unset fields keep their
+// defaults.
+type RetryConfig struct {
+	// MaxRetries is how often a request is retried, 8 when unset and never
+	// when 0.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+	// StatusCodes are the HTTP statuses worth retrying, rate limits,
+	// overloads and server errors when unset.
+	StatusCodes []int `json:"statusCodes,omitempty"`
+	// InitialDelay in seconds before the first retry, doubled for every
+	// retry after it, 2 when unset. A Retry-After header takes precedence.
+	InitialDelay int `json:"initialDelay,omitempty"`
+	// MaxDelay in seconds between retries, 60 when unset. A request the
+	// provider asks to retry later than this fails right away.
+	MaxDelay int `json:"maxDelay,omitempty"`
+	// Jitter is the fraction of the delay randomly added to it, 0.2 when
+	// unset.
+	Jitter *float64 `json:"jitter,omitempty"`
+}
+
+// RateLimit is a token bucket refilled with RequestsPerMinute tokens a
+// minute, each request takes one.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requestsPerMinute,omitempty"`
+	// Burst is the size of the bucket, RequestsPerMinute when unset.
+	Burst int `json:"burst,omitempty"`
+}
+
+// ProviderType is the API a custom provider speaks.
+type ProviderType string
+
+const (
+	ProviderOpenAICompatible    ProviderType = "openai-compatible"
+	ProviderAnthropicCompatible ProviderType = "anthropic-compatible"
+)
+
+// DefaultCustomContextWindow is the context window of custom models that
+// don't declare one.
+const DefaultCustomContextWindow = 32768
+
+// ModelConfig declares a model OpenCode doesn't know.
+type ModelConfig struct {
+	ID string `json:"id"`
+	// Name is shown in the model dialog, the ID when unset.
+	Name string `json:"name,omitempty"`
+	// APIModel is the name the API knows the model by, the ID when unset.
+	APIModel            string  `json:"apiModel,omitempty"`
+	ContextWindow       int64   `json:"contextWindow,omitempty"`
+	MaxTokens           int64   `json:"maxTokens,omitempty"`
+	CostPer1MIn         float64 `json:"costPer1MIn,omitempty"`
+	CostPer1MOut        float64 `json:"costPer1MOut,omitempty"`
+	CostPer1MInCached   float64 `json:"costPer1MInCached,omitempty"`
+	CostPer1MOutCached  float64 `json:"costPer1MOutCached,omitempty"`
+	CanReason           bool    `json:"canReason,omitempty"`
+	SupportsAttachments bool    `json:"supportsAttachments,omitempty"`
+	// ResponsesAPI makes an openai-compatible provider answer the model
+	// through the Responses API.
+	ResponsesAPI bool `json:"responsesAPI,omitempty"`
+}
+
+// Model returns the declared model as a model of provider.
+func (m ModelConfig) Model(provider models.ModelProvider) models.Model {
+	contextWindow := cmp.Or(m.ContextWindow, DefaultCustomContextWindow)
+	return models.Model{
+		ID:                  models.ModelID(string(provider) + "." + m.ID),
+		Name:                cmp.Or(m.Name, m.ID),
+		Provider:            provider,
+		APIModel:            cmp.Or(m.APIModel, m.ID),
+		CostPer1MIn:         m.CostPer1MIn,
+		CostPer1MOut:        m.CostPer1MOut,
+		CostPer1MInCached:   m.CostPer1MInCached,
+		CostPer1MOutCached:  m.CostPer1MOutCached,
+		ContextWindow:       contextWindow,
+		DefaultMaxTokens:    cmp.Or(m.MaxTokens, contextWindow/4),
+		CanReason:           m.CanReason,
+		SupportsAttachments: m.SupportsAttachments,
+		ResponsesAPI:        m.ResponsesAPI,
+	}
+}
+
+// Keyless reports whether the provider works without an API key. Custom
+// providers for local servers often don't need one, unless one is named by
+// APIKeyEnv.
+func (p Provider) Keyless() bool {
+	return p.Type != "" && p.APIKeyEnv == ""
+}
+
+// CatalogConfig defines where models are loaded from besides the built-in
+// ones, see applyModelCatalog.
+type CatalogConfig struct {
+	// URL of a catalog refreshed into the data directory once a day, in the
+	// catalog format or the one of the OpenRouter models API.
+	URL string `json:"url,omitempty"`
+	// File is the catalog of the user, models.json in the config directory
+	// when unset.
+	File string `json:"file,omitempty"`
+}
+
+// TokenizerConfig defines how the tokens of requests are estimated.
+type TokenizerConfig struct {
+	// DisableDownload keeps the tokenizer of OpenAI models from being
+	// downloaded, estimates are approximated unless its ranks are already
+	// in the data directory.
+	DisableDownload bool `json:"disableDownload,omitempty"`
 }
 
 // Data defines storage configuration.
@@ -106,6 +356,8 @@ type Config struct {
 	TUI          TUIConfig                         `json:"tui"`
 	Shell        ShellConfig                       `json:"shell,omitempty"`
 	AutoCompact  bool                              `json:"autoCompact,omitempty"`
+	Catalog      CatalogConfig                     `json:"catalog,omitempty"`
+	Tokenizer    TokenizerConfig                   `json:"tokenizer,omitempty"`
 }
 
 // Application constants
@@ -169,6 +421,8 @@ func Load(workingDir string, debug bool) (*Config, error) {
 	}
 
 	applyDefaultValues()
+	applyModelCatalog()
+	applyCustomProviders()
 	defaultLevel := slog.LevelInfo
 	if cfg.Debug {
 		defaultLevel = slog.LevelDebug
@@ -220,6 +474,35 @@ func Load(workingDir string, debug bool) (*Config, error) {
 	return cfg, nil
 }
 
+// applyCustomProviders reads the API keys named by apiKeyEnv and adds the
+// models declared in the config to the supported models. Bedrock, which
+// authenticates with AWS credentials, gets the placeholder key of providers
+// added from the environment.
+func applyCustomProviders() {
+	for name, provider := range cfg.Providers {
+		if provider.APIKey == "" && provider.APIKeyEnv != "" {
+			provider.APIKey = os.Getenv(provider.APIKeyEnv)
+			cfg.Providers[name] = provider
+		}
+		if provider.APIKey == "" && name == models.ProviderBedrock {
+			provider.APIKey = getProviderAPIKey(name)
+			cfg.Providers[name] = provider
+		}
+		for _, m := range provider.Models {
+			if m.ID == "" {
+				logging.Warn("model configuration has no id, ignoring it", "provider", name)
+				continue
+			}
+			model := m.Model(name)
+			models.SupportedModels[model.ID] = model
+		}
+		if _, ok := models.ProviderPopularity[name]; !ok && provider.Type != "" {
+			// After the built-in providers
+			models.ProviderPopularity[name] = len(models.ProviderPopularity) + 1
+		}
+	}
+}
+
 // configureViper sets up viper's configuration paths and environment variables.
 func configureViper() {
 	viper.SetConfigName(fmt.Sprintf(".%s", appName))
@@ -282,6 +565,19 @@ func setProviderDefaults() {
 		viper.SetDefault("providers.azure.apiKey", os.Getenv("AZURE_OPENAI_API_KEY"))
 	}
 
+	// A cassette replaces every model, for tests that must not reach the
+	// network
+	if os.Getenv("OPENCODE_CASSETTE") != "" {
+		viper.SetDefault("providers.__mock.apiKey", "cassette")
+		viper.SetDefault("agents.coder.model", models.MockModel)
+		viper.SetDefault("agents.summarizer.model", models.MockModel)
+		viper.SetDefault("agents.task.model", models.MockModel)
+		viper.SetDefault("agents.title.model", models.MockModel)
+		return
+	}
+
+	setOllamaDefaults()
+
 	// Use this order to set the default models
 	// 1. Anthropic
 	// 2. OpenAI
@@ -354,10 +650,10 @@ func setProviderDefaults() {
 
 	// AWS Bedrock configuration
 	if hasAWSCredentials() {
-		viper.SetDefault("agents.coder.model", models.BedrockClaude37Sonnet)
-		viper.SetDefault("agents.summarizer.model", models.BedrockClaude37Sonnet)
-		viper.SetDefault("agents.task.model", models.BedrockClaude37Sonnet)
-		viper.SetDefault("agents.title.model", models.BedrockClaude37Sonnet)
+		viper.SetDefault("agents.coder.model", models.BedrockClaude4Sonnet)
+		viper.SetDefault("agents.summarizer.model", models.BedrockClaude4Sonnet)
+		viper.SetDefault("agents.task.model", models.BedrockClaude4Sonnet)
+		viper.SetDefault("agents.title.model", models.BedrockClaude35Haiku)
 		return
 	}
 
@@ -380,6 +676,33 @@ func setProviderDefaults() {
 	}
 }
 
+// setOllamaDefaults loads the models of the Ollama server configured with
+// providers.ollama.baseURL or OLLAMA_HOST. They are the default models when
+// no other provider is configured.
+func setOllamaDefaults() {
+	baseURL := viper.GetString("providers.ollama.baseURL")
+	if baseURL == "" {
+		baseURL = models.OllamaURL(os.Getenv("OLLAMA_HOST"))
+	}
+	if baseURL == "" || viper.GetBool("providers.ollama.disabled") {
+		return
+	}
+
+	ollamaModels := models.LoadOllamaModels(baseURL, viper.GetInt64("providers.ollama.numCtx"))
+	if len(ollamaModels) == 0 {
+		logging.Debug("No Ollama models found", "endpoint", baseURL)
+		return
+	}
+
+	// Ollama needs no API key, but providers without one are disabled
+	viper.SetDefault("providers.ollama.apiKey", "ollama")
+	viper.SetDefault("providers.ollama.baseURL", baseURL)
+	viper.SetDefault("agents.coder.model", ollamaModels[0].ID)
+	viper.SetDefault("agents.summarizer.model", ollamaModels[0].ID)
+	viper.SetDefault("agents.task.model", ollamaModels[0].ID)
+	viper.SetDefault("agents.title.model", ollamaModels[0].ID)
+}
+
 // hasAWSCredentials checks if AWS credentials are available in the environment.
 func hasAWSCredentials() bool {
 	// Check for explicit AWS credentials
@@ -391,6 +714,9 @@ func hasAWSCredentials() bool {
 	if os.Getenv("AWS_PROFILE") != "" || os.Getenv("AWS_DEFAULT_PROFILE") != "" {
 		return true
 	}
+	if viper.GetString("providers.bedrock.profile") != "" || viper.GetString("providers.bedrock.region") != "" {
+		return true
+	}
 
 	// Check for AWS region
 	if os.Getenv("AWS_REGION") != "" || os.Getenv("AWS_DEFAULT_REGION") != "" {
@@ -501,7 +827,7 @@ func validateAgent(cfg *Config, name AgentName, agent Agent) error {
 			}
 			logging.Info("added provider from environment", "provider", provider)
 		}
-	} else if providerCfg.Disabled || providerCfg.APIKey == "" {
+	} else if providerCfg.Disabled || providerCfg.APIKey == "" && !providerCfg.Keyless() {
 		// Provider is disabled or has no API key
 		logging.Warn("provider is disabled or has no API key, reverting to default",
 			"agent", name,
@@ -588,6 +914,40 @@ func validateAgent(cfg *Config, name AgentName, agent Agent) error {
 	return nil
 }
 
+// validateFallbacks drops the fallback models of an agent that can't be used
+// and the fallback conditions that don't exist.
+func validateFallbacks(cfg *Config, name AgentName, agent Agent) {
+	if len(agent.Fallbacks) == 0 && len(agent.FallbackOn) == 0 {
+		return
+	}
+	var fallbacks []models.ModelID
+	for _, id := range agent.Fallbacks {
+		model, ok := models.SupportedModels[id]
+		providerCfg, configured := cfg.Providers[model.Provider]
+		switch {
+		case !ok:
+			logging.Warn("unsupported fallback model configured, ignoring it", "agent", name, "model", id)
+		case !configured || providerCfg.Disabled:
+			logging.Warn("fallback model provider is not enabled, ignoring it", "agent", name, "model", id, "provider", model.Provider)
+		case id == agent.Model || slices.Contains(fallbacks, id):
+			logging.Warn("fallback model is already in the chain, ignoring it", "agent", name, "model", id)
+		default:
+			fallbacks = append(fallbacks, id)
+		}
+	}
+	var on []FallbackCondition
+	for _, condition := range agent.FallbackOn {
+		if !slices.Contains(FallbackConditions, condition) {
+			logging.Warn("unknown fallback condition configured, ignoring it", "agent", name, "condition", condition)
+			continue
+		}
+		on = append(on, condition)
+	}
+	agent.Fallbacks = fallbacks
+	agent.FallbackOn = on
+	cfg.Agents[name] = agent
+}
+
 // Validate checks if the configuration is valid and applies defaults where needed.
 func Validate() error {
 	if cfg == nil {
@@ -603,13 +963,30 @@ func Validate() error {
 
 	// Validate providers
 	for provider, providerCfg := range cfg.Providers {
-		if providerCfg.APIKey == "" && !providerCfg.Disabled {
-			logging.Warn("provider has no API key, marking as disabled", "provider", provider)
+		if providerCfg.Disabled {
+			continue
+		}
+		reason := ""
+		switch {
+		case providerCfg.Type != "" && providerCfg.Type != ProviderOpenAICompatible && providerCfg.Type != ProviderAnthropicCompatible:
+			reason = fmt.Sprintf("unsupported type %q", providerCfg.Type)
+		case providerCfg.Type != "" && providerCfg.BaseURL == "":
+			reason = "no base URL"
+		case providerCfg.APIKey == "" && !providerCfg.Keyless():
+			reason = "no API key"
+		}
+		if reason != "" {
+			logging.Warn("provider has "+reason+", marking as disabled", "provider", provider)
 			providerCfg.Disabled = true
 			cfg.Providers[provider] = providerCfg
 		}
 	}
 
+	// Validate fallback models, once the providers they need are known
+	for name, agent := range cfg.Agents {
+		validateFallbacks(cfg, name, agent)
+	}
+
 	// Validate LSP configurations
 	for language, lspConfig := range cfg.LSP {
 		if lspConfig.Command == "" && !lspConfig.Disabled {
@@ -619,6 +996,38 @@ func Validate() error {
 		}
 	}
 
+	// Validate MCP servers
+	disabled := false
+	for name, server := range cfg.MCPServers {
+		if !server.IsEnabled() {
+			continue
+		}
+		reason := ""
+		switch {
+		case server.Type != MCPStdio && server.Type != MCPSse && server.Type != MCPHttp:
+			reason = fmt.Sprintf("unsupported type %q", server.Type)
+		case server.Type == MCPStdio && server.Command == "":
+			reason = "no command"
+		case server.Type != MCPStdio && server.URL == "":
+			reason = "no URL"
+		case server.Timeout < 0:
+			reason = "a negative timeout"
+		}
+		if reason != "" {
+			logging.Warn("MCP server configuration has "+reason+", marking as disabled", "server", name)
+			server.Enabled = &disabled
+			cfg.MCPServers[name] = server
+			continue
+		}
+		if agent := server.Sampling.Agent; agent != "" {
+			if _, ok := cfg.Agents[agent]; !ok {
+				logging.Warn("MCP server sampling agent not found, using the task agent", "server", name, "agent", agent)
+				server.Sampling.Agent = ""
+				cfg.MCPServers[name] = server
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -762,7 +1171,7 @@ func setDefaultModelForAgent(agent AgentName) bool {
 		}
 
 		cfg.Agents[agent] = Agent{
-			Model:           models.BedrockClaude37Sonnet,
+			Model:           models.BedrockClaude4Sonnet,
 			MaxTokens:       maxTokens,
 			ReasoningEffort: "medium", // Claude models support reasoning
 		}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomProviders(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("LITELLM_KEY", "sk-litellm")
+	t.Cleanup(func() {
+		cfg = nil
+		viper.Reset()
+	})
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".opencode.json"), []byte(`{
+  "providers": {
+    "vllm": {
+      "type": "openai-compatible",
+      "baseURL": "http://localhost:8000/v1",
+      "models": [{"id": "qwen3-32b", "apiModel": "Qwen/Qwen3-32B", "contextWindow": 65536, "canReason": true}],
+      "retry": {"maxRetries": 0, "statusCodes": [503]},
+      "rateLimit": {"requestsPerMinute": 30}
+    },
+    "litellm": {
+      "type": "anthropic-compatible",
+      "baseURL": "http://localhost:4000",
+      "apiKeyEnv": "LITELLM_KEY",
+      "headers": {"X-Team": "$USER"},
+      "models": [{"id": "claude-sonnet", "name": "Sonnet via LiteLLM", "costPer1MIn": 3, "costPer1MOut": 15}]
+    },
+    "broken": {"type": "grpc", "baseURL": "http://localhost:9000"}
+  },
+  "agents": {
+    "coder": {"model": "vllm.qwen3-32b"},
+    "task": {"model": "litellm.claude-sonnet"},
+    "title": {"model": "vllm.qwen3-32b"}
+  }
+}`), 0o644))
+
+	loaded, err := Load(dir, false)
+	require.NoError(t, err)
+
+	assert.False(t, loaded.Providers["vllm"].Disabled)
+	require.NotNil(t, loaded.Providers["vllm"].Retry.MaxRetries)
+	assert.Zero(t, *loaded.Providers["vllm"].Retry.MaxRetries)
+	assert.Equal(t, []int{503}, loaded.Providers["vllm"].Retry.StatusCodes)
+	assert.Equal(t, 30, loaded.Providers["vllm"].RateLimit.RequestsPerMinute)
+	assert.Equal(t, "sk-litellm", loaded.Providers["litellm"].APIKey)
+	assert.True(t, loaded.Providers["broken"].Disabled)
+
+	assert.Equal(t, models.Model{
+		ID:               "vllm.qwen3-32b",
+		Name:             "qwen3-32b",
+		Provider:         "vllm",
+		APIModel:         "Qwen/Qwen3-32B",
+		ContextWindow:    65536,
+		DefaultMaxTokens: 16384,
+		CanReason:        true,
+	}, models.SupportedModels["vllm.qwen3-32b"])
+
+	sonnet := models.SupportedModels["litellm.claude-sonnet"]
+	assert.Equal(t, "Sonnet via LiteLLM", sonnet.Name)
+	assert.Equal(t, "claude-sonnet", sonnet.APIModel)
+	assert.Equal(t, int64(DefaultCustomContextWindow), sonnet.ContextWindow)
+	assert.Equal(t, 15.0, sonnet.CostPer1MOut)
+
+	assert.Equal(t, models.ModelID("vllm.qwen3-32b"), loaded.Agents[AgentCoder].Model)
+	assert.Equal(t, models.ModelID("litellm.claude-sonnet"), loaded.Agents[AgentTask].Model)
+}
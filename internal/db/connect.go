@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -66,3 +67,11 @@ func Connect() (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// Vacuum rebuilds the database file to reclaim space left by deleted rows.
+func Vacuum(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, "VACUUM;"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
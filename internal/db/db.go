@@ -63,6 +63,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.importFileStmt, err = db.PrepareContext(ctx, importFile); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportFile: %w", err)
+	}
+	if q.importMessageStmt, err = db.PrepareContext(ctx, importMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportMessage: %w", err)
+	}
+	if q.importSessionStmt, err = db.PrepareContext(ctx, importSession); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportSession: %w", err)
+	}
+	if q.listAllSessionsStmt, err = db.PrepareContext(ctx, listAllSessions); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAllSessions: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -78,6 +90,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listNewFilesStmt, err = db.PrepareContext(ctx, listNewFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListNewFiles: %w", err)
 	}
+	if q.listSessionSizesStmt, err = db.PrepareContext(ctx, listSessionSizes); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSessionSizes: %w", err)
+	}
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
@@ -160,6 +175,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.importFileStmt != nil {
+		if cerr := q.importFileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importFileStmt: %w", cerr)
+		}
+	}
+	if q.importMessageStmt != nil {
+		if cerr := q.importMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importMessageStmt: %w", cerr)
+		}
+	}
+	if q.importSessionStmt != nil {
+		if cerr := q.importSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importSessionStmt: %w", cerr)
+		}
+	}
+	if q.listAllSessionsStmt != nil {
+		if cerr := q.listAllSessionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAllSessionsStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -185,6 +220,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listNewFilesStmt: %w", cerr)
 		}
 	}
+	if q.listSessionSizesStmt != nil {
+		if cerr := q.listSessionSizesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSessionSizesStmt: %w", cerr)
+		}
+	}
 	if q.listSessionsStmt != nil {
 		if cerr := q.listSessionsStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
@@ -257,11 +297,16 @@ type Queries struct {
 	getFileByPathAndSessionStmt *sql.Stmt
 	getMessageStmt              *sql.Stmt
 	getSessionByIDStmt          *sql.Stmt
+	importFileStmt              *sql.Stmt
+	importMessageStmt           *sql.Stmt
+	importSessionStmt           *sql.Stmt
+	listAllSessionsStmt         *sql.Stmt
 	listFilesByPathStmt         *sql.Stmt
 	listFilesBySessionStmt      *sql.Stmt
 	listLatestSessionFilesStmt  *sql.Stmt
 	listMessagesBySessionStmt   *sql.Stmt
 	listNewFilesStmt            *sql.Stmt
+	listSessionSizesStmt        *sql.Stmt
 	listSessionsStmt            *sql.Stmt
 	updateFileStmt              *sql.Stmt
 	updateMessageStmt           *sql.Stmt
@@ -285,11 +330,16 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
 		getMessageStmt:              q.getMessageStmt,
 		getSessionByIDStmt:          q.getSessionByIDStmt,
+		importFileStmt:              q.importFileStmt,
+		importMessageStmt:           q.importMessageStmt,
+		importSessionStmt:           q.importSessionStmt,
+		listAllSessionsStmt:         q.listAllSessionsStmt,
 		listFilesByPathStmt:         q.listFilesByPathStmt,
 		listFilesBySessionStmt:      q.listFilesBySessionStmt,
 		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
 		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
 		listNewFilesStmt:            q.listNewFilesStmt,
+		listSessionSizesStmt:        q.listSessionSizesStmt,
 		listSessionsStmt:            q.listSessionsStmt,
 		updateFileStmt:              q.updateFileStmt,
 		updateMessageStmt:           q.updateMessageStmt,
@@ -22,11 +22,16 @@ type Querier interface {
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
 	GetMessage(ctx context.Context, id string) (Message, error)
 	GetSessionByID(ctx context.Context, id string) (Session, error)
+	ImportFile(ctx context.Context, arg ImportFileParams) error
+	ImportMessage(ctx context.Context, arg ImportMessageParams) error
+	ImportSession(ctx context.Context, arg ImportSessionParams) (Session, error)
+	ListAllSessions(ctx context.Context) ([]Session, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
+	ListSessionSizes(ctx context.Context) ([]ListSessionSizesRow, error)
 	ListSessions(ctx context.Context) ([]Session, error)
 	UpdateFile(ctx context.Context, arg UpdateFileParams) (File, error)
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
@@ -106,6 +106,110 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (Session, error
 	return i, err
 }
 
+const listAllSessions = `-- name: ListAllSessions :many
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+FROM sessions
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListAllSessions(ctx context.Context) ([]Session, error) {
+	rows, err := q.query(ctx, q.listAllSessionsStmt, listAllSessions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Session{}
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.ParentSessionID,
+			&i.Title,
+			&i.MessageCount,
+			&i.PromptTokens,
+			&i.CompletionTokens,
+			&i.Cost,
+			&i.UpdatedAt,
+			&i.CreatedAt,
+			&i.SummaryMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const importSession = `-- name: ImportSession :one
+INSERT INTO sessions (
+    id,
+    parent_session_id,
+    title,
+    message_count,
+    prompt_tokens,
+    completion_tokens,
+    cost,
+    summary_message_id,
+    updated_at,
+    created_at
+) VALUES (
+    ?,
+    ?,
+    ?,
+    0,
+    ?,
+    ?,
+    ?,
+    null,
+    ?,
+    ?
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+`
+
+type ImportSessionParams struct {
+	ID               string         `json:"id"`
+	ParentSessionID  sql.NullString `json:"parent_session_id"`
+	Title            string         `json:"title"`
+	PromptTokens     int64          `json:"prompt_tokens"`
+	CompletionTokens int64          `json:"completion_tokens"`
+	Cost             float64        `json:"cost"`
+	UpdatedAt        int64          `json:"updated_at"`
+	CreatedAt        int64          `json:"created_at"`
+}
+
+func (q *Queries) ImportSession(ctx context.Context, arg ImportSessionParams) (Session, error) {
+	row := q.queryRow(ctx, q.importSessionStmt, importSession,
+		arg.ID,
+		arg.ParentSessionID,
+		arg.Title,
+		arg.PromptTokens,
+		arg.CompletionTokens,
+		arg.Cost,
+		arg.UpdatedAt,
+		arg.CreatedAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.ParentSessionID,
+		&i.Title,
+		&i.MessageCount,
+		&i.PromptTokens,
+		&i.CompletionTokens,
+		&i.Cost,
+		&i.UpdatedAt,
+		&i.CreatedAt,
+		&i.SummaryMessageID,
+	)
+	return i, err
+}
+
 const listSessions = `-- name: ListSessions :many
 SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
 FROM sessions
@@ -147,6 +251,44 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 	return items, nil
 }
 
+const listSessionSizes = `-- name: ListSessionSizes :many
+SELECT
+    s.id,
+    CAST(
+        COALESCE((SELECT SUM(LENGTH(m.parts)) FROM messages m WHERE m.session_id = s.id), 0) +
+        COALESCE((SELECT SUM(LENGTH(f.content)) FROM files f WHERE f.session_id = s.id), 0)
+    AS INTEGER) AS size
+FROM sessions s
+`
+
+type ListSessionSizesRow struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+}
+
+func (q *Queries) ListSessionSizes(ctx context.Context) ([]ListSessionSizesRow, error) {
+	rows, err := q.query(ctx, q.listSessionSizesStmt, listSessionSizes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListSessionSizesRow{}
+	for rows.Next() {
+		var i ListSessionSizesRow
+		if err := rows.Scan(&i.ID, &i.Size); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateSession = `-- name: UpdateSession :one
 UPDATE sessions
 SET
@@ -69,3 +69,16 @@ SELECT *
 FROM files
 WHERE is_new = 1
 ORDER BY created_at DESC;
+
+-- name: ImportFile :exec
+INSERT INTO files (
+    id,
+    session_id,
+    path,
+    content,
+    version,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+);
@@ -44,3 +44,18 @@ WHERE session_id = ?;
 -- name: DeleteMessagesFromID :exec
 DELETE FROM messages
 WHERE messages.session_id = ? AND messages.created_at >= (SELECT created_at FROM messages AS m2 WHERE m2.id = ?);
+
+-- name: ImportMessage :exec
+INSERT INTO messages (
+    id,
+    session_id,
+    role,
+    parts,
+    model,
+    hidden,
+    created_at,
+    updated_at,
+    finished_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
@@ -34,6 +34,20 @@ FROM sessions
 WHERE parent_session_id is NULL
 ORDER BY created_at DESC;
 
+-- name: ListAllSessions :many
+SELECT *
+FROM sessions
+ORDER BY created_at DESC;
+
+-- name: ListSessionSizes :many
+SELECT
+    s.id,
+    CAST(
+        COALESCE((SELECT SUM(LENGTH(m.parts)) FROM messages m WHERE m.session_id = s.id), 0) +
+        COALESCE((SELECT SUM(LENGTH(f.content)) FROM files f WHERE f.session_id = s.id), 0)
+    AS INTEGER) AS size
+FROM sessions s;
+
 -- name: UpdateSession :one
 UPDATE sessions
 SET
@@ -49,3 +63,28 @@ RETURNING *;
 -- name: DeleteSession :exec
 DELETE FROM sessions
 WHERE id = ?;
+
+-- name: ImportSession :one
+INSERT INTO sessions (
+    id,
+    parent_session_id,
+    title,
+    message_count,
+    prompt_tokens,
+    completion_tokens,
+    cost,
+    summary_message_id,
+    updated_at,
+    created_at
+) VALUES (
+    ?,
+    ?,
+    ?,
+    0,
+    ?,
+    ?,
+    ?,
+    null,
+    ?,
+    ?
+) RETURNING *;
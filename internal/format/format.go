@@ -15,6 +15,9 @@ const (
 
 	// JSON format outputs the AI response wrapped in a JSON object.
 	JSON OutputFormat = "json"
+
+	// StreamJSON format outputs newline-delimited JSON events while the agent runs.
+	StreamJSON OutputFormat = "stream-json"
 )
 
 // String returns the string representation of the OutputFormat
@@ -26,6 +29,7 @@ func (f OutputFormat) String() string {
 var SupportedFormats = []string{
 	string(Text),
 	string(JSON),
+	string(StreamJSON),
 }
 
 // Parse converts a string to an OutputFormat
@@ -37,6 +41,8 @@ func Parse(s string) (OutputFormat, error) {
 		return Text, nil
 	case string(JSON):
 		return JSON, nil
+	case string(StreamJSON):
+		return StreamJSON, nil
 	default:
 		return "", fmt.Errorf("invalid format: %s", s)
 	}
@@ -52,47 +58,55 @@ func IsValid(s string) bool {
 func GetHelpText() string {
 	return fmt.Sprintf(`Supported output formats:
 - %s: Plain text output (default)
-- %s: Output wrapped in a JSON object`,
-		Text, JSON)
+- %s: Output wrapped in a JSON object
+- %s: Newline-delimited JSON events streamed while the agent runs`,
+		Text, JSON, StreamJSON)
+}
+
+// Result is the outcome of a non-interactive run
+type Result struct {
+	Response  string `json:"response"`
+	SessionID string `json:"session_id,omitempty"`
+	// StructuredOutput is the response decoded as JSON when it was
+	// validated against an output schema.
+	StructuredOutput json.RawMessage `json:"structured_output,omitempty"`
 }
 
 // FormatOutput formats the AI response according to the specified format
-func FormatOutput(content string, formatStr string) string {
+func FormatOutput(result Result, formatStr string) string {
 	format, err := Parse(formatStr)
 	if err != nil {
 		// Default to text format on error
-		return content
+		return result.Response
 	}
 
 	switch format {
 	case JSON:
-		return formatAsJSON(content)
+		return formatAsJSON(result)
 	case Text:
 		fallthrough
 	default:
-		return content
+		return result.Response
 	}
 }
 
-// formatAsJSON wraps the content in a simple JSON object
-func formatAsJSON(content string) string {
+// formatAsJSON wraps the result in a simple JSON object
+func formatAsJSON(result Result) string {
 	// Use the JSON package to properly escape the content
-	response := struct {
-		Response string `json:"response"`
-	}{
-		Response: content,
-	}
-
-	jsonBytes, err := json.MarshalIndent(response, "", "  ")
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		// In case of an error, return a manually formatted JSON
+		content := result.Response
 		jsonEscaped := strings.Replace(content, "\\", "\\\\", -1)
 		jsonEscaped = strings.Replace(jsonEscaped, "\"", "\\\"", -1)
 		jsonEscaped = strings.Replace(jsonEscaped, "\n", "\\n", -1)
 		jsonEscaped = strings.Replace(jsonEscaped, "\r", "\\r", -1)
 		jsonEscaped = strings.Replace(jsonEscaped, "\t", "\\t", -1)
 
-		return fmt.Sprintf("{\n  \"response\": \"%s\"\n}", jsonEscaped)
+		if result.SessionID == "" {
+			return fmt.Sprintf("{\n  \"response\": \"%s\"\n}", jsonEscaped)
+		}
+		return fmt.Sprintf("{\n  \"response\": \"%s\",\n  \"session_id\": \"%s\"\n}", jsonEscaped, result.SessionID)
 	}
 
 	return string(jsonBytes)
@@ -0,0 +1,39 @@
+// Package httpcheck rejects the requests web pages can make to the HTTP
+// servers OpenCode runs on the machine of the user.
+package httpcheck
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Request returns why a request is rejected, with the status to answer it
+// with, or nil. Browsers send an Origin, and DNS rebinding makes requests
+// for a host other than the server.
+func Request(r *http.Request, hosts []string) (int, error) {
+	if r.Header.Get("Origin") != "" {
+		return http.StatusForbidden, errors.New("requests from browsers are not allowed")
+	}
+	if !AllowedHost(r.Host, hosts) {
+		return http.StatusForbidden, fmt.Errorf("host %s is not allowed", r.Host)
+	}
+	return 0, nil
+}
+
+// AllowedHost reports whether host, the Host header of a request, names the
+// server: localhost, a loopback address or one of hosts.
+func AllowedHost(host string, hosts []string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") || slices.Contains(hosts, host) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
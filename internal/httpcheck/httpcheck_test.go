@@ -0,0 +1,40 @@
+package httpcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{name: "localhost", host: "LocalHost:4096"},
+		{name: "loopback", host: "127.0.0.1"},
+		{name: "ipv6 loopback", host: "[::1]:4096"},
+		{name: "allowed host", host: "devbox:4096"},
+		{name: "foreign host", host: "evil.example", want: http.StatusForbidden},
+		{name: "foreign address", host: "192.168.1.2:4096", want: http.StatusForbidden},
+		{name: "origin", host: "localhost", origin: "http://localhost:3000", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			status, err := Request(req, []string{"devbox"})
+			assert.Equal(t, tt.want, status)
+			assert.Equal(t, tt.want != 0, err != nil)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -25,14 +26,34 @@ import (
 var (
 	ErrRequestCancelled = errors.New("request cancelled by user")
 	ErrSessionBusy      = errors.New("session is currently processing another request")
+	ErrMaxTurnsExceeded = errors.New("maximum number of turns exceeded")
+	ErrProviderRequest  = errors.New("provider request failed")
 )
 
+type skipTitleContextKey struct{}
+
+// WithoutTitleGeneration returns a context that keeps Run from replacing the
+// title of a new session with a generated one.
+func WithoutTitleGeneration(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipTitleContextKey{}, true)
+}
+
+type maxTurnsContextKey struct{}
+
+// WithMaxTurns returns a context that limits Run to the given number of
+// model requests. Exceeding the limit fails the run with ErrMaxTurnsExceeded.
+func WithMaxTurns(ctx context.Context, turns int) context.Context {
+	return context.WithValue(ctx, maxTurnsContextKey{}, turns)
+}
+
 type AgentEventType string
 
 const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+	AgentEventTypeFallback  AgentEventType = "fallback"
+	AgentEventTypeRetry     AgentEventType = "retry"
 
 	MaxToolUseRetries = 3
 )
@@ -46,6 +67,11 @@ type AgentEvent struct {
 	SessionID string
 	Progress  string
 	Done      bool
+
+	// When switching to another model of the fallback chain
+	Fallback *provider.Fallback
+	// When a failed request is about to be sent again
+	Retry *provider.Retry
 }
 
 type Service interface {
@@ -55,16 +81,21 @@ type Service interface {
 	Cancel(sessionID string)
 	IsSessionBusy(sessionID string) bool
 	IsBusy() bool
+	BusySessions() []string
 	Update(agentName config.AgentName, modelID models.ModelID) (models.Model, error)
 	Summarize(ctx context.Context, sessionID string) error
+	// Close releases the providers of the agent.
+	Close()
 }
 
 type agent struct {
 	*pubsub.Broker[AgentEvent]
+	name     config.AgentName
 	sessions session.Service
 	messages message.Service
 
 	tools    []tools.BaseTool
+	mcpTools func() []tools.BaseTool
 	provider provider.Provider
 
 	titleProvider     provider.Provider
@@ -80,6 +111,7 @@ func NewAgent(
 	sessions session.Service,
 	messages message.Service,
 	agentTools []tools.BaseTool,
+	mcpTools func() []tools.BaseTool,
 ) (Service, error) {
 	agentProvider, err := createAgentProvider(agentName)
 	if err != nil {
@@ -103,10 +135,12 @@ func NewAgent(
 
 	agent := &agent{
 		Broker:            pubsub.NewBroker[AgentEvent](),
+		name:              agentName,
 		provider:          agentProvider,
 		messages:          messages,
 		sessions:          sessions,
 		tools:             agentTools,
+		mcpTools:          mcpTools,
 		titleProvider:     titleProvider,
 		summarizeProvider: summarizeProvider,
 		activeRequests:    sync.Map{},
@@ -139,6 +173,20 @@ func (a *agent) Cancel(sessionID string) {
 	}
 }
 
+// BusySessions returns the IDs of the sessions with a request in progress.
+func (a *agent) BusySessions() []string {
+	ids := []string{}
+	a.activeRequests.Range(func(key, value any) bool {
+		// Summaries are tracked under a suffixed key and don't block the session.
+		if id, ok := key.(string); ok && !strings.HasSuffix(id, "-summarize") {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	slices.Sort(ids)
+	return ids
+}
+
 func (a *agent) IsBusy() bool {
 	busy := false
 	a.activeRequests.Range(func(key, value interface{}) bool {
@@ -203,7 +251,14 @@ func (a *agent) err(err error) AgentEvent {
 
 func (a *agent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
 	if !a.provider.Model().SupportsAttachments && attachments != nil {
-		attachments = nil
+		// Text attachments are sent as text blocks, so every model takes them.
+		var text []message.Attachment
+		for _, attachment := range attachments {
+			if attachment.IsText() {
+				text = append(text, attachment)
+			}
+		}
+		attachments = text
 	}
 	events := make(chan AgentEvent)
 	if a.IsSessionBusy(sessionID) {
@@ -223,6 +278,8 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 			attachmentParts = append(attachmentParts, message.BinaryContent{Path: attachment.FilePath, MIMEType: attachment.MimeType, Data: attachment.Content})
 		}
 		result := a.processGeneration(genCtx, sessionID, content, attachmentParts)
+		// Errors carry no message, so subscribers need the session explicitly.
+		result.SessionID = sessionID
 		if result.Error != nil && !errors.Is(result.Error, ErrRequestCancelled) && !errors.Is(result.Error, context.Canceled) {
 			logging.ErrorPersist(result.Error.Error())
 		}
@@ -242,7 +299,8 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	if err != nil {
 		return a.err(fmt.Errorf("failed to list messages: %w", err))
 	}
-	if len(msgs) == 0 {
+	skipTitle, _ := ctx.Value(skipTitleContextKey{}).(bool)
+	if len(msgs) == 0 && !skipTitle {
 		go func() {
 			defer logging.RecoverPanic("agent.Run", func() {
 				logging.ErrorPersist("panic while generating title")
@@ -270,6 +328,28 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			msgs[0].Role = message.User
 		}
 	}
+	// Summarize before the request instead of failing it
+	if a.shouldSummarize(msgs, content, attachmentParts) {
+		summary, err := a.summarize(ctx, sessionID, msgs, func(progress string) {
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
+				Type:      AgentEventTypeSummarize,
+				SessionID: sessionID,
+				Progress:  progress,
+			})
+		})
+		if err != nil {
+			logging.Warn("Failed to summarize the session", "session", sessionID, "error", err)
+		} else {
+			summary.Role = message.User
+			msgs = []message.Message{summary}
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
+				Type:      AgentEventTypeSummarize,
+				SessionID: sessionID,
+				Progress:  "Summary complete",
+				Done:      true,
+			})
+		}
+	}
 
 	// Find and apply microagents
 	finder, err := microagent.NewFinder()
@@ -316,6 +396,8 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 
 	lastResponseWasToolOnly := false
 	toolUseRetryCount := 0
+	maxTurns, _ := ctx.Value(maxTurnsContextKey{}).(int)
+	turns := 0
 	for {
 		// Check for cancellation before each iteration
 		select {
@@ -339,6 +421,11 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			lastResponseWasToolOnly = false
 		}
 
+		if maxTurns > 0 && turns >= maxTurns {
+			return a.err(fmt.Errorf("%w: stopped after %d turns", ErrMaxTurnsExceeded, turns))
+		}
+		turns++
+
 		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
@@ -346,8 +433,22 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 				a.messages.Update(context.Background(), agentMessage)
 				return a.err(ErrRequestCancelled)
 			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				agentMessage.AddFinish(message.FinishReasonCanceled)
+				a.messages.Update(context.Background(), agentMessage)
+				return a.err(err)
+			}
 			return a.err(fmt.Errorf("failed to process events: %w", err))
 		}
+		// A denied permission ends the run; the model must not carry on
+		// without the user's consent.
+		if agentMessage.FinishReason() == message.FinishReasonPermissionDenied {
+			return AgentEvent{
+				Type:    AgentEventTypeResponse,
+				Message: agentMessage,
+				Done:    true,
+			}
+		}
 		logging.Info("Result", "message", agentMessage.FinishReason(), "toolResults", toolResults)
 		// Retry if the model returns a tool use finish reason without any tools, or if it returns an empty response.
 		if toolResults == nil && (agentMessage.FinishReason() == message.FinishReasonToolUse || agentMessage.Content().Text == "") {
@@ -455,8 +556,18 @@ func (a *agent) createUserMessage(ctx context.Context, sessionID, content string
 	})
 }
 
+// availableTools returns the built-in tools followed by the tools of the
+// MCP servers that are currently connected.
+func (a *agent) availableTools() []tools.BaseTool {
+	if a.mcpTools == nil {
+		return a.tools
+	}
+	return append(slices.Clip(a.tools), a.mcpTools()...)
+}
+
 func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msgHistory []message.Message) (message.Message, *message.Message, error) {
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, a.tools)
+	agentTools := a.availableTools()
+	eventChan := a.provider.StreamResponse(ctx, fitContext(a.name, a.provider.Model(), msgHistory, agentTools), agentTools)
 
 	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
 		Role:  message.Assistant,
@@ -467,10 +578,6 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 		return assistantMsg, nil, fmt.Errorf("failed to create assistant message: %w", err)
 	}
 
-	// Add the session and message ID into the context if needed by tools.
-	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
-	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
-
 	// Process each event in the stream.
 	for event := range eventChan {
 		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
@@ -483,6 +590,11 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 		}
 	}
 
+	// Add the session and message ID into the context if needed by tools.
+	// The message is only final now, a fallback replaces it.
+	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+
 	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
 	toolCalls := assistantMsg.ToolCalls()
 	for i, toolCall := range toolCalls {
@@ -501,7 +613,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 		default:
 			// Continue processing
 			var tool tools.BaseTool
-			for _, availableTools := range a.tools {
+			for _, availableTools := range agentTools {
 				if availableTools.Info().Name == toolCall.Name {
 					tool = availableTools
 				}
@@ -540,10 +652,11 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 				}
 			}
 			toolResults[i] = message.ToolResult{
-				ToolCallID: toolCall.ID,
-				Content:    toolResult.Content,
-				Metadata:   toolResult.Metadata,
-				IsError:    toolResult.IsError,
+				ToolCallID:  toolCall.ID,
+				Content:     toolResult.Content,
+				Metadata:    toolResult.Metadata,
+				IsError:     toolResult.IsError,
+				Attachments: toolResult.Attachments,
 			}
 		}
 	}
@@ -581,7 +694,7 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 
 	switch event.Type {
 	case provider.EventThinkingDelta:
-		assistantMsg.AppendReasoningContent(event.Content)
+		assistantMsg.AppendReasoningContent(event.Thinking)
 		return a.messages.Update(ctx, *assistantMsg)
 	case provider.EventContentDelta:
 		assistantMsg.AppendContent(event.Content)
@@ -606,32 +719,84 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 			logging.InfoPersist(fmt.Sprintf("Event processing canceled for session: %s", sessionID))
 			return context.Canceled
 		}
+		if errors.Is(event.Error, context.DeadlineExceeded) {
+			return event.Error
+		}
 		logging.ErrorPersist(event.Error.Error())
-		return event.Error
+		return fmt.Errorf("%w: %w", ErrProviderRequest, event.Error)
 	case provider.EventComplete:
 		assistantMsg.SetToolCalls(event.Response.ToolCalls)
+		if event.Response.ResponseID != "" {
+			assistantMsg.SetReasoningItems(event.Response.ResponseID, event.Response.ReasoningItems)
+		}
 		assistantMsg.AddFinish(event.Response.FinishReason)
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.provider.Model(), event.Response.Usage)
+		return a.TrackUsage(ctx, sessionID, models.SupportedModels[assistantMsg.Model], event.Response.Usage)
+	case provider.EventFallback:
+		return a.switchModel(ctx, sessionID, assistantMsg, *event.Fallback)
+	case provider.EventRetry:
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeRetry,
+			SessionID: sessionID,
+			Retry:     event.Retry,
+		})
 	}
 
 	return nil
 }
 
-func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage) error {
-	sess, err := a.sessions.Get(ctx, sessionID)
+// switchModel replaces the message of a model that failed before answering
+// with a message of the fallback model.
+func (a *agent) switchModel(ctx context.Context, sessionID string, assistantMsg *message.Message, fallback provider.Fallback) error {
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeFallback,
+		SessionID: sessionID,
+		Fallback:  &fallback,
+	})
+	if assistantMsg.Model == fallback.To.ID {
+		return nil
+	}
+	if err := a.messages.Delete(ctx, assistantMsg.ID); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	msg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{message.Fallback{From: fallback.From.ID, Reason: string(fallback.Reason)}},
+		Model: fallback.To.ID,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get session: %w", err)
+		return fmt.Errorf("failed to create assistant message: %w", err)
 	}
+	*assistantMsg = msg
+	return nil
+}
 
-	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
+// usageCost returns the cost in USD of the tokens of a request. Tokens
+// written to or read from a cache are billed as input when the model has no
+// price for them.
+func usageCost(model models.Model, usage provider.TokenUsage) float64 {
+	cacheWrite, cacheRead := model.CostPer1MInCached, model.CostPer1MOutCached
+	if cacheWrite == 0 {
+		cacheWrite = model.CostPer1MIn
+	}
+	if cacheRead == 0 {
+		cacheRead = model.CostPer1MIn
+	}
+	return cacheWrite/1e6*float64(usage.CacheCreationTokens) +
+		cacheRead/1e6*float64(usage.CacheReadTokens) +
 		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
 		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+}
 
-	sess.Cost += cost
+func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage) error {
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	sess.Cost += usageCost(model, usage)
 	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
 	sess.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
 
@@ -656,11 +821,21 @@ func (a *agent) Update(agentName config.AgentName, modelID models.ModelID) (mode
 		return models.Model{}, fmt.Errorf("failed to create provider for model %s: %w", modelID, err)
 	}
 
+	previous := a.provider
 	a.provider = provider
+	previous.Close()
 
 	return a.provider.Model(), nil
 }
 
+func (a *agent) Close() {
+	for _, p := range []provider.Provider{a.provider, a.titleProvider, a.summarizeProvider} {
+		if p != nil {
+			p.Close()
+		}
+	}
+}
+
 func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	if a.summarizeProvider == nil {
 		return fmt.Errorf("summarize provider not available")
@@ -708,119 +883,25 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			return
 		}
 
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Analyzing conversation...",
-		}
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Add a system message to guide the summarization
-		summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
-
-		// Create a new message with the summarize prompt
-		promptMsg := message.Message{
-			Role:  message.User,
-			Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
-		}
-
-		// Append the prompt to the messages
-		msgsWithPrompt := append(msgs, promptMsg)
-
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Generating summary...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Send the messages to the summarize provider
-		response, err := a.summarizeProvider.SendMessages(
-			summarizeCtx,
-			msgsWithPrompt,
-			make([]tools.BaseTool, 0),
-		)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to summarize: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-
-		summary := strings.TrimSpace(response.Content)
-		if summary == "" {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("empty summary returned"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Creating new session...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to get session: %w", err),
-				Done:  true,
-			}
-
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		// Create a message in the new session with the summary
-		msg, err := a.messages.Create(summarizeCtx, oldSession.ID, message.CreateMessageParams{
-			Role: message.Assistant,
-			Parts: []message.ContentPart{
-				message.TextContent{Text: summary},
-				message.Finish{
-					Reason: message.FinishReasonEndTurn,
-					Time:   time.Now().Unix(),
-				},
-			},
-			Model: a.summarizeProvider.Model().ID,
+		_, err = a.summarize(summarizeCtx, sessionID, msgs, func(progress string) {
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
+				Type:     AgentEventTypeSummarize,
+				Progress: progress,
+			})
 		})
 		if err != nil {
 			event = AgentEvent{
 				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to create summary message: %w", err),
+				Error: err,
 				Done:  true,
 			}
-
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
-		oldSession.SummaryMessageID = msg.ID
-		oldSession.CompletionTokens = response.Usage.OutputTokens
-		oldSession.PromptTokens = 0
-		model := a.summarizeProvider.Model()
-		usage := response.Usage
-		cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-			model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-		oldSession.Cost += cost
-		_, err = a.sessions.Save(summarizeCtx, oldSession)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to save session: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-		}
 
 		event = AgentEvent{
 			Type:      AgentEventTypeSummarize,
-			SessionID: oldSession.ID,
+			SessionID: sessionID,
 			Progress:  "Summary complete",
 			Done:      true,
 		}
@@ -831,15 +912,103 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-func createAgentProvider(agentName config.AgentName) (provider.Provider, error) {
-	cfg := config.Get()
-	agentConfig, ok := cfg.Agents[agentName]
+// summarize replaces the history of a session with a summary of it, which
+// the next requests start from, and returns the summary.
+func (a *agent) summarize(ctx context.Context, sessionID string, msgs []message.Message, progress func(string)) (message.Message, error) {
+	progress("Analyzing conversation...")
+
+	// Add a system message to guide the summarization
+	summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
+
+	// Create a new message with the summarize prompt
+	promptMsg := message.Message{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
+	}
+
+	// Append the prompt to the messages, and fit them to the context window
+	// of the summarizer, which may be smaller than the one of the agent
+	msgsWithPrompt := fitContext(config.AgentSummarizer, a.summarizeProvider.Model(), append(msgs, promptMsg), nil)
+
+	progress("Generating summary...")
+
+	// Send the messages to the summarize provider
+	response, err := a.summarizeProvider.SendMessages(
+		ctx,
+		msgsWithPrompt,
+		make([]tools.BaseTool, 0),
+	)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to summarize: %w", err)
+	}
+
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return message.Message{}, fmt.Errorf("empty summary returned")
+	}
+	progress("Creating new session...")
+
+	oldSession, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	// Create a message in the new session with the summary
+	msg, err := a.messages.Create(ctx, oldSession.ID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: summary},
+			message.Finish{
+				Reason: message.FinishReasonEndTurn,
+				Time:   time.Now().Unix(),
+			},
+		},
+		Model: a.summarizeProvider.Model().ID,
+	})
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to create summary message: %w", err)
+	}
+	oldSession.SummaryMessageID = msg.ID
+	oldSession.CompletionTokens = response.Usage.OutputTokens
+	oldSession.PromptTokens = 0
+	model := a.summarizeProvider.Model()
+	oldSession.Cost += usageCost(model, response.Usage)
+	_, err = a.sessions.Save(ctx, oldSession)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	return msg, nil
+}
+
+// createAgentProvider creates the provider of the model of an agent, which
+// falls back on the fallback models of the agent. The extra options are
+// applied last, overriding the defaults of the agent.
+func createAgentProvider(agentName config.AgentName, extra ...provider.ProviderClientOption) (provider.Provider, error) {
+	agentConfig, ok := config.Get().Agents[agentName]
 	if !ok {
 		return nil, fmt.Errorf("agent %s not found", agentName)
 	}
-	model, ok := models.SupportedModels[agentConfig.Model]
+	agentProvider, err := createModelProvider(agentName, agentConfig, agentConfig.Model, extra...)
+	if err != nil {
+		return nil, err
+	}
+	chain := []provider.Provider{agentProvider}
+	for _, modelID := range agentConfig.Fallbacks {
+		fallback, err := createModelProvider(agentName, agentConfig, modelID, extra...)
+		if err != nil {
+			logging.Warn("Failed to create fallback provider", "agent", agentName, "model", modelID, "error", err)
+			continue
+		}
+		chain = append(chain, fallback)
+	}
+	return provider.NewFallbackProvider(chain, agentConfig.FallbackOn), nil
+}
+
+// createModelProvider creates the provider of one model for an agent.
+func createModelProvider(agentName config.AgentName, agentConfig config.Agent, modelID models.ModelID, extra ...provider.ProviderClientOption) (provider.Provider, error) {
+	cfg := config.Get()
+	model, ok := models.SupportedModels[modelID]
 	if !ok {
-		return nil, fmt.Errorf("model %s not supported", agentConfig.Model)
+		return nil, fmt.Errorf("model %s not supported", modelID)
 	}
 
 	providerCfg, ok := cfg.Providers[model.Provider]
@@ -852,39 +1021,50 @@ func createAgentProvider(agentName config.AgentName) (provider.Provider, error)
 	maxTokens := model.DefaultMaxTokens
 	if agentConfig.MaxTokens > 0 {
 		maxTokens = agentConfig.MaxTokens
+		// The max tokens are validated for the model of the agent only
+		if modelID != agentConfig.Model && model.ContextWindow > 0 {
+			maxTokens = min(maxTokens, model.ContextWindow/2)
+		}
 	}
 	opts := []provider.ProviderClientOption{
 		provider.WithAPIKey(providerCfg.APIKey),
+		provider.WithAgent(agentName),
 		provider.WithModel(model),
 		provider.WithSystemMessage(prompt.GetAgentPrompt(agentName, model.Provider)),
 		provider.WithMaxTokens(maxTokens),
 	}
-	if model.Provider == models.ProviderOpenAI || model.Provider == models.ProviderLocal && model.CanReason {
-		opts = append(
-			opts,
-			provider.WithOpenAIOptions(
-				provider.WithReasoningEffort(string(agentConfig.ReasoningEffort)),
-			),
-		)
-	} else if model.Provider == models.ProviderAnthropic && model.CanReason && agentName == config.AgentCoder {
-		opts = append(
-			opts,
-			provider.WithAnthropicOptions(
-				provider.WithAnthropicShouldThinkFn(provider.DefaultShouldThinkFn),
-			),
-		)
-	}
-	if model.Provider == models.ProviderGemini {
-		geminiProviderOpts := []provider.GeminiOption{}
-		if model.CanReason {
-			budget := calculateThinkingBudget(agentConfig.ReasoningEffort, model)
-			geminiProviderOpts = append(geminiProviderOpts, provider.WithGeminiThinkingBudget(int32(budget)))
-		}
-		// Add other Gemini specific options here if needed in the future
-		if len(geminiProviderOpts) > 0 {
-			opts = append(opts, provider.WithGeminiOptions(geminiProviderOpts...))
-		}
+	providerType := providerCfg.Type
+	var openaiOpts []provider.OpenAIOption
+	var anthropicOpts []provider.AnthropicOption
+	var geminiOpts []provider.GeminiOption
+	var bedrockOpts []provider.BedrockOption
+	if model.Provider == models.ProviderOpenAI || (model.Provider == models.ProviderLocal || providerType == config.ProviderOpenAICompatible) && model.CanReason {
+		openaiOpts = append(openaiOpts, provider.WithReasoningEffort(string(agentConfig.ReasoningEffort)))
+	} else if (model.Provider == models.ProviderAnthropic || providerType == config.ProviderAnthropicCompatible) && model.CanReason && agentName == config.AgentCoder {
+		anthropicOpts = append(anthropicOpts, provider.WithAnthropicShouldThinkFn(provider.DefaultShouldThinkFn))
+	} else if model.Provider == models.ProviderBedrock && model.CanReason && agentName == config.AgentCoder {
+		bedrockOpts = append(bedrockOpts, provider.WithBedrockShouldThinkFn(provider.DefaultShouldThinkFn))
+	}
+	if model.Provider == models.ProviderGemini && model.CanReason {
+		budget := calculateThinkingBudget(agentConfig.ReasoningEffort, model)
+		geminiOpts = append(geminiOpts, provider.WithGeminiThinkingBudget(int32(budget)))
+	}
+	if !agentConfig.Cache.IsEnabled() {
+		openaiOpts = append(openaiOpts, provider.WithOpenAIDisableCache())
+		anthropicOpts = append(anthropicOpts, provider.WithAnthropicDisableCache())
+		geminiOpts = append(geminiOpts, provider.WithGeminiDisableCache())
+		bedrockOpts = append(bedrockOpts, provider.WithBedrockDisableCache())
+	} else if agentConfig.Cache.TTL > 0 {
+		geminiOpts = append(geminiOpts, provider.WithGeminiCacheTTL(time.Duration(agentConfig.Cache.TTL)*time.Second))
 	}
+	opts = append(opts,
+		provider.WithOpenAIOptions(openaiOpts...),
+		provider.WithAnthropicOptions(anthropicOpts...),
+		provider.WithGeminiOptions(geminiOpts...),
+		provider.WithBedrockOptions(bedrockOpts...),
+	)
+
+	opts = append(opts, extra...)
 
 	agentProvider, err := provider.NewProvider(
 		model.Provider,
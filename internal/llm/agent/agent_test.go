@@ -0,0 +1,244 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoTool struct{}
+
+func (echoTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name:       "echo",
+		Parameters: map[string]any{"text": map[string]any{"type": "string"}},
+		Required:   []string{"text"},
+	}
+}
+
+func (echoTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	var params struct{ Text string }
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return tools.NewTextErrorResponse(err.Error()), nil
+	}
+	return tools.NewTextResponse(params.Text), nil
+}
+
+// newCassetteAgent creates the coder agent with every agent replaying the
+// cassette at path, on a fresh database.
+func newCassetteAgent(t *testing.T, path string, agentTools ...tools.BaseTool) (Service, session.Service, message.Service) {
+	t.Setenv(provider.CassetteEnv, path)
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	cfg.Providers[models.ProviderMock] = config.Provider{APIKey: "cassette"}
+	for _, name := range []config.AgentName{config.AgentCoder, config.AgentTitle, config.AgentSummarizer} {
+		cfg.Agents[name] = config.Agent{Model: models.MockModel, MaxTokens: 1000}
+	}
+
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q)
+	messages := message.NewService(q)
+
+	coder, err := NewAgent(config.AgentCoder, sessions, messages, agentTools, nil)
+	require.NoError(t, err)
+	return coder, sessions, messages
+}
+
+// TestRunReplaysCassette runs the agent loop against a scripted provider: a
+// tool call, an empty response that is asked again and the final answer.
+func TestRunReplaysCassette(t *testing.T) {
+	coder, sessions, messages := newCassetteAgent(t, "testdata/echo.yaml", echoTool{})
+
+	ctx := context.Background()
+	s, err := sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+	events, err := coder.Run(ctx, s.ID, "Echo hello")
+	require.NoError(t, err)
+	result := <-events
+	require.NoError(t, result.Error)
+	assert.Equal(t, "The tool said hello.", result.Message.Content().Text)
+
+	msgs, err := messages.List(ctx, s.ID)
+	require.NoError(t, err)
+	var results []string
+	for _, msg := range msgs {
+		for _, tr := range msg.ToolResults() {
+			results = append(results, tr.Content)
+		}
+	}
+	assert.Equal(t, []string{"hello"}, results)
+
+	assert.Eventually(t, func() bool {
+		s, err = sessions.Get(ctx, s.ID)
+		return err == nil && s.Title == "Echo test"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(150), s.PromptTokens)
+	assert.Equal(t, int64(6), s.CompletionTokens)
+}
+
+// TestRunRetriesCassette replays a rate limited request, which is retried
+// with a retry event before the answer.
+func TestRunRetriesCassette(t *testing.T) {
+	coder, sessions, _ := newCassetteAgent(t, "testdata/retry.yaml")
+	noJitter := 0.0
+	config.Get().Providers[models.ProviderMock] = config.Provider{
+		APIKey: "cassette",
+		Retry:  config.RetryConfig{InitialDelay: 1, Jitter: &noJitter},
+	}
+
+	ctx := WithoutTitleGeneration(context.Background())
+	s, err := sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+	agentEvents := coder.Subscribe(ctx)
+	events, err := coder.Run(ctx, s.ID, "Hello")
+	require.NoError(t, err)
+	result := <-events
+	require.NoError(t, result.Error)
+	assert.Equal(t, "Answered after a retry.", result.Message.Content().Text)
+
+	var retries []*provider.Retry
+	for len(agentEvents) > 0 {
+		if event := <-agentEvents; event.Payload.Type == AgentEventTypeRetry {
+			retries = append(retries, event.Payload.Retry)
+		}
+	}
+	require.Len(t, retries, 1)
+	assert.Equal(t, 1, retries[0].Attempt)
+	assert.Equal(t, time.Second, retries[0].Delay)
+	assert.ErrorContains(t, retries[0].Err, "rate limit")
+}
+
+// TestSummarizeCassette summarizes a session between two requests, the
+// second of which starts from the summary.
+func TestSummarizeCassette(t *testing.T) {
+	coder, sessions, messages := newCassetteAgent(t, "testdata/summarize.yaml")
+
+	ctx := WithoutTitleGeneration(context.Background())
+	s, err := sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+	events, err := coder.Run(ctx, s.ID, "What is in the readme?")
+	require.NoError(t, err)
+	require.NoError(t, (<-events).Error)
+
+	agentEvents := coder.Subscribe(ctx)
+	require.NoError(t, coder.Summarize(ctx, s.ID))
+	for event := range agentEvents {
+		require.NoError(t, event.Payload.Error)
+		if event.Payload.Type == AgentEventTypeSummarize && event.Payload.Done {
+			break
+		}
+	}
+	s, err = sessions.Get(ctx, s.ID)
+	require.NoError(t, err)
+	summary, err := messages.Get(ctx, s.SummaryMessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "We read the readme, which describes the CLI.", summary.Content().Text)
+
+	events, err = coder.Run(ctx, s.ID, "Which commands does it have?")
+	require.NoError(t, err)
+	result := <-events
+	require.NoError(t, result.Error)
+	assert.Equal(t, "It has a serve command.", result.Message.Content().Text)
+	s, err = sessions.Get(ctx, s.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), s.PromptTokens)
+}
+
+func TestUsageCost(t *testing.T) {
+	usage := provider.TokenUsage{InputTokens: 1e6, OutputTokens: 1e6, CacheCreationTokens: 1e6, CacheReadTokens: 1e6}
+	assert.InDelta(t, 2+8+0.5+2, usageCost(models.SupportedModels[models.GPT41], usage), 1e-9)
+	assert.InDelta(t, 3+15+3.75+0.3, usageCost(models.SupportedModels[models.Claude37Sonnet], usage), 1e-9)
+}
+
+// smallModel is a model with a small context window.
+var smallModel = models.Model{ID: "small", Provider: models.ProviderMock, ContextWindow: 2000}
+
+// largeHistory reads files too large for the context window of smallModel.
+func largeHistory(large string) []message.Message {
+	history := []message.Message{{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Read the files"}}}}
+	for i := range 4 {
+		history = append(history, message.Message{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: string(rune('a' + i)), Content: large},
+		}})
+	}
+	return history
+}
+
+func TestFitContext(t *testing.T) {
+	cfg, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	cfg.Agents[config.AgentTitle] = config.Agent{Model: models.MockModel, MaxTokens: 100}
+
+	large := strings.Repeat("word ", 2000)
+	history := append(largeHistory(large), message.Message{Role: message.Assistant, Parts: []message.ContentPart{
+		message.ReasoningContent{ResponseID: "resp_1"},
+	}}, message.Message{Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "e", Content: "ok"},
+	}})
+
+	fitted := fitContext(config.AgentTitle, smallModel, history, nil)
+	require.Len(t, fitted, len(history))
+	assert.Equal(t, elidedToolResult, fitted[1].ToolResults()[0].Content)
+	assert.Equal(t, "a", fitted[1].ToolResults()[0].ToolCallID)
+	last := fitted[4].ToolResults()[0].Content
+	assert.Contains(t, last, "tokens elided to fit the context window")
+	assert.Less(t, len(last), len(large)/2)
+	assert.Equal(t, "ok", fitted[6].ToolResults()[0].Content)
+	// The answer was given to the whole history, it can't be continued from
+	assert.Empty(t, fitted[5].ReasoningContent().ResponseID)
+	// The history itself is left whole
+	assert.Equal(t, large, history[1].ToolResults()[0].Content)
+	assert.Equal(t, large, history[4].ToolResults()[0].Content)
+	assert.Equal(t, "resp_1", history[5].ReasoningContent().ResponseID)
+}
+
+// summaryProvider summarizes with a model with a small context window.
+type summaryProvider struct {
+	provider.Provider
+	sent []message.Message
+}
+
+func (p *summaryProvider) Model() models.Model {
+	return smallModel
+}
+
+func (p *summaryProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*provider.ProviderResponse, error) {
+	p.sent = messages
+	return &provider.ProviderResponse{Content: "We read the files."}, nil
+}
+
+// TestSummarizeFitsContext sends the summarizer a history fitted to its own
+// context window.
+func TestSummarizeFitsContext(t *testing.T) {
+	coder, sessions, _ := newCassetteAgent(t, "testdata/summarize.yaml")
+	config.Get().Agents[config.AgentSummarizer] = config.Agent{Model: models.MockModel, MaxTokens: 100}
+	summarizer := &summaryProvider{}
+	coder.(*agent).summarizeProvider = summarizer
+
+	ctx := context.Background()
+	s, err := sessions.Create(ctx, "New Session")
+	require.NoError(t, err)
+	history := largeHistory(strings.Repeat("word ", 2000))
+	_, err = coder.(*agent).summarize(ctx, s.ID, history, func(string) {})
+	require.NoError(t, err)
+	require.Len(t, summarizer.sent, len(history)+1)
+	assert.Equal(t, elidedToolResult, summarizer.sent[1].ToolResults()[0].Content)
+	assert.Equal(t, message.User, summarizer.sent[len(history)].Role)
+}
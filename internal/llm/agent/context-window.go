@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/prompt"
+	"github.com/opencode-ai/opencode/internal/llm/tokenizer"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+const (
+	// summarizeThreshold is the share of the context budget a history
+	// takes before it is summarized.
+	summarizeThreshold = 0.95
+	// maxToolResultShare is the share of the context budget a single tool
+	// result may take.
+	maxToolResultShare = 4
+)
+
+const elidedToolResult = "[Tool result elided to fit the context window]"
+
+// contextBudget returns the tokens the history of a request of an agent may
+// take: the context window of the model, less the answer, the system prompt
+// and the tools. It is zero when the context window of the model is unknown.
+func contextBudget(t tokenizer.Tokenizer, agentName config.AgentName, model models.Model, agentTools []tools.BaseTool) int {
+	if model.ContextWindow <= 0 {
+		return 0
+	}
+	maxTokens := model.DefaultMaxTokens
+	if agentConfig, ok := config.Get().Agents[agentName]; ok && agentConfig.MaxTokens > 0 {
+		maxTokens = agentConfig.MaxTokens
+	}
+	if maxTokens >= model.ContextWindow {
+		maxTokens = model.ContextWindow / 4
+	}
+	budget := int(model.ContextWindow-maxTokens) -
+		t.Count(prompt.GetAgentPrompt(agentName, model.Provider)) -
+		tokenizer.Tools(t, agentTools)
+	return max(budget, 0)
+}
+
+// shouldSummarize reports whether the history of a session and the message
+// about to be sent are too close to the context window of the model, so
+// the history is summarized first.
+func (a *agent) shouldSummarize(msgs []message.Message, content string, attachmentParts []message.ContentPart) bool {
+	if !config.Get().AutoCompact || a.summarizeProvider == nil || len(msgs) == 0 {
+		return false
+	}
+	model := a.provider.Model()
+	t := tokenizer.For(model)
+	budget := contextBudget(t, a.name, model, a.availableTools())
+	if budget == 0 {
+		return false
+	}
+	tokens := tokenizer.Messages(t, msgs) + tokenizer.Message(t, message.Message{
+		Role:  message.User,
+		Parts: append([]message.ContentPart{message.TextContent{Text: content}}, attachmentParts...),
+	})
+	logging.Debug("Estimated history", "tokens", tokens, "budget", budget)
+	return float64(tokens) >= summarizeThreshold*float64(budget)
+}
+
+// fitContext elides tool results from a history of an agent that doesn't fit
+// the context window of its model. Results larger than a share of it are cut in
+// the middle, then the oldest results are left out until the history fits.
+// The stored messages are left whole, and answers after the first changed
+// message lose their response ID, so an OpenAI Responses API request sends
+// the fitted history instead of continuing from the whole one.
+func fitContext(agentName config.AgentName, model models.Model, msgHistory []message.Message, agentTools []tools.BaseTool) []message.Message {
+	t := tokenizer.For(model)
+	budget := contextBudget(t, agentName, model, agentTools)
+	if budget == 0 {
+		return msgHistory
+	}
+
+	fitted := slices.Clone(msgHistory)
+	total := tokenizer.Messages(t, fitted)
+	firstChanged := len(fitted)
+	replace := func(i, j int, result message.ToolResult, tokens int) {
+		// The parts are shared with the stored message
+		fitted[i].Parts = slices.Clone(fitted[i].Parts)
+		fitted[i].Parts[j] = result
+		total -= tokens - tokenizer.ToolResult(t, result)
+		firstChanged = min(firstChanged, i)
+	}
+
+	maxResult := budget / maxToolResultShare
+	for i, msg := range fitted {
+		for j, part := range msg.Parts {
+			result, ok := part.(message.ToolResult)
+			if !ok {
+				continue
+			}
+			if tokens := tokenizer.ToolResult(t, result); tokens > maxResult {
+				replace(i, j, truncateToolResult(result, tokens, maxResult), tokens)
+			}
+		}
+	}
+
+	// The results of the last tool calls are kept, the model asked for them
+	for i := 0; i < len(fitted)-1 && total > budget; i++ {
+		for j, part := range fitted[i].Parts {
+			result, ok := part.(message.ToolResult)
+			if !ok || result.Content == elidedToolResult {
+				continue
+			}
+			tokens := tokenizer.ToolResult(t, result)
+			result.Content = elidedToolResult
+			result.Attachments = nil
+			replace(i, j, result, tokens)
+		}
+	}
+	if total > budget {
+		logging.Warn("History doesn't fit the context window", "tokens", total, "budget", budget)
+	}
+
+	// The previous answers were given to the whole history
+	for i := firstChanged; i < len(fitted); i++ {
+		for j, part := range fitted[i].Parts {
+			if reasoning, ok := part.(message.ReasoningContent); ok && reasoning.ResponseID != "" {
+				reasoning.ResponseID = ""
+				fitted[i].Parts = slices.Clone(fitted[i].Parts)
+				fitted[i].Parts[j] = reasoning
+			}
+		}
+	}
+	return fitted
+}
+
+// truncateToolResult keeps the beginning and the end of the text of a tool
+// result, in about the tokens given.
+func truncateToolResult(result message.ToolResult, tokens, keep int) message.ToolResult {
+	text := result.Text()
+	n := int(float64(len(text)) * float64(keep) / float64(tokens))
+	head, tail := n/2, len(text)-n/2
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	for tail < len(text) && !utf8.RuneStart(text[tail]) {
+		tail++
+	}
+	result.Content = fmt.Sprintf("%s\n\n[... about %d tokens elided to fit the context window ...]\n\n%s", text[:head], tokens-keep, text[tail:])
+	result.Attachments = nil
+	return result
+}
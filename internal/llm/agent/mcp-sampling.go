@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+)
+
+// defaultSamplingSystemPrompt is used when a server doesn't send a system
+// prompt, some providers reject an empty one.
+const defaultSamplingSystemPrompt = "You are a helpful assistant."
+
+// mcpSampling answers the sampling requests of MCP servers with the model of
+// the configured agent, after asking for permission. Every server has a
+// budget of tokens for the lifetime of the app. Permission requests are
+// granted for now, which is why servers only get sampling when their
+// configuration enables it.
+type mcpSampling struct {
+	permissions permission.Service
+
+	mu   sync.Mutex
+	used map[string]int64
+}
+
+// MCPSampling returns the handler answering the sampling requests of MCP
+// servers.
+func MCPSampling(permissions permission.Service) mcpclient.SamplingHandler {
+	s := &mcpSampling{
+		permissions: permissions,
+		used:        make(map[string]int64),
+	}
+	return s.createMessage
+}
+
+func (s *mcpSampling) createMessage(ctx context.Context, serverName string, cfg config.MCPSampling, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	budget := cfg.TokenBudget
+	if budget <= 0 {
+		budget = config.DefaultSamplingTokenBudget
+	}
+	// The answer is reserved from the budget while it is requested, so
+	// concurrent requests of a server can't overspend it
+	s.mu.Lock()
+	remaining := budget - s.used[serverName]
+	if remaining <= 0 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("the sampling budget of %d tokens is used up", budget)
+	}
+	maxTokens := int64(request.Params.MaxTokens)
+	if maxTokens <= 0 || maxTokens > remaining {
+		maxTokens = remaining
+	}
+	s.used[serverName] += maxTokens
+	s.mu.Unlock()
+	// The reservation is replaced by the tokens used, none when it failed
+	var used int64
+	defer func() {
+		s.mu.Lock()
+		s.used[serverName] += used - maxTokens
+		s.mu.Unlock()
+	}()
+
+	messages, err := samplingMessages(request.Params.Messages)
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt := request.Params.SystemPrompt
+	if systemPrompt == "" {
+		systemPrompt = defaultSamplingSystemPrompt
+	}
+
+	agentName := cfg.Agent
+	if agentName == "" {
+		agentName = config.AgentTask
+	}
+	opts := []provider.ProviderClientOption{
+		provider.WithSystemMessage(systemPrompt),
+		provider.WithMaxTokens(maxTokens),
+	}
+	if request.Params.Temperature > 0 {
+		opts = append(opts, provider.WithTemperature(float32(request.Params.Temperature)))
+	}
+	samplingProvider, err := createAgentProvider(agentName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer samplingProvider.Close()
+	model := samplingProvider.Model()
+	if !model.SupportsAttachments {
+		for _, msg := range messages {
+			if len(msg.BinaryContent()) > 0 {
+				return nil, fmt.Errorf("model %s doesn't support images", model.Name)
+			}
+		}
+	}
+
+	granted := s.permissions.Request(permission.CreatePermissionRequest{
+		ToolName: fmt.Sprintf("%s_sampling", serverName),
+		Action:   "sample",
+		Description: fmt.Sprintf(
+			"The %s MCP server asks %s for up to %d tokens (%d of %d budget tokens left).\n\n%s",
+			serverName, model.Name, maxTokens, remaining, budget, samplingPrompt(systemPrompt, messages),
+		),
+		Params: request.Params,
+		Path:   config.WorkingDirectory(),
+	})
+	if !granted {
+		return nil, errors.New("the user rejected the sampling request")
+	}
+
+	response, err := samplingProvider.SendMessages(ctx, messages, make([]tools.BaseTool, 0))
+	if err != nil {
+		return nil, err
+	}
+	used = response.Usage.InputTokens + response.Usage.OutputTokens
+	logging.Info("Answered MCP sampling request", "server", serverName, "model", model.ID, "input_tokens", response.Usage.InputTokens, "output_tokens", response.Usage.OutputTokens)
+
+	result := &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(response.Content),
+		},
+		Model:      string(model.ID),
+		StopReason: string(response.FinishReason),
+	}
+	switch response.FinishReason {
+	case message.FinishReasonEndTurn:
+		result.StopReason = "endTurn"
+	case message.FinishReasonMaxTokens:
+		result.StopReason = "maxTokens"
+	}
+	return result, nil
+}
+
+// samplingMessages converts the messages of a sampling request, which hold
+// either text or an image.
+func samplingMessages(in []mcp.SamplingMessage) ([]message.Message, error) {
+	out := make([]message.Message, 0, len(in))
+	for _, msg := range in {
+		data, err := json.Marshal(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		var content struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Data     string `json:"data"`
+			MIMEType string `json:"mimeType"`
+		}
+		if err := json.Unmarshal(data, &content); err != nil {
+			return nil, fmt.Errorf("invalid sampling message: %w", err)
+		}
+
+		var part message.ContentPart
+		switch content.Type {
+		case "text":
+			part = message.TextContent{Text: content.Text}
+		case "image":
+			decoded, err := base64.StdEncoding.DecodeString(content.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image data: %w", err)
+			}
+			part = message.BinaryContent{Path: "image", MIMEType: content.MIMEType, Data: decoded}
+		default:
+			return nil, fmt.Errorf("unsupported sampling content type %q", content.Type)
+		}
+
+		role := message.User
+		if msg.Role == mcp.RoleAssistant {
+			role = message.Assistant
+		}
+		out = append(out, message.Message{Role: role, Parts: []message.ContentPart{part}})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("sampling request has no messages")
+	}
+	return out, nil
+}
+
+// samplingPrompt renders a sampling request for the permission dialog.
+func samplingPrompt(systemPrompt string, messages []message.Message) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "System: %s\n", systemPrompt)
+	for _, msg := range messages {
+		text := msg.Content().String()
+		if text == "" {
+			text = "[image]"
+		}
+		fmt.Fprintf(&sb, "\n%s: %s\n", msg.Role, text)
+	}
+	return sb.String()
+}
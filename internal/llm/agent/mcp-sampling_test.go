@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"maps"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// answeringPermissions answers every request with grant and records it,
+// after calling asking if set.
+type answeringPermissions struct {
+	permission.Service
+	grant    bool
+	requests []permission.CreatePermissionRequest
+	asking   func()
+}
+
+func (p *answeringPermissions) Request(opts permission.CreatePermissionRequest) bool {
+	p.requests = append(p.requests, opts)
+	if p.asking != nil {
+		p.asking()
+	}
+	return p.grant
+}
+
+func samplingRequest(maxTokens int, contents ...any) mcp.CreateMessageRequest {
+	var request mcp.CreateMessageRequest
+	request.Params.MaxTokens = maxTokens
+	for _, content := range contents {
+		request.Params.Messages = append(request.Params.Messages, mcp.SamplingMessage{Role: mcp.RoleUser, Content: content})
+	}
+	return request
+}
+
+func TestSamplingMessages(t *testing.T) {
+	image := []byte{0x89, 'P', 'N', 'G'}
+	msgs, err := samplingMessages([]mcp.SamplingMessage{
+		{Role: mcp.RoleUser, Content: mcp.NewTextContent("describe this")},
+		{Role: mcp.RoleUser, Content: mcp.NewImageContent(base64.StdEncoding.EncodeToString(image), "image/png")},
+		{Role: mcp.RoleAssistant, Content: mcp.NewTextContent("a logo")},
+	})
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	assert.Equal(t, message.User, msgs[0].Role)
+	assert.Equal(t, "describe this", msgs[0].Content().String())
+	require.Len(t, msgs[1].BinaryContent(), 1)
+	assert.Equal(t, image, msgs[1].BinaryContent()[0].Data)
+	assert.Equal(t, "image/png", msgs[1].BinaryContent()[0].MIMEType)
+	assert.Equal(t, message.Assistant, msgs[2].Role)
+
+	_, err = samplingMessages(nil)
+	assert.ErrorContains(t, err, "no messages")
+	_, err = samplingMessages([]mcp.SamplingMessage{{Role: mcp.RoleUser, Content: mcp.NewImageContent("not base64!", "image/png")}})
+	assert.ErrorContains(t, err, "invalid image data")
+	_, err = samplingMessages([]mcp.SamplingMessage{{Role: mcp.RoleUser, Content: map[string]any{"type": "audio"}}})
+	assert.ErrorContains(t, err, `unsupported sampling content type "audio"`)
+}
+
+func TestCreateMessage(t *testing.T) {
+	t.Setenv(provider.CassetteEnv, "testdata/sampling.yaml")
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	cfg.Providers[models.ProviderMock] = config.Provider{APIKey: "cassette"}
+	cfg.Agents[config.AgentTask] = config.Agent{Model: models.MockModel, MaxTokens: 1000}
+
+	ctx := context.Background()
+	samplingCfg := config.MCPSampling{TokenBudget: 50}
+	permissions := &answeringPermissions{grant: true}
+	s := &mcpSampling{permissions: permissions, used: make(map[string]int64)}
+
+	// The budget is reserved for the request in flight
+	permissions.asking = func() {
+		_, err := s.createMessage(ctx, "docs", samplingCfg, samplingRequest(100, mcp.NewTextContent("Meanwhile")))
+		assert.ErrorContains(t, err, "budget of 50 tokens is used up")
+	}
+	result, err := s.createMessage(ctx, "docs", samplingCfg, samplingRequest(100, mcp.NewTextContent("Summarize the readme")))
+	require.NoError(t, err)
+	permissions.asking = nil
+	assert.Equal(t, "A short summary.", result.Content.(mcp.TextContent).Text)
+	assert.Equal(t, "endTurn", result.StopReason)
+	require.Len(t, permissions.requests, 1)
+	assert.Equal(t, "docs_sampling", permissions.requests[0].ToolName)
+	assert.Contains(t, permissions.requests[0].Description, "up to 50 tokens")
+	assert.Contains(t, permissions.requests[0].Description, "Summarize the readme")
+
+	// The tokens used are taken from the budget of the server
+	_, err = s.createMessage(ctx, "docs", samplingCfg, samplingRequest(100, mcp.NewTextContent("Again")))
+	require.NoError(t, err)
+	assert.Contains(t, permissions.requests[1].Description, "up to 10 tokens (10 of 50 budget tokens left)")
+	_, err = s.createMessage(ctx, "docs", samplingCfg, samplingRequest(100, mcp.NewTextContent("Once more")))
+	assert.ErrorContains(t, err, "budget of 50 tokens is used up")
+	assert.Len(t, permissions.requests, 2)
+
+	// Other servers have their own budget, and may be turned down
+	permissions.grant = false
+	_, err = s.createMessage(ctx, "other", samplingCfg, samplingRequest(100, mcp.NewTextContent("Summarize")))
+	assert.ErrorContains(t, err, "rejected")
+	assert.Zero(t, s.used["other"])
+
+	// Images need a model that takes attachments
+	saved := maps.Clone(models.SupportedModels)
+	t.Cleanup(func() { models.SupportedModels = saved })
+	textOnly := models.SupportedModels[models.MockModel]
+	textOnly.SupportsAttachments = false
+	models.SupportedModels[models.MockModel] = textOnly
+	permissions.grant = true
+	_, err = s.createMessage(ctx, "other", samplingCfg, samplingRequest(100, mcp.NewImageContent(base64.StdEncoding.EncodeToString([]byte("png")), "image/png")))
+	assert.ErrorContains(t, err, "doesn't support images")
+	assert.Len(t, permissions.requests, 3)
+}
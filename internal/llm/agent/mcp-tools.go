@@ -2,36 +2,27 @@ package agent
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
-	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
+	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/permission"
-	"github.com/opencode-ai/opencode/internal/version"
 
-	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 type mcpTool struct {
 	mcpName     string
 	tool        mcp.Tool
-	mcpConfig   config.MCPServer
+	manager     *mcpclient.Manager
 	permissions permission.Service
 }
 
-type MCPClient interface {
-	Initialize(
-		ctx context.Context,
-		request mcp.InitializeRequest,
-	) (*mcp.InitializeResult, error)
-	ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error)
-	CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
-	Close() error
-}
-
 func (b *mcpTool) Info() tools.ToolInfo {
 	return tools.ToolInfo{
 		Name:        fmt.Sprintf("%s_%s", b.mcpName, b.tool.Name),
@@ -41,44 +32,6 @@ func (b *mcpTool) Info() tools.ToolInfo {
 	}
 }
 
-func runTool(ctx context.Context, c MCPClient, toolName string, input string) (tools.ToolResponse, error) {
-	defer c.Close()
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{
-		Name:    "OpenCode",
-		Version: version.Version,
-	}
-
-	_, err := c.Initialize(ctx, initRequest)
-	if err != nil {
-		return tools.NewTextErrorResponse(err.Error()), nil
-	}
-
-	toolRequest := mcp.CallToolRequest{}
-	toolRequest.Params.Name = toolName
-	var args map[string]any
-	if err = json.Unmarshal([]byte(input), &args); err != nil {
-		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
-	}
-	toolRequest.Params.Arguments = args
-	result, err := c.CallTool(ctx, toolRequest)
-	if err != nil {
-		return tools.NewTextErrorResponse(err.Error()), nil
-	}
-
-	output := ""
-	for _, v := range result.Content {
-		if v, ok := v.(mcp.TextContent); ok {
-			output = v.Text
-		} else {
-			output = fmt.Sprintf("%v", v)
-		}
-	}
-
-	return tools.NewTextResponse(output), nil
-}
-
 func (b *mcpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolResponse, error) {
 	sessionID, messageID := tools.GetContextValues(ctx)
 	if sessionID == "" || messageID == "" {
@@ -99,99 +52,102 @@ func (b *mcpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolRes
 		return tools.NewTextErrorResponse("permission denied"), nil
 	}
 
-	switch b.mcpConfig.Type {
-	case config.MCPStdio:
-		c, err := client.NewStdioMCPClient(
-			b.mcpConfig.Command,
-			b.mcpConfig.Env,
-			b.mcpConfig.Args...,
-		)
-		if err != nil {
-			return tools.NewTextErrorResponse(err.Error()), nil
-		}
-		return runTool(ctx, c, b.tool.Name, params.Input)
-	case config.MCPSse:
-		c, err := client.NewSSEMCPClient(
-			b.mcpConfig.URL,
-			client.WithHeaders(b.mcpConfig.Headers),
-		)
-		if err != nil {
-			return tools.NewTextErrorResponse(err.Error()), nil
-		}
-		return runTool(ctx, c, b.tool.Name, params.Input)
+	var args map[string]any
+	if err := json.Unmarshal([]byte(params.Input), &args); err != nil {
+		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	result, err := b.manager.CallTool(ctx, b.mcpName, b.tool.Name, args)
+	if err != nil {
+		return tools.NewTextErrorResponse(err.Error()), nil
 	}
 
-	return tools.NewTextErrorResponse("invalid mcp type"), nil
+	return toolResponse(b.Info().Name, result), nil
 }
 
-func NewMcpTool(name string, tool mcp.Tool, permissions permission.Service, mcpConfig config.MCPServer) tools.BaseTool {
-	return &mcpTool{
-		mcpName:     name,
-		tool:        tool,
-		mcpConfig:   mcpConfig,
-		permissions: permissions,
+// toolResponse converts the result of an MCP tool. Text is joined into the
+// content, images and embedded text resources become attachments the model
+// and the TUI can show.
+func toolResponse(toolName string, result *mcp.CallToolResult) tools.ToolResponse {
+	var texts []string
+	var attachments []message.BinaryContent
+	for _, content := range result.Content {
+		switch content := content.(type) {
+		case mcp.TextContent:
+			texts = append(texts, content.Text)
+		case mcp.ImageContent:
+			attachment, err := imageAttachment(toolName, content.MIMEType, content.Data)
+			if err != nil {
+				texts = append(texts, fmt.Sprintf("[image omitted: %s]", err))
+				continue
+			}
+			attachments = append(attachments, attachment)
+		case mcp.EmbeddedResource:
+			switch resource := content.Resource.(type) {
+			case mcp.TextResourceContents:
+				mimeType := resource.MIMEType
+				if !message.IsTextMIMEType(mimeType) {
+					mimeType = "text/plain"
+				}
+				attachments = append(attachments, message.BinaryContent{
+					Path:     resource.URI,
+					MIMEType: mimeType,
+					Data:     []byte(resource.Text),
+				})
+			case mcp.BlobResourceContents:
+				attachment, err := imageAttachment(resource.URI, resource.MIMEType, resource.Blob)
+				if err != nil {
+					texts = append(texts, fmt.Sprintf("[resource %s omitted: %s]", resource.URI, err))
+					continue
+				}
+				attachments = append(attachments, attachment)
+			}
+		default:
+			texts = append(texts, fmt.Sprintf("%v", content))
+		}
 	}
-}
 
-var mcpTools []tools.BaseTool
-
-func getTools(ctx context.Context, name string, m config.MCPServer, permissions permission.Service, c MCPClient) []tools.BaseTool {
-	var stdioTools []tools.BaseTool
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{
-		Name:    "OpenCode",
-		Version: version.Version,
+	response := tools.NewTextResponse(strings.Join(texts, "\n"))
+	response.IsError = result.IsError
+	response.Attachments = attachments
+	for _, attachment := range attachments {
+		if !attachment.IsText() {
+			response.Type = tools.ToolResponseTypeImage
+		}
 	}
+	return response
+}
 
-	_, err := c.Initialize(ctx, initRequest)
-	if err != nil {
-		logging.Error("error initializing mcp client", "error", err)
-		return stdioTools
+// imageAttachment decodes base64 image data. Other binary data can't be
+// shown to a model and is rejected.
+func imageAttachment(path, mimeType, data string) (message.BinaryContent, error) {
+	if !strings.HasPrefix(mimeType, "image/") {
+		return message.BinaryContent{}, fmt.Errorf("unsupported content type %q", mimeType)
 	}
-	toolsRequest := mcp.ListToolsRequest{}
-	tools, err := c.ListTools(ctx, toolsRequest)
+	decoded, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
-		logging.Error("error listing tools", "error", err)
-		return stdioTools
-	}
-	for _, t := range tools.Tools {
-		stdioTools = append(stdioTools, NewMcpTool(name, t, permissions, m))
+		return message.BinaryContent{}, fmt.Errorf("invalid image data: %w", err)
 	}
-	defer c.Close()
-	return stdioTools
+	return message.BinaryContent{Path: path, MIMEType: mimeType, Data: decoded}, nil
 }
 
-func GetMcpTools(ctx context.Context, permissions permission.Service) []tools.BaseTool {
-	if len(mcpTools) > 0 {
-		return mcpTools
+func NewMcpTool(name string, tool mcp.Tool, manager *mcpclient.Manager, permissions permission.Service) tools.BaseTool {
+	return &mcpTool{
+		mcpName:     name,
+		tool:        tool,
+		manager:     manager,
+		permissions: permissions,
 	}
-	for name, m := range config.Get().MCPServers {
-		switch m.Type {
-		case config.MCPStdio:
-			c, err := client.NewStdioMCPClient(
-				m.Command,
-				m.Env,
-				m.Args...,
-			)
-			if err != nil {
-				logging.Error("error creating mcp client", "error", err)
-				continue
-			}
+}
 
-			mcpTools = append(mcpTools, getTools(ctx, name, m, permissions, c)...)
-		case config.MCPSse:
-			c, err := client.NewSSEMCPClient(
-				m.URL,
-				client.WithHeaders(m.Headers),
-			)
-			if err != nil {
-				logging.Error("error creating mcp client", "error", err)
-				continue
-			}
-			mcpTools = append(mcpTools, getTools(ctx, name, m, permissions, c)...)
+// MCPTools returns a function listing the tools of the connected MCP
+// servers. The agent calls it for every request, so it sees servers that
+// reconnect and tool lists that change at runtime.
+func MCPTools(manager *mcpclient.Manager, permissions permission.Service) func() []tools.BaseTool {
+	return func() []tools.BaseTool {
+		var mcpTools []tools.BaseTool
+		for _, t := range manager.Tools() {
+			mcpTools = append(mcpTools, NewMcpTool(t.Server, t.Tool, manager, permissions))
 		}
+		return mcpTools
 	}
-
-	return mcpTools
 }
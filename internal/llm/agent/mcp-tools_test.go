@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A 1x1 PNG.
+const pixel = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII="
+
+func TestToolResponse(t *testing.T) {
+	response := toolResponse("shots_take", &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent("first"),
+			mcp.NewImageContent(pixel, "image/png"),
+			mcp.EmbeddedResource{Type: "resource", Resource: mcp.TextResourceContents{URI: "file:///notes.txt", Text: "notes"}},
+			mcp.EmbeddedResource{Type: "resource", Resource: mcp.BlobResourceContents{URI: "file:///a.zip", MIMEType: "application/zip", Blob: "UEs="}},
+			mcp.NewTextContent("second"),
+		},
+	})
+
+	assert.Equal(t, tools.ToolResponseTypeImage, response.Type)
+	assert.Equal(t, "first\n[resource file:///a.zip omitted: unsupported content type \"application/zip\"]\nsecond", response.Content)
+	require.Len(t, response.Attachments, 2)
+	assert.Equal(t, "image/png", response.Attachments[0].MIMEType)
+	assert.Equal(t, "shots_take", response.Attachments[0].Path)
+	assert.NotEmpty(t, response.Attachments[0].Data)
+	assert.Equal(t, "file:///notes.txt", response.Attachments[1].Path)
+	assert.Equal(t, "text/plain", response.Attachments[1].MIMEType)
+	assert.Equal(t, "notes", string(response.Attachments[1].Data))
+
+	response = toolResponse("shots_take", &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.NewTextContent("boom")},
+		IsError: true,
+	})
+	assert.True(t, response.IsError)
+	assert.Equal(t, tools.ToolResponseTypeText, response.Type)
+}
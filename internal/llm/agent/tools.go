@@ -1,8 +1,6 @@
 package agent
 
 import (
-	"context"
-
 	"github.com/opencode-ai/opencode/internal/history"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
 	"github.com/opencode-ai/opencode/internal/lsp"
@@ -18,8 +16,7 @@ func CoderAgentTools(
 	history history.Service,
 	lspClients map[string]*lsp.Client,
 ) []tools.BaseTool {
-	ctx := context.Background()
-	otherTools := GetMcpTools(ctx, permissions)
+	var otherTools []tools.BaseTool
 	if len(lspClients) > 0 {
 		otherTools = append(otherTools, tools.NewDiagnosticsTool(lspClients))
 	}
@@ -40,6 +37,25 @@ func CoderAgentTools(
 	)
 }
 
+// MCPServerTools returns the built-in tools published by `opencode mcp serve`.
+func MCPServerTools(
+	permissions permission.Service,
+	history history.Service,
+	lspClients map[string]*lsp.Client,
+) []tools.BaseTool {
+	return []tools.BaseTool{
+		tools.NewViewTool(lspClients),
+		tools.NewGrepTool(),
+		tools.NewGlobTool(),
+		tools.NewLsTool(),
+		tools.NewEditTool(lspClients, permissions, history),
+		tools.NewWriteTool(lspClients, permissions, history),
+		tools.NewPatchTool(lspClients, permissions, history),
+		tools.NewBashTool(permissions),
+		tools.NewDiagnosticsTool(lspClients),
+	}
+}
+
 func TaskAgentTools(lspClients map[string]*lsp.Client) []tools.BaseTool {
 	return []tools.BaseTool{
 		tools.NewGlobTool(),
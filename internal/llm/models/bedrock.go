@@ -0,0 +1,147 @@
+package models
+
+const (
+	ProviderBedrock ModelProvider = "bedrock"
+
+	// Models
+	BedrockClaude37Sonnet ModelID = "bedrock.claude-3.7-sonnet"
+	BedrockClaude35Haiku  ModelID = "bedrock.claude-3.5-haiku"
+	BedrockClaude4Sonnet  ModelID = "bedrock.claude-4-sonnet"
+	BedrockClaude4Opus    ModelID = "bedrock.claude-4-opus"
+	BedrockLlama4Maverick ModelID = "bedrock.llama-4-maverick"
+	BedrockLlama33_70B    ModelID = "bedrock.llama-3.3-70b"
+	BedrockPixtralLarge   ModelID = "bedrock.pixtral-large"
+	BedrockNovaPro        ModelID = "bedrock.nova-pro"
+	BedrockNovaLite       ModelID = "bedrock.nova-lite"
+	BedrockNovaMicro      ModelID = "bedrock.nova-micro"
+)
+
+// BedrockModels are called through the Converse API. The API model is the
+// ID of the model, Bedrock calls it through the cross-region inference
+// profile of the provider. Models with a cache read price cache prompts.
+//
+// https://docs.aws.amazon.com/bedrock/latest/userguide/conversation-inference-supported-models-features.html
+var BedrockModels = map[ModelID]Model{
+	BedrockClaude37Sonnet: {
+		ID:                  BedrockClaude37Sonnet,
+		Name:                "Bedrock: Claude 3.7 Sonnet",
+		Provider:            ProviderBedrock,
+		APIModel:            "anthropic.claude-3-7-sonnet-20250219-v1:0",
+		CostPer1MIn:         3.0,
+		CostPer1MInCached:   3.75,
+		CostPer1MOutCached:  0.30,
+		CostPer1MOut:        15.0,
+		ContextWindow:       200000,
+		DefaultMaxTokens:    50000,
+		CanReason:           true,
+		SupportsAttachments: true,
+	},
+	BedrockClaude35Haiku: {
+		ID:                  BedrockClaude35Haiku,
+		Name:                "Bedrock: Claude 3.5 Haiku",
+		Provider:            ProviderBedrock,
+		APIModel:            "anthropic.claude-3-5-haiku-20241022-v1:0",
+		CostPer1MIn:         0.80,
+		CostPer1MInCached:   1.0,
+		CostPer1MOutCached:  0.08,
+		CostPer1MOut:        4.0,
+		ContextWindow:       200000,
+		DefaultMaxTokens:    4096,
+		SupportsAttachments: true,
+	},
+	BedrockClaude4Sonnet: {
+		ID:                  BedrockClaude4Sonnet,
+		Name:                "Bedrock: Claude 4 Sonnet",
+		Provider:            ProviderBedrock,
+		APIModel:            "anthropic.claude-sonnet-4-20250514-v1:0",
+		CostPer1MIn:         3.0,
+		CostPer1MInCached:   3.75,
+		CostPer1MOutCached:  0.30,
+		CostPer1MOut:        15.0,
+		ContextWindow:       200000,
+		DefaultMaxTokens:    50000,
+		CanReason:           true,
+		SupportsAttachments: true,
+	},
+	BedrockClaude4Opus: {
+		ID:                  BedrockClaude4Opus,
+		Name:                "Bedrock: Claude 4 Opus",
+		Provider:            ProviderBedrock,
+		APIModel:            "anthropic.claude-opus-4-20250514-v1:0",
+		CostPer1MIn:         15.0,
+		CostPer1MInCached:   18.75,
+		CostPer1MOutCached:  1.50,
+		CostPer1MOut:        75.0,
+		ContextWindow:       200000,
+		DefaultMaxTokens:    4096,
+		CanReason:           true,
+		SupportsAttachments: true,
+	},
+	BedrockLlama4Maverick: {
+		ID:                  BedrockLlama4Maverick,
+		Name:                "Bedrock: Llama 4 Maverick",
+		Provider:            ProviderBedrock,
+		APIModel:            "meta.llama4-maverick-17b-instruct-v1:0",
+		CostPer1MIn:         0.24,
+		CostPer1MOut:        0.97,
+		ContextWindow:       1_000_000,
+		DefaultMaxTokens:    8192,
+		SupportsAttachments: true,
+	},
+	BedrockLlama33_70B: {
+		ID:               BedrockLlama33_70B,
+		Name:             "Bedrock: Llama 3.3 70B",
+		Provider:         ProviderBedrock,
+		APIModel:         "meta.llama3-3-70b-instruct-v1:0",
+		CostPer1MIn:      0.72,
+		CostPer1MOut:     0.72,
+		ContextWindow:    128_000,
+		DefaultMaxTokens: 8192,
+	},
+	BedrockPixtralLarge: {
+		ID:                  BedrockPixtralLarge,
+		Name:                "Bedrock: Pixtral Large",
+		Provider:            ProviderBedrock,
+		APIModel:            "mistral.pixtral-large-2502-v1:0",
+		CostPer1MIn:         2.0,
+		CostPer1MOut:        6.0,
+		ContextWindow:       128_000,
+		DefaultMaxTokens:    8192,
+		SupportsAttachments: true,
+	},
+	BedrockNovaPro: {
+		ID:                  BedrockNovaPro,
+		Name:                "Bedrock: Nova Pro",
+		Provider:            ProviderBedrock,
+		APIModel:            "amazon.nova-pro-v1:0",
+		CostPer1MIn:         0.80,
+		CostPer1MOutCached:  0.20,
+		CostPer1MOut:        3.20,
+		ContextWindow:       300_000,
+		DefaultMaxTokens:    10_000,
+		SupportsAttachments: true,
+	},
+	BedrockNovaLite: {
+		ID:                  BedrockNovaLite,
+		Name:                "Bedrock: Nova Lite",
+		Provider:            ProviderBedrock,
+		APIModel:            "amazon.nova-lite-v1:0",
+		CostPer1MIn:         0.06,
+		CostPer1MOutCached:  0.015,
+		CostPer1MOut:        0.24,
+		ContextWindow:       300_000,
+		DefaultMaxTokens:    10_000,
+		SupportsAttachments: true,
+	},
+	BedrockNovaMicro: {
+		ID:                 BedrockNovaMicro,
+		Name:               "Bedrock: Nova Micro",
+		Provider:           ProviderBedrock,
+		APIModel:           "amazon.nova-micro-v1:0",
+		CostPer1MIn:        0.035,
+		CostPer1MOutCached: 0.00875,
+		CostPer1MOut:       0.14,
+		ContextWindow:      128_000,
+		DefaultMaxTokens:   10_000,
+	},
+}
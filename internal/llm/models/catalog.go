@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+// catalog is a file of models that adds to the supported models or
+// overrides them. Entries are models in their JSON form, an entry with the
+// ID of a known model only changes the fields it sets.
+type catalog struct {
+	Models []json.RawMessage `json:"models"`
+}
+
+// openRouterCatalog is the list of models of the OpenRouter API, prices are
+// in dollars per token.
+type openRouterCatalog struct {
+	Data []struct {
+		ID            string `json:"id"`
+		Name          string `json:"name"`
+		ContextLength int64  `json:"context_length"`
+		Pricing       struct {
+			Prompt          string `json:"prompt"`
+			Completion      string `json:"completion"`
+			InputCacheRead  string `json:"input_cache_read"`
+			InputCacheWrite string `json:"input_cache_write"`
+		} `json:"pricing"`
+		TopProvider struct {
+			MaxCompletionTokens int64 `json:"max_completion_tokens"`
+		} `json:"top_provider"`
+		Architecture struct {
+			InputModalities []string `json:"input_modalities"`
+		} `json:"architecture"`
+		SupportedParameters []string `json:"supported_parameters"`
+	} `json:"data"`
+}
+
+// ParseCatalog reads a catalog, in its own format or in the one of the
+// OpenRouter models API, into the models it adds or overrides. Overrides
+// are merged into the supported model they name.
+func ParseCatalog(data []byte) ([]Model, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+	if _, ok := probe["data"]; ok {
+		return parseOpenRouterCatalog(data)
+	}
+
+	var c catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+	parsed := make([]Model, 0, len(c.Models))
+	for i, raw := range c.Models {
+		var entry struct {
+			ID ModelID `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, fmt.Errorf("invalid catalog model %d: %w", i, err)
+		}
+		if entry.ID == "" {
+			return nil, fmt.Errorf("catalog model %d has no id", i)
+		}
+		model, known := SupportedModels[entry.ID]
+		if err := json.Unmarshal(raw, &model); err != nil {
+			return nil, fmt.Errorf("invalid catalog model %s: %w", entry.ID, err)
+		}
+		if !known && (model.Provider == "" || model.APIModel == "") {
+			return nil, fmt.Errorf("catalog model %s needs a provider and an api_model", entry.ID)
+		}
+		parsed = append(parsed, model)
+	}
+	return parsed, nil
+}
+
+func parseOpenRouterCatalog(data []byte) ([]Model, error) {
+	var c openRouterCatalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid OpenRouter catalog: %w", err)
+	}
+	byAPIModel := make(map[string]Model)
+	for _, m := range SupportedModels {
+		if m.Provider == ProviderOpenRouter {
+			byAPIModel[m.APIModel] = m
+		}
+	}
+
+	var parsed []Model
+	for _, entry := range c.Data {
+		costIn, okIn := perMillion(entry.Pricing.Prompt)
+		costOut, okOut := perMillion(entry.Pricing.Completion)
+		if entry.ID == "" || !okIn || !okOut {
+			// Routers to other models have no price of their own
+			continue
+		}
+		cacheWrite, _ := perMillion(entry.Pricing.InputCacheWrite)
+		cacheRead, _ := perMillion(entry.Pricing.InputCacheRead)
+
+		// Curated models keep their ID and only get the current prices
+		model, known := byAPIModel[entry.ID]
+		if !known {
+			model = Model{
+				ID:                  ModelID("openrouter." + entry.ID),
+				Name:                "OpenRouter – " + entry.Name,
+				Provider:            ProviderOpenRouter,
+				APIModel:            entry.ID,
+				CanReason:           slices.Contains(entry.SupportedParameters, "reasoning"),
+				SupportsAttachments: slices.Contains(entry.Architecture.InputModalities, "image"),
+				NoTools:             !slices.Contains(entry.SupportedParameters, "tools"),
+			}
+		}
+		model.CostPer1MIn = costIn
+		model.CostPer1MOut = costOut
+		model.CostPer1MInCached = cacheWrite
+		model.CostPer1MOutCached = cacheRead
+		if entry.ContextLength > 0 {
+			model.ContextWindow = entry.ContextLength
+		}
+		if !known {
+			model.DefaultMaxTokens = model.ContextWindow / 4
+			if limit := entry.TopProvider.MaxCompletionTokens; limit > 0 {
+				model.DefaultMaxTokens = min(model.DefaultMaxTokens, limit)
+			}
+		}
+		parsed = append(parsed, model)
+	}
+	return parsed, nil
+}
+
+// perMillion turns a price per token of the OpenRouter API into a price per
+// million tokens. It reports false for missing and negative prices.
+func perMillion(price string) (float64, bool) {
+	if price == "" {
+		return 0, false
+	}
+	cost, err := strconv.ParseFloat(price, 64)
+	if err != nil || cost < 0 {
+		return 0, false
+	}
+	return cost * 1e6, true
+}
+
+// LoadCatalog adds the models of the catalog file at path to the supported
+// models and returns them. A missing file is an empty catalog.
+func LoadCatalog(path string) ([]Model, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := ParseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, model := range loaded {
+		SupportedModels[model.ID] = model
+	}
+	return loaded, nil
+}
+
+// FetchCatalog downloads the catalog at url to path. The file is only
+// replaced once the catalog parses.
+func FetchCatalog(ctx context.Context, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if _, err := ParseCatalog(data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	saved := maps.Clone(SupportedModels)
+	t.Cleanup(func() { SupportedModels = saved })
+
+	path := filepath.Join(t.TempDir(), "models.json")
+	loaded, err := LoadCatalog(path)
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"models":[
+		{"id":"claude-4-sonnet","cost_per_1m_in":2.5},
+		{"id":"openai.gpt-5-mini","name":"GPT 5 mini","provider":"openai","api_model":"gpt-5-mini","context_window":400000,"can_reason":true}
+	]}`), 0o644))
+	loaded, err = LoadCatalog(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	// Overrides only change the fields they set
+	sonnet := SupportedModels[Claude4Sonnet]
+	assert.Equal(t, 2.5, sonnet.CostPer1MIn)
+	assert.Equal(t, saved[Claude4Sonnet].CostPer1MOut, sonnet.CostPer1MOut)
+	assert.Equal(t, saved[Claude4Sonnet].ContextWindow, sonnet.ContextWindow)
+
+	gpt := SupportedModels["openai.gpt-5-mini"]
+	assert.Equal(t, ProviderOpenAI, gpt.Provider)
+	assert.Equal(t, int64(400000), gpt.ContextWindow)
+	assert.True(t, gpt.CanReason)
+
+	_, err = ParseCatalog([]byte(`{"models":[{"id":"unknown"}]}`))
+	assert.ErrorContains(t, err, "needs a provider")
+	_, err = ParseCatalog([]byte(`{"models":[{"name":"no id"}]}`))
+	assert.ErrorContains(t, err, "has no id")
+}
+
+func TestFetchOpenRouterCatalog(t *testing.T) {
+	saved := maps.Clone(SupportedModels)
+	t.Cleanup(func() { SupportedModels = saved })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[
+			{"id":"anthropic/claude-3.7-sonnet","name":"Anthropic: Claude 3.7 Sonnet","context_length":200000,
+			 "pricing":{"prompt":"0.000003","completion":"0.000015","input_cache_read":"0.0000003","input_cache_write":"0.00000375"}},
+			{"id":"qwen/qwen3-coder","name":"Qwen: Qwen3 Coder","context_length":262144,
+			 "pricing":{"prompt":"0.0000002","completion":"0.0000008"},
+			 "top_provider":{"max_completion_tokens":32768},
+			 "architecture":{"input_modalities":["text"]},
+			 "supported_parameters":["tools","temperature"]},
+			{"id":"openrouter/auto","name":"Auto Router","pricing":{"prompt":"-1","completion":"-1"}}
+		]}`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "models.json")
+	require.NoError(t, FetchCatalog(t.Context(), srv.URL, path))
+	loaded, err := LoadCatalog(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	// Curated models keep their ID
+	sonnet := SupportedModels[OpenRouterClaude37Sonnet]
+	assert.InDelta(t, 3.0, sonnet.CostPer1MIn, 1e-9)
+	assert.InDelta(t, 3.75, sonnet.CostPer1MInCached, 1e-9)
+	assert.InDelta(t, 0.3, sonnet.CostPer1MOutCached, 1e-9)
+	assert.Equal(t, saved[OpenRouterClaude37Sonnet].Name, sonnet.Name)
+
+	qwen := SupportedModels["openrouter.qwen/qwen3-coder"]
+	assert.Equal(t, "qwen/qwen3-coder", qwen.APIModel)
+	assert.Equal(t, int64(32768), qwen.DefaultMaxTokens)
+	assert.False(t, qwen.NoTools)
+	assert.False(t, qwen.CanReason)
+	assert.False(t, qwen.SupportsAttachments)
+
+	// Invalid catalogs don't replace the file
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `not json`)
+	})
+	assert.Error(t, FetchCatalog(t.Context(), srv.URL, path))
+	_, err = LoadCatalog(path)
+	assert.NoError(t, err)
+}
@@ -17,8 +17,8 @@ var GeminiModels = map[ModelID]Model{
 		Provider:              ProviderGemini,
 		APIModel:              "gemini-2.5-flash-preview-05-20",
 		CostPer1MIn:           0.15,
-		CostPer1MInCached:     0.0375,
-		CostPer1MOutCached:    0,
+		CostPer1MInCached:     0,
+		CostPer1MOutCached:    0.0375,
 		CostPer1MOut:          0.60,
 		ContextWindow:         1000000,
 		DefaultMaxTokens:      50000,
@@ -33,8 +33,8 @@ var GeminiModels = map[ModelID]Model{
 		Provider:            ProviderGemini,
 		APIModel:            "gemini-2.5-pro-preview-06-05",
 		CostPer1MIn:         1.25,
-		CostPer1MInCached:   0.31,
-		CostPer1MOutCached:  0,
+		CostPer1MInCached:   0,
+		CostPer1MOutCached:  0.31,
 		CostPer1MOut:        10,
 		ContextWindow:       1000000,
 		DefaultMaxTokens:    50000,
@@ -48,7 +48,7 @@ var GeminiModels = map[ModelID]Model{
 		APIModel:            "gemini-2.0-flash",
 		CostPer1MIn:         0.10,
 		CostPer1MInCached:   0,
-		CostPer1MOutCached:  0,
+		CostPer1MOutCached:  0.025,
 		CostPer1MOut:        0.40,
 		ContextWindow:       1000000,
 		DefaultMaxTokens:    6000,
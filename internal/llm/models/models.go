@@ -1,6 +1,10 @@
 package models
 
-import "maps"
+import (
+	"maps"
+	"os"
+	"testing"
+)
 
 type (
 	ModelID       string
@@ -22,16 +26,20 @@ type Model struct {
 	MaxThinkingBudget     int64         `json:"max_thinking_budget,omitempty"`
 	DefaultThinkingBudget int64         `json:"default_thinking_budget,omitempty"`
 	SupportsAttachments   bool          `json:"supports_attachments"`
+	// NoTools is set for models that can't call tools, they are sent none.
+	NoTools bool `json:"no_tools,omitempty"`
+	// ResponsesAPI is set for OpenAI models answered through the Responses
+	// API, which returns reasoning summaries, rather than Chat Completions.
+	ResponsesAPI bool `json:"responses_api,omitempty"`
 }
 
 // Model IDs
 const ( // GEMINI
-	// Bedrock
-	BedrockClaude37Sonnet ModelID = "bedrock.claude-3.7-sonnet"
+	// MockModel replays the cassette named by OPENCODE_CASSETTE
+	MockModel ModelID = "__mock.cassette"
 )
 
 const (
-	ProviderBedrock ModelProvider = "bedrock"
 	// ForTests
 	ProviderMock ModelProvider = "__mock"
 )
@@ -72,18 +80,18 @@ var SupportedModels = map[ModelID]Model{
 	// 	CostPer1MOutCached: 0.025,
 	// 	CostPer1MOut:       0.4,
 	// },
-	//
-	// // Bedrock
-	BedrockClaude37Sonnet: {
-		ID:                 BedrockClaude37Sonnet,
-		Name:               "Bedrock: Claude 3.7 Sonnet",
-		Provider:           ProviderBedrock,
-		APIModel:           "anthropic.claude-3-7-sonnet-20250219-v1:0",
-		CostPer1MIn:        3.0,
-		CostPer1MInCached:  3.75,
-		CostPer1MOutCached: 0.30,
-		CostPer1MOut:       15.0,
-	},
+}
+
+// mockModel is only supported in tests and when a cassette is replayed, so
+// it doesn't show up in model listings and the config schema.
+var mockModel = Model{
+	ID:                  MockModel,
+	Name:                "Cassette",
+	Provider:            ProviderMock,
+	APIModel:            "cassette",
+	ContextWindow:       200000,
+	DefaultMaxTokens:    4096,
+	SupportsAttachments: true,
 }
 
 func init() {
@@ -95,4 +103,8 @@ func init() {
 	maps.Copy(SupportedModels, OpenRouterModels)
 	maps.Copy(SupportedModels, XAIModels)
 	maps.Copy(SupportedModels, VertexAIGeminiModels)
+	maps.Copy(SupportedModels, BedrockModels)
+	if os.Getenv("OPENCODE_CASSETTE") != "" || testing.Testing() {
+		SupportedModels[MockModel] = mockModel
+	}
 }
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+const (
+	ProviderOllama ModelProvider = "ollama"
+
+	// DefaultOllamaContext is the context window requested from Ollama
+	// unless configured otherwise. The full window of recent models needs
+	// more memory than most machines have.
+	DefaultOllamaContext = 32768
+
+	ollamaDefaultPort = "11434"
+	// ollamaDiscoveryTimeout bounds listing the models at startup, so an
+	// unreachable server doesn't delay it.
+	ollamaDiscoveryTimeout = 5 * time.Second
+)
+
+type ollamaTags struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+type ollamaShow struct {
+	ModelInfo    map[string]any `json:"model_info"`
+	Capabilities []string       `json:"capabilities"`
+}
+
+// OllamaURL turns a host in any form OLLAMA_HOST accepts, like "0.0.0.0",
+// "localhost:11434" or "https://example.com", into the URL of the server.
+func OllamaURL(host string) string {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return ""
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return ""
+	}
+	if u.Port() == "" && u.Scheme == "http" {
+		u.Host = net.JoinHostPort(u.Hostname(), ollamaDefaultPort)
+	}
+	if u.Hostname() == "0.0.0.0" {
+		u.Host = net.JoinHostPort("127.0.0.1", u.Port())
+	}
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// LoadOllamaModels adds the models installed on the Ollama server at baseURL
+// to the supported models and returns them, most recently changed first.
+// Their context window is the one the model was trained with, capped at
+// numCtx, or at DefaultOllamaContext when numCtx is 0.
+func LoadOllamaModels(baseURL string, numCtx int64) []Model {
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaDiscoveryTimeout)
+	defer cancel()
+
+	var tags ollamaTags
+	if err := ollamaRequest(ctx, baseURL, http.MethodGet, "api/tags", nil, &tags); err != nil {
+		logging.Debug("Failed to list Ollama models", "error", err, "endpoint", baseURL)
+		return nil
+	}
+
+	if numCtx <= 0 {
+		numCtx = DefaultOllamaContext
+	}
+	var loaded []Model
+	for _, m := range tags.Models {
+		var show ollamaShow
+		if err := ollamaRequest(ctx, baseURL, http.MethodPost, "api/show", map[string]string{"model": m.Name}, &show); err != nil {
+			logging.Debug("Failed to show Ollama model", "error", err, "model", m.Name)
+			continue
+		}
+		if len(show.Capabilities) > 0 && !slices.Contains(show.Capabilities, "completion") {
+			// Embedding models can't chat
+			continue
+		}
+		model := convertOllamaModel(m.Name, show, numCtx)
+		SupportedModels[model.ID] = model
+		loaded = append(loaded, model)
+	}
+	if len(loaded) > 0 {
+		ProviderPopularity[ProviderOllama] = 0
+	}
+	return loaded
+}
+
+func convertOllamaModel(name string, show ollamaShow, numCtx int64) Model {
+	contextWindow := numCtx
+	for key, value := range show.ModelInfo {
+		if length, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			contextWindow = min(int64(length), numCtx)
+		}
+	}
+	shortName := strings.TrimSuffix(name, ":latest")
+	return Model{
+		ID:                  ModelID("ollama." + shortName),
+		Name:                "Ollama: " + shortName,
+		Provider:            ProviderOllama,
+		APIModel:            name,
+		ContextWindow:       contextWindow,
+		DefaultMaxTokens:    contextWindow / 4,
+		CanReason:           slices.Contains(show.Capabilities, "thinking"),
+		SupportsAttachments: slices.Contains(show.Capabilities, "vision"),
+		// Servers too old to report capabilities are assumed to handle tools
+		NoTools: len(show.Capabilities) > 0 && !slices.Contains(show.Capabilities, "tools"),
+	}
+}
+
+// OllamaPullProgress reports the progress of a pull. Total and Completed
+// are in bytes and only set while a layer is downloaded.
+type OllamaPullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PullOllamaModel downloads a model to the Ollama server at baseURL, calling
+// progress with every update the server sends.
+func PullOllamaModel(ctx context.Context, baseURL, name string, progress func(OllamaPullProgress)) error {
+	body, _ := json.Marshal(map[string]any{"model": name, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return OllamaError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var update OllamaPullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &update); err != nil {
+			continue
+		}
+		if update.Error != "" {
+			return fmt.Errorf("ollama: %s", update.Error)
+		}
+		progress(update)
+		if update.Status == "success" {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// OllamaError reads the error of a failed Ollama request.
+func OllamaError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return fmt.Errorf("ollama: %s (%s)", body.Error, resp.Status)
+	}
+	return fmt.Errorf("ollama: unexpected status %s", resp.Status)
+}
+
+func ollamaRequest(ctx context.Context, baseURL, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+"/"+path, reader)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return OllamaError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOllamaModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"qwen3:latest"},{"name":"gemma3:4b"},{"name":"nomic-embed-text:latest"}]}`)
+		case "/api/show":
+			var body struct{ Model string }
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			switch body.Model {
+			case "qwen3:latest":
+				fmt.Fprint(w, `{"model_info":{"general.architecture":"qwen3","qwen3.context_length":40960},"capabilities":["completion","tools","thinking"]}`)
+			case "gemma3:4b":
+				fmt.Fprint(w, `{"model_info":{"gemma3.context_length":131072},"capabilities":["completion","vision"]}`)
+			default:
+				fmt.Fprint(w, `{"model_info":{"nomic-bert.context_length":2048},"capabilities":["embedding"]}`)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	loaded := LoadOllamaModels(srv.URL, 0)
+	require.Len(t, loaded, 2)
+
+	assert.Equal(t, Model{
+		ID:               "ollama.qwen3",
+		Name:             "Ollama: qwen3",
+		Provider:         ProviderOllama,
+		APIModel:         "qwen3:latest",
+		ContextWindow:    DefaultOllamaContext,
+		DefaultMaxTokens: DefaultOllamaContext / 4,
+		CanReason:        true,
+	}, loaded[0])
+	assert.Equal(t, loaded[0], SupportedModels["ollama.qwen3"])
+
+	gemma := SupportedModels["ollama.gemma3:4b"]
+	assert.True(t, gemma.SupportsAttachments)
+	assert.True(t, gemma.NoTools)
+	assert.False(t, gemma.CanReason)
+
+	assert.Equal(t, int64(16384), LoadOllamaModels(srv.URL, 16384)[1].ContextWindow)
+}
+
+func TestOllamaURL(t *testing.T) {
+	for host, want := range map[string]string{
+		"":                    "",
+		"0.0.0.0":             "http://127.0.0.1:11434",
+		"localhost:8080":      "http://localhost:8080",
+		"http://gpu-box":      "http://gpu-box:11434",
+		"https://example.com": "https://example.com",
+	} {
+		assert.Equal(t, want, OllamaURL(host), host)
+	}
+}
@@ -24,8 +24,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "gpt-4.1",
 		CostPer1MIn:         2.00,
-		CostPer1MInCached:   0.50,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.50,
 		CostPer1MOut:        8.00,
 		ContextWindow:       1_047_576,
 		DefaultMaxTokens:    20000,
@@ -37,8 +37,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "gpt-4.1",
 		CostPer1MIn:         0.40,
-		CostPer1MInCached:   0.10,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.10,
 		CostPer1MOut:        1.60,
 		ContextWindow:       200_000,
 		DefaultMaxTokens:    20000,
@@ -50,8 +50,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "gpt-4.1-nano",
 		CostPer1MIn:         0.10,
-		CostPer1MInCached:   0.025,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.025,
 		CostPer1MOut:        0.40,
 		ContextWindow:       1_047_576,
 		DefaultMaxTokens:    20000,
@@ -63,8 +63,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "gpt-4.5-preview",
 		CostPer1MIn:         75.00,
-		CostPer1MInCached:   37.50,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  37.50,
 		CostPer1MOut:        150.00,
 		ContextWindow:       128_000,
 		DefaultMaxTokens:    15000,
@@ -76,8 +76,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "gpt-4o",
 		CostPer1MIn:         2.50,
-		CostPer1MInCached:   1.25,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  1.25,
 		CostPer1MOut:        10.00,
 		ContextWindow:       128_000,
 		DefaultMaxTokens:    4096,
@@ -89,8 +89,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "gpt-4o-mini",
 		CostPer1MIn:         0.15,
-		CostPer1MInCached:   0.075,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.075,
 		CostPer1MOut:        0.60,
 		ContextWindow:       128_000,
 		SupportsAttachments: true,
@@ -101,13 +101,14 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "o1",
 		CostPer1MIn:         15.00,
-		CostPer1MInCached:   7.50,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  7.50,
 		CostPer1MOut:        60.00,
 		ContextWindow:       200_000,
 		DefaultMaxTokens:    50000,
 		CanReason:           true,
 		SupportsAttachments: true,
+		ResponsesAPI:        true,
 	},
 	O1Pro: {
 		ID:                  O1Pro,
@@ -122,6 +123,7 @@ var OpenAIModels = map[ModelID]Model{
 		DefaultMaxTokens:    50000,
 		CanReason:           true,
 		SupportsAttachments: true,
+		ResponsesAPI:        true,
 	},
 	O1Mini: {
 		ID:                  O1Mini,
@@ -129,8 +131,8 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "o1-mini",
 		CostPer1MIn:         1.10,
-		CostPer1MInCached:   0.55,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.55,
 		CostPer1MOut:        4.40,
 		ContextWindow:       128_000,
 		DefaultMaxTokens:    50000,
@@ -143,12 +145,13 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "o3",
 		CostPer1MIn:         10.00,
-		CostPer1MInCached:   2.50,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  2.50,
 		CostPer1MOut:        40.00,
 		ContextWindow:       200_000,
 		CanReason:           true,
 		SupportsAttachments: true,
+		ResponsesAPI:        true,
 	},
 	O3Mini: {
 		ID:                  O3Mini,
@@ -156,13 +159,14 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "o3-mini",
 		CostPer1MIn:         1.10,
-		CostPer1MInCached:   0.55,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.55,
 		CostPer1MOut:        4.40,
 		ContextWindow:       200_000,
 		DefaultMaxTokens:    50000,
 		CanReason:           true,
 		SupportsAttachments: false,
+		ResponsesAPI:        true,
 	},
 	O4Mini: {
 		ID:                  O4Mini,
@@ -170,12 +174,13 @@ var OpenAIModels = map[ModelID]Model{
 		Provider:            ProviderOpenAI,
 		APIModel:            "o4-mini",
 		CostPer1MIn:         1.10,
-		CostPer1MInCached:   0.275,
-		CostPer1MOutCached:  0.0,
+		CostPer1MInCached:   0.0,
+		CostPer1MOutCached:  0.275,
 		CostPer1MOut:        4.40,
 		ContextWindow:       128_000,
 		DefaultMaxTokens:    50000,
 		CanReason:           true,
 		SupportsAttachments: true,
+		ResponsesAPI:        true,
 	},
 }
@@ -4,13 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"strings"
-	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/bedrock"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/llm/models"
@@ -20,8 +17,9 @@ import (
 )
 
 type anthropicOptions struct {
-	useBedrock   bool
 	disableCache bool
+	baseURL      string
+	extraHeaders map[string]string
 	shouldThink  func(userMessage string) bool
 }
 
@@ -41,12 +39,16 @@ func newAnthropicClient(opts providerClientOptions) AnthropicClient {
 		o(&anthropicOpts)
 	}
 
-	anthropicClientOptions := []option.RequestOption{}
+	// Requests are retried by the resilient client
+	anthropicClientOptions := []option.RequestOption{option.WithMaxRetries(0)}
 	if opts.apiKey != "" {
 		anthropicClientOptions = append(anthropicClientOptions, option.WithAPIKey(opts.apiKey))
 	}
-	if anthropicOpts.useBedrock {
-		anthropicClientOptions = append(anthropicClientOptions, bedrock.WithLoadDefaultConfig(context.Background()))
+	if anthropicOpts.baseURL != "" {
+		anthropicClientOptions = append(anthropicClientOptions, option.WithBaseURL(anthropicOpts.baseURL))
+	}
+	for key, value := range anthropicOpts.extraHeaders {
+		anthropicClientOptions = append(anthropicClientOptions, option.WithHeader(key, value))
 	}
 
 	client := anthropic.NewClient(anthropicClientOptions...)
@@ -74,6 +76,10 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 			var contentBlocks []anthropic.ContentBlockParamUnion
 			contentBlocks = append(contentBlocks, content)
 			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsText() {
+					contentBlocks = append(contentBlocks, anthropic.NewTextBlock(binaryContent.Text()))
+					continue
+				}
 				base64Image := binaryContent.String(models.ProviderAnthropic)
 				imageBlock := anthropic.NewImageBlockBase64(binaryContent.MIMEType, base64Image)
 				contentBlocks = append(contentBlocks, imageBlock)
@@ -110,7 +116,7 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 		case message.Tool:
 			results := make([]anthropic.ContentBlockParamUnion, len(msg.ToolResults()))
 			for i, toolResult := range msg.ToolResults() {
-				results[i] = anthropic.NewToolResultBlock(toolResult.ToolCallID, toolResult.Content, toolResult.IsError)
+				results[i] = anthropicToolResult(toolResult)
 			}
 			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(results...))
 		}
@@ -118,6 +124,24 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 	return
 }
 
+// anthropicToolResult sends the images returned by a tool inside its result
+// block.
+func anthropicToolResult(toolResult message.ToolResult) anthropic.ContentBlockParamUnion {
+	block := anthropic.NewToolResultBlock(toolResult.ToolCallID, toolResult.Text(), toolResult.IsError)
+	images := toolResult.Images()
+	if len(images) == 0 {
+		return block
+	}
+	if toolResult.Text() == "" {
+		block.OfToolResult.Content = nil
+	}
+	for _, image := range images {
+		imageBlock := anthropic.NewImageBlockBase64(image.MIMEType, image.String(models.ProviderAnthropic))
+		block.OfToolResult.Content = append(block.OfToolResult.Content, anthropic.ToolResultBlockParamContentUnion{OfImage: imageBlock.OfImage})
+	}
+	return block
+}
+
 func (a *anthropicClient) convertTools(tools []tools.BaseTool) []anthropic.ToolUnionParam {
 	anthropicTools := make([]anthropic.ToolUnionParam, len(tools))
 
@@ -177,6 +201,13 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		}
 	}
 
+	system := anthropic.TextBlockParam{Text: a.providerOptions.systemMessage}
+	if !a.options.disableCache {
+		system.CacheControl = anthropic.CacheControlEphemeralParam{
+			Type: "ephemeral",
+		}
+	}
+
 	return anthropic.MessageNewParams{
 		Model:       anthropic.Model(a.providerOptions.model.APIModel),
 		MaxTokens:   a.providerOptions.maxTokens,
@@ -184,14 +215,7 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		Messages:    messages,
 		Tools:       tools,
 		Thinking:    thinkingParam,
-		System: []anthropic.TextBlockParam{
-			{
-				Text: a.providerOptions.systemMessage,
-				CacheControl: anthropic.CacheControlEphemeralParam{
-					Type: "ephemeral",
-				},
-			},
-		},
+		System:      []anthropic.TextBlockParam{system},
 	}
 }
 
@@ -203,45 +227,27 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 		logging.Debug("Prepared messages", "messages", string(jsonData))
 	}
 
-	attempts := 0
-	for {
-		attempts++
-		anthropicResponse, err := a.client.Messages.New(
-			ctx,
-			preparedMessages,
-		)
-		// If there is an error we are going to see if we can retry the call
-		if err != nil {
-			logging.Error("Error in Anthropic API call", "error", err)
-			retry, after, retryErr := a.shouldRetry(attempts, err)
-			if retryErr != nil {
-				return nil, retryErr
-			}
-			if retry {
-				logging.WarnPersist(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries), logging.PersistTimeArg, time.Millisecond*time.Duration(after+100))
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
-			}
-			return nil, retryErr
-		}
+	anthropicResponse, err := a.client.Messages.New(
+		ctx,
+		preparedMessages,
+	)
+	if err != nil {
+		logging.Error("Error in Anthropic API call", "error", err)
+		return nil, err
+	}
 
-		content := ""
-		for _, block := range anthropicResponse.Content {
-			if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-				content += text.Text
-			}
+	content := ""
+	for _, block := range anthropicResponse.Content {
+		if text, ok := block.AsAny().(anthropic.TextBlock); ok {
+			content += text.Text
 		}
-
-		return &ProviderResponse{
-			Content:   content,
-			ToolCalls: a.toolCalls(*anthropicResponse),
-			Usage:     a.usage(*anthropicResponse),
-		}, nil
 	}
+
+	return &ProviderResponse{
+		Content:   content,
+		ToolCalls: a.toolCalls(*anthropicResponse),
+		Usage:     a.usage(*anthropicResponse),
+	}, nil
 }
 
 func (a *anthropicClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
@@ -251,163 +257,104 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 		// jsonData, _ := json.Marshal(preparedMessages)
 		// logging.Debug("Prepared messages", "messages", string(jsonData))
 	}
-	attempts := 0
 	eventChan := make(chan ProviderEvent)
 	go func() {
-		for {
-			attempts++
-			anthropicStream := a.client.Messages.NewStreaming(
-				ctx,
-				preparedMessages,
-			)
-			accumulatedMessage := anthropic.Message{}
-
-			currentToolCallID := ""
-			for anthropicStream.Next() {
-				event := anthropicStream.Current()
-				err := accumulatedMessage.Accumulate(event)
-				if err != nil {
-					logging.Warn("Error accumulating message", "error", err)
-					continue
-				}
+		defer close(eventChan)
+		anthropicStream := a.client.Messages.NewStreaming(
+			ctx,
+			preparedMessages,
+		)
+		accumulatedMessage := anthropic.Message{}
+
+		currentToolCallID := ""
+		for anthropicStream.Next() {
+			event := anthropicStream.Current()
+			err := accumulatedMessage.Accumulate(event)
+			if err != nil {
+				logging.Warn("Error accumulating message", "error", err)
+				continue
+			}
 
-				switch event := event.AsAny().(type) {
-				case anthropic.ContentBlockStartEvent:
-					if event.ContentBlock.Type == "text" {
-						eventChan <- ProviderEvent{Type: EventContentStart}
-					} else if event.ContentBlock.Type == "tool_use" {
-						currentToolCallID = event.ContentBlock.ID
-						eventChan <- ProviderEvent{
-							Type: EventToolUseStart,
-							ToolCall: &message.ToolCall{
-								ID:       event.ContentBlock.ID,
-								Name:     event.ContentBlock.Name,
-								Finished: false,
-							},
-						}
+			switch event := event.AsAny().(type) {
+			case anthropic.ContentBlockStartEvent:
+				if event.ContentBlock.Type == "text" {
+					eventChan <- ProviderEvent{Type: EventContentStart}
+				} else if event.ContentBlock.Type == "tool_use" {
+					currentToolCallID = event.ContentBlock.ID
+					eventChan <- ProviderEvent{
+						Type: EventToolUseStart,
+						ToolCall: &message.ToolCall{
+							ID:       event.ContentBlock.ID,
+							Name:     event.ContentBlock.Name,
+							Finished: false,
+						},
 					}
+				}
 
-				case anthropic.ContentBlockDeltaEvent:
-					if event.Delta.Type == "thinking_delta" && event.Delta.Thinking != "" {
-						eventChan <- ProviderEvent{
-							Type:     EventThinkingDelta,
-							Thinking: event.Delta.Thinking,
-						}
-					} else if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
-						eventChan <- ProviderEvent{
-							Type:    EventContentDelta,
-							Content: event.Delta.Text,
-						}
-					} else if event.Delta.Type == "input_json_delta" {
-						if currentToolCallID != "" {
-							eventChan <- ProviderEvent{
-								Type: EventToolUseDelta,
-								ToolCall: &message.ToolCall{
-									ID:       currentToolCallID,
-									Finished: false,
-									Input:    event.Delta.JSON.PartialJSON.Raw(),
-								},
-							}
-						}
+			case anthropic.ContentBlockDeltaEvent:
+				if event.Delta.Type == "thinking_delta" && event.Delta.Thinking != "" {
+					eventChan <- ProviderEvent{
+						Type:     EventThinkingDelta,
+						Thinking: event.Delta.Thinking,
 					}
-				case anthropic.ContentBlockStopEvent:
+				} else if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					eventChan <- ProviderEvent{
+						Type:    EventContentDelta,
+						Content: event.Delta.Text,
+					}
+				} else if event.Delta.Type == "input_json_delta" {
 					if currentToolCallID != "" {
 						eventChan <- ProviderEvent{
-							Type: EventToolUseStop,
+							Type: EventToolUseDelta,
 							ToolCall: &message.ToolCall{
-								ID: currentToolCallID,
+								ID:       currentToolCallID,
+								Finished: false,
+								Input:    event.Delta.JSON.PartialJSON.Raw(),
 							},
 						}
-						currentToolCallID = ""
-					} else {
-						eventChan <- ProviderEvent{Type: EventContentStop}
 					}
-
-				case anthropic.MessageStopEvent:
-					content := ""
-					for _, block := range accumulatedMessage.Content {
-						if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-							content += text.Text
-						}
-					}
-
+				}
+			case anthropic.ContentBlockStopEvent:
+				if currentToolCallID != "" {
 					eventChan <- ProviderEvent{
-						Type: EventComplete,
-						Response: &ProviderResponse{
-							Content:      content,
-							ToolCalls:    a.toolCalls(accumulatedMessage),
-							Usage:        a.usage(accumulatedMessage),
-							FinishReason: a.finishReason(string(accumulatedMessage.StopReason)),
+						Type: EventToolUseStop,
+						ToolCall: &message.ToolCall{
+							ID: currentToolCallID,
 						},
 					}
+					currentToolCallID = ""
+				} else {
+					eventChan <- ProviderEvent{Type: EventContentStop}
 				}
-			}
 
-			err := anthropicStream.Err()
-			if err == nil || errors.Is(err, io.EOF) {
-				close(eventChan)
-				return
-			}
-			// If there is an error we are going to see if we can retry the call
-			retry, after, retryErr := a.shouldRetry(attempts, err)
-			if retryErr != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-				close(eventChan)
-				return
-			}
-			if retry {
-				logging.WarnPersist(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries), logging.PersistTimeArg, time.Millisecond*time.Duration(after+100))
-				select {
-				case <-ctx.Done():
-					// context cancelled
-					if ctx.Err() != nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+			case anthropic.MessageStopEvent:
+				content := ""
+				for _, block := range accumulatedMessage.Content {
+					if text, ok := block.AsAny().(anthropic.TextBlock); ok {
+						content += text.Text
 					}
-					close(eventChan)
-					return
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
+				}
+
+				eventChan <- ProviderEvent{
+					Type: EventComplete,
+					Response: &ProviderResponse{
+						Content:      content,
+						ToolCalls:    a.toolCalls(accumulatedMessage),
+						Usage:        a.usage(accumulatedMessage),
+						FinishReason: a.finishReason(string(accumulatedMessage.StopReason)),
+					},
 				}
 			}
-			if ctx.Err() != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-			}
+		}
 
-			close(eventChan)
-			return
+		err := anthropicStream.Err()
+		if err != nil && !errors.Is(err, io.EOF) {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
 		}
 	}()
 	return eventChan
 }
 
-func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	var apierr *anthropic.Error
-	if !errors.As(err, &apierr) {
-		return false, 0, err
-	}
-
-	if apierr.StatusCode != 429 && apierr.StatusCode != 529 {
-		return false, 0, err
-	}
-
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	retryMs := 0
-	retryAfterValues := apierr.Response.Header.Values("Retry-After")
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
-		}
-	}
-	return true, int64(retryMs), nil
-}
-
 func (a *anthropicClient) toolCalls(msg anthropic.Message) []message.ToolCall {
 	var toolCalls []message.ToolCall
 
@@ -437,15 +384,21 @@ func (a *anthropicClient) usage(msg anthropic.Message) TokenUsage {
 	}
 }
 
-func WithAnthropicBedrock(useBedrock bool) AnthropicOption {
+func WithAnthropicDisableCache() AnthropicOption {
 	return func(options *anthropicOptions) {
-		options.useBedrock = useBedrock
+		options.disableCache = true
 	}
 }
 
-func WithAnthropicDisableCache() AnthropicOption {
+func WithAnthropicBaseURL(baseURL string) AnthropicOption {
 	return func(options *anthropicOptions) {
-		options.disableCache = true
+		options.baseURL = baseURL
+	}
+}
+
+func WithAnthropicExtraHeaders(headers map[string]string) AnthropicOption {
+	return func(options *anthropicOptions) {
+		options.extraHeaders = headers
 	}
 }
 
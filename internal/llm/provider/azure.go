@@ -26,6 +26,7 @@ func newAzureClient(opts providerClientOptions) AzureClient {
 
 	reqOpts := []option.RequestOption{
 		azure.WithEndpoint(endpoint, apiVersion),
+		option.WithMaxRetries(0),
 	}
 
 	if opts.apiKey != "" || os.Getenv("AZURE_OPENAI_API_KEY") != "" {
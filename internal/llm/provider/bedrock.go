@@ -1,101 +1,550 @@
 package provider
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/message"
 )
 
+// noInferenceProfile calls models in the region of the provider only.
+const noInferenceProfile = "none"
+
 type bedrockOptions struct {
-	// Bedrock specific options can be added here
+	region           string
+	profile          string
+	inferenceProfile string
+	endpoint         string
+	disableCache     bool
+	shouldThink      func(userMessage string) bool
 }
 
 type BedrockOption func(*bedrockOptions)
 
+// bedrockClient talks to the models of Bedrock through the Converse API,
+// which takes the same messages and tools for all of them.
 type bedrockClient struct {
 	providerOptions providerClientOptions
 	options         bedrockOptions
-	childProvider   ProviderClient
+	client          *bedrockruntime.Client
+	// modelID is the model or inference profile the requests are sent to
+	modelID string
+	// err is why the client couldn't be configured, returned by every
+	// request
+	err error
 }
 
 type BedrockClient ProviderClient
 
 func newBedrockClient(opts providerClientOptions) BedrockClient {
 	bedrockOpts := bedrockOptions{}
-	// Apply bedrock specific options if they are added in the future
-
-	// Get AWS region from environment
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = os.Getenv("AWS_DEFAULT_REGION")
+	for _, o := range opts.bedrockOptions {
+		o(&bedrockOpts)
 	}
 
-	if region == "" {
-		region = "us-east-1" // default region
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if bedrockOpts.region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(bedrockOpts.region))
 	}
-	if len(region) < 2 {
-		return &bedrockClient{
-			providerOptions: opts,
-			options:         bedrockOpts,
-			childProvider:   nil, // Will cause an error when used
-		}
+	if bedrockOpts.profile != "" {
+		loadOptions = append(loadOptions, awsconfig.WithSharedConfigProfile(bedrockOpts.profile))
 	}
-
-	// Prefix the model name with region
-	regionPrefix := region[:2]
-	modelName := opts.model.APIModel
-	opts.model.APIModel = fmt.Sprintf("%s.%s", regionPrefix, modelName)
-
-	// Determine which provider to use based on the model
-	if strings.Contains(string(opts.model.APIModel), "anthropic") {
-		// Create Anthropic client with Bedrock configuration
-		anthropicOpts := opts
-		anthropicOpts.anthropicOptions = append(anthropicOpts.anthropicOptions,
-			WithAnthropicBedrock(true),
-			WithAnthropicDisableCache(),
-		)
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
 		return &bedrockClient{
 			providerOptions: opts,
 			options:         bedrockOpts,
-			childProvider:   newAnthropicClient(anthropicOpts),
+			err:             fmt.Errorf("failed to load the AWS configuration: %w", err),
 		}
 	}
+	region := cmp.Or(awsCfg.Region, os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
 
-	// Return client with nil childProvider if model is not supported
-	// This will cause an error when used
+	client := bedrockruntime.NewFromConfig(awsCfg, func(o *bedrockruntime.Options) {
+		o.Region = region
+		// Requests are retried by the resilient client
+		o.Retryer = aws.NopRetryer{}
+		if bedrockOpts.endpoint != "" {
+			o.BaseEndpoint = aws.String(bedrockOpts.endpoint)
+		}
+	})
 	return &bedrockClient{
 		providerOptions: opts,
 		options:         bedrockOpts,
-		childProvider:   nil,
+		client:          client,
+		modelID:         inferenceProfileID(opts.model.APIModel, region, bedrockOpts.inferenceProfile),
+	}
+}
+
+// regionGeographies are the geographies of the cross-region inference
+// profiles, by the prefix of the regions they route between.
+var regionGeographies = []struct{ prefix, geography string }{
+	{"us-gov-", "us-gov"},
+	{"us-", "us"},
+	{"eu-", "eu"},
+	{"ap-", "apac"},
+}
+
+// inferenceGeographies prefix the IDs of inference profiles.
+var inferenceGeographies = []string{"us", "us-gov", "eu", "apac", "jp", "au", "ca", "global"}
+
+// inferenceProfileID returns the ID the model is called by: the model
+// prefixed with the geography of the inference profile, or of the region
+// when the profile is unset. Models that already name a profile or an ARN
+// are called as they are.
+func inferenceProfileID(apiModel, region, profile string) string {
+	if profile == noInferenceProfile || strings.HasPrefix(apiModel, "arn:") {
+		return apiModel
+	}
+	if profile == "" {
+		for _, g := range regionGeographies {
+			if strings.HasPrefix(region, g.prefix) {
+				profile = g.geography
+				break
+			}
+		}
+	}
+	if profile == "" {
+		return apiModel
+	}
+	geography, _, _ := strings.Cut(apiModel, ".")
+	if geography == profile || slices.Contains(inferenceGeographies, geography) {
+		return apiModel
+	}
+	return profile + "." + apiModel
+}
+
+// caches reports whether the model caches prompts at cache points, the
+// models with a cache read price.
+func (b *bedrockClient) caches() bool {
+	return !b.options.disableCache && b.providerOptions.model.CostPer1MOutCached > 0
+}
+
+// cachesTools reports whether the model takes a cache point after the
+// tools, only Claude does.
+func (b *bedrockClient) cachesTools() bool {
+	return b.caches() && strings.Contains(b.providerOptions.model.APIModel, "anthropic.")
+}
+
+func (b *bedrockClient) convertMessages(messages []message.Message, withTools bool) (bedrockMessages []types.Message) {
+	for _, msg := range messages {
+		var role types.ConversationRole
+		var blocks []types.ContentBlock
+		switch msg.Role {
+		case message.User:
+			role = types.ConversationRoleUser
+			if text := msg.Content().String(); text != "" {
+				blocks = append(blocks, &types.ContentBlockMemberText{Value: text})
+			}
+			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsText() {
+					blocks = append(blocks, &types.ContentBlockMemberText{Value: binaryContent.Text()})
+					continue
+				}
+				blocks = append(blocks, &types.ContentBlockMemberImage{Value: bedrockImage(binaryContent)})
+			}
+
+		case message.Assistant:
+			role = types.ConversationRoleAssistant
+			if text := msg.Content().String(); text != "" {
+				blocks = append(blocks, &types.ContentBlockMemberText{Value: text})
+			}
+			for _, toolCall := range msg.ToolCalls() {
+				if !withTools {
+					// Converse rejects tool calls in requests without tools
+					blocks = append(blocks, &types.ContentBlockMemberText{
+						Value: fmt.Sprintf("[Called the %s tool with %s]", toolCall.Name, toolCall.Input),
+					})
+					continue
+				}
+				var input map[string]any
+				if err := json.Unmarshal([]byte(toolCall.Input), &input); err != nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, &types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+					ToolUseId: aws.String(toolCall.ID),
+					Name:      aws.String(toolCall.Name),
+					Input:     document.NewLazyDocument(input),
+				}})
+			}
+
+		case message.Tool:
+			role = types.ConversationRoleUser
+			for _, toolResult := range msg.ToolResults() {
+				if !withTools {
+					blocks = append(blocks, &types.ContentBlockMemberText{
+						Value: fmt.Sprintf("[Result of the %s tool]\n%s", toolResult.Name, toolResult.Text()),
+					})
+					continue
+				}
+				blocks = append(blocks, &types.ContentBlockMemberToolResult{Value: bedrockToolResult(toolResult)})
+			}
+		}
+		if len(blocks) == 0 {
+			logging.Warn("There is a message without content, investigate, this should not happen")
+			continue
+		}
+		// Converse takes turns that alternate between the user and the
+		// assistant, like tool results and the next user message in one
+		if last := len(bedrockMessages) - 1; last >= 0 && bedrockMessages[last].Role == role {
+			bedrockMessages[last].Content = append(bedrockMessages[last].Content, blocks...)
+			continue
+		}
+		bedrockMessages = append(bedrockMessages, types.Message{Role: role, Content: blocks})
+	}
+
+	if b.caches() {
+		for i := max(len(bedrockMessages)-2, 0); i < len(bedrockMessages); i++ {
+			bedrockMessages[i].Content = append(bedrockMessages[i].Content, &types.ContentBlockMemberCachePoint{
+				Value: types.CachePointBlock{Type: types.CachePointTypeDefault},
+			})
+		}
+	}
+	return bedrockMessages
+}
+
+// bedrockImage sends an image by value, in the format of its MIME type.
+func bedrockImage(image message.BinaryContent) types.ImageBlock {
+	format := strings.TrimPrefix(image.MIMEType, "image/")
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	return types.ImageBlock{
+		Format: types.ImageFormat(format),
+		Source: &types.ImageSourceMemberBytes{Value: image.Data},
+	}
+}
+
+// bedrockToolResult sends the images returned by a tool inside its result
+// block.
+func bedrockToolResult(toolResult message.ToolResult) types.ToolResultBlock {
+	block := types.ToolResultBlock{
+		ToolUseId: aws.String(toolResult.ToolCallID),
+		Status:    types.ToolResultStatusSuccess,
+	}
+	if toolResult.IsError {
+		block.Status = types.ToolResultStatusError
+	}
+	images := toolResult.Images()
+	if text := toolResult.Text(); text != "" || len(images) == 0 {
+		block.Content = append(block.Content, &types.ToolResultContentBlockMemberText{Value: text})
+	}
+	for _, image := range images {
+		block.Content = append(block.Content, &types.ToolResultContentBlockMemberImage{Value: bedrockImage(image)})
+	}
+	return block
+}
+
+func (b *bedrockClient) convertTools(tools []tools.BaseTool) *types.ToolConfiguration {
+	if len(tools) == 0 {
+		return nil
+	}
+	bedrockTools := make([]types.Tool, 0, len(tools)+1)
+	for _, tool := range tools {
+		info := tool.Info()
+		required := info.Required
+		if required == nil {
+			required = []string{}
+		}
+		bedrockTools = append(bedrockTools, &types.ToolMemberToolSpec{Value: types.ToolSpecification{
+			Name:        aws.String(info.Name),
+			Description: aws.String(info.Description),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(map[string]any{
+				"type":       "object",
+				"properties": info.Parameters,
+				"required":   required,
+			})},
+		}})
+	}
+	if b.cachesTools() {
+		bedrockTools = append(bedrockTools, &types.ToolMemberCachePoint{
+			Value: types.CachePointBlock{Type: types.CachePointTypeDefault},
+		})
+	}
+	return &types.ToolConfiguration{Tools: bedrockTools}
+}
+
+func (b *bedrockClient) finishReason(reason types.StopReason) message.FinishReason {
+	switch reason {
+	case types.StopReasonEndTurn, types.StopReasonStopSequence:
+		return message.FinishReasonEndTurn
+	case types.StopReasonMaxTokens:
+		return message.FinishReasonMaxTokens
+	case types.StopReasonToolUse:
+		return message.FinishReasonToolUse
+	case types.StopReasonGuardrailIntervened, types.StopReasonContentFiltered:
+		return message.FinishReasonPermissionDenied
+	default:
+		return message.FinishReasonUnknown
+	}
+}
+
+func (b *bedrockClient) preparedMessages(messages []message.Message, tools []tools.BaseTool) *bedrockruntime.ConverseInput {
+	input := &bedrockruntime.ConverseInput{
+		ModelId:    aws.String(b.modelID),
+		Messages:   b.convertMessages(messages, len(tools) > 0),
+		ToolConfig: b.convertTools(tools),
+		InferenceConfig: &types.InferenceConfiguration{
+			MaxTokens: aws.Int32(int32(b.providerOptions.maxTokens)),
+		},
+	}
+	if b.providerOptions.systemMessage != "" {
+		input.System = []types.SystemContentBlock{
+			&types.SystemContentBlockMemberText{Value: b.providerOptions.systemMessage},
+		}
+		if b.caches() {
+			input.System = append(input.System, &types.SystemContentBlockMemberCachePoint{
+				Value: types.CachePointBlock{Type: types.CachePointTypeDefault},
+			})
+		}
+	}
+
+	lastMessage := messages[len(messages)-1]
+	if b.providerOptions.model.CanReason && lastMessage.Role == message.User && b.options.shouldThink != nil {
+		if content := lastMessage.Content().String(); content != "" && b.options.shouldThink(content) {
+			input.AdditionalModelRequestFields = document.NewLazyDocument(map[string]any{
+				"thinking": map[string]any{
+					"type":          "enabled",
+					"budget_tokens": int64(float64(b.providerOptions.maxTokens) * 0.8),
+				},
+			})
+		}
 	}
+	return input
 }
 
 func (b *bedrockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
-	if b.childProvider == nil {
-		return nil, errors.New("unsupported model for bedrock provider")
+	if b.err != nil {
+		return nil, b.err
 	}
-	return b.childProvider.send(ctx, messages, tools)
+	output, err := b.client.Converse(ctx, b.preparedMessages(messages, tools))
+	if err != nil {
+		logging.Error("Error in Bedrock API call", "error", err)
+		return nil, err
+	}
+
+	response := &ProviderResponse{
+		Usage:        b.usage(output.Usage),
+		FinishReason: b.finishReason(output.StopReason),
+	}
+	msg, ok := output.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return response, nil
+	}
+	for _, block := range msg.Value.Content {
+		switch block := block.(type) {
+		case *types.ContentBlockMemberText:
+			response.Content += block.Value
+		case *types.ContentBlockMemberToolUse:
+			input := "{}"
+			if block.Value.Input != nil {
+				if data, err := block.Value.Input.MarshalSmithyDocument(); err == nil {
+					input = string(data)
+				}
+			}
+			response.ToolCalls = append(response.ToolCalls, message.ToolCall{
+				ID:       aws.ToString(block.Value.ToolUseId),
+				Name:     aws.ToString(block.Value.Name),
+				Input:    input,
+				Type:     "tool_use",
+				Finished: true,
+			})
+		}
+	}
+	return response, nil
 }
 
 func (b *bedrockClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		if b.err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: b.err}
+			return
+		}
+		input := b.preparedMessages(messages, tools)
+		output, err := b.client.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+			ModelId:                      input.ModelId,
+			Messages:                     input.Messages,
+			System:                       input.System,
+			ToolConfig:                   input.ToolConfig,
+			InferenceConfig:              input.InferenceConfig,
+			AdditionalModelRequestFields: input.AdditionalModelRequestFields,
+		})
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+		stream := output.GetStream()
+		defer stream.Close()
+
+		var content strings.Builder
+		var toolCalls []message.ToolCall
+		var usage TokenUsage
+		var stopReason types.StopReason
+		// current is the tool call being streamed, -1 for text
+		current, inText := -1, false
+		for event := range stream.Events() {
+			switch event := event.(type) {
+			case *types.ConverseStreamOutputMemberContentBlockStart:
+				if start, ok := event.Value.Start.(*types.ContentBlockStartMemberToolUse); ok {
+					toolCalls = append(toolCalls, message.ToolCall{
+						ID:   aws.ToString(start.Value.ToolUseId),
+						Name: aws.ToString(start.Value.Name),
+						Type: "tool_use",
+					})
+					current = len(toolCalls) - 1
+					eventChan <- ProviderEvent{Type: EventToolUseStart, ToolCall: &message.ToolCall{
+						ID:   toolCalls[current].ID,
+						Name: toolCalls[current].Name,
+					}}
+				}
+
+			case *types.ConverseStreamOutputMemberContentBlockDelta:
+				switch delta := event.Value.Delta.(type) {
+				case *types.ContentBlockDeltaMemberText:
+					if !inText {
+						inText = true
+						eventChan <- ProviderEvent{Type: EventContentStart}
+					}
+					content.WriteString(delta.Value)
+					eventChan <- ProviderEvent{Type: EventContentDelta, Content: delta.Value}
+				case *types.ContentBlockDeltaMemberReasoningContent:
+					if text, ok := delta.Value.(*types.ReasoningContentBlockDeltaMemberText); ok && text.Value != "" {
+						eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: text.Value}
+					}
+				case *types.ContentBlockDeltaMemberToolUse:
+					if current >= 0 {
+						partial := aws.ToString(delta.Value.Input)
+						toolCalls[current].Input += partial
+						eventChan <- ProviderEvent{Type: EventToolUseDelta, ToolCall: &message.ToolCall{
+							ID:    toolCalls[current].ID,
+							Input: partial,
+						}}
+					}
+				}
+
+			case *types.ConverseStreamOutputMemberContentBlockStop:
+				if current >= 0 {
+					toolCalls[current].Finished = true
+					if toolCalls[current].Input == "" {
+						toolCalls[current].Input = "{}"
+					}
+					eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &message.ToolCall{ID: toolCalls[current].ID}}
+					current = -1
+				} else if inText {
+					inText = false
+					eventChan <- ProviderEvent{Type: EventContentStop}
+				}
+
+			case *types.ConverseStreamOutputMemberMessageStop:
+				stopReason = event.Value.StopReason
 
-	if b.childProvider == nil {
-		go func() {
-			eventChan <- ProviderEvent{
-				Type:  EventError,
-				Error: errors.New("unsupported model for bedrock provider"),
+			case *types.ConverseStreamOutputMemberMetadata:
+				usage = b.usage(event.Value.Usage)
 			}
-			close(eventChan)
-		}()
-		return eventChan
+		}
+		if err := stream.Err(); err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+		if ctx.Err() != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+			return
+		}
+
+		eventChan <- ProviderEvent{
+			Type: EventComplete,
+			Response: &ProviderResponse{
+				Content:      content.String(),
+				ToolCalls:    toolCalls,
+				Usage:        usage,
+				FinishReason: b.finishReason(stopReason),
+			},
+		}
+	}()
+	return eventChan
+}
+
+func (b *bedrockClient) usage(usage *types.TokenUsage) TokenUsage {
+	if usage == nil {
+		return TokenUsage{}
 	}
+	return TokenUsage{
+		InputTokens:         int64(aws.ToInt32(usage.InputTokens)),
+		OutputTokens:        int64(aws.ToInt32(usage.OutputTokens)),
+		CacheCreationTokens: int64(aws.ToInt32(usage.CacheWriteInputTokens)),
+		CacheReadTokens:     int64(aws.ToInt32(usage.CacheReadInputTokens)),
+	}
+}
 
-	return b.childProvider.stream(ctx, messages, tools)
+// bedrockStatusCode returns the HTTP status of the exceptions Bedrock
+// reports during a stream, which come without one.
+func bedrockStatusCode(err error) int {
+	var throttling *types.ThrottlingException
+	var unavailable *types.ServiceUnavailableException
+	var notReady *types.ModelNotReadyException
+	var internal *types.InternalServerException
+	var streamErr *types.ModelStreamErrorException
+	switch {
+	case errors.As(err, &throttling):
+		return 429
+	case errors.As(err, &unavailable), errors.As(err, &notReady):
+		return 503
+	case errors.As(err, &internal), errors.As(err, &streamErr):
+		return 500
+	}
+	return 0
 }
 
+func WithBedrockRegion(region string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.region = region
+	}
+}
+
+func WithBedrockProfile(profile string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.profile = profile
+	}
+}
+
+// WithBedrockInferenceProfile sets the geography of the cross-region
+// inference profile models are called through, "none" for none.
+func WithBedrockInferenceProfile(profile string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.inferenceProfile = profile
+	}
+}
+
+// WithBedrockEndpoint sends the requests to another endpoint than the one of
+// the region, like a VPC endpoint.
+func WithBedrockEndpoint(endpoint string) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.endpoint = endpoint
+	}
+}
+
+func WithBedrockDisableCache() BedrockOption {
+	return func(options *bedrockOptions) {
+		options.disableCache = true
+	}
+}
+
+func WithBedrockShouldThinkFn(fn func(string) bool) BedrockOption {
+	return func(options *bedrockOptions) {
+		options.shouldThink = fn
+	}
+}
@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferenceProfileID(t *testing.T) {
+	model := "anthropic.claude-sonnet-4-20250514-v1:0"
+	assert.Equal(t, "us."+model, inferenceProfileID(model, "us-west-2", ""))
+	assert.Equal(t, "apac."+model, inferenceProfileID(model, "ap-northeast-1", ""))
+	assert.Equal(t, "global."+model, inferenceProfileID(model, "eu-west-1", "global"))
+	assert.Equal(t, model, inferenceProfileID(model, "eu-west-1", "none"))
+	assert.Equal(t, model, inferenceProfileID(model, "sa-east-1", ""))
+	// Models that name a profile are called as they are
+	assert.Equal(t, "eu."+model, inferenceProfileID("eu."+model, "us-east-1", ""))
+	arn := "arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc"
+	assert.Equal(t, arn, inferenceProfileID(arn, "us-east-1", ""))
+}
+
+// bedrockEvents encodes events of a Converse stream.
+func bedrockEvents(t *testing.T, events ...[2]string) []byte {
+	var buf bytes.Buffer
+	encoder := eventstream.NewEncoder()
+	for _, event := range events {
+		require.NoError(t, encoder.Encode(&buf, eventstream.Message{
+			Headers: eventstream.Headers{
+				{Name: ":message-type", Value: eventstream.StringValue("event")},
+				{Name: ":event-type", Value: eventstream.StringValue(event[0])},
+				{Name: ":content-type", Value: eventstream.StringValue("application/json")},
+			},
+			Payload: []byte(event[1]),
+		}))
+	}
+	return buf.Bytes()
+}
+
+func TestBedrockConverse(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	var paths []string
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		paths = append(paths, r.URL.Path)
+		requests = append(requests, request)
+		if filepath.Base(r.URL.Path) == "converse" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"Let me check."},` +
+				`{"toolUse":{"toolUseId":"t1","name":"weather","input":{"city":"Oslo"}}}]}},"stopReason":"tool_use",` +
+				`"usage":{"inputTokens":12,"outputTokens":7,"totalTokens":4019,"cacheReadInputTokens":4000},"metrics":{"latencyMs":1}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(bedrockEvents(t,
+			[2]string{"messageStart", `{"role":"assistant"}`},
+			[2]string{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"reasoningContent":{"text":"rain?"}}}`},
+			[2]string{"contentBlockStop", `{"contentBlockIndex":0}`},
+			[2]string{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"It is sunny."}}`},
+			[2]string{"contentBlockStop", `{"contentBlockIndex":1}`},
+			[2]string{"contentBlockStart", `{"contentBlockIndex":2,"start":{"toolUse":{"toolUseId":"t2","name":"weather"}}}`},
+			[2]string{"contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"{\"city\":"}}}`},
+			[2]string{"contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"\"Bergen\"}"}}}`},
+			[2]string{"contentBlockStop", `{"contentBlockIndex":2}`},
+			[2]string{"messageStop", `{"stopReason":"tool_use"}`},
+			[2]string{"metadata", `{"usage":{"inputTokens":30,"outputTokens":9,"totalTokens":1039,"cacheWriteInputTokens":1000},"metrics":{"latencyMs":1}}`},
+		))
+	}))
+	defer srv.Close()
+	config.Get().Providers[models.ProviderBedrock] = config.Provider{Region: "eu-west-1", BaseURL: srv.URL}
+
+	p, err := NewProvider(models.ProviderBedrock,
+		WithModel(models.SupportedModels[models.BedrockClaude4Sonnet]),
+		WithMaxTokens(1024),
+		WithSystemMessage("Be brief."),
+		WithBedrockOptions(WithBedrockShouldThinkFn(DefaultShouldThinkFn)),
+	)
+	require.NoError(t, err)
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Weather in Oslo?"}}},
+	}
+	response, err := p.SendMessages(context.Background(), messages, []tools.BaseTool{weatherTool{}})
+	require.NoError(t, err)
+	assert.Equal(t, "/model/eu.anthropic.claude-sonnet-4-20250514-v1:0/converse", paths[0])
+	assert.Equal(t, "Let me check.", response.Content)
+	assert.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, "t1", response.ToolCalls[0].ID)
+	assert.JSONEq(t, `{"city":"Oslo"}`, response.ToolCalls[0].Input)
+	assert.Equal(t, TokenUsage{InputTokens: 12, OutputTokens: 7, CacheReadTokens: 4000}, response.Usage)
+
+	// Claude caches the system prompt, the tools and the last messages
+	request := requests[0]
+	assert.Equal(t, []any{map[string]any{"text": "Be brief."}, map[string]any{"cachePoint": map[string]any{"type": "default"}}}, request["system"])
+	toolConfig := request["toolConfig"].(map[string]any)["tools"].([]any)
+	require.Len(t, toolConfig, 2)
+	assert.Equal(t, "weather", toolConfig[0].(map[string]any)["toolSpec"].(map[string]any)["name"])
+	assert.Contains(t, toolConfig[1], "cachePoint")
+	assert.Equal(t, map[string]any{"maxTokens": float64(1024)}, request["inferenceConfig"])
+	assert.Nil(t, request["additionalModelRequestFields"])
+
+	// Tool results and the next user message share a turn
+	messages = append(messages,
+		message.Message{Role: message.Assistant, Parts: []message.ContentPart{
+			message.TextContent{Text: "Let me check."},
+			message.ToolCall{ID: "t1", Name: "weather", Input: `{"city":"Oslo"}`, Finished: true},
+		}},
+		message.Message{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "t1", Name: "weather", Content: "sunny"},
+		}},
+		message.Message{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Think about Bergen too"}}},
+	)
+	var thinking, content string
+	var started []string
+	response = nil
+	for event := range p.StreamResponse(context.Background(), messages, []tools.BaseTool{weatherTool{}}) {
+		switch event.Type {
+		case EventThinkingDelta:
+			thinking += event.Thinking
+		case EventContentDelta:
+			content += event.Content
+		case EventToolUseStart:
+			started = append(started, event.ToolCall.Name)
+		case EventComplete:
+			response = event.Response
+		case EventError:
+			t.Fatal(event.Error)
+		}
+	}
+	assert.Equal(t, "/model/eu.anthropic.claude-sonnet-4-20250514-v1:0/converse-stream", paths[1])
+	request = requests[1]
+	turns := request["messages"].([]any)
+	require.Len(t, turns, 3)
+	lastTurn := turns[2].(map[string]any)
+	assert.Equal(t, "user", lastTurn["role"])
+	blocks := lastTurn["content"].([]any)
+	require.Len(t, blocks, 3)
+	assert.Contains(t, blocks[0], "toolResult")
+	assert.Equal(t, map[string]any{"text": "Think about Bergen too"}, blocks[1])
+	assert.Contains(t, blocks[2], "cachePoint")
+	assert.Equal(t, map[string]any{"thinking": map[string]any{"type": "enabled", "budget_tokens": float64(819)}}, request["additionalModelRequestFields"])
+
+	assert.Equal(t, "rain?", thinking)
+	assert.Equal(t, "It is sunny.", content)
+	assert.Equal(t, []string{"weather"}, started)
+	require.NotNil(t, response)
+	assert.Equal(t, "It is sunny.", response.Content)
+	assert.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, `{"city":"Bergen"}`, response.ToolCalls[0].Input)
+	assert.Equal(t, TokenUsage{InputTokens: 30, OutputTokens: 9, CacheCreationTokens: 1000}, response.Usage)
+}
+
+func TestBedrockWithoutTools(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	var request map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Amzn-Errortype", "ThrottlingException")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message":"Too many requests, please wait before trying again."}`))
+	}))
+	defer srv.Close()
+	noRetries := 0
+	config.Get().Providers[models.ProviderBedrock] = config.Provider{
+		Region:           "us-east-1",
+		InferenceProfile: "none",
+		BaseURL:          srv.URL,
+		Retry:            config.RetryConfig{MaxRetries: &noRetries},
+	}
+
+	p, err := NewProvider(models.ProviderBedrock,
+		WithModel(models.SupportedModels[models.BedrockLlama33_70B]),
+		WithMaxTokens(1024),
+		WithSystemMessage("Summarize."),
+	)
+	require.NoError(t, err)
+
+	// A summary sends the tool calls of the history without tools
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Weather in Oslo?"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{ID: "t1", Name: "weather", Input: `{"city":"Oslo"}`, Finished: true},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "t1", Name: "weather", Content: "sunny"},
+		}},
+	}
+	_, err = p.SendMessages(context.Background(), messages, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, StatusCode(err))
+	assert.Equal(t, config.FallbackOnRateLimit, FallbackReason(err))
+
+	assert.Nil(t, request["toolConfig"])
+	assert.Equal(t, []any{map[string]any{"text": "Summarize."}}, request["system"])
+	turns := request["messages"].([]any)
+	require.Len(t, turns, 3)
+	assert.Equal(t, []any{map[string]any{"text": `[Called the weather tool with {"city":"Oslo"}]`}}, turns[1].(map[string]any)["content"])
+	assert.Equal(t, []any{map[string]any{"text": "[Result of the weather tool]\nsunny"}}, turns[2].(map[string]any)["content"])
+}
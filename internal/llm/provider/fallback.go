@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/openai/openai-go"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"google.golang.org/genai"
+)
+
+// FallbackCooldown is how long a model that failed is skipped in favor of
+// its fallbacks.
+const FallbackCooldown = 5 * time.Minute
+
+// StatusCode returns the HTTP status of the response a provider request
+// failed with, 0 when it got none.
+func StatusCode(err error) int {
+	var anthropicErr *anthropic.Error
+	var openaiErr *openai.Error
+	var genaiErr genai.APIError
+	var awsErr *awshttp.ResponseError
+	switch {
+	case errors.As(err, &anthropicErr):
+		return anthropicErr.StatusCode
+	case errors.As(err, &openaiErr):
+		return openaiErr.StatusCode
+	case errors.As(err, &genaiErr):
+		return genaiErr.Code
+	case errors.As(err, &awsErr):
+		return awsErr.HTTPStatusCode()
+	}
+	return bedrockStatusCode(err)
+}
+
+// FallbackReason returns the fallback condition a provider error falls
+// under, empty for errors another model wouldn't avoid, like an invalid
+// request or a cancelled context.
+func FallbackReason(err error) config.FallbackCondition {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ""
+	}
+	switch status := StatusCode(err); {
+	case status == 429:
+		return config.FallbackOnRateLimit
+	case status == 503 || status == 529:
+		return config.FallbackOnOverloaded
+	case status >= 500:
+		return config.FallbackOnServerError
+	case status == 401 || status == 403:
+		return config.FallbackOnAuth
+	case status != 0:
+		return ""
+	}
+	// Anthropic reports overloads during a stream as an error event
+	if strings.Contains(err.Error(), "overloaded_error") {
+		return config.FallbackOnOverloaded
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return config.FallbackOnConnection
+	}
+	return ""
+}
+
+// Fallback is a switch to another model of a fallback chain.
+type Fallback struct {
+	From models.Model
+	To   models.Model
+	// Reason is why From failed, empty when switching back to a model that
+	// cooled down.
+	Reason config.FallbackCondition
+}
+
+func (f Fallback) String() string {
+	if f.Reason == "" {
+		return fmt.Sprintf("Switching back to %s", f.To.Name)
+	}
+	return fmt.Sprintf("%s failed (%s), switching to %s", f.From.Name, strings.ReplaceAll(string(f.Reason), "_", " "), f.To.Name)
+}
+
+// fallbackProvider sends requests to the first provider of a chain whose
+// model didn't fail recently, and on to the next one when it fails with one
+// of the fallback conditions.
+type fallbackProvider struct {
+	providers []Provider
+	on        []config.FallbackCondition
+
+	mu sync.Mutex
+	// active is the index of the provider of the last request
+	active int
+	failed map[models.ModelID]failure
+}
+
+// failure is why a model failed, and until when it is skipped.
+type failure struct {
+	reason config.FallbackCondition
+	until  time.Time
+}
+
+// NewFallbackProvider creates a provider that falls back on the providers
+// after the first one, in order, when a request fails with one of the
+// conditions.
+func NewFallbackProvider(providers []Provider, on []config.FallbackCondition) Provider {
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	if len(on) == 0 {
+		on = config.DefaultFallbackOn
+	}
+	return &fallbackProvider{
+		providers: providers,
+		on:        on,
+		failed:    make(map[models.ModelID]failure),
+	}
+}
+
+func (f *fallbackProvider) Model() models.Model {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.providers[f.active].Model()
+}
+
+// start returns the provider for a new request, the first one that isn't
+// cooling down, or the first one when they all are.
+func (f *fallbackProvider) start() (int, *Fallback) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := max(slices.IndexFunc(f.providers, f.available), 0)
+	if i == f.active {
+		return i, nil
+	}
+	from := f.providers[f.active].Model()
+	fallback := &Fallback{From: from, To: f.providers[i].Model()}
+	if i > f.active {
+		// The active model failed after the chain moved past it
+		fallback.Reason = f.failed[from.ID].reason
+		logging.WarnPersist(fallback.String())
+	} else {
+		logging.InfoPersist(fallback.String())
+	}
+	f.active = i
+	return i, fallback
+}
+
+// available reports whether the model of p isn't cooling down. The caller
+// holds f.mu.
+func (f *fallbackProvider) available(p Provider) bool {
+	return time.Now().After(f.failed[p.Model().ID].until)
+}
+
+// next returns the provider after the one at i that isn't cooling down,
+// -1 when err is no reason to fall back or there is none.
+func (f *fallbackProvider) next(i int, err error) (int, *Fallback) {
+	reason := FallbackReason(err)
+	if !slices.Contains(f.on, reason) {
+		return -1, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	from := f.providers[i].Model()
+	f.failed[from.ID] = failure{reason: reason, until: time.Now().Add(FallbackCooldown)}
+	for j := i + 1; j < len(f.providers); j++ {
+		if f.available(f.providers[j]) {
+			f.active = j
+			fallback := &Fallback{From: from, To: f.providers[j].Model(), Reason: reason}
+			logging.WarnPersist(fallback.String())
+			return j, fallback
+		}
+	}
+	return -1, nil
+}
+
+func (f *fallbackProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	i, _ := f.start()
+	for {
+		response, err := f.providers[i].SendMessages(ctx, messages, tools)
+		if err == nil || ctx.Err() != nil {
+			return response, err
+		}
+		if i, _ = f.next(i, err); i < 0 {
+			return response, err
+		}
+	}
+}
+
+func (f *fallbackProvider) Close() {
+	for _, p := range f.providers {
+		p.Close()
+	}
+}
+
+func (f *fallbackProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	// Pick the provider before returning, so Model is the model answering
+	i, fallback := f.start()
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		if fallback != nil {
+			eventChan <- ProviderEvent{Type: EventFallback, Fallback: fallback}
+		}
+		for {
+			// Errors before any output can be retried with the next model
+			answered := false
+			var failed error
+			for event := range f.providers[i].StreamResponse(ctx, messages, tools) {
+				switch event.Type {
+				case EventContentDelta, EventToolUseStart:
+					answered = true
+				case EventError:
+					if !answered && ctx.Err() == nil {
+						failed = event.Error
+						continue
+					}
+				}
+				eventChan <- event
+			}
+			if failed == nil {
+				return
+			}
+			if i, fallback = f.next(i, failed); i < 0 {
+				eventChan <- ProviderEvent{Type: EventError, Error: failed}
+				return
+			}
+			eventChan <- ProviderEvent{Type: EventFallback, Fallback: fallback}
+		}
+	}()
+	return eventChan
+}
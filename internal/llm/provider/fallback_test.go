@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider answers every request with the same events.
+type scriptedProvider struct {
+	model  models.Model
+	events []ProviderEvent
+	calls  int
+}
+
+func (s *scriptedProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	s.calls++
+	last := s.events[len(s.events)-1]
+	return last.Response, last.Error
+}
+
+func (s *scriptedProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	s.calls++
+	eventChan := make(chan ProviderEvent, len(s.events))
+	for _, event := range s.events {
+		eventChan <- event
+	}
+	close(eventChan)
+	return eventChan
+}
+
+func (s *scriptedProvider) Model() models.Model {
+	return s.model
+}
+
+func (s *scriptedProvider) Close() {}
+
+func TestFallbackProvider(t *testing.T) {
+	unreachable := &url.Error{Op: "Post", URL: "https://api.example.com", Err: syscall.ECONNREFUSED}
+	primary := &scriptedProvider{
+		model:  models.Model{ID: "primary", Name: "Primary"},
+		events: []ProviderEvent{{Type: EventContentStart}, {Type: EventError, Error: unreachable}},
+	}
+	invalid := &scriptedProvider{
+		model:  models.Model{ID: "invalid", Name: "Invalid"},
+		events: []ProviderEvent{{Type: EventError, Error: errors.New("prompt is too long")}},
+	}
+	response := &ProviderResponse{Content: "hi", FinishReason: message.FinishReasonEndTurn}
+	backup := &scriptedProvider{
+		model:  models.Model{ID: "backup", Name: "Backup"},
+		events: []ProviderEvent{{Type: EventContentDelta, Content: "hi"}, {Type: EventComplete, Response: response}},
+	}
+
+	p := NewFallbackProvider([]Provider{primary, backup}, nil)
+	assert.Equal(t, models.ModelID("primary"), p.Model().ID)
+
+	var events []ProviderEvent
+	for event := range p.StreamResponse(context.Background(), nil, nil) {
+		events = append(events, event)
+	}
+	require.Len(t, events, 4)
+	assert.Equal(t, EventContentStart, events[0].Type)
+	assert.Equal(t, EventFallback, events[1].Type)
+	assert.Equal(t, Fallback{From: primary.model, To: backup.model, Reason: config.FallbackOnConnection}, *events[1].Fallback)
+	assert.Equal(t, "Primary failed (connection), switching to Backup", events[1].Fallback.String())
+	assert.Equal(t, EventContentDelta, events[2].Type)
+	assert.Equal(t, EventComplete, events[3].Type)
+
+	// The primary model cools down
+	assert.Equal(t, models.ModelID("backup"), p.Model().ID)
+	got, err := p.SendMessages(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, response, got)
+	assert.Equal(t, 1, primary.calls)
+
+	// Errors another model wouldn't avoid aren't retried
+	p = NewFallbackProvider([]Provider{invalid, backup}, []config.FallbackCondition{config.FallbackOnConnection})
+	_, err = p.SendMessages(context.Background(), nil, nil)
+	assert.EqualError(t, err, "prompt is too long")
+	assert.Equal(t, 2, backup.calls)
+}
+
+func TestFallbackReason(t *testing.T) {
+	assert.Equal(t, config.FallbackOnConnection, FallbackReason(&url.Error{Op: "Post", URL: "http://localhost", Err: syscall.ECONNREFUSED}))
+	assert.Equal(t, config.FallbackOnOverloaded, FallbackReason(errors.New(`received error while streaming: {"type":"error","error":{"type":"overloaded_error"}}`)))
+	assert.Empty(t, FallbackReason(context.Canceled))
+	assert.Empty(t, FallbackReason(errors.New("invalid request")))
+}
+
+func TestPortableToolCallID(t *testing.T) {
+	assert.Equal(t, "call_abc", portableToolCallID("call_abc"))
+	assert.Len(t, portableToolCallID("call_0f8fad5b-d9cb-469f-a165-70867728950e"), 37)
+}
@@ -2,23 +2,28 @@ package provider
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/message"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/genai"
-	"google.golang.org/api/googleapi"
 )
 
+// geminiCacheDeleteTimeout bounds the deletion of cached content, which
+// happens on shutdown too.
+const geminiCacheDeleteTimeout = 5 * time.Second
+
 type geminiOptions struct {
 	disableCache bool
+	cacheTTL     time.Duration
 	baseURL      string
 	temperature   *float32
 	thinkingBudget *int32
@@ -30,6 +35,23 @@ type geminiClient struct {
 	providerOptions providerClientOptions
 	options         geminiOptions
 	client          *genai.Client
+	cache           geminiCache
+}
+
+// geminiCache is the cached content holding the system prompt and the tools
+// of the requests, which Gemini bills at a fraction of the input price.
+type geminiCache struct {
+	// group creates the cached content of a key once for the requests
+	// waiting on it.
+	group singleflight.Group
+
+	mu      sync.Mutex
+	key     string
+	name    string
+	expires time.Time
+	// rejected is the key of a prompt Gemini refused to cache, too short
+	// ones are, so it isn't tried again.
+	rejected string
 }
 
 type GeminiClient ProviderClient
@@ -65,6 +87,10 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 			var parts []*genai.Part
 			parts = append(parts, &genai.Part{Text: msg.Content().String()})
 			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsText() {
+					parts = append(parts, &genai.Part{Text: binaryContent.Text()})
+					continue
+				}
 				imageFormat := strings.Split(binaryContent.MIMEType, "/")
 				parts = append(parts, &genai.Part{InlineData: &genai.Blob{
 					MIMEType: imageFormat[1],
@@ -102,9 +128,11 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 			}
 
 		case message.Tool:
+			// Function responses only take JSON, images follow as user parts.
+			var images []*genai.Part
 			for _, result := range msg.ToolResults() {
-				response := map[string]interface{}{"result": result.Content}
-				parsed, err := parseJsonToMap(result.Content)
+				response := map[string]interface{}{"result": result.Text()}
+				parsed, err := parseJsonToMap(result.Text())
 				if err == nil {
 					response = parsed
 				}
@@ -132,6 +160,20 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 					},
 					Role: "function",
 				})
+
+				for _, image := range result.Images() {
+					imageFormat := strings.Split(image.MIMEType, "/")
+					images = append(images, &genai.Part{InlineData: &genai.Blob{
+						MIMEType: imageFormat[1],
+						Data:     image.Data,
+					}})
+				}
+			}
+			if len(images) > 0 {
+				history = append(history, &genai.Content{
+					Parts: append([]*genai.Part{{Text: "Images returned by the function calls above:"}}, images...),
+					Role:  "user",
+				})
 			}
 		}
 	}
@@ -215,71 +257,57 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 	if len(tools) > 0 {
 		config.Tools = g.convertTools(tools)
 	}
+	cacheTokens := g.applyCache(ctx, config)
 	chat, _ := g.client.Chats.Create(ctx, g.providerOptions.model.APIModel, config, history)
 
-	attempts := 0
-	for {
-		attempts++
-		var toolCalls []message.ToolCall
+	var toolCalls []message.ToolCall
 
-		var lastMsgParts []genai.Part
-		for _, part := range lastMsg.Parts {
-			lastMsgParts = append(lastMsgParts, *part)
-		}
-		resp, err := chat.SendMessage(ctx, lastMsgParts...)
-		// If there is an error we are going to see if we can retry the call
-		if err != nil {
-			retry, after, retryErr := g.shouldRetry(attempts, err)
-			if retryErr != nil {
-				return nil, retryErr
-			}
-			if retry {
-				logging.WarnPersist(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries), logging.PersistTimeArg, time.Millisecond*time.Duration(after+100))
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
-			}
-			return nil, retryErr
-		}
+	var lastMsgParts []genai.Part
+	for _, part := range lastMsg.Parts {
+		lastMsgParts = append(lastMsgParts, *part)
+	}
+	resp, err := chat.SendMessage(ctx, lastMsgParts...)
+	if err != nil {
+		g.checkCache(config, err)
+		return nil, err
+	}
 
-		content := ""
-
-		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-			for _, part := range resp.Candidates[0].Content.Parts {
-				switch {
-				case part.Text != "":
-					content = string(part.Text)
-				case part.FunctionCall != nil:
-					id := "call_" + uuid.New().String()
-					args, _ := json.Marshal(part.FunctionCall.Args)
-					toolCalls = append(toolCalls, message.ToolCall{
-						ID:       id,
-						Name:     part.FunctionCall.Name,
-						Input:    string(args),
-						Type:     "function",
-						Finished: true,
-					})
-				}
+	content := ""
+
+	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			switch {
+			case part.Text != "":
+				content = string(part.Text)
+			case part.FunctionCall != nil:
+				id := "call_" + uuid.New().String()
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				toolCalls = append(toolCalls, message.ToolCall{
+					ID:       id,
+					Name:     part.FunctionCall.Name,
+					Input:    string(args),
+					Type:     "function",
+					Finished: true,
+				})
 			}
 		}
-		finishReason := message.FinishReasonEndTurn
-		if len(resp.Candidates) > 0 {
-			finishReason = g.finishReason(resp.Candidates[0].FinishReason)
-		}
-		if len(toolCalls) > 0 {
-			finishReason = message.FinishReasonToolUse
-		}
-
-		return &ProviderResponse{
-			Content:      content,
-			ToolCalls:    toolCalls,
-			Usage:        g.usage(resp),
-			FinishReason: finishReason,
-		}, nil
 	}
+	finishReason := message.FinishReasonEndTurn
+	if len(resp.Candidates) > 0 {
+		finishReason = g.finishReason(resp.Candidates[0].FinishReason)
+	}
+	if len(toolCalls) > 0 {
+		finishReason = message.FinishReasonToolUse
+	}
+
+	usage := g.usage(resp)
+	usage.CacheCreationTokens = cacheTokens
+	return &ProviderResponse{
+		Content:      content,
+		ToolCalls:    toolCalls,
+		Usage:        usage,
+		FinishReason: finishReason,
+	}, nil
 }
 
 func (g *geminiClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
@@ -325,171 +353,213 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 	if len(tools) > 0 {
 		config.Tools = g.convertTools(tools)
 	}
+	cacheTokens := g.applyCache(ctx, config)
 	chat, _ := g.client.Chats.Create(ctx, g.providerOptions.model.APIModel, config, history)
 
-	attempts := 0
 	eventChan := make(chan ProviderEvent)
 
 	go func() {
 		defer close(eventChan)
 
-		for {
-			attempts++
-
-			currentContent := ""
-			toolCalls := []message.ToolCall{}
-			var finalResp *genai.GenerateContentResponse
+		currentContent := ""
+		toolCalls := []message.ToolCall{}
+		var finalResp *genai.GenerateContentResponse
 
-			eventChan <- ProviderEvent{Type: EventContentStart}
+		eventChan <- ProviderEvent{Type: EventContentStart}
 
-			var lastMsgParts []genai.Part
+		var lastMsgParts []genai.Part
 
-			for _, part := range lastMsg.Parts {
-				lastMsgParts = append(lastMsgParts, *part)
+		for _, part := range lastMsg.Parts {
+			lastMsgParts = append(lastMsgParts, *part)
+		}
+		for resp, err := range chat.SendMessageStream(ctx, lastMsgParts...) {
+			if err != nil {
+				g.checkCache(config, err)
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
 			}
-			StreamLoop:
-				for resp, err := range chat.SendMessageStream(ctx, lastMsgParts...) {
-					if err != nil {
-						retry, after, retryErr := g.shouldRetry(attempts, err)
-						if retryErr != nil {
-							eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-							return
-						}
-						if retry {
-							logging.WarnPersist(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries), logging.PersistTimeArg, time.Millisecond*time.Duration(after+100))
-							select {
-							case <-ctx.Done():
-								if ctx.Err() != nil {
-									eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-								}
-								return
-							case <-time.After(time.Duration(after) * time.Millisecond):
-							}
-							break StreamLoop
-						} else {
-							eventChan <- ProviderEvent{Type: EventError, Error: err}
-							return
-						}
-					}
 
-					finalResp = resp
-
-				if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
-					for _, part := range resp.Candidates[0].Content.Parts {
-						switch {
-						case part.Text != "":
-							delta := string(part.Text)
-							if delta != "" {
-								eventChan <- ProviderEvent{
-									Type:    EventContentDelta,
-									Content: delta,
-								}
-								currentContent += delta
-							}
-						case part.FunctionCall != nil:
-							id := "call_" + uuid.New().String()
-							args, _ := json.Marshal(part.FunctionCall.Args)
-							newCall := message.ToolCall{
-								ID:       id,
-								Name:     part.FunctionCall.Name,
-								Input:    string(args),
-								Type:     "function",
-								Finished: true,
+			finalResp = resp
+
+			if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+				for _, part := range resp.Candidates[0].Content.Parts {
+					switch {
+					case part.Text != "":
+						delta := string(part.Text)
+						if delta != "" {
+							eventChan <- ProviderEvent{
+								Type:    EventContentDelta,
+								Content: delta,
 							}
+							currentContent += delta
+						}
+					case part.FunctionCall != nil:
+						id := "call_" + uuid.New().String()
+						args, _ := json.Marshal(part.FunctionCall.Args)
+						newCall := message.ToolCall{
+							ID:       id,
+							Name:     part.FunctionCall.Name,
+							Input:    string(args),
+							Type:     "function",
+							Finished: true,
+						}
 
-							isNew := true
-							for _, existing := range toolCalls {
-								if existing.Name == newCall.Name && existing.Input == newCall.Input {
-									isNew = false
-									break
-								}
+						isNew := true
+						for _, existing := range toolCalls {
+							if existing.Name == newCall.Name && existing.Input == newCall.Input {
+								isNew = false
+								break
 							}
+						}
 
-							if isNew {
-								toolCalls = append(toolCalls, newCall)
-							}
+						if isNew {
+							toolCalls = append(toolCalls, newCall)
 						}
 					}
 				}
 			}
+		}
 
-			eventChan <- ProviderEvent{Type: EventContentStop}
+		eventChan <- ProviderEvent{Type: EventContentStop}
 
-			if finalResp != nil {
+		if finalResp != nil {
 
-				finishReason := message.FinishReasonEndTurn
-				if len(finalResp.Candidates) > 0 {
-					finishReason = g.finishReason(finalResp.Candidates[0].FinishReason)
-				}
-				if len(toolCalls) > 0 {
-					finishReason = message.FinishReasonToolUse
-				}
-				eventChan <- ProviderEvent{
-					Type: EventComplete,
-					Response: &ProviderResponse{
-						Content:      currentContent,
-						ToolCalls:    toolCalls,
-						Usage:        g.usage(finalResp),
-						FinishReason: finishReason,
-					},
-				}
-				return
+			finishReason := message.FinishReasonEndTurn
+			if len(finalResp.Candidates) > 0 {
+				finishReason = g.finishReason(finalResp.Candidates[0].FinishReason)
+			}
+			if len(toolCalls) > 0 {
+				finishReason = message.FinishReasonToolUse
+			}
+			usage := g.usage(finalResp)
+			usage.CacheCreationTokens = cacheTokens
+			eventChan <- ProviderEvent{
+				Type: EventComplete,
+				Response: &ProviderResponse{
+					Content:      currentContent,
+					ToolCalls:    toolCalls,
+					Usage:        usage,
+					FinishReason: finishReason,
+				},
 			}
-
 		}
 	}()
 
 	return eventChan
 }
 
-func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	// Check if error is a rate limit error
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	// Gemini doesn't have a standard error type we can check against
-	// So we'll check the error message for rate limit indicators
-	if errors.Is(err, io.EOF) {
-		return false, 0, err
-	}
-
-	var apiErr *googleapi.Error
-	isRateLimit := false
-	retryMs := 0
-
-	if errors.As(err, &apiErr) {
-		if apiErr.Code == 429 {
-			isRateLimit = true
-			retryAfterValues := apiErr.Header.Values("Retry-After")
-			if len(retryAfterValues) > 0 {
-				if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-					retryMs = retryMs * 1000 // Convert seconds to milliseconds
-				}
+// applyCache moves the system prompt and the tools of a request to cached
+// content, created again when they change or it is about to expire, and
+// returns the tokens written to it.
+func (g *geminiClient) applyCache(ctx context.Context, config *genai.GenerateContentConfig) int64 {
+	if g.options.disableCache {
+		return 0
+	}
+	data, _ := json.Marshal([]any{g.providerOptions.model.APIModel, config.SystemInstruction, config.Tools})
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+
+	g.cache.mu.Lock()
+	rejected := key == g.cache.rejected
+	name := g.cache.name
+	fresh := key == g.cache.key && time.Until(g.cache.expires) >= time.Minute
+	g.cache.mu.Unlock()
+	if rejected {
+		return 0
+	}
+
+	var written int64
+	if !fresh {
+		// Concurrent requests wait for the same cached content, created
+		// without holding the lock
+		created, err, _ := g.cache.group.Do(key, func() (any, error) {
+			cached, err := g.createCache(ctx, key, config)
+			if err == nil && cached.UsageMetadata != nil {
+				written = int64(cached.UsageMetadata.TotalTokenCount)
 			}
+			return cached, err
+		})
+		if err != nil {
+			return 0
 		}
+		name = created.(*genai.CachedContent).Name
 	}
+	config.CachedContent = name
+	config.SystemInstruction = nil
+	config.Tools = nil
+	return written
+}
 
-	// Fallback to checking error message for rate limit indicators if not already identified
-	if !isRateLimit {
-		errMsg := err.Error()
-		if contains(errMsg, "rate limit", "quota exceeded", "too many requests", "resource has been exhausted") {
-			isRateLimit = true
+// createCache creates the cached content of a request and deletes the one
+// it replaces.
+func (g *geminiClient) createCache(ctx context.Context, key string, config *genai.GenerateContentConfig) (*genai.CachedContent, error) {
+	ttl := g.options.cacheTTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	cached, err := g.client.Caches.Create(ctx, g.providerOptions.model.APIModel, &genai.CreateCachedContentConfig{
+		TTL:               ttl,
+		DisplayName:       "opencode-" + string(g.providerOptions.agent),
+		SystemInstruction: config.SystemInstruction,
+		Tools:             config.Tools,
+	})
+	if err != nil {
+		logging.Debug("Failed to cache the system prompt and tools", "error", err)
+		if StatusCode(err) == 400 {
+			g.cache.mu.Lock()
+			g.cache.rejected = key
+			g.cache.mu.Unlock()
 		}
+		return nil, err
 	}
 
-	if !isRateLimit {
-		return false, 0, err
+	g.cache.mu.Lock()
+	previous := g.cache.name
+	g.cache.key = key
+	g.cache.name = cached.Name
+	g.cache.expires = time.Now().Add(ttl)
+	if !cached.ExpireTime.IsZero() {
+		g.cache.expires = cached.ExpireTime
 	}
+	g.cache.mu.Unlock()
+	if previous != "" && previous != cached.Name {
+		g.deleteCache(previous)
+	}
+	return cached, nil
+}
 
-	// If retryMs was not set by Retry-After header, calculate backoff with jitter
-	if retryMs == 0 {
-		backoffMs := 10000 * (1 << (attempts - 1))
-		jitterMs := int(float64(backoffMs) * 0.2)
-		retryMs = backoffMs + jitterMs
+// deleteCache deletes cached content rather than leaving it billed until it
+// expires.
+func (g *geminiClient) deleteCache(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), geminiCacheDeleteTimeout)
+	defer cancel()
+	if _, err := g.client.Caches.Delete(ctx, name, nil); err != nil {
+		logging.Debug("Failed to delete the cached system prompt and tools", "name", name, "error", err)
 	}
+}
 
-	return true, int64(retryMs), nil
+// close deletes the cached content of the client.
+func (g *geminiClient) close() {
+	g.cache.mu.Lock()
+	name := g.cache.name
+	g.cache.key, g.cache.name = "", ""
+	g.cache.mu.Unlock()
+	if name != "" {
+		g.deleteCache(name)
+	}
+}
+
+// checkCache forgets the cached content a request failed to find, the next
+// attempt creates it again.
+func (g *geminiClient) checkCache(config *genai.GenerateContentConfig, err error) {
+	if config.CachedContent == "" || !contains(err.Error(), "cachedcontent", "cached content") {
+		return
+	}
+	g.cache.mu.Lock()
+	defer g.cache.mu.Unlock()
+	if g.cache.name == config.CachedContent {
+		g.cache.key = ""
+	}
 }
 
 func (g *geminiClient) toolCalls(resp *genai.GenerateContentResponse) []message.ToolCall {
@@ -518,11 +588,12 @@ func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
 		return TokenUsage{}
 	}
 
+	// The prompt tokens include the cached ones
+	cachedTokens := int64(resp.UsageMetadata.CachedContentTokenCount)
 	return TokenUsage{
-		InputTokens:         int64(resp.UsageMetadata.PromptTokenCount),
-		OutputTokens:        int64(resp.UsageMetadata.CandidatesTokenCount),
-		CacheCreationTokens: 0, // Not directly provided by Gemini
-		CacheReadTokens:     int64(resp.UsageMetadata.CachedContentTokenCount),
+		InputTokens:     int64(resp.UsageMetadata.PromptTokenCount) - cachedTokens,
+		OutputTokens:    int64(resp.UsageMetadata.CandidatesTokenCount),
+		CacheReadTokens: cachedTokens,
 	}
 }
 
@@ -538,6 +609,14 @@ func WithGeminiDisableCache() GeminiOption {
 	}
 }
 
+// WithGeminiCacheTTL sets how long the cached content holding the system
+// prompt and the tools lives, an hour by default.
+func WithGeminiCacheTTL(ttl time.Duration) GeminiOption {
+	return func(options *geminiOptions) {
+		options.cacheTTL = ttl
+	}
+}
+
 func WithGeminiBaseURL(baseURL string) GeminiOption {
 	return func(options *geminiOptions) {
 		options.baseURL = baseURL
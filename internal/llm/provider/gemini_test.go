@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiCache(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+
+	var caches []map[string]any
+	var requests []map[string]any
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1beta/"))
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/cachedContents"):
+			caches = append(caches, request)
+			name := fmt.Sprintf("cachedContents/c%d", len(caches))
+			fmt.Fprintf(w, `{"name":%q,"expireTime":%q,"usageMetadata":{"totalTokenCount":4000}}`,
+				name, time.Now().Add(time.Hour).Format(time.RFC3339))
+		case strings.HasSuffix(r.URL.Path, ":generateContent"):
+			requests = append(requests, request)
+			if request["cachedContent"] == "cachedContents/c1" && len(requests) == 3 {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":{"code":403,"message":"CachedContent not found (or permission denied)","status":"PERMISSION_DENIED"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Sunny."}]},"finishReason":"STOP"}],`+
+				`"usageMetadata":{"promptTokenCount":4100,"cachedContentTokenCount":4000,"candidatesTokenCount":3}}`)
+		default:
+			t.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider(models.ProviderGemini,
+		WithAPIKey("test"),
+		WithAgent(config.AgentCoder),
+		WithModel(models.SupportedModels[models.Gemini20Flash]),
+		WithMaxTokens(1024),
+		WithSystemMessage("Be brief."),
+		WithGeminiOptions(WithGeminiBaseURL(srv.URL), WithGeminiCacheTTL(30*time.Minute)),
+	)
+	require.NoError(t, err)
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Weather in Oslo?"}}},
+	}
+	response, err := p.SendMessages(context.Background(), messages, []tools.BaseTool{weatherTool{}})
+	require.NoError(t, err)
+	assert.Equal(t, TokenUsage{InputTokens: 100, OutputTokens: 3, CacheCreationTokens: 4000, CacheReadTokens: 4000}, response.Usage)
+	require.Len(t, caches, 1)
+	assert.Equal(t, "1800s", caches[0]["ttl"])
+	assert.NotNil(t, caches[0]["systemInstruction"])
+	assert.NotNil(t, caches[0]["tools"])
+	assert.Equal(t, "cachedContents/c1", requests[0]["cachedContent"])
+	assert.Nil(t, requests[0]["systemInstruction"])
+	assert.Nil(t, requests[0]["tools"])
+
+	// The cached content is reused until it is gone
+	response, err = p.SendMessages(context.Background(), messages, []tools.BaseTool{weatherTool{}})
+	require.NoError(t, err)
+	assert.Zero(t, response.Usage.CacheCreationTokens)
+	assert.Len(t, caches, 1)
+
+	_, err = p.SendMessages(context.Background(), messages, []tools.BaseTool{weatherTool{}})
+	require.Error(t, err)
+	_, err = p.SendMessages(context.Background(), messages, []tools.BaseTool{weatherTool{}})
+	require.NoError(t, err)
+	assert.Len(t, caches, 2)
+	assert.Equal(t, "cachedContents/c2", requests[3]["cachedContent"])
+	// The replaced cached content is deleted, and the last one on close
+	assert.Equal(t, []string{"cachedContents/c1"}, deleted)
+	p.Close()
+	assert.Equal(t, []string{"cachedContents/c1", "cachedContents/c2"}, deleted)
+}
@@ -0,0 +1,336 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// CassetteEnv names the cassette the mock provider replays.
+	CassetteEnv = "OPENCODE_CASSETTE"
+	// RecordCassetteEnv names a cassette every other provider records its
+	// responses to.
+	RecordCassetteEnv = "OPENCODE_RECORD_CASSETTE"
+)
+
+// Cassette is a script of provider responses, replayed in order by the mock
+// provider. Cassettes are JSON, or YAML when the file name ends in .yaml or
+// .yml.
+type Cassette struct {
+	Turns []CassetteTurn `json:"turns" yaml:"turns"`
+
+	mu   sync.Mutex
+	used []bool
+	path string
+}
+
+// CassetteTurn is the response to one request.
+type CassetteTurn struct {
+	// Agent is the agent the turn is for, so the title and coder agents each
+	// get their own turns however their requests interleave. Turns without
+	// it are for any agent.
+	Agent  config.AgentName `json:"agent,omitempty" yaml:"agent,omitempty"`
+	Events []CassetteEvent  `json:"events" yaml:"events"`
+	// Usage is reported when the events don't end with a complete event.
+	Usage TokenUsage `json:"usage" yaml:"usage"`
+}
+
+// CassetteEvent is a ProviderEvent that can be written to a file.
+type CassetteEvent struct {
+	Type     EventType         `json:"type" yaml:"type"`
+	Content  string            `json:"content,omitempty" yaml:"content,omitempty"`
+	Thinking string            `json:"thinking,omitempty" yaml:"thinking,omitempty"`
+	ToolCall *message.ToolCall `json:"toolCall,omitempty" yaml:"toolCall,omitempty"`
+	Response *CassetteResponse `json:"response,omitempty" yaml:"response,omitempty"`
+	Error    string            `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type CassetteResponse struct {
+	Content      string               `json:"content,omitempty" yaml:"content,omitempty"`
+	ToolCalls    []message.ToolCall   `json:"toolCalls,omitempty" yaml:"toolCalls,omitempty"`
+	Usage        TokenUsage           `json:"usage" yaml:"usage"`
+	FinishReason message.FinishReason `json:"finishReason" yaml:"finishReason"`
+}
+
+var (
+	cassettesMu sync.Mutex
+	// cassettes are shared by all providers of the process, so every turn is
+	// replayed once and every turn is recorded to the same file.
+	cassettes = map[string]*Cassette{}
+)
+
+// LoadCassette reads the cassette at path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{path: path}
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, c)
+	} else {
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	c.used = make([]bool, len(c.Turns))
+	return c, nil
+}
+
+// sharedCassette returns the cassette at path, loading it the first time
+// unless it is recorded.
+func sharedCassette(path string, record bool) (*Cassette, error) {
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+	if c, ok := cassettes[path]; ok {
+		return c, nil
+	}
+	c := &Cassette{path: path}
+	if !record {
+		var err error
+		if c, err = LoadCassette(path); err != nil {
+			return nil, err
+		}
+	}
+	cassettes[path] = c
+	return c, nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// next returns the first unused turn for the agent.
+func (c *Cassette) next(agent config.AgentName) (CassetteTurn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, turn := range c.Turns {
+		if c.used[i] || turn.Agent != "" && turn.Agent != agent {
+			continue
+		}
+		c.used[i] = true
+		return turn, nil
+	}
+	return CassetteTurn{}, errors.New("the cassette has no more turns")
+}
+
+// record appends a turn and writes the cassette, so it is complete whenever
+// the process ends.
+func (c *Cassette) record(turn CassetteTurn) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Turns = append(c.Turns, turn)
+
+	var data []byte
+	var err error
+	if isYAML(c.path) {
+		data, err = yaml.Marshal(c)
+	} else {
+		data, err = json.MarshalIndent(c, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+func (e CassetteEvent) event() ProviderEvent {
+	event := ProviderEvent{
+		Type:     e.Type,
+		Content:  e.Content,
+		Thinking: e.Thinking,
+		ToolCall: e.ToolCall,
+	}
+	if e.Response != nil {
+		event.Response = &ProviderResponse{
+			Content:      e.Response.Content,
+			ToolCalls:    e.Response.ToolCalls,
+			Usage:        e.Response.Usage,
+			FinishReason: e.Response.FinishReason,
+		}
+	}
+	if e.Type == EventError {
+		event.Error = errors.New(cmp.Or(e.Error, "provider error"))
+	}
+	return event
+}
+
+func newCassetteEvent(event ProviderEvent) CassetteEvent {
+	e := CassetteEvent{
+		Type:     event.Type,
+		Content:  event.Content,
+		Thinking: event.Thinking,
+		ToolCall: event.ToolCall,
+	}
+	if event.Response != nil {
+		e.Response = &CassetteResponse{
+			Content:      event.Response.Content,
+			ToolCalls:    event.Response.ToolCalls,
+			Usage:        event.Response.Usage,
+			FinishReason: event.Response.FinishReason,
+		}
+	}
+	if event.Error != nil {
+		e.Error = event.Error.Error()
+	}
+	return e
+}
+
+// events returns the provider events of the turn. Turns that don't end
+// with a complete or error event get a complete event made of their
+// content and tool calls, so they are easy to write by hand.
+func (t CassetteTurn) events() []ProviderEvent {
+	var events []ProviderEvent
+	var content strings.Builder
+	var toolCalls []message.ToolCall
+	for _, e := range t.Events {
+		event := e.event()
+		events = append(events, event)
+		switch event.Type {
+		case EventComplete, EventError:
+			return events
+		case EventContentDelta:
+			content.WriteString(event.Content)
+		case EventToolUseStart:
+			call := *event.ToolCall
+			call.Finished = true
+			toolCalls = append(toolCalls, call)
+		}
+	}
+
+	finishReason := message.FinishReasonEndTurn
+	if len(toolCalls) > 0 {
+		finishReason = message.FinishReasonToolUse
+	}
+	return append(events, ProviderEvent{
+		Type: EventComplete,
+		Response: &ProviderResponse{
+			Content:      content.String(),
+			ToolCalls:    toolCalls,
+			Usage:        t.Usage,
+			FinishReason: finishReason,
+		},
+	})
+}
+
+// mockClient replays the turns of a cassette instead of calling a model.
+type mockClient struct {
+	providerOptions providerClientOptions
+	cassette        *Cassette
+	err             error
+}
+
+type MockClient ProviderClient
+
+func newMockClient(opts providerClientOptions) MockClient {
+	path := os.Getenv(CassetteEnv)
+	if path == "" {
+		return &mockClient{err: fmt.Errorf("%s is not set", CassetteEnv)}
+	}
+	cassette, err := sharedCassette(path, false)
+	return &mockClient{providerOptions: opts, cassette: cassette, err: err}
+}
+
+func (m *mockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	turn, err := m.cassette.next(m.providerOptions.agent)
+	if err != nil {
+		return nil, err
+	}
+	events := turn.events()
+	last := events[len(events)-1]
+	if last.Type == EventError {
+		return nil, last.Error
+	}
+	return last.Response, nil
+}
+
+func (m *mockClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		if m.err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: m.err}
+			return
+		}
+		turn, err := m.cassette.next(m.providerOptions.agent)
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+		for _, event := range turn.events() {
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return eventChan
+}
+
+// recordingProvider records the responses of a provider to a cassette.
+type recordingProvider struct {
+	Provider
+	agent    config.AgentName
+	cassette *Cassette
+}
+
+func newRecordingProvider(p Provider, agent config.AgentName, path string) (Provider, error) {
+	cassette, err := sharedCassette(path, true)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingProvider{Provider: p, agent: agent, cassette: cassette}, nil
+}
+
+func (r *recordingProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	response, err := r.Provider.SendMessages(ctx, messages, tools)
+	event := ProviderEvent{Type: EventComplete, Response: response}
+	if err != nil {
+		event = ProviderEvent{Type: EventError, Error: err}
+	}
+	r.save([]CassetteEvent{newCassetteEvent(event)})
+	return response, err
+}
+
+func (r *recordingProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		var recorded []CassetteEvent
+		defer func() { r.save(recorded) }()
+		for event := range r.Provider.StreamResponse(ctx, messages, tools) {
+			recorded = append(recorded, newCassetteEvent(event))
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return eventChan
+}
+
+func (r *recordingProvider) save(events []CassetteEvent) {
+	if err := r.cassette.record(CassetteTurn{Agent: r.agent, Events: events}); err != nil {
+		logging.Error("Failed to record cassette", "path", r.cassette.path, "error", err)
+	}
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(events <-chan ProviderEvent) []ProviderEvent {
+	var all []ProviderEvent
+	for event := range events {
+		all = append(all, event)
+	}
+	return all
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.json")
+	require.NoError(t, os.WriteFile(script, []byte(`{"turns": [
+		{"agent": "title", "events": [{"type": "content_delta", "content": "A title"}]},
+		{"events": [
+			{"type": "thinking_delta", "thinking": "hmm"},
+			{"type": "tool_use_start", "toolCall": {"id": "call_1", "name": "ls", "input": "{}"}}
+		], "usage": {"inputTokens": 10, "outputTokens": 2}},
+		{"events": [{"type": "error", "error": "overloaded"}]}
+	]}`), 0o644))
+	t.Setenv(CassetteEnv, script)
+
+	replay, err := NewProvider(models.ProviderMock, WithAgent(config.AgentCoder))
+	require.NoError(t, err)
+	recorded := filepath.Join(dir, "recorded.yaml")
+	recorder, err := newRecordingProvider(replay, config.AgentCoder, recorded)
+	require.NoError(t, err)
+
+	messages := []message.Message{{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "hi"}}}}
+	first := collect(recorder.StreamResponse(context.Background(), messages, nil))
+	require.Len(t, first, 3)
+	assert.Equal(t, EventThinkingDelta, first[0].Type)
+	assert.Equal(t, "call_1", first[1].ToolCall.ID)
+	assert.Equal(t, &ProviderResponse{
+		ToolCalls:    []message.ToolCall{{ID: "call_1", Name: "ls", Input: "{}", Finished: true}},
+		Usage:        TokenUsage{InputTokens: 10, OutputTokens: 2},
+		FinishReason: message.FinishReasonToolUse,
+	}, first[2].Response)
+
+	_, err = recorder.SendMessages(context.Background(), messages, nil)
+	assert.EqualError(t, err, "overloaded")
+	_, err = recorder.SendMessages(context.Background(), messages, nil)
+	assert.EqualError(t, err, "the cassette has no more turns")
+
+	title, err := NewProvider(models.ProviderMock, WithAgent(config.AgentTitle))
+	require.NoError(t, err)
+	response, err := title.SendMessages(context.Background(), messages, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "A title", response.Content)
+
+	cassette, err := LoadCassette(recorded)
+	require.NoError(t, err)
+	require.Len(t, cassette.Turns, 3)
+	assert.Equal(t, config.AgentCoder, cassette.Turns[0].Agent)
+	assert.Equal(t, first, cassette.Turns[0].events())
+	assert.Equal(t, []CassetteEvent{{Type: EventError, Error: "overloaded"}}, cassette.Turns[1].Events)
+}
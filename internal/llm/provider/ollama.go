@@ -0,0 +1,370 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+type ollamaOptions struct {
+	baseURL   string
+	keepAlive string
+}
+
+type OllamaOption func(*ollamaOptions)
+
+// ollamaClient talks to the native chat API of Ollama, which unlike its
+// OpenAI compatible API takes the context window and keep alive per request.
+type ollamaClient struct {
+	providerOptions providerClientOptions
+	options         ollamaOptions
+	client          *http.Client
+}
+
+type OllamaClient ProviderClient
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    [][]byte         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string             `json:"type"`
+	Function ollamaToolFunction `json:"function"`
+}
+
+type ollamaToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Tools     []ollamaTool    `json:"tools,omitempty"`
+	Stream    bool            `json:"stream"`
+	Think     bool            `json:"think,omitempty"`
+	KeepAlive any             `json:"keep_alive,omitempty"`
+	Options   map[string]any  `json:"options"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int64         `json:"prompt_eval_count"`
+	EvalCount       int64         `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func newOllamaClient(opts providerClientOptions) OllamaClient {
+	ollamaOpts := ollamaOptions{}
+	for _, o := range opts.ollamaOptions {
+		o(&ollamaOpts)
+	}
+	return &ollamaClient{
+		providerOptions: opts,
+		options:         ollamaOpts,
+		client:          &http.Client{},
+	}
+}
+
+func (o *ollamaClient) convertMessages(messages []message.Message) []ollamaMessage {
+	ollamaMessages := []ollamaMessage{{Role: "system", Content: o.providerOptions.systemMessage}}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			userMsg := ollamaMessage{Role: "user", Content: msg.Content().String()}
+			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsText() {
+					userMsg.Content += "\n\n" + binaryContent.Text()
+					continue
+				}
+				userMsg.Images = append(userMsg.Images, binaryContent.Data)
+			}
+			ollamaMessages = append(ollamaMessages, userMsg)
+
+		case message.Assistant:
+			assistantMsg := ollamaMessage{Role: "assistant", Content: msg.Content().String()}
+			for _, call := range msg.ToolCalls() {
+				var toolCall ollamaToolCall
+				toolCall.Function.Name = call.Name
+				toolCall.Function.Arguments = json.RawMessage(call.Input)
+				if !json.Valid(toolCall.Function.Arguments) {
+					toolCall.Function.Arguments = json.RawMessage("{}")
+				}
+				assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, toolCall)
+			}
+			ollamaMessages = append(ollamaMessages, assistantMsg)
+
+		case message.Tool:
+			// Tool messages only take text, images follow in a user message.
+			var images [][]byte
+			for _, result := range msg.ToolResults() {
+				ollamaMessages = append(ollamaMessages, ollamaMessage{
+					Role:     "tool",
+					Content:  result.Text(),
+					ToolName: result.Name,
+				})
+				for _, image := range result.Images() {
+					images = append(images, image.Data)
+				}
+			}
+			if len(images) > 0 {
+				ollamaMessages = append(ollamaMessages, ollamaMessage{
+					Role:    "user",
+					Content: "Images returned by the tool calls above:",
+					Images:  images,
+				})
+			}
+		}
+	}
+
+	return ollamaMessages
+}
+
+func (o *ollamaClient) convertTools(tools []tools.BaseTool) []ollamaTool {
+	ollamaTools := make([]ollamaTool, len(tools))
+	for i, tool := range tools {
+		info := tool.Info()
+		ollamaTools[i] = ollamaTool{
+			Type: "function",
+			Function: ollamaToolFunction{
+				Name:        info.Name,
+				Description: info.Description,
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": info.Parameters,
+					"required":   info.Required,
+				},
+			},
+		}
+	}
+	return ollamaTools
+}
+
+func (o *ollamaClient) preparedRequest(messages []message.Message, tools []tools.BaseTool, stream bool) ollamaChatRequest {
+	model := o.providerOptions.model
+	options := map[string]any{
+		"num_ctx":     model.ContextWindow,
+		"num_predict": o.providerOptions.maxTokens,
+	}
+	if o.providerOptions.temperature > 0 {
+		options["temperature"] = o.providerOptions.temperature
+	}
+	request := ollamaChatRequest{
+		Model:    model.APIModel,
+		Messages: o.convertMessages(messages),
+		Tools:    o.convertTools(tools),
+		Stream:   stream,
+		Think:    model.CanReason,
+		Options:  options,
+	}
+	// Ollama takes a number of seconds or a duration
+	if seconds, err := strconv.Atoi(o.options.keepAlive); err == nil {
+		request.KeepAlive = seconds
+	} else if o.options.keepAlive != "" {
+		request.KeepAlive = o.options.keepAlive
+	}
+	return request
+}
+
+// chat posts a chat request and returns the body of a successful response.
+func (o *ollamaClient) chat(ctx context.Context, request ollamaChatRequest) (io.ReadCloser, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	if config.Get().Debug {
+		logging.Debug("Prepared messages", "messages", string(body))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.options.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, models.OllamaError(resp)
+	}
+	return resp.Body, nil
+}
+
+func (o *ollamaClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	body, err := o.chat(ctx, o.preparedRequest(messages, tools, false))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var chatResponse ollamaChatResponse
+	if err := json.NewDecoder(body).Decode(&chatResponse); err != nil {
+		return nil, err
+	}
+	if chatResponse.Error != "" {
+		return nil, errors.New("ollama: " + chatResponse.Error)
+	}
+	return o.response(chatResponse.Message.Content, o.toolCalls(chatResponse.Message), chatResponse), nil
+}
+
+func (o *ollamaClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	request := o.preparedRequest(messages, tools, true)
+	eventChan := make(chan ProviderEvent)
+
+	go func() {
+		defer close(eventChan)
+
+		body, err := o.chat(ctx, request)
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: o.contextError(ctx, err)}
+			return
+		}
+		defer body.Close()
+
+		eventChan <- ProviderEvent{Type: EventContentStart}
+
+		var content strings.Builder
+		var toolCalls []message.ToolCall
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var chunk ollamaChatResponse
+			if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+				continue
+			}
+			if chunk.Error != "" {
+				eventChan <- ProviderEvent{Type: EventError, Error: errors.New("ollama: " + chunk.Error)}
+				return
+			}
+			if chunk.Message.Thinking != "" {
+				eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: chunk.Message.Thinking}
+			}
+			if chunk.Message.Content != "" {
+				eventChan <- ProviderEvent{Type: EventContentDelta, Content: chunk.Message.Content}
+				content.WriteString(chunk.Message.Content)
+			}
+			for _, call := range o.toolCalls(chunk.Message) {
+				eventChan <- ProviderEvent{Type: EventToolUseStart, ToolCall: &call}
+				eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &call}
+				toolCalls = append(toolCalls, call)
+			}
+			if chunk.Done {
+				eventChan <- ProviderEvent{Type: EventContentStop}
+				eventChan <- ProviderEvent{
+					Type:     EventComplete,
+					Response: o.response(content.String(), toolCalls, chunk),
+				}
+				return
+			}
+		}
+
+		err = scanner.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		eventChan <- ProviderEvent{Type: EventError, Error: o.contextError(ctx, err)}
+	}()
+
+	return eventChan
+}
+
+// contextError prefers the error of a cancelled request to the error of the
+// connection it closed.
+func (o *ollamaClient) contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// toolCalls converts the calls of a message, giving them the IDs Ollama
+// leaves out.
+func (o *ollamaClient) toolCalls(msg ollamaMessage) []message.ToolCall {
+	var toolCalls []message.ToolCall
+	for _, call := range msg.ToolCalls {
+		input := string(call.Function.Arguments)
+		if input == "" || input == "null" {
+			input = "{}"
+		}
+		toolCalls = append(toolCalls, message.ToolCall{
+			ID:       "call_" + uuid.New().String(),
+			Name:     call.Function.Name,
+			Input:    input,
+			Type:     "function",
+			Finished: true,
+		})
+	}
+	return toolCalls
+}
+
+func (o *ollamaClient) response(content string, toolCalls []message.ToolCall, final ollamaChatResponse) *ProviderResponse {
+	finishReason := o.finishReason(final.DoneReason)
+	if len(toolCalls) > 0 {
+		finishReason = message.FinishReasonToolUse
+	}
+	return &ProviderResponse{
+		Content:   content,
+		ToolCalls: toolCalls,
+		Usage: TokenUsage{
+			InputTokens:  final.PromptEvalCount,
+			OutputTokens: final.EvalCount,
+		},
+		FinishReason: finishReason,
+	}
+}
+
+func (o *ollamaClient) finishReason(reason string) message.FinishReason {
+	switch reason {
+	case "stop":
+		return message.FinishReasonEndTurn
+	case "length":
+		return message.FinishReasonMaxTokens
+	default:
+		return message.FinishReasonUnknown
+	}
+}
+
+func WithOllamaBaseURL(baseURL string) OllamaOption {
+	return func(options *ollamaOptions) {
+		options.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithOllamaKeepAlive sets how long Ollama keeps the model loaded after a
+// request, as a duration like "10m" or a number of seconds, negative to keep
+// it loaded.
+func WithOllamaKeepAlive(keepAlive string) OllamaOption {
+	return func(options *ollamaOptions) {
+		options.keepAlive = keepAlive
+	}
+}
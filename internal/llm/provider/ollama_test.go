@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type weatherTool struct{}
+
+func (weatherTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name:        "weather",
+		Description: "Current weather of a city",
+		Parameters:  map[string]any{"city": map[string]any{"type": "string"}},
+		Required:    []string{"city"},
+	}
+}
+
+func (weatherTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.NewTextResponse("sunny"), nil
+}
+
+func TestOllamaStream(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+
+	var request map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/chat", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		for _, line := range []string{
+			`{"message":{"role":"assistant","content":"","thinking":"rain?"},"done":false}`,
+			`{"message":{"role":"assistant","content":"Let me check."},"done":false}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Oslo"}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":42,"eval_count":7}`,
+		} {
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider(models.ProviderOllama,
+		WithModel(models.Model{Provider: models.ProviderOllama, APIModel: "qwen3:8b", ContextWindow: 8192, CanReason: true}),
+		WithMaxTokens(1024),
+		WithSystemMessage("Be brief."),
+		WithOllamaOptions(WithOllamaBaseURL(srv.URL), WithOllamaKeepAlive("-1")),
+	)
+	require.NoError(t, err)
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Weather in Oslo?"}}},
+	}
+	var thinking, content string
+	var started []string
+	var response *ProviderResponse
+	for event := range p.StreamResponse(context.Background(), messages, []tools.BaseTool{weatherTool{}}) {
+		switch event.Type {
+		case EventThinkingDelta:
+			thinking += event.Thinking
+		case EventContentDelta:
+			content += event.Content
+		case EventToolUseStart:
+			started = append(started, event.ToolCall.Name)
+		case EventComplete:
+			response = event.Response
+		case EventError:
+			t.Fatal(event.Error)
+		}
+	}
+
+	assert.Equal(t, "qwen3:8b", request["model"])
+	assert.Equal(t, true, request["think"])
+	assert.Equal(t, float64(-1), request["keep_alive"])
+	assert.Equal(t, map[string]any{"num_ctx": float64(8192), "num_predict": float64(1024)}, request["options"])
+	assert.Len(t, request["tools"], 1)
+	assert.Len(t, request["messages"], 2)
+
+	assert.Equal(t, "rain?", thinking)
+	assert.Equal(t, "Let me check.", content)
+	assert.Equal(t, []string{"weather"}, started)
+	require.NotNil(t, response)
+	assert.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, `{"city":"Oslo"}`, response.ToolCalls[0].Input)
+	assert.NotEmpty(t, response.ToolCalls[0].ID)
+	assert.Equal(t, TokenUsage{InputTokens: 42, OutputTokens: 7}, response.Usage)
+}
+
+func TestOllamaNoTools(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.NotContains(t, request, "tools")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"gemma\" not found, try pulling it first"}`)
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider(models.ProviderOllama,
+		WithModel(models.Model{Provider: models.ProviderOllama, APIModel: "gemma", NoTools: true}),
+		WithOllamaOptions(WithOllamaBaseURL(srv.URL)),
+	)
+	require.NoError(t, err)
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Hi"}}},
+	}
+	_, err = p.SendMessages(context.Background(), messages, []tools.BaseTool{weatherTool{}})
+	assert.EqualError(t, err, `ollama: model "gemma" not found, try pulling it first (404 Not Found)`)
+}
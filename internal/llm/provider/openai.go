@@ -4,9 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
-	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -43,7 +41,8 @@ func newOpenAIClient(opts providerClientOptions) OpenAIClient {
 		o(&openaiOpts)
 	}
 
-	openaiClientOptions := []option.RequestOption{}
+	// Requests are retried by the resilient client
+	openaiClientOptions := []option.RequestOption{option.WithMaxRetries(0)}
 	if opts.apiKey != "" {
 		openaiClientOptions = append(openaiClientOptions, option.WithAPIKey(opts.apiKey))
 	}
@@ -76,6 +75,11 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 			textBlock := openai.ChatCompletionContentPartTextParam{Text: msg.Content().String()}
 			content = append(content, openai.ChatCompletionContentPartUnionParam{OfText: &textBlock})
 			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsText() {
+					textBlock := openai.ChatCompletionContentPartTextParam{Text: binaryContent.Text()}
+					content = append(content, openai.ChatCompletionContentPartUnionParam{OfText: &textBlock})
+					continue
+				}
 				imageURL := openai.ChatCompletionContentPartImageImageURLParam{URL: binaryContent.String(models.ProviderOpenAI)}
 				imageBlock := openai.ChatCompletionContentPartImageParam{ImageURL: imageURL}
 
@@ -114,10 +118,21 @@ func (o *openaiClient) convertMessages(messages []message.Message) (openaiMessag
 			})
 
 		case message.Tool:
+			// Tool messages only take text, images follow in a user message.
+			var images []openai.ChatCompletionContentPartUnionParam
 			for _, result := range msg.ToolResults() {
 				openaiMessages = append(openaiMessages,
-					openai.ToolMessage(result.Content, result.ToolCallID),
+					openai.ToolMessage(result.Text(), result.ToolCallID),
 				)
+				for _, image := range result.Images() {
+					imageURL := openai.ChatCompletionContentPartImageImageURLParam{URL: image.String(models.ProviderOpenAI)}
+					images = append(images, openai.ChatCompletionContentPartUnionParam{OfImageURL: &openai.ChatCompletionContentPartImageParam{ImageURL: imageURL}})
+				}
+			}
+			if len(images) > 0 {
+				textBlock := openai.ChatCompletionContentPartTextParam{Text: "Images returned by the tool calls above:"}
+				content := append([]openai.ChatCompletionContentPartUnionParam{{OfText: &textBlock}}, images...)
+				openaiMessages = append(openaiMessages, openai.UserMessage(content))
 			}
 		}
 	}
@@ -181,10 +196,23 @@ func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessagePar
 	} else {
 		params.MaxTokens = openai.Int(o.providerOptions.maxTokens)
 	}
+	if key := o.promptCacheKey(); key != "" {
+		params.WithExtraFields(map[string]any{"prompt_cache_key": key})
+	}
 
 	return params
 }
 
+// promptCacheKey groups the requests of an agent, so OpenAI, which caches
+// prompts on its own, routes them to the same cache. Other providers speaking
+// its API may reject the field.
+func (o *openaiClient) promptCacheKey() string {
+	if o.options.disableCache || o.providerOptions.model.Provider != models.ProviderOpenAI || o.providerOptions.agent == "" {
+		return ""
+	}
+	return "opencode-" + string(o.providerOptions.agent)
+}
+
 func (o *openaiClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (response *ProviderResponse, err error) {
 	params := o.preparedParams(o.convertMessages(messages), o.convertTools(tools))
 	cfg := config.Get()
@@ -192,50 +220,32 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 		jsonData, _ := json.Marshal(params)
 		logging.Debug("Prepared messages", "messages", string(jsonData))
 	}
-	attempts := 0
-	for {
-		attempts++
-		openaiResponse, err := o.client.Chat.Completions.New(
-			ctx,
-			params,
-		)
-		// If there is an error we are going to see if we can retry the call
-		if err != nil {
-			retry, after, retryErr := o.shouldRetry(attempts, err)
-			if retryErr != nil {
-				return nil, retryErr
-			}
-			if retry {
-				logging.WarnPersist(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries), logging.PersistTimeArg, time.Millisecond*time.Duration(after+100))
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
-			}
-			return nil, retryErr
-		}
-
-		content := ""
-		if openaiResponse.Choices[0].Message.Content != "" {
-			content = openaiResponse.Choices[0].Message.Content
-		}
+	openaiResponse, err := o.client.Chat.Completions.New(
+		ctx,
+		params,
+	)
+	if err != nil {
+		return nil, err
+	}
 
-		toolCalls := o.toolCalls(*openaiResponse)
-		finishReason := o.finishReason(string(openaiResponse.Choices[0].FinishReason))
+	content := ""
+	if openaiResponse.Choices[0].Message.Content != "" {
+		content = openaiResponse.Choices[0].Message.Content
+	}
 
-		if len(toolCalls) > 0 {
-			finishReason = message.FinishReasonToolUse
-		}
+	toolCalls := o.toolCalls(*openaiResponse)
+	finishReason := o.finishReason(string(openaiResponse.Choices[0].FinishReason))
 
-		return &ProviderResponse{
-			Content:      content,
-			ToolCalls:    toolCalls,
-			Usage:        o.usage(*openaiResponse),
-			FinishReason: finishReason,
-		}, nil
+	if len(toolCalls) > 0 {
+		finishReason = message.FinishReasonToolUse
 	}
+
+	return &ProviderResponse{
+		Content:      content,
+		ToolCalls:    toolCalls,
+		Usage:        o.usage(*openaiResponse),
+		FinishReason: finishReason,
+	}, nil
 }
 
 func (o *openaiClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
@@ -250,118 +260,67 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 		logging.Debug("Prepared messages", "messages", string(jsonData))
 	}
 
-	attempts := 0
 	eventChan := make(chan ProviderEvent)
 
 	go func() {
-		for {
-			attempts++
-			openaiStream := o.client.Chat.Completions.NewStreaming(
-				ctx,
-				params,
-			)
-
-			acc := openai.ChatCompletionAccumulator{}
-			currentContent := ""
-			toolCalls := make([]message.ToolCall, 0)
-
-			for openaiStream.Next() {
-				chunk := openaiStream.Current()
-				acc.AddChunk(chunk)
-
-				for _, choice := range chunk.Choices {
-					if choice.Delta.Content != "" {
-						eventChan <- ProviderEvent{
-							Type:    EventContentDelta,
-							Content: choice.Delta.Content,
-						}
-						currentContent += choice.Delta.Content
+		defer close(eventChan)
+		openaiStream := o.client.Chat.Completions.NewStreaming(
+			ctx,
+			params,
+		)
+
+		acc := openai.ChatCompletionAccumulator{}
+		currentContent := ""
+		toolCalls := make([]message.ToolCall, 0)
+		// The accumulator drops the token details of the usage chunk
+		var cachedTokens int64
+
+		for openaiStream.Next() {
+			chunk := openaiStream.Current()
+			acc.AddChunk(chunk)
+			cachedTokens += chunk.Usage.PromptTokensDetails.CachedTokens
+
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content != "" {
+					eventChan <- ProviderEvent{
+						Type:    EventContentDelta,
+						Content: choice.Delta.Content,
 					}
+					currentContent += choice.Delta.Content
 				}
 			}
+		}
 
-			err := openaiStream.Err()
-			if err == nil || errors.Is(err, io.EOF) {
-				// Stream completed successfully
-				finishReason := o.finishReason(string(acc.ChatCompletion.Choices[0].FinishReason))
-				if len(acc.ChatCompletion.Choices[0].Message.ToolCalls) > 0 {
-					toolCalls = append(toolCalls, o.toolCalls(acc.ChatCompletion)...)
-				}
-				if len(toolCalls) > 0 {
-					finishReason = message.FinishReasonToolUse
-				}
-
-				eventChan <- ProviderEvent{
-					Type: EventComplete,
-					Response: &ProviderResponse{
-						Content:      currentContent,
-						ToolCalls:    toolCalls,
-						Usage:        o.usage(acc.ChatCompletion),
-						FinishReason: finishReason,
-					},
-				}
-				close(eventChan)
-				return
+		err := openaiStream.Err()
+		if err == nil || errors.Is(err, io.EOF) {
+			// Stream completed successfully
+			acc.ChatCompletion.Usage.PromptTokensDetails.CachedTokens = cachedTokens
+			finishReason := o.finishReason(string(acc.ChatCompletion.Choices[0].FinishReason))
+			if len(acc.ChatCompletion.Choices[0].Message.ToolCalls) > 0 {
+				toolCalls = append(toolCalls, o.toolCalls(acc.ChatCompletion)...)
 			}
-
-			// If there is an error we are going to see if we can retry the call
-			retry, after, retryErr := o.shouldRetry(attempts, err)
-			if retryErr != nil {
-				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-				close(eventChan)
-				return
+			if len(toolCalls) > 0 {
+				finishReason = message.FinishReasonToolUse
 			}
-			if retry {
-				logging.WarnPersist(fmt.Sprintf("Retrying due to rate limit... attempt %d of %d", attempts, maxRetries), logging.PersistTimeArg, time.Millisecond*time.Duration(after+100))
-				select {
-				case <-ctx.Done():
-					// context cancelled
-					if ctx.Err() == nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-					}
-					close(eventChan)
-					return
-				case <-time.After(time.Duration(after) * time.Millisecond):
-					continue
-				}
+
+			eventChan <- ProviderEvent{
+				Type: EventComplete,
+				Response: &ProviderResponse{
+					Content:      currentContent,
+					ToolCalls:    toolCalls,
+					Usage:        o.usage(acc.ChatCompletion),
+					FinishReason: finishReason,
+				},
 			}
-			eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-			close(eventChan)
 			return
 		}
+
+		eventChan <- ProviderEvent{Type: EventError, Error: err}
 	}()
 
 	return eventChan
 }
 
-func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
-	var apierr *openai.Error
-	if !errors.As(err, &apierr) {
-		return false, 0, err
-	}
-
-	if apierr.StatusCode != 429 && apierr.StatusCode != 500 {
-		return false, 0, err
-	}
-
-	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
-	}
-
-	retryMs := 0
-	retryAfterValues := apierr.Response.Header.Values("Retry-After")
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
-		}
-	}
-	return true, int64(retryMs), nil
-}
-
 func (o *openaiClient) toolCalls(completion openai.ChatCompletion) []message.ToolCall {
 	var toolCalls []message.ToolCall
 
@@ -388,7 +347,7 @@ func (o *openaiClient) usage(completion openai.ChatCompletion) TokenUsage {
 	return TokenUsage{
 		InputTokens:         inputTokens,
 		OutputTokens:        completion.Usage.CompletionTokens,
-		CacheCreationTokens: 0, // OpenAI doesn't provide this directly
+		CacheCreationTokens: 0, // OpenAI doesn't charge for writing its cache
 		CacheReadTokens:     cachedTokens,
 	}
 }
@@ -0,0 +1,314 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"github.com/openai/openai-go/shared"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// includeEncryptedReasoning asks for the reasoning items in a form that can
+// be sent back, the SDK doesn't know it yet.
+const includeEncryptedReasoning responses.ResponseIncludable = "reasoning.encrypted_content"
+
+// openaiResponsesClient talks to OpenAI models through the Responses API,
+// which returns reasoning summaries and keeps the reasoning of a model
+// between its tool calls.
+type openaiResponsesClient struct {
+	*openaiClient
+}
+
+type OpenAIResponsesClient ProviderClient
+
+func newOpenAIResponsesClient(opts providerClientOptions) OpenAIResponsesClient {
+	return &openaiResponsesClient{openaiClient: newOpenAIClient(opts).(*openaiClient)}
+}
+
+// continuation returns the answer of the model the request can continue
+// from, and the messages that came after it. The history is sent whole when
+// the model has no answer in it, when the last one is another model's, or
+// when it lost its response ID because the history was fitted to the
+// context window after it.
+func (o *openaiResponsesClient) continuation(messages []message.Message) (string, []message.Message) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role != message.Assistant {
+			continue
+		}
+		if id := msg.ReasoningContent().ResponseID; id != "" && msg.Model == o.providerOptions.model.ID {
+			return id, messages[i+1:]
+		}
+		break
+	}
+	return "", messages
+}
+
+func (o *openaiResponsesClient) convertMessages(messages []message.Message) (input responses.ResponseInputParam) {
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			content := responses.ResponseInputMessageContentListParam{
+				responses.ResponseInputContentParamOfInputText(msg.Content().String()),
+			}
+			for _, binaryContent := range msg.BinaryContent() {
+				if binaryContent.IsText() {
+					content = append(content, responses.ResponseInputContentParamOfInputText(binaryContent.Text()))
+					continue
+				}
+				content = append(content, o.inputImage(binaryContent))
+			}
+			input = append(input, responses.ResponseInputItemParamOfMessage(content, responses.EasyInputMessageRoleUser))
+
+		case message.Assistant:
+			// Reasoning of other models can't be sent back
+			if msg.Model == o.providerOptions.model.ID {
+				for _, item := range msg.ReasoningContent().Items {
+					input = append(input, o.reasoningItem(item))
+				}
+			}
+			if text := msg.Content().String(); text != "" {
+				input = append(input, responses.ResponseInputItemParamOfMessage(text, responses.EasyInputMessageRoleAssistant))
+			}
+			for _, call := range msg.ToolCalls() {
+				input = append(input, responses.ResponseInputItemParamOfFunctionCall(call.Input, call.ID, call.Name))
+			}
+
+		case message.Tool:
+			// Tool outputs only take text, images follow in a user message.
+			images := responses.ResponseInputMessageContentListParam{
+				responses.ResponseInputContentParamOfInputText("Images returned by the tool calls above:"),
+			}
+			for _, result := range msg.ToolResults() {
+				input = append(input, responses.ResponseInputItemParamOfFunctionCallOutput(result.ToolCallID, result.Text()))
+				for _, image := range result.Images() {
+					images = append(images, o.inputImage(image))
+				}
+			}
+			if len(images) > 1 {
+				input = append(input, responses.ResponseInputItemParamOfMessage(images, responses.EasyInputMessageRoleUser))
+			}
+		}
+	}
+	return
+}
+
+func (o *openaiResponsesClient) inputImage(image message.BinaryContent) responses.ResponseInputContentUnionParam {
+	content := responses.ResponseInputContentParamOfInputImage(responses.ResponseInputImageDetailAuto)
+	content.OfInputImage.ImageURL = openai.String(image.String(models.ProviderOpenAI))
+	return content
+}
+
+func (o *openaiResponsesClient) reasoningItem(item message.ReasoningItem) responses.ResponseInputItemUnionParam {
+	summary := make([]responses.ResponseReasoningItemSummaryParam, 0, len(item.Summary))
+	for _, text := range item.Summary {
+		summary = append(summary, responses.ResponseReasoningItemSummaryParam{Text: text})
+	}
+	param := responses.ResponseInputItemParamOfReasoning(item.ID, summary)
+	if item.EncryptedContent != "" {
+		param.OfReasoning.WithExtraFields(map[string]any{"encrypted_content": item.EncryptedContent})
+	}
+	return param
+}
+
+func (o *openaiResponsesClient) convertTools(tools []tools.BaseTool) []responses.ToolUnionParam {
+	responsesTools := make([]responses.ToolUnionParam, len(tools))
+	for i, tool := range tools {
+		info := tool.Info()
+		responsesTools[i] = responses.ToolParamOfFunction(info.Name, map[string]any{
+			"type":       "object",
+			"properties": info.Parameters,
+			"required":   info.Required,
+		}, false)
+		responsesTools[i].OfFunction.Description = openai.String(info.Description)
+	}
+	return responsesTools
+}
+
+func (o *openaiResponsesClient) preparedParams(previousResponseID string, messages []message.Message, tools []tools.BaseTool) responses.ResponseNewParams {
+	params := responses.ResponseNewParams{
+		Model:           shared.ResponsesModel(o.providerOptions.model.APIModel),
+		Instructions:    openai.String(o.providerOptions.systemMessage),
+		Input:           responses.ResponseNewParamsInputUnion{OfInputItemList: o.convertMessages(messages)},
+		MaxOutputTokens: openai.Int(o.providerOptions.maxTokens),
+		Tools:           o.convertTools(tools),
+	}
+	if previousResponseID != "" {
+		params.PreviousResponseID = openai.String(previousResponseID)
+	}
+	if key := o.promptCacheKey(); key != "" {
+		params.WithExtraFields(map[string]any{"prompt_cache_key": key})
+	}
+	if o.providerOptions.model.CanReason {
+		params.Reasoning.Effort = shared.ReasoningEffort(o.options.reasoningEffort)
+		params.Reasoning.WithExtraFields(map[string]any{"summary": "auto"})
+		params.Include = []responses.ResponseIncludable{includeEncryptedReasoning}
+	}
+	cfg := config.Get()
+	if cfg.Debug {
+		jsonData, _ := json.Marshal(params)
+		logging.Debug("Prepared messages", "messages", string(jsonData))
+	}
+	return params
+}
+
+// continuationLost reports whether a request failed because OpenAI no longer
+// has the answer it continues from, it is then sent with the whole history.
+func continuationLost(previousResponseID string, err error) bool {
+	if previousResponseID == "" {
+		return false
+	}
+	status := StatusCode(err)
+	return (status == 400 || status == 404) && contains(err.Error(), "previous response", "previous_response")
+}
+
+func (o *openaiResponsesClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	previousResponseID, newMessages := o.continuation(messages)
+	response, err := o.client.Responses.New(ctx, o.preparedParams(previousResponseID, newMessages, tools))
+	if continuationLost(previousResponseID, err) {
+		logging.Debug("Previous response is gone, sending the whole history", "response", previousResponseID)
+		response, err = o.client.Responses.New(ctx, o.preparedParams("", messages, tools))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response.Status == responses.ResponseStatusFailed {
+		return nil, errors.New(response.Error.Message)
+	}
+	return o.providerResponse(*response), nil
+}
+
+func (o *openaiResponsesClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	previousResponseID, newMessages := o.continuation(messages)
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		err := o.streamResponse(ctx, o.preparedParams(previousResponseID, newMessages, tools), eventChan)
+		if continuationLost(previousResponseID, err) {
+			logging.Debug("Previous response is gone, sending the whole history", "response", previousResponseID)
+			err = o.streamResponse(ctx, o.preparedParams("", messages, tools), eventChan)
+		}
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+		}
+	}()
+	return eventChan
+}
+
+// streamResponse sends the events of a streamed answer, and returns the
+// error it failed with.
+func (o *openaiResponsesClient) streamResponse(ctx context.Context, params responses.ResponseNewParams, eventChan chan<- ProviderEvent) error {
+	stream := o.client.Responses.NewStreaming(ctx, params)
+	// Argument deltas name the item of the function call, not the call
+	callIDs := make(map[string]string)
+	thinking := false
+	for stream.Next() {
+		event := stream.Current()
+		switch event.Type {
+		case "response.reasoning_summary_part.added":
+			if thinking {
+				eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: "\n\n"}
+			}
+		case "response.reasoning_summary_text.delta":
+			thinking = true
+			eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: event.Delta}
+		case "response.output_text.delta":
+			eventChan <- ProviderEvent{Type: EventContentDelta, Content: event.Delta}
+		case "response.output_item.added":
+			if event.Item.Type == "function_call" {
+				callIDs[event.Item.ID] = event.Item.CallID
+				eventChan <- ProviderEvent{
+					Type:     EventToolUseStart,
+					ToolCall: &message.ToolCall{ID: event.Item.CallID, Name: event.Item.Name},
+				}
+			}
+		case "response.function_call_arguments.delta":
+			eventChan <- ProviderEvent{
+				Type:     EventToolUseDelta,
+				ToolCall: &message.ToolCall{ID: callIDs[event.ItemID], Input: event.Delta},
+			}
+		case "response.output_item.done":
+			if event.Item.Type == "function_call" {
+				eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &message.ToolCall{ID: event.Item.CallID}}
+			}
+		case "response.completed", "response.incomplete":
+			eventChan <- ProviderEvent{Type: EventComplete, Response: o.providerResponse(event.Response)}
+		case "response.failed":
+			return errors.New(event.Response.Error.Message)
+		case "error":
+			return fmt.Errorf("%s: %s", event.Code, event.Message)
+		}
+	}
+	if err := stream.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (o *openaiResponsesClient) providerResponse(response responses.Response) *ProviderResponse {
+	var content strings.Builder
+	var toolCalls []message.ToolCall
+	var items []message.ReasoningItem
+	for _, output := range response.Output {
+		switch output.Type {
+		case "message":
+			for _, part := range output.Content {
+				content.WriteString(part.Text)
+			}
+		case "function_call":
+			toolCalls = append(toolCalls, message.ToolCall{
+				ID:       output.CallID,
+				Name:     output.Name,
+				Input:    output.Arguments,
+				Type:     "function",
+				Finished: true,
+			})
+		case "reasoning":
+			item := message.ReasoningItem{ID: output.ID}
+			for _, summary := range output.Summary {
+				item.Summary = append(item.Summary, summary.Text)
+			}
+			var encrypted struct {
+				EncryptedContent string `json:"encrypted_content"`
+			}
+			if err := json.Unmarshal([]byte(output.RawJSON()), &encrypted); err == nil {
+				item.EncryptedContent = encrypted.EncryptedContent
+			}
+			items = append(items, item)
+		}
+	}
+
+	finishReason := message.FinishReasonEndTurn
+	switch {
+	case len(toolCalls) > 0:
+		finishReason = message.FinishReasonToolUse
+	case response.IncompleteDetails.Reason == "max_output_tokens":
+		finishReason = message.FinishReasonMaxTokens
+	case response.Status == responses.ResponseStatusIncomplete:
+		finishReason = message.FinishReasonUnknown
+	}
+
+	cachedTokens := response.Usage.InputTokensDetails.CachedTokens
+	return &ProviderResponse{
+		Content:      content.String(),
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+		Usage: TokenUsage{
+			InputTokens:     response.Usage.InputTokens - cachedTokens,
+			OutputTokens:    response.Usage.OutputTokens,
+			CacheReadTokens: cachedTokens,
+		},
+		ResponseID:     response.ID,
+		ReasoningItems: items,
+	}
+}
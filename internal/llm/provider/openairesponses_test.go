@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIResponsesStream(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/responses", r.URL.Path)
+		var request map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests = append(requests, request)
+		if request["previous_response_id"] != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"Previous response with id 'resp_1' not found.","type":"invalid_request_error","param":"previous_response_id"}}`)
+			return
+		}
+		if request["stream"] != true {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"resp_2","object":"response","status":"completed","output":[`+
+				`{"type":"message","id":"msg_2","role":"assistant","status":"completed","content":[{"type":"output_text","text":"Sunny.","annotations":[]}]}],`+
+				`"usage":{"input_tokens":70,"input_tokens_details":{"cached_tokens":0},"output_tokens":3,"output_tokens_details":{"reasoning_tokens":0},"total_tokens":73}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"response.reasoning_summary_part.added","item_id":"rs_1","output_index":0,"summary_index":0}`,
+			`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","output_index":0,"summary_index":0,"delta":"Checking the weather"}`,
+			`{"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"weather","arguments":"","status":"in_progress"}}`,
+			`{"type":"response.function_call_arguments.delta","item_id":"fc_1","output_index":1,"delta":"{\"city\":\"Oslo\"}"}`,
+			`{"type":"response.output_item.done","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"weather","arguments":"{\"city\":\"Oslo\"}","status":"completed"}}`,
+			`{"type":"response.completed","response":{"id":"resp_1","object":"response","status":"completed","output":[` +
+				`{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"Checking the weather"}],"encrypted_content":"gAAA"},` +
+				`{"type":"function_call","id":"fc_1","call_id":"call_1","name":"weather","arguments":"{\"city\":\"Oslo\"}","status":"completed"}],` +
+				`"usage":{"input_tokens":50,"input_tokens_details":{"cached_tokens":20},"output_tokens":12,"output_tokens_details":{"reasoning_tokens":8},"total_tokens":62}}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	model := models.Model{ID: "o4-mini", Provider: models.ProviderOpenAI, APIModel: "o4-mini", CanReason: true, ResponsesAPI: true}
+	p, err := NewProvider(models.ProviderOpenAI,
+		WithAPIKey("test"),
+		WithModel(model),
+		WithMaxTokens(1024),
+		WithSystemMessage("Be brief."),
+		WithOpenAIOptions(WithOpenAIBaseURL(srv.URL)),
+	)
+	require.NoError(t, err)
+
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Weather in Oslo?"}}},
+	}
+	var thinking string
+	var response *ProviderResponse
+	for event := range p.StreamResponse(context.Background(), messages, []tools.BaseTool{weatherTool{}}) {
+		switch event.Type {
+		case EventThinkingDelta:
+			thinking += event.Thinking
+		case EventComplete:
+			response = event.Response
+		case EventError:
+			t.Fatal(event.Error)
+		}
+	}
+	assert.Equal(t, "Checking the weather", thinking)
+	require.NotNil(t, response)
+	assert.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	assert.Equal(t, TokenUsage{InputTokens: 30, OutputTokens: 12, CacheReadTokens: 20}, response.Usage)
+	assert.Equal(t, "resp_1", response.ResponseID)
+	assert.Equal(t, []message.ReasoningItem{{ID: "rs_1", Summary: []string{"Checking the weather"}, EncryptedContent: "gAAA"}}, response.ReasoningItems)
+	assert.Equal(t, "Be brief.", requests[0]["instructions"])
+	assert.Equal(t, map[string]any{"effort": "medium", "summary": "auto"}, requests[0]["reasoning"])
+
+	// The tool results continue from the answer, or go with the whole
+	// history, reasoning included, once OpenAI lost it
+	assistant := message.Message{Role: message.Assistant, Model: model.ID}
+	assistant.SetToolCalls(response.ToolCalls)
+	assistant.SetReasoningItems(response.ResponseID, response.ReasoningItems)
+	messages = append(messages, assistant, message.Message{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_1", Content: "sunny"}},
+	})
+	response, err = p.SendMessages(context.Background(), messages, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Sunny.", response.Content)
+	assert.Equal(t, "resp_2", response.ResponseID)
+	require.Len(t, requests, 3)
+	assert.Equal(t, "resp_1", requests[1]["previous_response_id"])
+	assert.Len(t, requests[1]["input"], 1)
+	input := requests[2]["input"].([]any)
+	require.Len(t, input, 4)
+	assert.Equal(t, "reasoning", input[1].(map[string]any)["type"])
+	assert.Equal(t, "gAAA", input[1].(map[string]any)["encrypted_content"])
+	assert.Equal(t, "function_call_output", input[3].(map[string]any)["type"])
+}
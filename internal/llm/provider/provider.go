@@ -1,9 +1,14 @@
 package provider
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
 
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/llm/models"
@@ -13,8 +18,6 @@ import (
 
 type EventType string
 
-const maxRetries = 8
-
 const (
 	EventContentStart  EventType = "content_start"
 	EventToolUseStart  EventType = "tool_use_start"
@@ -26,13 +29,19 @@ const (
 	EventComplete      EventType = "complete"
 	EventError         EventType = "error"
 	EventWarning       EventType = "warning"
+	// EventFallback is sent when a fallback chain switches models, before
+	// the events of the new model.
+	EventFallback EventType = "fallback"
+	// EventRetry is sent when a request that failed before any output is
+	// about to be sent again.
+	EventRetry EventType = "retry"
 )
 
 type TokenUsage struct {
-	InputTokens         int64
-	OutputTokens        int64
-	CacheCreationTokens int64
-	CacheReadTokens     int64
+	InputTokens         int64 `json:"inputTokens,omitempty" yaml:"inputTokens,omitempty"`
+	OutputTokens        int64 `json:"outputTokens,omitempty" yaml:"outputTokens,omitempty"`
+	CacheCreationTokens int64 `json:"cacheCreationTokens,omitempty" yaml:"cacheCreationTokens,omitempty"`
+	CacheReadTokens     int64 `json:"cacheReadTokens,omitempty" yaml:"cacheReadTokens,omitempty"`
 }
 
 type ProviderResponse struct {
@@ -40,6 +49,10 @@ type ProviderResponse struct {
 	ToolCalls    []message.ToolCall
 	Usage        TokenUsage
 	FinishReason message.FinishReason
+	// ResponseID and ReasoningItems are set by the OpenAI Responses API, to
+	// be kept with the message for the next request.
+	ResponseID     string
+	ReasoningItems []message.ReasoningItem
 }
 
 type ProviderEvent struct {
@@ -50,6 +63,8 @@ type ProviderEvent struct {
 	Response *ProviderResponse
 	ToolCall *message.ToolCall
 	Error    error
+	Fallback *Fallback
+	Retry    *Retry
 }
 type Provider interface {
 	SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
@@ -57,6 +72,10 @@ type Provider interface {
 	StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
 
 	Model() models.Model
+
+	// Close releases what the provider keeps on the side of the API, like
+	// cached content.
+	Close()
 }
 
 type providerClientOptions struct {
@@ -65,11 +84,13 @@ type providerClientOptions struct {
 	maxTokens     int64
 	systemMessage string
 	temperature   float32
+	agent         config.AgentName
 
 	anthropicOptions []AnthropicOption
 	openaiOptions    []OpenAIOption
 	geminiOptions    []GeminiOption
 	bedrockOptions   []BedrockOption
+	ollamaOptions    []OllamaOption
 }
 
 type ProviderClientOption func(*providerClientOptions)
@@ -89,6 +110,17 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 	for _, o := range opts {
 		o(&clientOptions)
 	}
+	p, err := newProvider(providerName, clientOptions)
+	if err != nil {
+		return nil, err
+	}
+	if path := os.Getenv(RecordCassetteEnv); path != "" && providerName != models.ProviderMock {
+		return newRecordingProvider(p, clientOptions.agent, path)
+	}
+	return p, nil
+}
+
+func newProvider(providerName models.ModelProvider, clientOptions providerClientOptions) (Provider, error) {
 	switch providerName {
 	case models.ProviderAnthropic:
 		return &baseProvider[AnthropicClient]{
@@ -96,6 +128,12 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 			client:  newAnthropicClient(clientOptions),
 		}, nil
 	case models.ProviderOpenAI:
+		if clientOptions.model.ResponsesAPI {
+			return &baseProvider[OpenAIResponsesClient]{
+				options: clientOptions,
+				client:  newOpenAIResponsesClient(clientOptions),
+			}, nil
+		}
 		return &baseProvider[OpenAIClient]{
 			options: clientOptions,
 			client:  newOpenAIClient(clientOptions),
@@ -126,6 +164,13 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 			client:  newGeminiClient(clientOptions),
 		}, nil
 	case models.ProviderBedrock:
+		providerCfg := config.Get().Providers[models.ProviderBedrock]
+		clientOptions.bedrockOptions = append([]BedrockOption{
+			WithBedrockRegion(providerCfg.Region),
+			WithBedrockProfile(providerCfg.Profile),
+			WithBedrockInferenceProfile(providerCfg.InferenceProfile),
+			WithBedrockEndpoint(providerCfg.BaseURL),
+		}, clientOptions.bedrockOptions...)
 		return &baseProvider[BedrockClient]{
 			options: clientOptions,
 			client:  newBedrockClient(clientOptions),
@@ -176,9 +221,66 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 			options: clientOptions,
 			client:  newOpenAIClient(clientOptions),
 		}, nil
+	case models.ProviderOllama:
+		providerCfg := config.Get().Providers[models.ProviderOllama]
+		clientOptions.ollamaOptions = append([]OllamaOption{
+			WithOllamaBaseURL(providerCfg.BaseURL),
+			WithOllamaKeepAlive(providerCfg.KeepAlive),
+		}, clientOptions.ollamaOptions...)
+		return &baseProvider[OllamaClient]{
+			options: clientOptions,
+			client:  newOllamaClient(clientOptions),
+		}, nil
 	case models.ProviderMock:
-		// TODO: implement mock client for test
-		panic("not implemented")
+		return &baseProvider[MockClient]{
+			options: clientOptions,
+			client:  newMockClient(clientOptions),
+		}, nil
+	}
+	return newCustomProvider(providerName, clientOptions)
+}
+
+// customAPIKey is sent to custom providers without an API key, the SDKs
+// would otherwise send the key of OPENAI_API_KEY or ANTHROPIC_API_KEY.
+const customAPIKey = "none"
+
+// newCustomProvider creates a provider declared in the config with a type.
+func newCustomProvider(providerName models.ModelProvider, clientOptions providerClientOptions) (Provider, error) {
+	var providerCfg config.Provider
+	if cfg := config.Get(); cfg != nil {
+		providerCfg = cfg.Providers[providerName]
+	}
+	headers := make(map[string]string, len(providerCfg.Headers))
+	for key, value := range providerCfg.Headers {
+		headers[key] = os.ExpandEnv(value)
+	}
+	clientOptions.apiKey = cmp.Or(clientOptions.apiKey, customAPIKey)
+
+	switch providerCfg.Type {
+	case config.ProviderOpenAICompatible:
+		clientOptions.openaiOptions = append(clientOptions.openaiOptions,
+			WithOpenAIBaseURL(providerCfg.BaseURL),
+			WithOpenAIExtraHeaders(headers),
+		)
+		if clientOptions.model.ResponsesAPI {
+			return &baseProvider[OpenAIResponsesClient]{
+				options: clientOptions,
+				client:  newOpenAIResponsesClient(clientOptions),
+			}, nil
+		}
+		return &baseProvider[OpenAIClient]{
+			options: clientOptions,
+			client:  newOpenAIClient(clientOptions),
+		}, nil
+	case config.ProviderAnthropicCompatible:
+		clientOptions.anthropicOptions = append(clientOptions.anthropicOptions,
+			WithAnthropicBaseURL(providerCfg.BaseURL),
+			WithAnthropicExtraHeaders(headers),
+		)
+		return &baseProvider[AnthropicClient]{
+			options: clientOptions,
+			client:  newAnthropicClient(clientOptions),
+		}, nil
 	}
 	return nil, fmt.Errorf("provider not supported: %s", providerName)
 }
@@ -189,23 +291,130 @@ func (p *baseProvider[C]) cleanMessages(messages []message.Message) (cleaned []m
 		if len(msg.Parts) == 0 {
 			continue
 		}
-		cleaned = append(cleaned, msg)
+		if msg.Role == message.Tool && !p.options.model.SupportsAttachments {
+			msg = withoutToolImages(msg)
+		}
+		if msg.Role == message.User && !p.options.model.SupportsAttachments {
+			msg = withoutImages(msg)
+		}
+		cleaned = append(cleaned, withPortableToolCallIDs(msg))
 	}
 	return
 }
 
+// maxToolCallIDLength is the longest tool call ID all providers take.
+const maxToolCallIDLength = 40
+
+var toolCallIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// portableToolCallID returns the ID of a tool call, or a stable replacement
+// when other providers would reject it, so the history of a session can be
+// sent to any model.
+func portableToolCallID(id string) string {
+	if len(id) <= maxToolCallIDLength && toolCallIDPattern.MatchString(id) {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return "call_" + hex.EncodeToString(sum[:16])
+}
+
+// withPortableToolCallIDs replaces the tool call IDs of a message with
+// portable ones.
+func withPortableToolCallIDs(msg message.Message) message.Message {
+	parts := slices.Clone(msg.Parts)
+	for i, part := range parts {
+		switch p := part.(type) {
+		case message.ToolCall:
+			p.ID = portableToolCallID(p.ID)
+			parts[i] = p
+		case message.ToolResult:
+			p.ToolCallID = portableToolCallID(p.ToolCallID)
+			parts[i] = p
+		}
+	}
+	msg.Parts = parts
+	return msg
+}
+
+// withoutImages drops the images attached to a user message, for models
+// that can't see them, like a fallback of a model that could.
+func withoutImages(msg message.Message) message.Message {
+	var parts []message.ContentPart
+	omitted := 0
+	for _, part := range msg.Parts {
+		switch p := part.(type) {
+		case message.BinaryContent:
+			if !p.IsText() {
+				omitted++
+				continue
+			}
+		case message.ImageURLContent:
+			omitted++
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if omitted == 0 {
+		return msg
+	}
+	for i, part := range parts {
+		if text, ok := part.(message.TextContent); ok {
+			text.Text += fmt.Sprintf("\n[%d image(s) omitted, the model doesn't support images]", omitted)
+			parts[i] = text
+			break
+		}
+	}
+	msg.Parts = parts
+	return msg
+}
+
+// withoutToolImages replaces the images returned by tools with a note, for
+// models that can't see them.
+func withoutToolImages(msg message.Message) message.Message {
+	parts := make([]message.ContentPart, len(msg.Parts))
+	for i, part := range msg.Parts {
+		result, ok := part.(message.ToolResult)
+		if ok && len(result.Images()) > 0 {
+			var text []message.BinaryContent
+			for _, attachment := range result.Attachments {
+				if attachment.IsText() {
+					text = append(text, attachment)
+				}
+			}
+			result.Content += fmt.Sprintf("\n[%d image(s) omitted, the model doesn't support images]", len(result.Images()))
+			result.Attachments = text
+			part = result
+		}
+		parts[i] = part
+	}
+	msg.Parts = parts
+	return msg
+}
+
 func (p *baseProvider[C]) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
 	messages = p.cleanMessages(messages)
-	return p.client.send(ctx, messages, tools)
+	if p.options.model.NoTools {
+		tools = nil
+	}
+	return newResilientClient(p.client, p.options.model.Provider).send(ctx, messages, tools)
 }
 
 func (p *baseProvider[C]) Model() models.Model {
 	return p.options.model
 }
 
+func (p *baseProvider[C]) Close() {
+	if c, ok := any(p.client).(interface{ close() }); ok {
+		c.close()
+	}
+}
+
 func (p *baseProvider[C]) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	messages = p.cleanMessages(messages)
-	return p.client.stream(ctx, messages, tools)
+	if p.options.model.NoTools {
+		tools = nil
+	}
+	return newResilientClient(p.client, p.options.model.Provider).stream(ctx, messages, tools)
 }
 
 func WithTemperature(temperature float32) ProviderClientOption {
@@ -232,6 +441,14 @@ func WithMaxTokens(maxTokens int64) ProviderClientOption {
 	}
 }
 
+// WithAgent tells the provider which agent it answers, so cassettes can keep
+// the turns of the agents apart.
+func WithAgent(agent config.AgentName) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.agent = agent
+	}
+}
+
 func WithSystemMessage(systemMessage string) ProviderClientOption {
 	return func(options *providerClientOptions) {
 		options.systemMessage = systemMessage
@@ -262,3 +479,8 @@ func WithBedrockOptions(bedrockOptions ...BedrockOption) ProviderClientOption {
 	}
 }
 
+func WithOllamaOptions(ollamaOptions ...OllamaOption) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.ollamaOptions = ollamaOptions
+	}
+}
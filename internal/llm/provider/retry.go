@@ -0,0 +1,428 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// Defaults of the providers that don't configure their retry policy and
+// timeouts.
+const (
+	DefaultMaxRetries        = 8
+	DefaultRetryDelay        = 2 * time.Second
+	DefaultMaxRetryDelay     = time.Minute
+	DefaultRetryJitter       = 0.2
+	DefaultRequestTimeout    = 10 * time.Minute
+	DefaultStreamIdleTimeout = 2 * time.Minute
+)
+
+// DefaultRetryStatusCodes are the HTTP statuses retried by default: rate
+// limits, overloads and server errors.
+var DefaultRetryStatusCodes = []int{408, 429, 500, 502, 503, 504, 529}
+
+// Retry is a failed request about to be sent again.
+type Retry struct {
+	// Attempt is the number of the retry, from 1 to MaxRetries.
+	Attempt    int
+	MaxRetries int
+	Delay      time.Duration
+	// Reason is the fallback condition the failure falls under, empty when
+	// it falls under none.
+	Reason config.FallbackCondition
+	Err    error
+}
+
+func newRetry(attempt, maxRetries int, delay time.Duration, err error) Retry {
+	return Retry{Attempt: attempt, MaxRetries: maxRetries, Delay: delay, Reason: FallbackReason(err), Err: err}
+}
+
+func (r Retry) String() string {
+	reason := "Request failed"
+	switch r.Reason {
+	case config.FallbackOnRateLimit:
+		reason = "Rate limited"
+	case config.FallbackOnOverloaded:
+		reason = "Provider overloaded"
+	case config.FallbackOnServerError:
+		reason = "Provider error"
+	case config.FallbackOnConnection:
+		reason = "Connection failed"
+	}
+	seconds := int(math.Ceil(r.Delay.Seconds()))
+	return fmt.Sprintf("%s, retrying in %ds (%d/%d)", reason, seconds, r.Attempt, r.MaxRetries)
+}
+
+// retriesExhaustedError is returned by a request that kept failing after
+// all its retries, with the error of the last attempt.
+type retriesExhaustedError struct {
+	retries int
+	err     error
+}
+
+func (e *retriesExhaustedError) Error() string {
+	return fmt.Sprintf("maximum retry attempts reached (%d retries): %v", e.retries, e.err)
+}
+
+func (e *retriesExhaustedError) Unwrap() error {
+	return e.err
+}
+
+// timeoutError is returned by a request the provider didn't answer in time.
+// It is a net.Error, so it falls back like a broken connection.
+type timeoutError struct {
+	stream bool
+	after  time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	if e.stream {
+		return fmt.Sprintf("stream stalled: no response for %s", e.after)
+	}
+	return fmt.Sprintf("request timed out after %s", e.after)
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// retryPolicy decides which failed requests are retried, and when.
+type retryPolicy struct {
+	maxRetries  int
+	statusCodes []int
+	delay       time.Duration
+	maxDelay    time.Duration
+	jitter      float64
+}
+
+func newRetryPolicy(cfg config.RetryConfig) retryPolicy {
+	policy := retryPolicy{
+		maxRetries:  DefaultMaxRetries,
+		statusCodes: DefaultRetryStatusCodes,
+		delay:       DefaultRetryDelay,
+		maxDelay:    DefaultMaxRetryDelay,
+		jitter:      DefaultRetryJitter,
+	}
+	if cfg.MaxRetries != nil {
+		policy.maxRetries = max(*cfg.MaxRetries, 0)
+	}
+	if len(cfg.StatusCodes) > 0 {
+		policy.statusCodes = cfg.StatusCodes
+	}
+	if cfg.InitialDelay > 0 {
+		policy.delay = time.Duration(cfg.InitialDelay) * time.Second
+	}
+	if cfg.MaxDelay > 0 {
+		policy.maxDelay = time.Duration(cfg.MaxDelay) * time.Second
+	}
+	if cfg.Jitter != nil {
+		policy.jitter = max(*cfg.Jitter, 0)
+	}
+	return policy
+}
+
+// retryable reports whether a request that failed with err may succeed when
+// sent again.
+func (p retryPolicy) retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var timeout *timeoutError
+	if errors.As(err, &timeout) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	status := StatusCode(err)
+	if status == 0 {
+		// Anthropic reports overloads during a stream as an error event,
+		// and Gemini rate limits don't always come with a status
+		switch {
+		case contains(err.Error(), "overloaded_error"):
+			status = 529
+		case contains(err.Error(), "rate limit", "quota exceeded", "too many requests", "resource has been exhausted"):
+			status = http.StatusTooManyRequests
+		}
+	}
+	return slices.Contains(p.statusCodes, status)
+}
+
+// backoff returns how long to wait before retry n of a request that failed
+// with err, or the error to give up with.
+func (p retryPolicy) backoff(n int, err error) (time.Duration, error) {
+	if !p.retryable(err) || p.maxRetries == 0 {
+		return 0, err
+	}
+	if n > p.maxRetries {
+		return 0, &retriesExhaustedError{retries: p.maxRetries, err: err}
+	}
+	if after, ok := retryAfter(err); ok {
+		if after > p.maxDelay {
+			// Waiting that long is worse than failing, and falling back
+			return 0, err
+		}
+		return after, nil
+	}
+	delay := min(p.delay<<(n-1), p.maxDelay)
+	if delay < 0 {
+		delay = p.maxDelay
+	}
+	return delay + time.Duration(rand.Float64()*p.jitter*float64(delay)), nil
+}
+
+// retryAfter returns the delay a provider asked for in the Retry-After
+// header of an error response, in seconds or as a date, or in the
+// retry-after-ms header OpenAI and Anthropic send.
+func retryAfter(err error) (time.Duration, bool) {
+	var header http.Header
+	var anthropicErr *anthropic.Error
+	var openaiErr *openai.Error
+	switch {
+	case errors.As(err, &anthropicErr) && anthropicErr.Response != nil:
+		header = anthropicErr.Response.Header
+	case errors.As(err, &openaiErr) && openaiErr.Response != nil:
+		header = openaiErr.Response.Header
+	default:
+		return 0, false
+	}
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// rateLimiter is a token bucket of the requests sent to a provider.
+type rateLimiter struct {
+	limit config.RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+var (
+	rateLimiters   = make(map[models.ModelProvider]*rateLimiter)
+	rateLimitersMu sync.Mutex
+)
+
+// providerRateLimiter returns the limiter shared by the requests to a
+// provider, nil when it isn't limited.
+func providerRateLimiter(provider models.ModelProvider, limit config.RateLimit) *rateLimiter {
+	if limit.RequestsPerMinute <= 0 {
+		return nil
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.RequestsPerMinute
+	}
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	l, ok := rateLimiters[provider]
+	if !ok || l.limit != limit {
+		l = &rateLimiter{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+		rateLimiters[provider] = l
+	}
+	return l
+}
+
+// wait takes a token, waiting for one when the bucket is empty. Requests
+// waiting reserve their token, so they go out in order.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	rate := float64(l.limit.RequestsPerMinute) / 60
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(float64(l.limit.Burst), l.tokens+now.Sub(l.last).Seconds()*rate)
+	l.last = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / rate * float64(time.Second))
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	logging.Debug("Waiting for the provider rate limit", "wait", wait)
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// resilientClient wraps a ProviderClient with the retry policy, timeouts and
+// rate limit of its provider.
+type resilientClient struct {
+	client         ProviderClient
+	policy         retryPolicy
+	requestTimeout time.Duration
+	idleTimeout    time.Duration
+	limiter        *rateLimiter
+}
+
+func newResilientClient(client ProviderClient, provider models.ModelProvider) *resilientClient {
+	var providerCfg config.Provider
+	if cfg := config.Get(); cfg != nil {
+		providerCfg = cfg.Providers[provider]
+	}
+	c := &resilientClient{
+		client:         client,
+		policy:         newRetryPolicy(providerCfg.Retry),
+		requestTimeout: DefaultRequestTimeout,
+		idleTimeout:    DefaultStreamIdleTimeout,
+		limiter:        providerRateLimiter(provider, providerCfg.RateLimit),
+	}
+	if providerCfg.Timeout > 0 {
+		c.requestTimeout = time.Duration(providerCfg.Timeout) * time.Second
+	}
+	if providerCfg.StreamIdleTimeout > 0 {
+		c.idleTimeout = time.Duration(providerCfg.StreamIdleTimeout) * time.Second
+	}
+	return c
+}
+
+// timedOut returns the error an attempt ended with, the timeout when it
+// timed out rather than the cancelled context the client saw.
+func timedOut(attemptCtx context.Context, err error) error {
+	var timeout *timeoutError
+	if errors.As(context.Cause(attemptCtx), &timeout) {
+		return timeout
+	}
+	return err
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func (c *resilientClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	for n := 1; ; n++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		attemptCtx, cancel := context.WithCancelCause(ctx)
+		timer := time.AfterFunc(c.requestTimeout, func() {
+			cancel(&timeoutError{after: c.requestTimeout})
+		})
+		response, err := c.client.send(attemptCtx, messages, tools)
+		timer.Stop()
+		if err != nil && ctx.Err() == nil {
+			err = timedOut(attemptCtx, err)
+		}
+		cancel(nil)
+		if err == nil || ctx.Err() != nil {
+			return response, err
+		}
+		delay, err := c.policy.backoff(n, err)
+		if err != nil {
+			return nil, err
+		}
+		retry := newRetry(n, c.policy.maxRetries, delay, err)
+		logging.WarnPersist(retry.String(), logging.PersistTimeArg, delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// stream retries a stream that fails before it answered anything. The
+// request timeout applies until the first output of the model, the idle
+// timeout between outputs after that.
+func (c *resilientClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		for n := 1; ; n++ {
+			if err := c.limiter.wait(ctx); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			attemptCtx, cancel := context.WithCancelCause(ctx)
+			timer := time.AfterFunc(c.requestTimeout, func() {
+				cancel(&timeoutError{after: c.requestTimeout})
+			})
+			answered, done := false, false
+			var failed error
+			// The client stream is drained even after a timeout, so its
+			// goroutine ends
+			for event := range c.client.stream(attemptCtx, messages, tools) {
+				switch event.Type {
+				case EventContentStart, EventWarning:
+				case EventComplete:
+					done = true
+				case EventError:
+					done = true
+					event.Error = timedOut(attemptCtx, event.Error)
+					if !answered && ctx.Err() == nil {
+						failed = event.Error
+						continue
+					}
+				default:
+					answered = true
+					if timer.Stop() {
+						timer = time.AfterFunc(c.idleTimeout, func() {
+							cancel(&timeoutError{stream: true, after: c.idleTimeout})
+						})
+					}
+				}
+				eventChan <- event
+			}
+			timer.Stop()
+			if cause := context.Cause(attemptCtx); !done && cause != nil && ctx.Err() == nil {
+				// The client ended the timed out attempt without an error
+				if answered {
+					eventChan <- ProviderEvent{Type: EventError, Error: cause}
+				} else {
+					failed = cause
+				}
+			}
+			cancel(nil)
+			if failed == nil {
+				return
+			}
+			delay, err := c.policy.backoff(n, failed)
+			if err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			logging.Warn("Retrying provider request", "error", failed, "attempt", n, "delay", delay)
+			retry := newRetry(n, c.policy.maxRetries, delay, failed)
+			eventChan <- ProviderEvent{Type: EventRetry, Retry: &retry}
+			if err := sleep(ctx, delay); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+		}
+	}()
+	return eventChan
+}
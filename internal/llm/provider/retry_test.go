@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyClient fails with its errors, one per request, before answering.
+type flakyClient struct {
+	errs  []error
+	calls int
+}
+
+func (f *flakyClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &ProviderResponse{Content: "ok", FinishReason: message.FinishReasonEndTurn}, nil
+}
+
+func (f *flakyClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent, 1)
+	if response, err := f.send(ctx, messages, tools); err != nil {
+		eventChan <- ProviderEvent{Type: EventError, Error: err}
+	} else {
+		eventChan <- ProviderEvent{Type: EventComplete, Response: response}
+	}
+	close(eventChan)
+	return eventChan
+}
+
+// stallingClient starts answering, then goes quiet until it is cancelled.
+type stallingClient struct{}
+
+func (stallingClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stallingClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		eventChan <- ProviderEvent{Type: EventContentDelta, Content: "hi"}
+		<-ctx.Done()
+		eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+	}()
+	return eventChan
+}
+
+func statusError(status int, header http.Header) error {
+	return &openai.Error{
+		StatusCode: status,
+		Request:    httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil),
+		Response:   &http.Response{StatusCode: status, Header: header},
+	}
+}
+
+func TestResilientClient(t *testing.T) {
+	rateLimited := statusError(http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}})
+	flaky := &flakyClient{errs: []error{rateLimited}}
+	c := &resilientClient{
+		client:         flaky,
+		policy:         newRetryPolicy(config.RetryConfig{}),
+		requestTimeout: time.Minute,
+		idleTimeout:    time.Minute,
+	}
+
+	var events []ProviderEvent
+	for event := range c.stream(context.Background(), nil, nil) {
+		events = append(events, event)
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, EventRetry, events[0].Type)
+	assert.Equal(t, "Rate limited, retrying in 0s (1/8)", events[0].Retry.String())
+	assert.Equal(t, EventComplete, events[1].Type)
+
+	// Requests give up once the retries are used up
+	retries := 1
+	c.policy = newRetryPolicy(config.RetryConfig{MaxRetries: &retries})
+	flaky.errs = []error{rateLimited, rateLimited}
+	_, err := c.send(context.Background(), nil, nil)
+	require.ErrorContains(t, err, "maximum retry attempts reached (1 retries)")
+	assert.Equal(t, http.StatusTooManyRequests, StatusCode(err))
+
+	// Errors that would happen again aren't retried
+	flaky.calls = 0
+	flaky.errs = []error{statusError(http.StatusBadRequest, nil)}
+	_, err = c.send(context.Background(), nil, nil)
+	assert.Equal(t, http.StatusBadRequest, StatusCode(err))
+	assert.Equal(t, 1, flaky.calls)
+
+	// A stream that stalls after answering fails instead of hanging
+	c = &resilientClient{client: stallingClient{}, policy: c.policy, requestTimeout: time.Minute, idleTimeout: 10 * time.Millisecond}
+	events = nil
+	for event := range c.stream(context.Background(), nil, nil) {
+		events = append(events, event)
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, EventContentDelta, events[0].Type)
+	assert.EqualError(t, events[1].Error, "stream stalled: no response for 10ms")
+	assert.Equal(t, config.FallbackOnConnection, FallbackReason(events[1].Error))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{})
+
+	delay, err := policy.backoff(3, statusError(529, nil))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, delay, 8*time.Second)
+	assert.Less(t, delay, 10*time.Second)
+
+	delay, err = policy.backoff(1, statusError(http.StatusTooManyRequests, http.Header{"Retry-After-Ms": {"1500"}}))
+	require.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, delay)
+
+	// Waiting longer than the maximum delay is left to a fallback
+	tooLong := statusError(http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+	_, err = policy.backoff(1, tooLong)
+	assert.Same(t, tooLong, err)
+
+	_, err = policy.backoff(1, errors.New(`{"type":"error","error":{"type":"overloaded_error"}}`))
+	assert.NoError(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := &rateLimiter{limit: config.RateLimit{RequestsPerMinute: 1, Burst: 1}, tokens: 1, last: time.Now()}
+	require.NoError(t, l.wait(context.Background()))
+
+	// The next token is a minute away
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.wait(ctx), context.DeadlineExceeded)
+	assert.InDelta(t, 0, l.tokens, 0.01)
+}
@@ -0,0 +1,258 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+// encodings are the patterns splitting text into the pieces the BPE of an
+// encoding merges, from tiktoken.
+var encodings = map[string]string{
+	"cl100k_base": `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	"o200k_base": `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+}
+
+// ranksURL is where OpenAI publishes the ranks of its encodings.
+var ranksURL = "https://openaipublic.blob.core.windows.net/encodings/%s.tiktoken"
+
+// ranksSHA256 are the hashes tiktoken publishes for the ranks of the
+// encodings, fetched ranks are only saved when they match.
+var ranksSHA256 = map[string]string{
+	"cl100k_base": "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	"o200k_base":  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+const (
+	// countCacheSize bounds the counts of long texts kept, histories are
+	// counted again before every request.
+	countCacheSize = 4096
+	// countCacheMinLength is the length of the texts whose count is kept.
+	countCacheMinLength = 1024
+)
+
+// bpe counts tokens with the byte pair encoding of OpenAI models.
+type bpe struct {
+	pattern *regexp2.Regexp
+	ranks   map[string]int
+
+	mu     sync.Mutex
+	counts map[[sha256.Size]byte]int
+}
+
+var (
+	bpeMu    sync.Mutex
+	bpes     = map[string]*bpe{}
+	fetching = map[string]bool{}
+)
+
+// loadBPE returns the BPE of an encoding, nil until its ranks are in the
+// data directory. They are fetched in the background the first time, unless
+// downloads are disabled.
+func loadBPE(encoding string) *bpe {
+	bpeMu.Lock()
+	defer bpeMu.Unlock()
+	if b, ok := bpes[encoding]; ok {
+		return b
+	}
+	cfg := config.Get()
+	if cfg == nil || cfg.Data.Directory == "" {
+		return nil
+	}
+	path := filepath.Join(cfg.Data.Directory, "tokenizers", encoding+".tiktoken")
+	f, err := os.Open(path)
+	if err != nil {
+		if !fetching[encoding] && !cfg.Tokenizer.DisableDownload {
+			fetching[encoding] = true
+			go fetchRanks(encoding, path)
+		}
+		return nil
+	}
+	defer f.Close()
+	b, err := newBPE(encodings[encoding], f)
+	if err != nil {
+		logging.Warn("Failed to load tokenizer", "encoding", encoding, "error", err)
+		fetching[encoding] = true
+		return nil
+	}
+	bpes[encoding] = b
+	return b
+}
+
+// fetchRanks downloads the ranks of an encoding, estimates are approximated
+// until they are there.
+func fetchRanks(encoding, path string) {
+	defer logging.RecoverPanic("tokenizer.fetchRanks", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(ranksURL, encoding), nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logging.Debug("Failed to fetch tokenizer", "encoding", encoding, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logging.Debug("Failed to fetch tokenizer", "encoding", encoding, "status", resp.Status)
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logging.Debug("Failed to fetch tokenizer", "encoding", encoding, "error", err)
+		return
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != ranksSHA256[encoding] {
+		logging.Warn("Fetched tokenizer doesn't match its published hash", "encoding", encoding)
+		return
+	}
+	if _, err := newBPE(encodings[encoding], bytes.NewReader(data)); err != nil {
+		logging.Debug("Fetched an invalid tokenizer", "encoding", encoding, "error", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return
+	}
+	bpeMu.Lock()
+	delete(fetching, encoding)
+	bpeMu.Unlock()
+}
+
+// newBPE reads ranks in the format of tiktoken, a base64 token and its rank
+// on every line.
+func newBPE(pattern string, r io.Reader) (*bpe, error) {
+	b := &bpe{
+		pattern: regexp2.MustCompile(pattern, regexp2.None),
+		ranks:   make(map[string]int),
+		counts:  make(map[[sha256.Size]byte]int),
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		token, rank, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, err
+		}
+		b.ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(b.ranks) == 0 {
+		return nil, fmt.Errorf("no ranks")
+	}
+	return b, nil
+}
+
+func (b *bpe) Count(text string) int {
+	if len(text) < countCacheMinLength {
+		return b.count(text)
+	}
+	sum := sha256.Sum256([]byte(text))
+	b.mu.Lock()
+	n, ok := b.counts[sum]
+	b.mu.Unlock()
+	if ok {
+		return n
+	}
+	n = b.count(text)
+	b.mu.Lock()
+	if len(b.counts) >= countCacheSize {
+		clear(b.counts)
+	}
+	b.counts[sum] = n
+	b.mu.Unlock()
+	return n
+}
+
+func (b *bpe) count(text string) int {
+	tokens := 0
+	m, _ := b.pattern.FindStringMatch(text)
+	for m != nil {
+		tokens += b.countPiece([]byte(m.String()))
+		m, _ = b.pattern.FindNextMatch(m)
+	}
+	return tokens
+}
+
+// countPiece merges the pair of parts with the lowest rank until no pair
+// has one, and returns the parts left.
+func (b *bpe) countPiece(piece []byte) int {
+	if _, ok := b.ranks[string(piece)]; ok {
+		return 1
+	}
+	// Every part starts at a byte of the piece, the last one ends it
+	starts := make([]int, len(piece)+1)
+	for i := range starts {
+		starts[i] = i
+	}
+	rank := func(i int) int {
+		if i+2 >= len(starts) {
+			return math.MaxInt
+		}
+		if r, ok := b.ranks[string(piece[starts[i]:starts[i+2]])]; ok {
+			return r
+		}
+		return math.MaxInt
+	}
+	ranks := make([]int, len(starts))
+	for i := range ranks {
+		ranks[i] = rank(i)
+	}
+	for len(starts) > 2 {
+		lowest := 0
+		for i := range ranks[:len(ranks)-1] {
+			if ranks[i] < ranks[lowest] {
+				lowest = i
+			}
+		}
+		if ranks[lowest] == math.MaxInt {
+			break
+		}
+		starts = append(starts[:lowest+1], starts[lowest+2:]...)
+		ranks = append(ranks[:lowest+1], ranks[lowest+2:]...)
+		ranks[lowest] = rank(lowest)
+		if lowest > 0 {
+			ranks[lowest-1] = rank(lowest - 1)
+		}
+	}
+	return len(starts) - 1
+}
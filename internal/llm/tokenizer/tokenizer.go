@@ -0,0 +1,176 @@
+// Package tokenizer estimates how many tokens a request takes, to check it
+// against the context window of the model before sending it.
+package tokenizer
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+const (
+	// messageOverhead is what the role and separators of a message take.
+	messageOverhead = 4
+	// imageTokens is what an image takes, providers scale them down to
+	// about a megapixel.
+	imageTokens = 1600
+)
+
+// Tokenizer counts the tokens text takes for a model.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// For returns the tokenizer of a model: the BPE of OpenAI models once its
+// ranks are in the data directory, an approximation for other models.
+func For(model models.Model) Tokenizer {
+	if encoding := encodingOf(model); encoding != "" {
+		if b := loadBPE(encoding); b != nil {
+			return b
+		}
+	}
+	factor := 1.0
+	if model.Provider == models.ProviderAnthropic || strings.Contains(model.APIModel, "claude") {
+		// Claude splits text into more tokens than OpenAI models do
+		factor = 1.15
+	}
+	return approximation{factor: factor}
+}
+
+// encodingOf returns the BPE encoding of OpenAI models, empty for others.
+func encodingOf(model models.Model) string {
+	name := strings.ToLower(model.APIModel)
+	// OpenRouter names models after their provider
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "chatgpt-", "o1", "o3", "o4"} {
+		if strings.HasPrefix(name, prefix) {
+			return "o200k_base"
+		}
+	}
+	if strings.HasPrefix(name, "gpt-4") || strings.HasPrefix(name, "gpt-3.5") {
+		return "cl100k_base"
+	}
+	return ""
+}
+
+// approximation counts about four characters of English words per token,
+// three digits and two symbols, and more for other scripts.
+type approximation struct {
+	factor float64
+}
+
+// Character classes of the runs of text the approximation counts.
+const (
+	classSpace = iota
+	classASCIILetter
+	classLetter
+	classDigit
+	classSymbol
+)
+
+func classOf(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case r < utf8.RuneSelf && unicode.IsLetter(r):
+		return classASCIILetter
+	case unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	default:
+		return classSymbol
+	}
+}
+
+func (a approximation) Count(text string) int {
+	var tokens float64
+	run, runClass, newline := 0, -1, false
+	flush := func() {
+		n := float64(run)
+		switch runClass {
+		case classSpace:
+			// Single spaces go with the next word
+			if run > 1 || newline {
+				tokens++
+			}
+		case classASCIILetter:
+			tokens += math.Ceil(n / 4)
+		case classLetter, classSymbol:
+			tokens += math.Ceil(n / 2)
+		case classDigit:
+			tokens += math.Ceil(n / 3)
+		}
+	}
+	for _, r := range text {
+		class := classOf(r)
+		// Non-ASCII letters continue words of ASCII ones
+		if class == classLetter && runClass == classASCIILetter {
+			runClass = classLetter
+		}
+		if class != runClass && !(class == classASCIILetter && runClass == classLetter) {
+			flush()
+			run, runClass, newline = 0, class, false
+		}
+		run++
+		newline = newline || r == '\n'
+	}
+	flush()
+	return int(math.Ceil(tokens * a.factor))
+}
+
+// Message returns the tokens a message takes in a request.
+func Message(t Tokenizer, msg message.Message) int {
+	tokens := messageOverhead
+	for _, part := range msg.Parts {
+		switch part := part.(type) {
+		case message.TextContent:
+			tokens += t.Count(part.Text)
+		case message.BinaryContent:
+			if part.IsText() {
+				tokens += t.Count(part.Text())
+			} else {
+				tokens += imageTokens
+			}
+		case message.ImageURLContent:
+			tokens += imageTokens
+		case message.ToolCall:
+			tokens += t.Count(part.Name) + t.Count(part.Input)
+		case message.ToolResult:
+			tokens += ToolResult(t, part)
+		}
+	}
+	return tokens
+}
+
+// ToolResult returns the tokens the result of a tool call takes.
+func ToolResult(t Tokenizer, result message.ToolResult) int {
+	return t.Count(result.Text()) + imageTokens*len(result.Images())
+}
+
+// Messages returns the tokens a history takes in a request.
+func Messages(t Tokenizer, msgs []message.Message) int {
+	tokens := 0
+	for _, msg := range msgs {
+		tokens += Message(t, msg)
+	}
+	return tokens
+}
+
+// Tools returns the tokens the definitions of tools take in a request.
+func Tools(t Tokenizer, agentTools []tools.BaseTool) int {
+	tokens := 0
+	for _, tool := range agentTools {
+		data, _ := json.Marshal(tool.Info())
+		tokens += t.Count(string(data))
+	}
+	return tokens
+}
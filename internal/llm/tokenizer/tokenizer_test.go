@@ -0,0 +1,118 @@
+package tokenizer
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRanks merge "hello world" into two tokens.
+func testRanks() string {
+	var lines []string
+	for i, token := range []string{"he", "ll", "hell", "hello", " w", "or", "ld", "orld", " world"} {
+		lines = append(lines, fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(token)), i))
+	}
+	for i, c := range "helowrd !" {
+		lines = append(lines, fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(string(c))), 100+i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestBPE(t *testing.T) {
+	b, err := newBPE(encodings["o200k_base"], strings.NewReader(testRanks()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, b.Count("hello world"))
+	assert.Equal(t, 4, b.Count("hello world !"))
+	// Bytes without a rank stay apart
+	assert.Equal(t, 3, b.Count("hhx"))
+
+	_, err = newBPE(encodings["o200k_base"], strings.NewReader("not ranks"))
+	assert.Error(t, err)
+}
+
+func TestFor(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = t.TempDir()
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/o200k_base.tiktoken", r.URL.Path)
+		fmt.Fprint(w, testRanks())
+	}))
+	defer srv.Close()
+	ranksURL = srv.URL + "/%s.tiktoken"
+	sum := sha256.Sum256([]byte(testRanks()))
+	ranksSHA256["o200k_base"] = hex.EncodeToString(sum[:])
+
+	// Estimates are approximated while the ranks are fetched
+	gpt := models.SupportedModels[models.GPT41]
+	assert.IsType(t, approximation{}, For(gpt))
+	assert.Eventually(t, func() bool {
+		_, ok := For(gpt).(*bpe)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 2, For(gpt).Count("hello world"))
+
+	claude := For(models.SupportedModels[models.Claude4Sonnet])
+	assert.Equal(t, approximation{factor: 1.15}, claude)
+	gemini := For(models.SupportedModels[models.Gemini25])
+	assert.Equal(t, 4, gemini.Count("The quick fox"))
+	assert.Greater(t, claude.Count(strings.Repeat("The quick fox ", 100)), gemini.Count(strings.Repeat("The quick fox ", 100)))
+}
+
+func TestFetchRanks(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = t.TempDir()
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, testRanks())
+	}))
+	defer srv.Close()
+	ranksURL = srv.URL + "/%s.tiktoken"
+
+	// Ranks that don't match the published hash aren't saved
+	path := filepath.Join(config.Get().Data.Directory, "tokenizers", "cl100k_base.tiktoken")
+	fetchRanks("cl100k_base", path)
+	assert.Equal(t, 1, requests)
+	assert.NoFileExists(t, path)
+
+	config.Get().Tokenizer.DisableDownload = true
+	defer func() { config.Get().Tokenizer.DisableDownload = false }()
+	assert.Nil(t, loadBPE("cl100k_base"))
+	bpeMu.Lock()
+	assert.False(t, fetching["cl100k_base"])
+	bpeMu.Unlock()
+	assert.Equal(t, 1, requests)
+}
+
+func TestMessages(t *testing.T) {
+	a := approximation{factor: 1}
+	msgs := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{
+			message.TextContent{Text: "The quick fox"},
+			message.BinaryContent{MIMEType: "image/png", Data: []byte{1}},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{Content: "jumps over"}}},
+	}
+	assert.Equal(t, messageOverhead+4+imageTokens, Message(a, msgs[0]))
+	assert.Equal(t, 2*messageOverhead+4+imageTokens+3, Messages(a, msgs))
+}
@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"encoding/json"
+
+	"github.com/opencode-ai/opencode/internal/message"
 )
 
 type ToolInfo struct {
@@ -32,6 +34,9 @@ type ToolResponse struct {
 	Content  string           `json:"content"`
 	Metadata string           `json:"metadata,omitempty"`
 	IsError  bool             `json:"is_error"`
+	// Attachments hold images and embedded resources returned next to the
+	// content. Type is ToolResponseTypeImage when one of them is an image.
+	Attachments []message.BinaryContent `json:"attachments,omitempty"`
 }
 
 func NewTextResponse(content string) ToolResponse {
@@ -0,0 +1,296 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// errClosed is returned for requests on a closed connection.
+var errClosed = errors.New("connection closed")
+
+// errMethodNotFound is returned by request handlers for methods the client
+// doesn't support.
+var errMethodNotFound = errors.New("method not found")
+
+// transport carries JSON-RPC messages to a server.
+type transport interface {
+	// request sends a request and waits for its result.
+	request(ctx context.Context, method string, params any) (json.RawMessage, error)
+	// notify sends a notification.
+	notify(ctx context.Context, method string, params any) error
+	close() error
+}
+
+// rpcMessage is any JSON-RPC message read from a server.
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// isResponse reports whether the message answers a request.
+func (m *rpcMessage) isResponse() bool {
+	return m.ID != nil && m.Method == ""
+}
+
+// isRequest reports whether the message is a request from the server.
+func (m *rpcMessage) isRequest() bool {
+	return m.ID != nil && m.Method != ""
+}
+
+// result returns the result of a response, or its error.
+func (m *rpcMessage) result() (json.RawMessage, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	return m.Result, nil
+}
+
+// rpcClient implements client.MCPClient on top of a transport. It is used for
+// the transports mcp-go doesn't provide.
+type rpcClient struct {
+	transport transport
+
+	// onRequest answers requests from the server, like sampling. It is set
+	// before the connection is initialized.
+	onRequest func(method string, params json.RawMessage) (any, error)
+
+	handlersMu sync.RWMutex
+	handlers   []func(mcp.JSONRPCNotification)
+
+	// queue holds the notifications waiting for the handlers, which run
+	// one at a time off the reader so they may send requests themselves.
+	queueMu     sync.Mutex
+	queue       []mcp.JSONRPCNotification
+	dispatching bool
+}
+
+var _ client.MCPClient = (*rpcClient)(nil)
+
+// handleMessage dispatches a message the server sent on its own and returns
+// the answer to a request. Answering may take long, so transports call it
+// in the background for requests. Notifications are queued for the
+// handlers, so it returns right away for them.
+func (c *rpcClient) handleMessage(msg *rpcMessage) *rpcResponse {
+	if msg.isRequest() {
+		response := &rpcResponse{JSONRPC: mcp.JSONRPC_VERSION, ID: *msg.ID}
+		answer, err := any(nil), errMethodNotFound
+		if c.onRequest != nil {
+			answer, err = c.onRequest(msg.Method, msg.Params)
+		}
+		switch {
+		case errors.Is(err, errMethodNotFound):
+			response.Error = &rpcError{Code: mcp.METHOD_NOT_FOUND, Message: fmt.Sprintf("method %q is not supported", msg.Method)}
+		case err != nil:
+			response.Error = &rpcError{Code: mcp.INTERNAL_ERROR, Message: err.Error()}
+		default:
+			response.Result = answer
+		}
+		return response
+	}
+
+	var notification mcp.JSONRPCNotification
+	notification.JSONRPC = mcp.JSONRPC_VERSION
+	notification.Method = msg.Method
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &notification.Params); err != nil {
+			return nil
+		}
+	}
+	c.queueMu.Lock()
+	c.queue = append(c.queue, notification)
+	if !c.dispatching {
+		c.dispatching = true
+		go c.dispatchNotifications()
+	}
+	c.queueMu.Unlock()
+	return nil
+}
+
+// dispatchNotifications runs the handlers for the queued notifications in
+// order until the queue is empty.
+func (c *rpcClient) dispatchNotifications() {
+	for {
+		c.queueMu.Lock()
+		if len(c.queue) == 0 {
+			c.dispatching = false
+			c.queueMu.Unlock()
+			return
+		}
+		notification := c.queue[0]
+		c.queue = c.queue[1:]
+		c.queueMu.Unlock()
+
+		c.handlersMu.RLock()
+		handlers := c.handlers
+		c.handlersMu.RUnlock()
+		for _, handler := range handlers {
+			handler(notification)
+		}
+	}
+}
+
+func (c *rpcClient) OnNotification(handler func(notification mcp.JSONRPCNotification)) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *rpcClient) Close() error {
+	return c.transport.close()
+}
+
+// call sends a request and decodes its result into out.
+func (c *rpcClient) call(ctx context.Context, method string, params any, out any) error {
+	result, err := c.transport.request(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(result, out); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func (c *rpcClient) Initialize(ctx context.Context, request mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	// Params are marshalled by hand so an empty capabilities object is
+	// sent, which some servers require.
+	params := struct {
+		ProtocolVersion string                 `json:"protocolVersion"`
+		ClientInfo      mcp.Implementation     `json:"clientInfo"`
+		Capabilities    mcp.ClientCapabilities `json:"capabilities"`
+	}{
+		ProtocolVersion: request.Params.ProtocolVersion,
+		ClientInfo:      request.Params.ClientInfo,
+		Capabilities:    request.Params.Capabilities,
+	}
+	var result mcp.InitializeResult
+	if err := c.call(ctx, string(mcp.MethodInitialize), params, &result); err != nil {
+		return nil, err
+	}
+	if err := c.transport.notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, fmt.Errorf("failed to send initialized notification: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *rpcClient) Ping(ctx context.Context) error {
+	return c.call(ctx, string(mcp.MethodPing), nil, nil)
+}
+
+func (c *rpcClient) ListResources(ctx context.Context, request mcp.ListResourcesRequest) (*mcp.ListResourcesResult, error) {
+	var result mcp.ListResourcesResult
+	if err := c.call(ctx, string(mcp.MethodResourcesList), request.Params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *rpcClient) ListResourceTemplates(ctx context.Context, request mcp.ListResourceTemplatesRequest) (*mcp.ListResourceTemplatesResult, error) {
+	var result mcp.ListResourceTemplatesResult
+	if err := c.call(ctx, string(mcp.MethodResourcesTemplatesList), request.Params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *rpcClient) ReadResource(ctx context.Context, request mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	result, err := c.transport.request(ctx, string(mcp.MethodResourcesRead), request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.ParseReadResourceResult(&result)
+}
+
+func (c *rpcClient) Subscribe(ctx context.Context, request mcp.SubscribeRequest) error {
+	return c.call(ctx, "resources/subscribe", request.Params, nil)
+}
+
+func (c *rpcClient) Unsubscribe(ctx context.Context, request mcp.UnsubscribeRequest) error {
+	return c.call(ctx, "resources/unsubscribe", request.Params, nil)
+}
+
+func (c *rpcClient) ListPrompts(ctx context.Context, request mcp.ListPromptsRequest) (*mcp.ListPromptsResult, error) {
+	var result mcp.ListPromptsResult
+	if err := c.call(ctx, string(mcp.MethodPromptsList), request.Params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *rpcClient) GetPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	result, err := c.transport.request(ctx, string(mcp.MethodPromptsGet), request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.ParseGetPromptResult(&result)
+}
+
+func (c *rpcClient) ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	var result mcp.ListToolsResult
+	if err := c.call(ctx, string(mcp.MethodToolsList), request.Params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *rpcClient) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	result, err := c.transport.request(ctx, string(mcp.MethodToolsCall), request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.ParseCallToolResult(&result)
+}
+
+func (c *rpcClient) SetLevel(ctx context.Context, request mcp.SetLevelRequest) error {
+	return c.call(ctx, "logging/setLevel", request.Params, nil)
+}
+
+func (c *rpcClient) Complete(ctx context.Context, request mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+	var result mcp.CompleteResult
+	if err := c.call(ctx, "completion/complete", request.Params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
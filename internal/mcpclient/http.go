@@ -0,0 +1,272 @@
+package mcpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+const sessionHeader = "Mcp-Session-Id"
+
+// errSessionExpired is returned when the server no longer knows the session,
+// the connection has to be initialized again.
+var errSessionExpired = errors.New("session expired")
+
+// httpTransport implements the streamable HTTP transport. Every message is
+// POSTed to the endpoint, and the server answers with a JSON body or an
+// event stream carrying the response.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	handle  func(*rpcMessage) *rpcResponse
+
+	nextID atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string
+
+	// listening is cancelled on close to end the stream of server messages.
+	listening       context.Context
+	cancelListening context.CancelFunc
+}
+
+// newHTTPClient creates a client for the streamable HTTP endpoint at url.
+func newHTTPClient(url string, headers map[string]string) *rpcClient {
+	c := &rpcClient{}
+	listening, cancel := context.WithCancel(context.Background())
+	c.transport = &httpTransport{
+		url:             url,
+		headers:         headers,
+		client:          &http.Client{},
+		handle:          c.handleMessage,
+		listening:       listening,
+		cancelListening: cancel,
+	}
+	return c
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.url, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(sessionHeader, t.sessionID)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+// post sends a message and returns the response once its status is known.
+func (t *httpTransport) post(ctx context.Context, message any) (*http.Response, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && t.hasSession() {
+		resp.Body.Close()
+		return nil, errSessionExpired
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) hasSession() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID != ""
+}
+
+func (t *httpTransport) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: mcp.JSONRPC_VERSION, ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	msg, err := readResponse(resp, fmt.Sprint(id), t.dispatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	if method == string(mcp.MethodInitialize) && msg.Error == nil {
+		go t.listen()
+	}
+	return msg.result()
+}
+
+// readResponse reads the response with the given ID from a JSON body or an
+// event stream. The stream may carry messages of the server before the
+// response, they are passed to dispatch.
+func readResponse(resp *http.Response, id string, dispatch func(*rpcMessage)) (*rpcMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg rpcMessage
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+
+	var result *rpcMessage
+	err := readEvents(resp.Body, func(msg *rpcMessage) bool {
+		if msg.isResponse() && string(*msg.ID) == id {
+			result = msg
+			return false
+		}
+		dispatch(msg)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params any) error {
+	resp, err := t.post(ctx, rpcNotification{JSONRPC: mcp.JSONRPC_VERSION, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// dispatch handles a message of the server. Requests are answered in the
+// background, posting the answer for as long as the connection is open.
+func (t *httpTransport) dispatch(msg *rpcMessage) {
+	switch {
+	case msg.isResponse():
+	case msg.isRequest():
+		go func() {
+			resp, err := t.post(t.listening, t.handle(msg))
+			if err != nil {
+				logging.Debug("Failed to answer MCP server request", "method", msg.Method, "error", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	default:
+		t.handle(msg)
+	}
+}
+
+// listen opens the optional stream of messages the server sends on its own.
+// Servers that don't offer it answer 405, which is fine.
+func (t *httpTransport) listen() {
+	req, err := t.newRequest(t.listening, http.MethodGet, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	err = readEvents(resp.Body, func(msg *rpcMessage) bool {
+		t.dispatch(msg)
+		return true
+	})
+	if err != nil && t.listening.Err() == nil {
+		logging.Debug("MCP server stream ended", "url", t.url, "error", err)
+	}
+}
+
+// close ends the session on the server.
+func (t *httpTransport) close() error {
+	t.cancelListening()
+	if !t.hasSession() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+	req, err := t.newRequest(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readEvents calls fn with the JSON-RPC message of every event of a server-sent
+// event stream until fn returns false or the stream ends.
+func readEvents(r io.Reader, fn func(*rpcMessage) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		err := json.Unmarshal([]byte(strings.Join(data, "\n")), &msg)
+		data = data[:0]
+		if err != nil {
+			continue
+		}
+		if !fn(&msg) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
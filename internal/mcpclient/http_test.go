@@ -0,0 +1,182 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStreamableServer serves an MCP server over streamable HTTP. Tool calls
+// are answered with an event stream that sends a notification first, other
+// requests with a JSON body.
+func newStreamableServer(t *testing.T, token string) *httptest.Server {
+	s := mcpserver.NewMCPServer("test", "1.0.0", mcpserver.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("text")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		text, _ := request.Params.Arguments["text"].(string)
+		return mcp.NewToolResultText(text), nil
+	})
+	s.AddTool(mcp.NewTool("hidden"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("hidden"), nil
+	})
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var request struct {
+			Method string `json:"method"`
+		}
+		json.Unmarshal(body, &request)
+		if request.Method == string(mcp.MethodInitialize) {
+			w.Header().Set(sessionHeader, "session-1")
+		} else if r.Header.Get(sessionHeader) != "session-1" {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+
+		response := s.HandleMessage(r.Context(), body)
+		if response == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(response)
+		if request.Method == string(mcp.MethodToolsCall) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\",\"params\":{}}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
+
+func TestStreamableHTTP(t *testing.T) {
+	t.Setenv("MCPCLIENT_TEST_TOKEN", "secret")
+	srv := newStreamableServer(t, "secret")
+	defer srv.Close()
+
+	m := NewManager(map[string]config.MCPServer{
+		"remote": {
+			Type:        config.MCPHttp,
+			URL:         srv.URL,
+			Headers:     map[string]string{"Authorization": "Bearer ${MCPCLIENT_TEST_TOKEN}"},
+			DeniedTools: []string{"hidden"},
+		},
+	})
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+	require.Equal(t, []string{"echo"}, toolNames(m))
+
+	result, err := m.CallTool(ctx, "remote", "echo", map[string]any{"text": "over http"})
+	require.NoError(t, err)
+	assert.Equal(t, "over http", result.Content[0].(mcp.TextContent).Text)
+}
+
+// TestServerRequests checks that requests the server sends while a tool call
+// is running are answered, here with the roots and a sampled completion.
+func TestServerRequests(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+
+	var capabilities mcp.ClientCapabilities
+	answers := make(chan rpcMessage, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg rpcMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		if msg.isResponse() {
+			answers <- msg
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		reply := func(result string) string {
+			return fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, *msg.ID, result)
+		}
+		switch msg.Method {
+		case string(mcp.MethodInitialize):
+			var params mcp.InitializeRequest
+			json.Unmarshal(msg.Params, &params.Params)
+			capabilities = params.Params.Capabilities
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, reply(`{"protocolVersion":"2024-11-05","capabilities":{"tools":{}},"serverInfo":{"name":"asking","version":"1"}}`))
+		case string(mcp.MethodToolsList):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, reply(`{"tools":[{"name":"summarize","inputSchema":{"type":"object"}}]}`))
+		case string(mcp.MethodToolsCall):
+			w.Header().Set("Content-Type", "text/event-stream")
+			ask := func(id, method, params string) rpcMessage {
+				fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":%q,\"method\":%q,\"params\":%s}\n\n", id, method, params)
+				w.(http.Flusher).Flush()
+				return <-answers
+			}
+			roots := ask("r1", "roots/list", `{}`)
+			var listed mcp.ListRootsResult
+			require.NoError(t, json.Unmarshal(roots.Result, &listed))
+			sampled := ask("s1", "sampling/createMessage", `{"messages":[{"role":"user","content":{"type":"text","text":"the readme"}}],"maxTokens":100}`)
+			var created struct {
+				Content mcp.TextContent `json:"content"`
+			}
+			require.NoError(t, json.Unmarshal(sampled.Result, &created))
+			text, _ := json.Marshal(listed.Roots[0].URI + " " + created.Content.Text)
+			fmt.Fprintf(w, "data: %s\n\n", reply(fmt.Sprintf(`{"content":[{"type":"text","text":%s}]}`, text)))
+		}
+	}))
+	defer srv.Close()
+
+	enabled := true
+	m := NewManager(map[string]config.MCPServer{
+		"asking": {Type: config.MCPHttp, URL: srv.URL, Sampling: config.MCPSampling{Enabled: &enabled}},
+	})
+	m.SetSamplingHandler(func(ctx context.Context, server string, cfg config.MCPSampling, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		assert.Equal(t, "asking", server)
+		assert.Equal(t, 100, request.Params.MaxTokens)
+		return &mcp.CreateMessageResult{
+			SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.NewTextContent("summary")},
+			Model:           "test",
+		}, nil
+	})
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+	require.NotNil(t, capabilities.Roots)
+	require.NotNil(t, capabilities.Sampling)
+
+	result, err := m.CallTool(ctx, "asking", "summarize", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "file://"+filepath.ToSlash(dir)+" summary", result.Content[0].(mcp.TextContent).Text)
+}
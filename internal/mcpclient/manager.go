@@ -0,0 +1,813 @@
+// Package mcpclient keeps connections to the configured MCP servers open for
+// the lifetime of the app.
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/version"
+)
+
+const (
+	connectTimeout      = 30 * time.Second
+	healthCheckInterval = 30 * time.Second
+	pingTimeout         = 10 * time.Second
+	closeTimeout        = 5 * time.Second
+	// drainTimeout bounds how long a connection waits for the calls in
+	// flight before it is closed.
+	drainTimeout = 30 * time.Second
+
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// ErrNotConnected is returned when calling a tool of a server that is not
+// connected.
+var ErrNotConnected = errors.New("MCP server is not connected")
+
+// State describes the connection of a server.
+type State string
+
+const (
+	StateConnecting State = "connecting"
+	StateConnected  State = "connected"
+	StateFailed     State = "failed"
+	StateDisabled   State = "disabled"
+)
+
+// Tool is a tool of a connected server.
+type Tool struct {
+	Server string
+	Tool   mcp.Tool
+}
+
+// Resource is a resource of a connected server.
+type Resource struct {
+	Server   string
+	Resource mcp.Resource
+}
+
+// Prompt is a prompt of a connected server.
+type Prompt struct {
+	Server string
+	Prompt mcp.Prompt
+}
+
+// SamplingHandler answers a sampling request of a server with the given
+// sampling configuration.
+type SamplingHandler func(ctx context.Context, server string, cfg config.MCPSampling, request mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+
+// ServerStatus describes the connection to a configured server.
+type ServerStatus struct {
+	Name  string
+	Type  config.MCPType
+	State State
+	// Err is the reason of the last failed connection attempt.
+	Err error
+	// ProtocolVersion and ServerInfo are reported by a connected server.
+	ProtocolVersion string
+	ServerInfo      mcp.Implementation
+	Tools           int
+	Resources       int
+	Prompts         int
+	// Stderr holds the last lines a stdio server wrote to its standard error.
+	Stderr []string
+}
+
+// Manager holds one connection per configured server. Connections are
+// health checked and re-established with exponential backoff, and the tools,
+// resources and prompts of a server are listed again when it reports a
+// change. Every change of a server is published as its ServerStatus.
+type Manager struct {
+	*pubsub.Broker[ServerStatus]
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	servers map[string]*server
+
+	// mu serializes starting and stopping servers.
+	mu       sync.Mutex
+	sampling SamplingHandler
+}
+
+// NewManager creates a manager for the given servers. Call Start to connect.
+func NewManager(servers map[string]config.MCPServer) *Manager {
+	m := &Manager{
+		Broker:  pubsub.NewBroker[ServerStatus](),
+		servers: make(map[string]*server, len(servers)),
+	}
+	for name, cfg := range servers {
+		s := &server{
+			name:    name,
+			config:  cfg,
+			state:   StateConnecting,
+			stderr:  &stderrTail{},
+			refresh: make(chan struct{}, 1),
+			check:   make(chan struct{}, 1),
+			ready:   make(chan struct{}),
+		}
+		s.changed = func() {
+			m.Publish(pubsub.UpdatedEvent, s.status())
+		}
+		if !cfg.IsEnabled() {
+			s.state = StateDisabled
+			s.readyOnce.Do(func() { close(s.ready) })
+		}
+		m.servers[name] = s
+	}
+	return m
+}
+
+// SetSamplingHandler lets servers request completions through handler. It
+// applies to servers started afterwards, so call it before Start.
+func (m *Manager) SetSamplingHandler(handler SamplingHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sampling = handler
+}
+
+// Start connects to every enabled server in the background.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	for _, s := range m.servers {
+		if s.state != StateDisabled {
+			m.start(s)
+		}
+	}
+}
+
+// start runs the connection of a server until it is stopped or the manager
+// is closed. m.mu must be held.
+func (m *Manager) start(s *server) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	done := make(chan struct{})
+	s.sampling = m.sampling
+	s.stop = func() {
+		cancel()
+		<-done
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(done)
+		defer logging.RecoverPanic("mcp-"+s.name, nil)
+		s.run(ctx)
+	}()
+}
+
+// Enable connects to a server for the rest of the session, even when it is
+// disabled in the configuration.
+func (m *Manager) Enable(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.servers[name]
+	if !ok {
+		return fmt.Errorf("unknown MCP server %q", name)
+	}
+	if m.ctx == nil {
+		return errors.New("MCP servers are not started")
+	}
+	if s.stop != nil {
+		return nil
+	}
+	s.setState(StateConnecting, nil, nil)
+	m.start(s)
+	return nil
+}
+
+// Disable disconnects from a server for the rest of the session.
+func (m *Manager) Disable(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.servers[name]
+	if !ok {
+		return fmt.Errorf("unknown MCP server %q", name)
+	}
+	if s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+	s.setState(StateDisabled, nil, nil)
+	s.readyOnce.Do(func() { close(s.ready) })
+	return nil
+}
+
+// Status returns the status of every configured server, sorted by name.
+func (m *Manager) Status() []ServerStatus {
+	out := make([]ServerStatus, 0, len(m.servers))
+	for _, name := range m.names() {
+		out = append(out, m.servers[name].status())
+	}
+	return out
+}
+
+// WaitReady blocks until every server has either connected or failed its
+// first connection attempt, or ctx is done.
+func (m *Manager) WaitReady(ctx context.Context) {
+	for _, s := range m.servers {
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close disconnects from every server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	cancel := m.cancel
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		m.wg.Wait()
+	}
+	m.Shutdown()
+}
+
+// Tools returns the tools of the connected servers, sorted by server.
+func (m *Manager) Tools() []Tool {
+	var out []Tool
+	m.eachConnected(func(s *server) {
+		for _, t := range s.tools {
+			out = append(out, Tool{Server: s.name, Tool: t})
+		}
+	})
+	return out
+}
+
+// Resources returns the resources of the connected servers, sorted by
+// server.
+func (m *Manager) Resources() []Resource {
+	var out []Resource
+	m.eachConnected(func(s *server) {
+		for _, r := range s.resources {
+			out = append(out, Resource{Server: s.name, Resource: r})
+		}
+	})
+	return out
+}
+
+// Prompts returns the prompts of the connected servers, sorted by server.
+func (m *Manager) Prompts() []Prompt {
+	var out []Prompt
+	m.eachConnected(func(s *server) {
+		for _, p := range s.prompts {
+			out = append(out, Prompt{Server: s.name, Prompt: p})
+		}
+	})
+	return out
+}
+
+// eachConnected calls fn with the lock of every connected server held, in
+// the order of their names.
+func (m *Manager) eachConnected(fn func(s *server)) {
+	for _, name := range m.names() {
+		s := m.servers[name]
+		s.mu.RLock()
+		if s.state == StateConnected {
+			fn(s)
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// CallTool calls a tool on the connection of its server. A failed call
+// triggers a health check so dead connections are noticed right away.
+func (m *Manager) CallTool(ctx context.Context, serverName, toolName string, args map[string]any) (*mcp.CallToolResult, error) {
+	s, c, done, err := m.client(serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if !s.toolAllowed(toolName) {
+		return nil, fmt.Errorf("tool %s of %s is not allowed", toolName, serverName)
+	}
+
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = toolName
+	request.Params.Arguments = args
+	result, err := c.CallTool(ctx, request)
+	if err != nil {
+		s.signal(s.check)
+	}
+	return result, err
+}
+
+// ReadResource reads a resource from its server.
+func (m *Manager) ReadResource(ctx context.Context, serverName, uri string) (*mcp.ReadResourceResult, error) {
+	s, c, done, err := m.client(serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+	result, err := c.ReadResource(ctx, request)
+	if err != nil {
+		s.signal(s.check)
+	}
+	return result, err
+}
+
+// GetPrompt renders a prompt of a server with the given arguments.
+func (m *Manager) GetPrompt(ctx context.Context, serverName, promptName string, args map[string]string) (*mcp.GetPromptResult, error) {
+	s, c, done, err := m.client(serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	ctx, cancel := s.requestContext(ctx)
+	defer cancel()
+	request := mcp.GetPromptRequest{}
+	request.Params.Name = promptName
+	request.Params.Arguments = args
+	result, err := c.GetPrompt(ctx, request)
+	if err != nil {
+		s.signal(s.check)
+	}
+	return result, err
+}
+
+func (m *Manager) names() []string {
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// client returns the connection of a server for a request, which must call
+// done once it finished so the connection isn't closed under it.
+func (m *Manager) client(serverName string) (s *server, c client.MCPClient, done func(), err error) {
+	s, ok := m.servers[serverName]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("unknown MCP server %q", serverName)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.client == nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", serverName, ErrNotConnected)
+	}
+	calls := s.calls
+	calls.Add(1)
+	return s, s.client, calls.Done, nil
+}
+
+// server is the connection to a single configured server.
+type server struct {
+	name   string
+	config config.MCPServer
+
+	mu              sync.RWMutex
+	state           State
+	err             error
+	client          client.MCPClient
+	calls           *sync.WaitGroup
+	protocolVersion string
+	serverInfo      mcp.Implementation
+	capabilities    mcp.ServerCapabilities
+	tools           []mcp.Tool
+	resources       []mcp.Resource
+	prompts         []mcp.Prompt
+
+	stderr *stderrTail
+	// changed publishes the status of the server.
+	changed func()
+	// stop ends the connection started by Manager.start, it is nil while
+	// the server isn't running. Guarded by Manager.mu.
+	stop     func()
+	sampling SamplingHandler
+
+	refresh chan struct{}
+	check   chan struct{}
+
+	readyOnce sync.Once
+	ready     chan struct{}
+}
+
+// run connects to the server and keeps the connection alive until ctx is
+// done.
+func (s *server) run(ctx context.Context) {
+	backoff := minBackoff
+	reported := false
+	for {
+		c, err := s.connect(ctx)
+		if err == nil {
+			backoff = minBackoff
+			reported = false
+			err = s.serve(ctx, c)
+			s.disconnect(c)
+		}
+		if ctx.Err() != nil {
+			s.setState(StateFailed, ctx.Err(), nil)
+			return
+		}
+
+		// Only the first failure is shown, retries are logged.
+		if !reported {
+			logging.WarnPersist(fmt.Sprintf("MCP server %s failed: %v", s.name, err))
+			reported = true
+		}
+		logging.Warn("MCP server connection failed", "server", s.name, "error", err, "retry", backoff)
+		s.setState(StateFailed, err, nil)
+		s.readyOnce.Do(func() { close(s.ready) })
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+		s.setState(StateConnecting, nil, nil)
+	}
+}
+
+// connect starts a client, completes the handshake and lists what the
+// server offers.
+func (s *server) connect(ctx context.Context) (client.MCPClient, error) {
+	var c client.MCPClient
+	switch s.config.Type {
+	case config.MCPStdio:
+		dir := s.config.Cwd
+		if dir != "" && !filepath.IsAbs(dir) {
+			dir = filepath.Join(config.WorkingDirectory(), dir)
+		}
+		stdio, err := newStdioClient(s.config.Command, s.config.Args, expandEnv(s.config.Env), dir, s.stderr)
+		if err != nil {
+			return nil, err
+		}
+		c = stdio
+	case config.MCPHttp:
+		c = newHTTPClient(s.config.URL, expandHeaders(s.config.Headers))
+	case config.MCPSse:
+		sse, err := client.NewSSEMCPClient(s.config.URL, client.WithHeaders(expandHeaders(s.config.Headers)))
+		if err != nil {
+			return nil, err
+		}
+		// The stream lives until the client is closed, not just the
+		// handshake, so calls in flight get their responses after ctx
+		// is done.
+		streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		if err := sse.Start(streamCtx); err != nil {
+			cancel()
+			return nil, err
+		}
+		c = &sseClient{SSEMCPClient: sse, cancel: cancel}
+	default:
+		return nil, fmt.Errorf("unsupported MCP server type %q", s.config.Type)
+	}
+
+	c.OnNotification(func(notification mcp.JSONRPCNotification) {
+		switch notification.Method {
+		case "notifications/tools/list_changed",
+			"notifications/resources/list_changed",
+			"notifications/prompts/list_changed":
+			s.signal(s.refresh)
+		}
+	})
+
+	initCtx, cancel := context.WithTimeout(ctx, s.connectTimeout())
+	defer cancel()
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "OpenCode",
+		Version: version.Version,
+	}
+	// Only our own transports can answer requests of the server.
+	if rpc, ok := c.(*rpcClient); ok {
+		rpc.onRequest = func(method string, params json.RawMessage) (any, error) {
+			return s.handleRequest(ctx, method, params)
+		}
+		initRequest.Params.Capabilities.Roots = &struct {
+			ListChanged bool `json:"listChanged,omitempty"`
+		}{}
+		if s.samplingEnabled() {
+			initRequest.Params.Capabilities.Sampling = &struct{}{}
+		}
+	}
+	initResult, err := c.Initialize(initCtx, initRequest)
+	if err != nil {
+		closeClient(s.name, c)
+		return nil, fmt.Errorf("failed to initialize: %w", err)
+	}
+	catalog, err := s.list(initCtx, c, initResult.Capabilities)
+	if err != nil {
+		closeClient(s.name, c)
+		return nil, err
+	}
+
+	s.setConnected(c, initResult, catalog)
+	s.readyOnce.Do(func() { close(s.ready) })
+	logging.Info("Connected to MCP server", "server", s.name, "tools", len(catalog.tools), "resources", len(catalog.resources), "prompts", len(catalog.prompts))
+	return c, nil
+}
+
+// serve health checks the connection and refreshes the lists of the server
+// until the connection fails or ctx is done.
+func (s *server) serve(ctx context.Context, c client.MCPClient) error {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := ping(ctx, c); err != nil {
+				return err
+			}
+		case <-s.check:
+			if err := ping(ctx, c); err != nil {
+				return err
+			}
+		case <-s.refresh:
+			s.mu.RLock()
+			capabilities := s.capabilities
+			s.mu.RUnlock()
+			listCtx, cancel := context.WithTimeout(ctx, s.connectTimeout())
+			catalog, err := s.list(listCtx, c, capabilities)
+			cancel()
+			if err != nil {
+				return err
+			}
+			s.setCatalog(capabilities, catalog)
+			logging.Info("Refreshed MCP server lists", "server", s.name, "tools", len(catalog.tools), "resources", len(catalog.resources), "prompts", len(catalog.prompts))
+		}
+	}
+}
+
+func (s *server) setState(state State, err error, c client.MCPClient) {
+	s.mu.Lock()
+	s.state = state
+	s.err = err
+	s.client = c
+	if c == nil {
+		s.protocolVersion = ""
+		s.serverInfo = mcp.Implementation{}
+		s.capabilities = mcp.ServerCapabilities{}
+		s.tools = nil
+		s.resources = nil
+		s.prompts = nil
+	}
+	s.mu.Unlock()
+	s.changed()
+}
+
+// disconnect stops handing out c and closes it once the calls in flight
+// finished, or after drainTimeout for calls stuck on a dead connection.
+func (s *server) disconnect(c client.MCPClient) {
+	s.mu.Lock()
+	calls := s.calls
+	if s.client == c {
+		s.client = nil
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		calls.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		logging.Warn("Closing MCP client with calls in flight", "server", s.name)
+	}
+	closeClient(s.name, c)
+}
+
+func (s *server) setConnected(c client.MCPClient, result *mcp.InitializeResult, catalog catalog) {
+	s.mu.Lock()
+	s.state = StateConnected
+	s.err = nil
+	s.client = c
+	s.calls = &sync.WaitGroup{}
+	s.protocolVersion = result.ProtocolVersion
+	s.serverInfo = result.ServerInfo
+	s.mu.Unlock()
+	s.setCatalog(result.Capabilities, catalog)
+}
+
+func (s *server) status() ServerStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return ServerStatus{
+		Name:            s.name,
+		Type:            s.config.Type,
+		State:           s.state,
+		Err:             s.err,
+		ProtocolVersion: s.protocolVersion,
+		ServerInfo:      s.serverInfo,
+		Tools:           len(s.tools),
+		Resources:       len(s.resources),
+		Prompts:         len(s.prompts),
+		Stderr:          s.stderr.Lines(),
+	}
+}
+
+// catalog is what a server offers.
+type catalog struct {
+	tools     []mcp.Tool
+	resources []mcp.Resource
+	prompts   []mcp.Prompt
+}
+
+func (s *server) setCatalog(capabilities mcp.ServerCapabilities, catalog catalog) {
+	s.mu.Lock()
+	s.capabilities = capabilities
+	s.tools = slices.Clone(catalog.tools)
+	s.resources = slices.Clone(catalog.resources)
+	s.prompts = slices.Clone(catalog.prompts)
+	s.mu.Unlock()
+	s.changed()
+}
+
+// list fetches the tools of a server, and its resources and prompts when it
+// declares them. Only a failure to list the tools is fatal, servers that
+// can't list their resources or prompts still offer their tools.
+func (s *server) list(ctx context.Context, c client.MCPClient, capabilities mcp.ServerCapabilities) (catalog, error) {
+	var out catalog
+	tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return out, fmt.Errorf("failed to list tools: %w", err)
+	}
+	for _, tool := range tools.Tools {
+		if s.toolAllowed(tool.Name) {
+			out.tools = append(out.tools, tool)
+		}
+	}
+
+	if capabilities.Resources != nil {
+		resources, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+		if err != nil {
+			logging.Warn("Failed to list MCP server resources", "server", s.name, "error", err)
+		} else {
+			out.resources = resources.Resources
+		}
+	}
+	if capabilities.Prompts != nil {
+		prompts, err := c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+		if err != nil {
+			logging.Warn("Failed to list MCP server prompts", "server", s.name, "error", err)
+		} else {
+			out.prompts = prompts.Prompts
+		}
+	}
+	return out, nil
+}
+
+// handleRequest answers the requests a server sends to the client.
+func (s *server) handleRequest(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "roots/list":
+		return mcp.ListRootsResult{Roots: s.roots()}, nil
+	case "sampling/createMessage":
+		if !s.samplingEnabled() {
+			return nil, errMethodNotFound
+		}
+		var request mcp.CreateMessageRequest
+		request.Method = method
+		if err := json.Unmarshal(params, &request.Params); err != nil {
+			return nil, fmt.Errorf("invalid sampling request: %w", err)
+		}
+		return s.sampling(ctx, s.name, s.config.Sampling, request)
+	case string(mcp.MethodPing):
+		return struct{}{}, nil
+	}
+	return nil, errMethodNotFound
+}
+
+func (s *server) samplingEnabled() bool {
+	return s.sampling != nil && s.config.Sampling.IsEnabled()
+}
+
+// roots returns the project directory and the configured roots as file
+// URIs.
+func (s *server) roots() []mcp.Root {
+	dirs := append([]string{config.WorkingDirectory()}, s.config.Roots...)
+	roots := make([]mcp.Root, 0, len(dirs))
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(config.WorkingDirectory(), dir)
+		}
+		uri := url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}
+		roots = append(roots, mcp.Root{URI: uri.String(), Name: filepath.Base(dir)})
+	}
+	return roots
+}
+
+// connectTimeout bounds the handshake and listing what the server offers.
+func (s *server) connectTimeout() time.Duration {
+	if s.config.Timeout > 0 {
+		return time.Duration(s.config.Timeout) * time.Second
+	}
+	return connectTimeout
+}
+
+// requestContext applies the configured timeout to a request.
+func (s *server) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.config.Timeout > 0 {
+		return context.WithTimeout(ctx, time.Duration(s.config.Timeout)*time.Second)
+	}
+	return context.WithCancel(ctx)
+}
+
+// toolAllowed applies the allow and deny lists of the server.
+func (s *server) toolAllowed(name string) bool {
+	if len(s.config.AllowedTools) > 0 && !slices.Contains(s.config.AllowedTools, name) {
+		return false
+	}
+	return !slices.Contains(s.config.DeniedTools, name)
+}
+
+// expandEnv replaces references to environment variables in the values of
+// KEY=VALUE entries.
+func expandEnv(env []string) []string {
+	out := make([]string, len(env))
+	for i, entry := range env {
+		out[i] = os.ExpandEnv(entry)
+	}
+	return out
+}
+
+// expandHeaders replaces references to environment variables in header
+// values.
+func expandHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		out[k] = os.ExpandEnv(v)
+	}
+	return out
+}
+
+// signal wakes the run loop without blocking when a wake-up is pending.
+func (s *server) signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func ping(ctx context.Context, c client.MCPClient) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := c.Ping(ctx); err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	return nil
+}
+
+// sseClient ends the event stream of an SSE client when it is closed.
+type sseClient struct {
+	*client.SSEMCPClient
+	cancel context.CancelFunc
+}
+
+func (c *sseClient) Close() error {
+	defer c.cancel()
+	return c.SSEMCPClient.Close()
+}
+
+// closeClient closes a client without waiting forever for a stdio server
+// that ignores its closed input.
+func closeClient(name string, c client.MCPClient) {
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			logging.Debug("Failed to close MCP client", "server", name, "error", err)
+		}
+	case <-time.After(closeTimeout):
+		logging.Warn("Timed out closing MCP client", "server", name)
+	}
+}
@@ -0,0 +1,326 @@
+package mcpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain runs the test binary as a stdio MCP server when the manager
+// under test starts it.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCPCLIENT_TEST_SERVER") == "1" {
+		runTestServer()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runTestServer() {
+	s := mcpserver.NewMCPServer("test", "1.0.0", mcpserver.WithToolCapabilities(true),
+		mcpserver.WithResourceCapabilities(false, true), mcpserver.WithPromptCapabilities(true))
+	s.AddResource(mcp.NewResource("test://readme", "readme", mcp.WithMIMEType("text/markdown")), func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "text/markdown", Text: "# Readme"}}, nil
+	})
+	s.AddPrompt(mcp.NewPrompt("greet", mcp.WithArgument("name", mcp.RequiredArgument())), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("", []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Hello "+request.Params.Arguments["name"])),
+		}), nil
+	})
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("text")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		text, _ := request.Params.Arguments["text"].(string)
+		return mcp.NewToolResultText(text), nil
+	})
+	s.AddTool(mcp.NewTool("grow"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		s.AddTool(mcp.NewTool("grown"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("grown"), nil
+		})
+		return mcp.NewToolResultText("ok"), nil
+	})
+	s.AddTool(mcp.NewTool("cwd"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(dir), nil
+	})
+	s.AddTool(mcp.NewTool("exit"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		os.Exit(0)
+		return nil, nil
+	})
+	fmt.Fprintln(os.Stderr, "test server started")
+	mcpserver.NewStdioServer(s).Listen(context.Background(), os.Stdin, os.Stdout)
+}
+
+func toolNames(m *Manager) []string {
+	var names []string
+	for _, t := range m.Tools() {
+		names = append(names, t.Tool.Name)
+	}
+	return names
+}
+
+func TestManager(t *testing.T) {
+	m := NewManager(map[string]config.MCPServer{
+		"test": {
+			Type:    config.MCPStdio,
+			Command: os.Args[0],
+			Env:     append(os.Environ(), "MCPCLIENT_TEST_SERVER=1"),
+		},
+	})
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+	require.ElementsMatch(t, []string{"echo", "grow", "cwd", "exit"}, toolNames(m))
+
+	result, err := m.CallTool(ctx, "test", "echo", map[string]any{"text": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Content[0].(mcp.TextContent).Text)
+
+	_, err = m.CallTool(ctx, "missing", "echo", nil)
+	assert.Error(t, err)
+
+	t.Run("resources and prompts", func(t *testing.T) {
+		resources := m.Resources()
+		require.Len(t, resources, 1)
+		assert.Equal(t, "test", resources[0].Server)
+		assert.Equal(t, "test://readme", resources[0].Resource.URI)
+
+		read, err := m.ReadResource(ctx, "test", "test://readme")
+		require.NoError(t, err)
+		require.Len(t, read.Contents, 1)
+		assert.Equal(t, "# Readme", read.Contents[0].(mcp.TextResourceContents).Text)
+
+		prompts := m.Prompts()
+		require.Len(t, prompts, 1)
+		assert.Equal(t, "greet", prompts[0].Prompt.Name)
+
+		prompt, err := m.GetPrompt(ctx, "test", "greet", map[string]string{"name": "world"})
+		require.NoError(t, err)
+		require.Len(t, prompt.Messages, 1)
+		assert.Equal(t, "Hello world", prompt.Messages[0].Content.(mcp.TextContent).Text)
+	})
+
+	t.Run("refreshes tools on list_changed", func(t *testing.T) {
+		_, err := m.CallTool(ctx, "test", "grow", nil)
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return len(toolNames(m)) == 5
+		}, 10*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("reconnects after the server exits", func(t *testing.T) {
+		callCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, err := m.CallTool(callCtx, "test", "exit", nil)
+		require.Error(t, err)
+
+		assert.Eventually(t, func() bool {
+			callCtx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			result, err := m.CallTool(callCtx, "test", "echo", map[string]any{"text": "again"})
+			return err == nil && result.Content[0].(mcp.TextContent).Text == "again"
+		}, 25*time.Second, 100*time.Millisecond)
+		assert.Len(t, toolNames(m), 4)
+	})
+}
+
+func TestServerOptions(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	t.Setenv("MCPCLIENT_TEST_FLAG", "1")
+	disabled := false
+
+	m := NewManager(map[string]config.MCPServer{
+		"test": {
+			Type:         config.MCPStdio,
+			Command:      os.Args[0],
+			Env:          append(os.Environ(), "MCPCLIENT_TEST_SERVER=${MCPCLIENT_TEST_FLAG}"),
+			Cwd:          dir,
+			Timeout:      10,
+			AllowedTools: []string{"cwd", "echo"},
+			DeniedTools:  []string{"echo"},
+		},
+		"off": {
+			Type:    config.MCPStdio,
+			Command: "does-not-exist",
+			Enabled: &disabled,
+		},
+	})
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+	assert.Equal(t, []string{"cwd"}, toolNames(m))
+
+	result, err := m.CallTool(ctx, "test", "cwd", nil)
+	require.NoError(t, err)
+	assert.Equal(t, dir, result.Content[0].(mcp.TextContent).Text)
+
+	_, err = m.CallTool(ctx, "test", "echo", nil)
+	assert.ErrorContains(t, err, "not allowed")
+	_, err = m.CallTool(ctx, "off", "anything", nil)
+	assert.ErrorIs(t, err, ErrNotConnected)
+}
+
+func TestStatus(t *testing.T) {
+	m := NewManager(map[string]config.MCPServer{
+		"test": {
+			Type:    config.MCPStdio,
+			Command: os.Args[0],
+			Env:     append(os.Environ(), "MCPCLIENT_TEST_SERVER=1"),
+		},
+		"broken": {
+			Type:    config.MCPStdio,
+			Command: "sh",
+			Args:    []string{"-c", "echo boom >&2"},
+		},
+	})
+	events := m.Subscribe(t.Context())
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+
+	status := m.Status()
+	require.Len(t, status, 2)
+	broken, test := status[0], status[1]
+
+	assert.Equal(t, StateFailed, broken.State)
+	assert.Error(t, broken.Err)
+	assert.Contains(t, broken.Stderr, "boom")
+
+	assert.Equal(t, StateConnected, test.State)
+	assert.NotEmpty(t, test.ProtocolVersion)
+	assert.Equal(t, "test", test.ServerInfo.Name)
+	assert.Equal(t, 4, test.Tools)
+	assert.Equal(t, []string{"test server started"}, test.Stderr)
+
+	select {
+	case event := <-events:
+		assert.NotEmpty(t, event.Payload.Name)
+	default:
+		t.Fatal("no status change was published")
+	}
+
+	require.NoError(t, m.Disable("test"))
+	assert.Equal(t, StateDisabled, m.Status()[1].State)
+	_, err := m.CallTool(ctx, "test", "echo", nil)
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	require.NoError(t, m.Enable("test"))
+	assert.Eventually(t, func() bool {
+		return m.Status()[1].State == StateConnected
+	}, 25*time.Second, 50*time.Millisecond)
+	assert.Error(t, m.Enable("missing"))
+}
+
+// TestEnableDisabledInConfig connects to a server the configuration
+// disables, whose readiness was already signalled.
+func TestEnableDisabledInConfig(t *testing.T) {
+	disabled := false
+	m := NewManager(map[string]config.MCPServer{
+		"off": {
+			Type:    config.MCPStdio,
+			Command: os.Args[0],
+			Env:     append(os.Environ(), "MCPCLIENT_TEST_SERVER=1"),
+			Enabled: &disabled,
+		},
+	})
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+	assert.Equal(t, StateDisabled, m.Status()[0].State)
+
+	require.NoError(t, m.Enable("off"))
+	require.Eventually(t, func() bool {
+		return m.Status()[0].State == StateConnected
+	}, 25*time.Second, 50*time.Millisecond)
+	// The connection is served, the tools are listed again on list_changed
+	_, err := m.CallTool(ctx, "off", "grow", nil)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return m.Status()[0].Tools == 5
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestDisableWaitsForCalls(t *testing.T) {
+	s := mcpserver.NewMCPServer("test", "1.0.0", mcpserver.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("sleep"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		time.Sleep(500 * time.Millisecond)
+		return mcp.NewToolResultText("slept"), nil
+	})
+	ts := mcpserver.NewTestServer(s)
+	defer ts.Close()
+
+	m := NewManager(map[string]config.MCPServer{
+		"test": {Type: config.MCPSse, URL: ts.URL + "/sse"},
+	})
+	m.Start(context.Background())
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	m.WaitReady(ctx)
+	require.Equal(t, []string{"sleep"}, toolNames(m))
+
+	called := make(chan error, 1)
+	go func() {
+		_, err := m.CallTool(ctx, "test", "sleep", nil)
+		called <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, m.Disable("test"))
+	assert.NoError(t, <-called)
+}
+
+func TestNotificationHandlerRequests(t *testing.T) {
+	c, err := newStdioClient(os.Args[0], nil, []string{"MCPCLIENT_TEST_SERVER=1"}, "", io.Discard)
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	_, err = c.Initialize(ctx, initRequest)
+	require.NoError(t, err)
+
+	// A handler may call the server, the reader keeps delivering responses
+	pinged := make(chan error, 1)
+	c.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == "notifications/tools/list_changed" {
+			pinged <- c.Ping(ctx)
+		}
+	})
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "grow"
+	_, err = c.CallTool(ctx, request)
+	require.NoError(t, err)
+	select {
+	case err := <-pinged:
+		assert.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("the notification handler never got its response")
+	}
+}
@@ -0,0 +1,57 @@
+package mcpclient
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	// stderrLines is how many lines of the standard error of a stdio server
+	// are kept.
+	stderrLines = 20
+	// maxStderrLine is the longest line kept, longer lines are cut.
+	maxStderrLine = 1024
+)
+
+// stderrTail keeps the last lines written to it. It outlives the processes
+// of a server, so the output of a server that keeps crashing can be shown.
+type stderrTail struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, b := range p {
+		if b == '\n' {
+			t.push()
+			continue
+		}
+		if len(t.partial) < maxStderrLine {
+			t.partial = append(t.partial, b)
+		}
+	}
+	return len(p), nil
+}
+
+func (t *stderrTail) push() {
+	t.lines = append(t.lines, strings.TrimRight(string(t.partial), "\r"))
+	if len(t.lines) > stderrLines {
+		t.lines = t.lines[len(t.lines)-stderrLines:]
+	}
+	t.partial = t.partial[:0]
+}
+
+// Lines returns the kept lines, including an unterminated last line.
+func (t *stderrTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, 0, len(t.lines)+1)
+	out = append(out, t.lines...)
+	if len(t.partial) > 0 {
+		out = append(out, string(t.partial))
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxMessageSize is the largest message read from a stdio server.
+	maxMessageSize = 16 << 20
+	// killTimeout is how long a server may take to exit once its input is
+	// closed.
+	killTimeout = 3 * time.Second
+)
+
+// stdioTransport talks to a server process over its standard input and
+// output, one JSON message per line.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	handle func(*rpcMessage) *rpcResponse
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[string]chan *rpcMessage
+	err     error
+}
+
+// newStdioClient starts command in dir with env added to the environment of
+// OpenCode. The standard error of the server is written to stderr.
+func newStdioClient(command string, args, env []string, dir string, stderr io.Writer) (*rpcClient, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Dir = dir
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	c := &rpcClient{}
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		handle:  c.handleMessage,
+		pending: make(map[string]chan *rpcMessage),
+	}
+	c.transport = t
+	go t.read(stdout)
+	return c, nil
+}
+
+// read dispatches the messages of the server until its output is closed,
+// then fails the pending requests.
+func (t *stdioTransport) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var msg rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.isResponse() {
+			t.mu.Lock()
+			ch, ok := t.pending[string(*msg.ID)]
+			delete(t.pending, string(*msg.ID))
+			t.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+			continue
+		}
+		if msg.isRequest() {
+			// Answering may take long, like sampling, and must not hold
+			// up the responses.
+			go func() {
+				t.write(t.handle(&msg))
+			}()
+			continue
+		}
+		t.handle(&msg)
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = errClosed
+	}
+	t.mu.Lock()
+	t.err = err
+	for id, ch := range t.pending {
+		close(ch)
+		delete(t.pending, id)
+	}
+	t.mu.Unlock()
+}
+
+func (t *stdioTransport) write(message any) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	key := fmt.Sprint(id)
+	ch := make(chan *rpcMessage, 1)
+
+	t.mu.Lock()
+	if t.err != nil {
+		err := t.err
+		t.mu.Unlock()
+		return nil, err
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(rpcRequest{JSONRPC: mcp.JSONRPC_VERSION, ID: id, Method: method, Params: params}); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, fmt.Errorf("failed to write request: %w", err)
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return nil, t.err
+		}
+		return msg.result()
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params any) error {
+	return t.write(rpcNotification{JSONRPC: mcp.JSONRPC_VERSION, Method: method, Params: params})
+}
+
+// close closes the input of the server and kills it if it doesn't exit.
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- t.cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(killTimeout):
+		t.cmd.Process.Kill()
+		return <-exited
+	}
+}
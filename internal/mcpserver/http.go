@@ -0,0 +1,285 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/httpcheck"
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+// Endpoint is the path of the streamable HTTP endpoint.
+const Endpoint = "/mcp"
+
+const sessionHeader = "Mcp-Session-Id"
+
+// httpSession is an MCP session of the streamable HTTP transport. Tools
+// don't send notifications, so they are dropped.
+type httpSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+	// lastUsed is when the session last handled a request, in Unix
+	// nanoseconds.
+	lastUsed atomic.Int64
+}
+
+func (s *httpSession) SessionID() string { return s.id }
+
+func (s *httpSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *httpSession) Initialize() { s.initialized.Store(true) }
+
+func (s *httpSession) Initialized() bool { return s.initialized.Load() }
+
+// Handler returns the handler of the streamable HTTP transport. Every
+// response is sent as a single JSON body; the optional server-initiated
+// event stream is not offered.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+Endpoint, s.handlePost)
+	mux.HandleFunc("DELETE "+Endpoint, s.handleDelete)
+	mux.HandleFunc("GET "+Endpoint, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "server-initiated streams are not supported", http.StatusMethodNotAllowed)
+	})
+	return s.checkRequest(s.authenticate(mux))
+}
+
+// Serve accepts streamable HTTP connections on ln until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	go s.expireSessions(ctx)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// checkRequest rejects requests web pages could send: those carrying an
+// Origin header or naming a host other than the server in the Host header,
+// which a DNS rebinding attack would.
+func (s *Server) checkRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, err := httpcheck.Request(r, s.hosts); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePost handles a JSON-RPC message or batch. An initialize request
+// starts a new session; every other request must carry its ID.
+func (s *Server) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 4<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	messages, batch, err := splitBatch(body)
+	if err != nil {
+		writeRPCError(w, mcp.PARSE_ERROR, err.Error())
+		return
+	}
+
+	var session *httpSession
+	if isInitialize(messages) {
+		session = &httpSession{
+			id:            uuid.New().String(),
+			notifications: make(chan mcp.JSONRPCNotification, 16),
+		}
+		session.lastUsed.Store(time.Now().UnixNano())
+		if err := s.mcp.RegisterSession(session); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.httpSessionsMu.Lock()
+		s.httpSessions[session.id] = session
+		s.httpSessionsMu.Unlock()
+	} else {
+		id := r.Header.Get(sessionHeader)
+		if id == "" {
+			http.Error(w, "missing "+sessionHeader+" header", http.StatusBadRequest)
+			return
+		}
+		s.httpSessionsMu.Lock()
+		session = s.httpSessions[id]
+		s.httpSessionsMu.Unlock()
+		if session == nil {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+
+	session.lastUsed.Store(time.Now().UnixNano())
+	ctx := s.mcp.WithContext(r.Context(), session)
+	var responses []mcp.JSONRPCMessage
+	for _, message := range messages {
+		if response := s.mcp.HandleMessage(ctx, message); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	s.drainNotifications(session)
+
+	w.Header().Set(sessionHeader, session.id)
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	var out any = responses[0]
+	if batch {
+		out = responses
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		logging.Warn("Failed to write MCP response", "error", err)
+	}
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.closeSession(r.Header.Get(sessionHeader)) {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// closeSession forgets an HTTP session, reporting whether it existed.
+func (s *Server) closeSession(id string) bool {
+	s.httpSessionsMu.Lock()
+	_, ok := s.httpSessions[id]
+	delete(s.httpSessions, id)
+	s.httpSessionsMu.Unlock()
+	if !ok {
+		return false
+	}
+	s.mcp.UnregisterSession(id)
+	s.sessionsMu.Lock()
+	delete(s.sessions, id)
+	s.sessionsMu.Unlock()
+	return true
+}
+
+// expireSessions drops the HTTP sessions that stayed idle longer than the
+// session timeout until ctx is done. Clients that vanish without deleting
+// their session would otherwise keep it forever.
+func (s *Server) expireSessions(ctx context.Context) {
+	ticker := time.NewTicker(s.sessionTimeout / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.dropIdleSessions(now)
+		}
+	}
+}
+
+func (s *Server) dropIdleSessions(now time.Time) {
+	cutoff := now.Add(-s.sessionTimeout).UnixNano()
+	var idle []string
+	s.httpSessionsMu.Lock()
+	for id, session := range s.httpSessions {
+		if session.lastUsed.Load() < cutoff {
+			idle = append(idle, id)
+		}
+	}
+	s.httpSessionsMu.Unlock()
+	for _, id := range idle {
+		if s.closeSession(id) {
+			logging.Debug("Dropped idle MCP session", "session", id)
+		}
+	}
+}
+
+// drainNotifications drops queued notifications so the channel never fills.
+func (s *Server) drainNotifications(session *httpSession) {
+	for {
+		select {
+		case <-session.notifications:
+		default:
+			return
+		}
+	}
+}
+
+// splitBatch returns the messages of a JSON-RPC body, which is either a
+// single message or an array of them.
+func splitBatch(body []byte) ([]json.RawMessage, bool, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var messages []json.RawMessage
+		if err := json.Unmarshal(body, &messages); err != nil {
+			return nil, true, err
+		}
+		if len(messages) == 0 {
+			return nil, true, errors.New("empty batch")
+		}
+		return messages, true, nil
+	}
+	if !json.Valid(body) {
+		return nil, false, errors.New("invalid JSON")
+	}
+	return []json.RawMessage{body}, false, nil
+}
+
+func isInitialize(messages []json.RawMessage) bool {
+	for _, message := range messages {
+		var request struct {
+			Method mcp.MCPMethod `json:"method"`
+		}
+		if json.Unmarshal(message, &request) == nil && request.Method == mcp.MethodInitialize {
+			return true
+		}
+	}
+	return false
+}
+
+func writeRPCError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(mcp.NewJSONRPCError(nil, code, message, nil))
+}
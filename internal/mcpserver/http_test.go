@@ -0,0 +1,181 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamableHTTP(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(&app.App{}, Options{Tools: []string{"view", "ls"}})
+	require.NoError(t, err)
+	handler := s.Handler()
+
+	post := func(sessionID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, Endpoint, strings.NewReader(body))
+		req.Host = "127.0.0.1:4097"
+		if sessionID != "" {
+			req.Header.Set(sessionHeader, sessionID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	sessionID := rec.Header().Get(sessionHeader)
+	require.NotEmpty(t, sessionID)
+
+	rec = post("", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = post("unknown", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = post(sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = post(sessionID, `[{"jsonrpc":"2.0","id":2,"method":"tools/list"},{"jsonrpc":"2.0","id":3,"method":"ping"}]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var responses []struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &responses))
+	require.Len(t, responses, 2)
+	var names []string
+	for _, tool := range responses[0].Result.Tools {
+		names = append(names, tool.Name)
+	}
+	assert.ElementsMatch(t, []string{"view", "ls"}, names)
+
+	req := httptest.NewRequest(http.MethodDelete, Endpoint, nil)
+	req.Host = "127.0.0.1:4097"
+	req.Header.Set(sessionHeader, sessionID)
+	del := httptest.NewRecorder()
+	handler.ServeHTTP(del, req)
+	assert.Equal(t, http.StatusNoContent, del.Code)
+	rec = post(sessionID, `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCheckRequest(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(&app.App{}, Options{Tools: []string{"view"}, Token: "secret", Hosts: []string{"devbox"}})
+	require.NoError(t, err)
+	handler := s.Handler()
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		token  string
+		want   int
+	}{
+		{name: "localhost", host: "localhost:4097", token: "secret", want: http.StatusOK},
+		{name: "ipv6 loopback", host: "[::1]:4097", token: "secret", want: http.StatusOK},
+		{name: "allowed host", host: "devbox", token: "secret", want: http.StatusOK},
+		{name: "foreign host", host: "evil.example", token: "secret", want: http.StatusForbidden},
+		{name: "origin", host: "localhost", origin: "http://evil.example", token: "secret", want: http.StatusForbidden},
+		{name: "missing token", host: "localhost", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, Endpoint, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`))
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestIdleSessions(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(&app.App{}, Options{Tools: []string{"view"}, SessionTimeout: time.Minute})
+	require.NoError(t, err)
+	handler := s.Handler()
+
+	post := func(sessionID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, Endpoint, strings.NewReader(body))
+		req.Host = "localhost"
+		if sessionID != "" {
+			req.Header.Set(sessionHeader, sessionID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	sessionID := rec.Header().Get(sessionHeader)
+
+	s.dropIdleSessions(time.Now().Add(30 * time.Second))
+	assert.Equal(t, http.StatusOK, post(sessionID, `{"jsonrpc":"2.0","id":2,"method":"ping"}`).Code)
+
+	s.dropIdleSessions(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, http.StatusNotFound, post(sessionID, `{"jsonrpc":"2.0","id":3,"method":"ping"}`).Code)
+}
+
+func TestUnknownTool(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(&app.App{}, Options{Tools: []string{"fetch"}})
+	assert.ErrorContains(t, err, `unknown tool "fetch"`)
+}
+
+func TestWriteTools(t *testing.T) {
+	t.Parallel()
+
+	s, err := New(&app.App{}, Options{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"view", "grep", "glob", "ls", "diagnostics"}, publishedTools(t, s))
+
+	_, err = New(&app.App{}, Options{Tools: []string{"view", "bash"}})
+	assert.ErrorContains(t, err, `tool "bash" edits files or runs commands, publishing it must be allowed`)
+
+	s, err = New(&app.App{}, Options{AllowWrite: true})
+	require.NoError(t, err)
+	assert.Len(t, publishedTools(t, s), 9)
+}
+
+// publishedTools returns the names of the tools s lists.
+func publishedTools(t *testing.T, s *Server) []string {
+	response := s.mcp.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	data, err := json.Marshal(response)
+	require.NoError(t, err)
+	var list struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(data, &list))
+	var names []string
+	for _, tool := range list.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
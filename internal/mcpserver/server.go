@@ -0,0 +1,184 @@
+// Package mcpserver publishes the built-in tools of OpenCode over the Model
+// Context Protocol so other agents can use them.
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/version"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Tools limits the published tools to the given names, the read-only
+	// ones are published when empty.
+	Tools []string
+	// AllowWrite lets clients edit files and run commands. Permission
+	// requests are granted, so any client can, it is off unless asked for.
+	AllowWrite bool
+	// Token, when set, must be sent as a bearer token with every HTTP request.
+	Token string
+	// Hosts are the names HTTP clients may use in the Host header besides
+	// localhost and loopback addresses.
+	Hosts []string
+	// SessionTimeout is how long an HTTP session may stay idle before it is
+	// dropped, DefaultSessionTimeout when zero.
+	SessionTimeout time.Duration
+}
+
+// DefaultSessionTimeout is the idle timeout of HTTP sessions.
+const DefaultSessionTimeout = 30 * time.Minute
+
+// writeTools are the tools that change files or run commands, published
+// only with AllowWrite.
+var writeTools = []string{tools.EditToolName, tools.WriteToolName, tools.PatchToolName, tools.BashToolName}
+
+// Server publishes tools over MCP. Every MCP client session gets its own
+// OpenCode session, which records the file history of its edits.
+type Server struct {
+	app            *app.App
+	mcp            *server.MCPServer
+	token          string
+	hosts          []string
+	sessionTimeout time.Duration
+
+	sessionsMu sync.Mutex
+	sessions   map[string]string
+
+	httpSessionsMu sync.Mutex
+	httpSessions   map[string]*httpSession
+}
+
+// New creates an MCP server for the tools of the app.
+func New(a *app.App, opts Options) (*Server, error) {
+	s := &Server{
+		app:            a,
+		mcp:            server.NewMCPServer("OpenCode", version.Version, server.WithToolCapabilities(false)),
+		token:          opts.Token,
+		hosts:          opts.Hosts,
+		sessionTimeout: opts.SessionTimeout,
+		sessions:       make(map[string]string),
+		httpSessions:   make(map[string]*httpSession),
+	}
+	if s.sessionTimeout == 0 {
+		s.sessionTimeout = DefaultSessionTimeout
+	}
+
+	available := agent.MCPServerTools(a.Permissions, a.History, a.LSPClients)
+	for _, name := range opts.Tools {
+		if !slices.ContainsFunc(available, func(t tools.BaseTool) bool { return t.Info().Name == name }) {
+			return nil, fmt.Errorf("unknown tool %q, expected one of %s", name, strings.Join(toolNames(available), ", "))
+		}
+		if !opts.AllowWrite && slices.Contains(writeTools, name) {
+			return nil, fmt.Errorf("tool %q edits files or runs commands, publishing it must be allowed with --allow-write", name)
+		}
+	}
+	for _, tool := range available {
+		name := tool.Info().Name
+		if len(opts.Tools) > 0 && !slices.Contains(opts.Tools, name) {
+			continue
+		}
+		if !opts.AllowWrite && slices.Contains(writeTools, name) {
+			continue
+		}
+		s.mcp.AddTool(newMCPTool(tool.Info()), s.handler(tool))
+	}
+	return s, nil
+}
+
+// ServeStdio serves a single client over in and out until ctx is done or
+// the input is closed.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	return server.NewStdioServer(s.mcp).Listen(ctx, in, out)
+}
+
+func toolNames(available []tools.BaseTool) []string {
+	names := make([]string, len(available))
+	for i, tool := range available {
+		names[i] = tool.Info().Name
+	}
+	return names
+}
+
+// newMCPTool describes a tool with the same schema it has for the agent.
+func newMCPTool(info tools.ToolInfo) mcp.Tool {
+	return mcp.Tool{
+		Name:        info.Name,
+		Description: info.Description,
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: info.Parameters,
+			Required:   info.Required,
+		},
+	}
+}
+
+// handler runs a tool in the OpenCode session of the calling client. Tools
+// request permissions and record file history as they do for the agent.
+func (s *Server) handler(tool tools.BaseTool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sessionID, err := s.session(ctx)
+		if err != nil {
+			return nil, err
+		}
+		input, err := json.Marshal(request.Params.Arguments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode arguments: %w", err)
+		}
+
+		callID := uuid.New().String()
+		ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+		ctx = context.WithValue(ctx, tools.MessageIDContextKey, callID)
+		response, err := tool.Run(ctx, tools.ToolCall{
+			ID:    callID,
+			Name:  tool.Info().Name,
+			Input: string(input),
+		})
+		if err != nil {
+			logging.Warn("MCP tool call failed", "tool", tool.Info().Name, "error", err)
+			response = tools.NewTextErrorResponse(err.Error())
+		}
+		return newToolResult(response), nil
+	}
+}
+
+func newToolResult(response tools.ToolResponse) *mcp.CallToolResult {
+	result := mcp.NewToolResultText(response.Content)
+	result.IsError = response.IsError
+	return result
+}
+
+// session returns the OpenCode session of the MCP client calling a tool,
+// creating it on the first call.
+func (s *Server) session(ctx context.Context) (string, error) {
+	key := ""
+	if client := server.ClientSessionFromContext(ctx); client != nil {
+		key = client.SessionID()
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if id, ok := s.sessions[key]; ok {
+		return id, nil
+	}
+	sess, err := s.app.Sessions.Create(ctx, "MCP client session")
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	s.sessions[key] = sess.ID
+	return sess.ID, nil
+}
@@ -1,8 +1,72 @@
 package message
 
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxAttachmentSize is the largest file that can be attached to a message.
+const MaxAttachmentSize = int64(5 * 1024 * 1024) // 5MB
+
 type Attachment struct {
 	FilePath string
 	FileName string
 	MimeType string
 	Content  []byte
 }
+
+// IsAttachmentSupported reports whether the file at path has an extension
+// that can be sent to the providers as an attachment.
+func IsAttachmentSupported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg" || ext == ".webp" || ext == ".png"
+}
+
+// IsText reports whether the attachment is text, which is sent to the
+// model even when it doesn't accept images.
+func (a Attachment) IsText() bool {
+	return IsTextMIMEType(a.MimeType)
+}
+
+// IsTextMIMEType reports whether content of the given MIME type is text.
+func IsTextMIMEType(mimeType string) bool {
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/xml" ||
+		mediaType == "application/yaml" ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+}
+
+// LoadAttachment reads the file at path and detects its MIME type from the
+// content. Files larger than sizeLimit are rejected.
+func LoadAttachment(path string, sizeLimit int64) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("error getting file info: %w", err)
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("%s is a directory", filepath.Base(path))
+	}
+	if info.Size() > sizeLimit {
+		return Attachment{}, fmt.Errorf("file too large (%d bytes), max %d bytes", info.Size(), sizeLimit)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("error reading file: %w", err)
+	}
+
+	mimeBufferSize := min(512, len(content))
+	return Attachment{
+		FilePath: path,
+		FileName: filepath.Base(path),
+		MimeType: http.DetectContentType(content[:mimeBufferSize]),
+		Content:  content,
+	}, nil
+}
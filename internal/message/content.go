@@ -2,7 +2,9 @@ package message
 
 import (
 	"encoding/base64"
+	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/opencode-ai/opencode/internal/llm/models"
@@ -37,6 +39,20 @@ type ContentPart interface {
 
 type ReasoningContent struct {
 	Thinking string `json:"thinking"`
+	// ResponseID identifies the OpenAI Responses API answer of the message,
+	// the next request can continue from it instead of sending the history.
+	ResponseID string `json:"responseId,omitempty"`
+	// Items are the reasoning items of a Responses API answer, sent back
+	// with the tool results so the model keeps its reasoning.
+	Items []ReasoningItem `json:"items,omitempty"`
+}
+
+// ReasoningItem is a reasoning step of an OpenAI model, its content is only
+// readable by OpenAI.
+type ReasoningItem struct {
+	ID               string   `json:"id"`
+	Summary          []string `json:"summary,omitempty"`
+	EncryptedContent string   `json:"encryptedContent,omitempty"`
 }
 
 func (tc ReasoningContent) String() string {
@@ -79,6 +95,18 @@ func (bc BinaryContent) String(provider models.ModelProvider) string {
 	return base64Encoded
 }
 
+// IsText reports whether the content is text, like an attached MCP
+// resource, rather than an image.
+func (bc BinaryContent) IsText() bool {
+	return IsTextMIMEType(bc.MIMEType)
+}
+
+// Text returns text content wrapped with the path it came from, for
+// providers to send as a text block.
+func (bc BinaryContent) Text() string {
+	return fmt.Sprintf("<attachment path=%q>\n%s\n</attachment>", bc.Path, bc.Data)
+}
+
 func (BinaryContent) isPart() {}
 
 type ToolCall struct {
@@ -97,6 +125,31 @@ type ToolResult struct {
 	Content    string `json:"content"`
 	Metadata   string `json:"metadata"`
 	IsError    bool   `json:"is_error"`
+	// Attachments are the images and embedded resources a tool returned
+	// besides its text, like the results of MCP tools.
+	Attachments []BinaryContent `json:"attachments,omitempty"`
+}
+
+// Text returns the content followed by the text attachments.
+func (tr ToolResult) Text() string {
+	parts := []string{tr.Content}
+	for _, attachment := range tr.Attachments {
+		if attachment.IsText() {
+			parts = append(parts, attachment.Text())
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Images returns the attachments that are not text.
+func (tr ToolResult) Images() []BinaryContent {
+	var images []BinaryContent
+	for _, attachment := range tr.Attachments {
+		if !attachment.IsText() {
+			images = append(images, attachment)
+		}
+	}
+	return images
 }
 
 func (ToolResult) isPart() {}
@@ -108,6 +161,15 @@ type Finish struct {
 
 func (Finish) isPart() {}
 
+// Fallback records that the message is from a fallback model, because the
+// model before it failed.
+type Fallback struct {
+	From   models.ModelID `json:"from"`
+	Reason string         `json:"reason"`
+}
+
+func (Fallback) isPart() {}
+
 type Message struct {
 	ID        string
 	Role      MessageRole
@@ -208,6 +270,15 @@ func (m *Message) FinishPart() *Finish {
 	return nil
 }
 
+func (m *Message) FallbackPart() *Fallback {
+	for _, part := range m.Parts {
+		if c, ok := part.(Fallback); ok {
+			return &c
+		}
+	}
+	return nil
+}
+
 func (m *Message) FinishReason() FinishReason {
 	for _, part := range m.Parts {
 		if c, ok := part.(Finish); ok {
@@ -241,7 +312,8 @@ func (m *Message) AppendReasoningContent(delta string) {
 	found := false
 	for i, part := range m.Parts {
 		if c, ok := part.(ReasoningContent); ok {
-			m.Parts[i] = ReasoningContent{Thinking: c.Thinking + delta}
+			c.Thinking += delta
+			m.Parts[i] = c
 			found = true
 		}
 	}
@@ -250,6 +322,20 @@ func (m *Message) AppendReasoningContent(delta string) {
 	}
 }
 
+// SetReasoningItems keeps the Responses API answer ID and reasoning items of
+// the message, alongside its reasoning summary.
+func (m *Message) SetReasoningItems(responseID string, items []ReasoningItem) {
+	for i, part := range m.Parts {
+		if c, ok := part.(ReasoningContent); ok {
+			c.ResponseID = responseID
+			c.Items = items
+			m.Parts[i] = c
+			return
+		}
+	}
+	m.Parts = append(m.Parts, ReasoningContent{ResponseID: responseID, Items: items})
+}
+
 func (m *Message) FinishToolCall(toolCallID string) {
 	for i, part := range m.Parts {
 		if c, ok := part.(ToolCall); ok {
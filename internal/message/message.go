@@ -181,6 +181,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	fallbackType   partType = "fallback"
 )
 
 type partWrapper struct {
@@ -188,6 +189,16 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
+// MarshalParts encodes parts in the tagged format used to persist messages.
+func MarshalParts(parts []ContentPart) ([]byte, error) {
+	return marshallParts(parts)
+}
+
+// UnmarshalParts decodes parts previously encoded with MarshalParts.
+func UnmarshalParts(data []byte) ([]ContentPart, error) {
+	return unmarshallParts(data)
+}
+
 func marshallParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
@@ -209,6 +220,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case Fallback:
+			typ = fallbackType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -258,6 +271,7 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
 				return nil, err
 			}
+			parts = append(parts, part)
 		case binaryType:
 			part := BinaryContent{}
 			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
@@ -282,6 +296,12 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case fallbackType:
+			part := Fallback{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
@@ -1,9 +1,11 @@
 package permission
 
 import (
+	"context"
 	"errors"
 	"sync"
 
+	"github.com/google/uuid"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 )
 
@@ -28,8 +30,15 @@ type PermissionRequest struct {
 	Path        string `json:"path"`
 }
 
+// PermissionDecision records how a permission request was resolved.
+type PermissionDecision struct {
+	Request PermissionRequest `json:"request"`
+	Granted bool              `json:"granted"`
+}
+
 type Service interface {
 	pubsub.Suscriber[PermissionRequest]
+	SubscribeDecisions(ctx context.Context) <-chan pubsub.Event[PermissionDecision]
 	GrantPersistant(permission PermissionRequest)
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
@@ -40,17 +49,24 @@ type Service interface {
 type permissionService struct {
 	*pubsub.Broker[PermissionRequest]
 
+	decisions *pubsub.Broker[PermissionDecision]
+
 	sessionPermissions  []PermissionRequest
 	pendingRequests     sync.Map
 	autoApproveSessions []string
 }
 
+func (s *permissionService) SubscribeDecisions(ctx context.Context) <-chan pubsub.Event[PermissionDecision] {
+	return s.decisions.Subscribe(ctx)
+}
+
 func (s *permissionService) GrantPersistant(permission PermissionRequest) {
 	respCh, ok := s.pendingRequests.Load(permission.ID)
 	if ok {
 		respCh.(chan bool) <- true
 	}
 	s.sessionPermissions = append(s.sessionPermissions, permission)
+	s.decisions.Publish(pubsub.CreatedEvent, PermissionDecision{Request: permission, Granted: true})
 }
 
 func (s *permissionService) Grant(permission PermissionRequest) {
@@ -58,6 +74,7 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 	if ok {
 		respCh.(chan bool) <- true
 	}
+	s.decisions.Publish(pubsub.CreatedEvent, PermissionDecision{Request: permission, Granted: true})
 }
 
 func (s *permissionService) Deny(permission PermissionRequest) {
@@ -65,9 +82,25 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 	if ok {
 		respCh.(chan bool) <- false
 	}
+	s.decisions.Publish(pubsub.CreatedEvent, PermissionDecision{Request: permission, Granted: false})
 }
 
+// Request grants every request for now. No request is pending, so the
+// decision it publishes, for observers such as the stream-json output, has
+// an ID of its own that Grant and Deny don't know.
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
+	s.decisions.Publish(pubsub.CreatedEvent, PermissionDecision{
+		Request: PermissionRequest{
+			ID:          uuid.New().String(),
+			SessionID:   opts.SessionID,
+			ToolName:    opts.ToolName,
+			Description: opts.Description,
+			Action:      opts.Action,
+			Params:      opts.Params,
+			Path:        opts.Path,
+		},
+		Granted: true,
+	})
 	return true
 }
 
@@ -78,6 +111,7 @@ func (s *permissionService) AutoApproveSession(sessionID string) {
 func NewPermissionService() Service {
 	return &permissionService{
 		Broker:             pubsub.NewBroker[PermissionRequest](),
+		decisions:          pubsub.NewBroker[PermissionDecision](),
 		sessionPermissions: make([]PermissionRequest, 0),
 	}
 }
@@ -0,0 +1,251 @@
+// Package remote implements the app services on top of the HTTP API of a
+// running daemon, so a front-end can drive sessions owned by another process.
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/server"
+)
+
+// baseURL is a placeholder host, requests are always sent over the socket.
+// The daemon only accepts requests for local hosts.
+const baseURL = "http://localhost"
+
+const (
+	reconnectDelay = time.Second
+	maxEventSize   = 16 << 20
+	// requestTimeout bounds requests made on behalf of the user that aren't
+	// tied to a context, like answering a permission request.
+	requestTimeout = 10 * time.Second
+)
+
+// ErrUnsupported is returned by service methods the daemon doesn't expose:
+// writes only the agent and its tools make inside the daemon, which the TUI
+// never calls.
+var ErrUnsupported = errors.New("not supported by the daemon")
+
+// Client talks to a daemon over its Unix socket.
+type Client struct {
+	http   *http.Client
+	events *http.Client
+
+	Sessions    *SessionService
+	Messages    *MessageService
+	History     *HistoryService
+	Permissions *PermissionService
+	CoderAgent  *AgentService
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Dial connects to the daemon listening on socketPath and starts streaming
+// its events. The client stops when ctx is done or Close is called.
+func Dial(ctx context.Context, socketPath string) (*Client, error) {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	c := &Client{
+		http:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		events: &http.Client{Transport: transport},
+	}
+	c.Sessions = newSessionService(c)
+	c.Messages = newMessageService(c)
+	c.History = newHistoryService(c)
+	c.Permissions = newPermissionService(c)
+	c.CoderAgent = newAgentService(c)
+
+	if err := c.CoderAgent.fetchStatus(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	body, err := c.connectEvents(ctx)
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	c.wg.Add(2)
+	go c.streamEvents(ctx, body)
+	go c.CoderAgent.pollStatus(ctx)
+	return c, nil
+}
+
+// Close stops the event stream and shuts down the local brokers.
+func (c *Client) Close() {
+	c.cancel()
+	c.wg.Wait()
+	c.Sessions.Shutdown()
+	c.Messages.Shutdown()
+	c.History.Shutdown()
+	c.Permissions.Shutdown()
+	c.Permissions.decisions.Shutdown()
+	c.CoderAgent.Shutdown()
+}
+
+// Shutdown asks the daemon to stop.
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/shutdown", nil, nil)
+}
+
+// do sends a request to the daemon and decodes the JSON response into out
+// when it is not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr server.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		if resp.StatusCode == http.StatusConflict && apiErr.Error == agent.ErrSessionBusy.Error() {
+			return agent.ErrSessionBusy
+		}
+		return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) connectEvents(ctx context.Context) (io.ReadCloser, error) {
+	topics := strings.Join([]string{
+		server.TopicSessions,
+		server.TopicMessages,
+		server.TopicFiles,
+		server.TopicAgent,
+		server.TopicPermissions,
+	}, ",")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/events?topics="+topics, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.events.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to daemon events: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to subscribe to daemon events: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// streamEvents publishes the daemon events on the local brokers, reconnecting
+// when the connection drops.
+func (c *Client) streamEvents(ctx context.Context, body io.ReadCloser) {
+	defer c.wg.Done()
+	defer logging.RecoverPanic("remote-events", nil)
+
+	for {
+		err := c.readEvents(body)
+		body.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		logging.ErrorPersist(fmt.Sprintf("Lost connection to the daemon: %v", err))
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+			body, err = c.connectEvents(ctx)
+			if err == nil {
+				logging.InfoPersist("Reconnected to the daemon")
+				break
+			}
+		}
+	}
+}
+
+// readEvents parses server-sent events until the stream ends.
+func (c *Client) readEvents(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	var name string
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if name != "" {
+				c.dispatch(name, data)
+			}
+			name, data = "", nil
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: ")...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// dispatch decodes an event named "<topic>.<event type>" and publishes it on
+// the broker of the matching service.
+func (c *Client) dispatch(name string, data []byte) {
+	topic, eventType, ok := strings.Cut(name, ".")
+	if !ok {
+		return
+	}
+	var err error
+	switch topic {
+	case server.TopicSessions:
+		err = c.Sessions.publish(eventType, data)
+	case server.TopicMessages:
+		err = c.Messages.publish(eventType, data)
+	case server.TopicFiles:
+		err = c.History.publish(eventType, data)
+	case server.TopicAgent:
+		err = c.CoderAgent.publish(eventType, data)
+	case server.TopicPermissions:
+		err = c.Permissions.publish(eventType, data)
+	}
+	if err != nil {
+		logging.Warn("Failed to decode daemon event", "event", name, "error", err)
+	}
+}
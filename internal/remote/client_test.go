@@ -0,0 +1,153 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAgent records the attachments of the runs the daemon starts and
+// answers them right away.
+type stubAgent struct {
+	agent.Service
+	events      *pubsub.Broker[agent.AgentEvent]
+	attachments chan []message.Attachment
+}
+
+func (a *stubAgent) Subscribe(ctx context.Context) <-chan pubsub.Event[agent.AgentEvent] {
+	return a.events.Subscribe(ctx)
+}
+
+func (a *stubAgent) Model() models.Model {
+	return models.SupportedModels[models.Claude4Sonnet]
+}
+
+func (a *stubAgent) IsBusy() bool              { return false }
+func (a *stubAgent) BusySessions() []string    { return nil }
+func (a *stubAgent) IsSessionBusy(string) bool { return false }
+
+func (a *stubAgent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan agent.AgentEvent, error) {
+	a.attachments <- attachments
+	event := agent.AgentEvent{
+		Type:      agent.AgentEventTypeResponse,
+		SessionID: sessionID,
+		Message:   message.Message{SessionID: sessionID, Role: message.Assistant},
+		Done:      true,
+	}
+	done := make(chan agent.AgentEvent, 1)
+	done <- event
+	a.events.Publish(pubsub.CreatedEvent, event)
+	return done, nil
+}
+
+// newDaemon serves the API of an app on a Unix socket and connects a client
+// to it.
+func newDaemon(t *testing.T) (*app.App, *stubAgent, *Client) {
+	dir := t.TempDir()
+	cfg, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg.Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	a := app.NewWithoutAgent(ctx, conn)
+	coder := &stubAgent{
+		events:      pubsub.NewBroker[agent.AgentEvent](),
+		attachments: make(chan []message.Attachment, 1),
+	}
+	a.CoderAgent = coder
+
+	socket := filepath.Join(dir, "daemon.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(server.New(ctx, a, server.Options{}).Handler())
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	c, err := Dial(ctx, socket)
+	require.NoError(t, err)
+	t.Cleanup(c.Close)
+	return a, coder, c
+}
+
+func TestClient(t *testing.T) {
+	a, coder, c := newDaemon(t)
+	ctx := context.Background()
+
+	sess, err := c.Sessions.Create(ctx, "Remote")
+	require.NoError(t, err)
+	got, err := a.Sessions.Get(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Remote", got.Title)
+
+	t.Run("attachments are sent by content", func(t *testing.T) {
+		pasted := message.Attachment{FileName: "clipboard.png", MimeType: "image/png", Content: []byte("\x89PNG")}
+		notes := message.Attachment{FilePath: "/nowhere/notes.md", FileName: "notes.md", MimeType: "text/markdown", Content: []byte("# Notes")}
+		events, err := c.CoderAgent.Run(ctx, sess.ID, "Look", pasted, notes)
+		require.NoError(t, err)
+
+		attachments := <-coder.attachments
+		require.Len(t, attachments, 2)
+		assert.Equal(t, "clipboard.png", attachments[0].FileName)
+		assert.Equal(t, pasted.Content, attachments[0].Content)
+		assert.Equal(t, "text/markdown", attachments[1].MimeType)
+		assert.Equal(t, notes.Content, attachments[1].Content)
+
+		select {
+		case event := <-events:
+			assert.True(t, event.Done)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the run didn't finish")
+		}
+	})
+
+	t.Run("oversized attachments are rejected", func(t *testing.T) {
+		large := message.Attachment{FileName: "large.png", MimeType: "image/png", Content: make([]byte, message.MaxAttachmentSize+1)}
+		_, err := c.CoderAgent.Run(ctx, sess.ID, "Look", large)
+		assert.ErrorContains(t, err, "large.png too large")
+	})
+
+	t.Run("permission requests are answered", func(t *testing.T) {
+		requests := c.Permissions.Subscribe(ctx)
+		decisions := a.Permissions.SubscribeDecisions(ctx)
+		broker, ok := a.Permissions.(interface {
+			Publish(pubsub.EventType, permission.PermissionRequest)
+		})
+		require.True(t, ok)
+		broker.Publish(pubsub.CreatedEvent, permission.PermissionRequest{ID: "perm", SessionID: sess.ID, ToolName: "bash"})
+
+		select {
+		case event := <-requests:
+			assert.Equal(t, "bash", event.Payload.ToolName)
+			c.Permissions.Grant(event.Payload)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the permission request wasn't forwarded")
+		}
+		select {
+		case event := <-decisions:
+			assert.Equal(t, "perm", event.Payload.Request.ID)
+			assert.True(t, event.Payload.Granted)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the permission request wasn't answered")
+		}
+	})
+}
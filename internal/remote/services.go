@@ -0,0 +1,507 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/server"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// statusInterval is how often the daemon status is polled to notice runs
+// started by other clients. Agent events refresh it sooner.
+const statusInterval = time.Second
+
+// SessionService implements session.Service over the daemon API.
+type SessionService struct {
+	*pubsub.Broker[session.Session]
+	c *Client
+}
+
+var _ session.Service = (*SessionService)(nil)
+
+func newSessionService(c *Client) *SessionService {
+	return &SessionService{Broker: pubsub.NewBroker[session.Session](), c: c}
+}
+
+func (s *SessionService) publish(eventType string, data []byte) error {
+	var sess server.Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return err
+	}
+	s.Publish(pubsub.EventType(eventType), sess.ToSession())
+	return nil
+}
+
+func (s *SessionService) Create(ctx context.Context, title string) (session.Session, error) {
+	var sess server.Session
+	if err := s.c.do(ctx, http.MethodPost, "/sessions", server.CreateSessionRequest{Title: title}, &sess); err != nil {
+		return session.Session{}, err
+	}
+	return sess.ToSession(), nil
+}
+
+// CreateTitleSession and CreateTaskSession are only used by the agent,
+// which runs in the daemon.
+func (s *SessionService) CreateTitleSession(ctx context.Context, parentSessionID string) (session.Session, error) {
+	return session.Session{}, ErrUnsupported
+}
+
+func (s *SessionService) CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (session.Session, error) {
+	return session.Session{}, ErrUnsupported
+}
+
+func (s *SessionService) Get(ctx context.Context, id string) (session.Session, error) {
+	var sess server.Session
+	if err := s.c.do(ctx, http.MethodGet, "/sessions/"+url.PathEscape(id), nil, &sess); err != nil {
+		return session.Session{}, err
+	}
+	return sess.ToSession(), nil
+}
+
+func (s *SessionService) list(ctx context.Context, path string) ([]session.Session, error) {
+	var list []server.Session
+	if err := s.c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	sessions := make([]session.Session, len(list))
+	for i, sess := range list {
+		sessions[i] = sess.ToSession()
+	}
+	return sessions, nil
+}
+
+func (s *SessionService) List(ctx context.Context) ([]session.Session, error) {
+	return s.list(ctx, "/sessions")
+}
+
+func (s *SessionService) ListAll(ctx context.Context) ([]session.Session, error) {
+	return s.list(ctx, "/sessions?all=true")
+}
+
+func (s *SessionService) Sizes(ctx context.Context) (map[string]int64, error) {
+	var sizes map[string]int64
+	if err := s.c.do(ctx, http.MethodGet, "/sessions/sizes", nil, &sizes); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// Save updates the title of the session, the only field the daemon lets
+// clients change.
+func (s *SessionService) Save(ctx context.Context, sess session.Session) (session.Session, error) {
+	var saved server.Session
+	if err := s.c.do(ctx, http.MethodPatch, "/sessions/"+url.PathEscape(sess.ID), server.UpdateSessionRequest{Title: sess.Title}, &saved); err != nil {
+		return session.Session{}, err
+	}
+	return saved.ToSession(), nil
+}
+
+func (s *SessionService) Delete(ctx context.Context, id string) error {
+	return s.c.do(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(id), nil, nil)
+}
+
+// MessageService implements message.Service over the daemon API. Messages
+// are written by the agent inside the daemon, so clients can only read them
+// and delete them with DeleteFromID or with their session.
+type MessageService struct {
+	*pubsub.Broker[message.Message]
+	c *Client
+}
+
+var _ message.Service = (*MessageService)(nil)
+
+func newMessageService(c *Client) *MessageService {
+	return &MessageService{Broker: pubsub.NewBroker[message.Message](), c: c}
+}
+
+func (s *MessageService) publish(eventType string, data []byte) error {
+	var m server.Message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	msg, err := m.ToMessage()
+	if err != nil {
+		return err
+	}
+	s.Publish(pubsub.EventType(eventType), msg)
+	return nil
+}
+
+func (s *MessageService) Create(ctx context.Context, sessionID string, params message.CreateMessageParams) (message.Message, error) {
+	return message.Message{}, ErrUnsupported
+}
+
+func (s *MessageService) Update(ctx context.Context, msg message.Message) error {
+	return ErrUnsupported
+}
+
+func (s *MessageService) Get(ctx context.Context, id string) (message.Message, error) {
+	var m server.Message
+	if err := s.c.do(ctx, http.MethodGet, "/messages/"+url.PathEscape(id), nil, &m); err != nil {
+		return message.Message{}, err
+	}
+	return m.ToMessage()
+}
+
+func (s *MessageService) List(ctx context.Context, sessionID string) ([]message.Message, error) {
+	var list []server.Message
+	if err := s.c.do(ctx, http.MethodGet, "/sessions/"+url.PathEscape(sessionID)+"/messages", nil, &list); err != nil {
+		return nil, err
+	}
+	msgs := make([]message.Message, 0, len(list))
+	for _, m := range list {
+		msg, err := m.ToMessage()
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (s *MessageService) Delete(ctx context.Context, id string) error {
+	return ErrUnsupported
+}
+
+func (s *MessageService) DeleteSessionMessages(ctx context.Context, sessionID string) error {
+	return ErrUnsupported
+}
+
+func (s *MessageService) DeleteFromID(ctx context.Context, sessionID, messageID string) error {
+	path := "/sessions/" + url.PathEscape(sessionID) + "/messages?from=" + url.QueryEscape(messageID)
+	return s.c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// HistoryService implements history.Service over the daemon API. File
+// versions are recorded by the tools inside the daemon, so clients can only
+// read them; they are deleted with their session.
+type HistoryService struct {
+	*pubsub.Broker[history.File]
+	c *Client
+}
+
+var _ history.Service = (*HistoryService)(nil)
+
+func newHistoryService(c *Client) *HistoryService {
+	return &HistoryService{Broker: pubsub.NewBroker[history.File](), c: c}
+}
+
+func (s *HistoryService) publish(eventType string, data []byte) error {
+	var f server.File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	s.Publish(pubsub.EventType(eventType), f.ToFile())
+	return nil
+}
+
+func (s *HistoryService) list(ctx context.Context, sessionID string, latest bool) ([]history.File, error) {
+	path := "/sessions/" + url.PathEscape(sessionID) + "/files"
+	if latest {
+		path += "?latest=true"
+	}
+	var list []server.File
+	if err := s.c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	files := make([]history.File, len(list))
+	for i, f := range list {
+		files[i] = f.ToFile()
+	}
+	return files, nil
+}
+
+func (s *HistoryService) Create(ctx context.Context, sessionID, path, content string) (history.File, error) {
+	return history.File{}, ErrUnsupported
+}
+
+func (s *HistoryService) CreateVersion(ctx context.Context, sessionID, path, content string) (history.File, error) {
+	return history.File{}, ErrUnsupported
+}
+
+func (s *HistoryService) Get(ctx context.Context, id string) (history.File, error) {
+	var f server.File
+	if err := s.c.do(ctx, http.MethodGet, "/files/"+url.PathEscape(id), nil, &f); err != nil {
+		return history.File{}, err
+	}
+	return f.ToFile(), nil
+}
+
+func (s *HistoryService) GetByPathAndSession(ctx context.Context, path, sessionID string) (history.File, error) {
+	return history.File{}, ErrUnsupported
+}
+
+func (s *HistoryService) ListBySession(ctx context.Context, sessionID string) ([]history.File, error) {
+	return s.list(ctx, sessionID, false)
+}
+
+func (s *HistoryService) ListLatestSessionFiles(ctx context.Context, sessionID string) ([]history.File, error) {
+	return s.list(ctx, sessionID, true)
+}
+
+func (s *HistoryService) Update(ctx context.Context, file history.File) (history.File, error) {
+	return history.File{}, ErrUnsupported
+}
+
+func (s *HistoryService) Delete(ctx context.Context, id string) error {
+	return ErrUnsupported
+}
+
+func (s *HistoryService) DeleteSessionFiles(ctx context.Context, sessionID string) error {
+	return ErrUnsupported
+}
+
+// PermissionService implements permission.Service over the daemon API.
+// Requests are raised by tools inside the daemon; clients only answer them.
+type PermissionService struct {
+	*pubsub.Broker[permission.PermissionRequest]
+	decisions *pubsub.Broker[permission.PermissionDecision]
+	c         *Client
+}
+
+var _ permission.Service = (*PermissionService)(nil)
+
+func newPermissionService(c *Client) *PermissionService {
+	return &PermissionService{
+		Broker:    pubsub.NewBroker[permission.PermissionRequest](),
+		decisions: pubsub.NewBroker[permission.PermissionDecision](),
+		c:         c,
+	}
+}
+
+func (s *PermissionService) publish(eventType string, data []byte) error {
+	var req permission.PermissionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+	s.Publish(pubsub.EventType(eventType), req)
+	return nil
+}
+
+// SubscribeDecisions reports the decisions made by this client.
+func (s *PermissionService) SubscribeDecisions(ctx context.Context) <-chan pubsub.Event[permission.PermissionDecision] {
+	return s.decisions.Subscribe(ctx)
+}
+
+func (s *PermissionService) respond(req permission.PermissionRequest, action string, granted bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	err := s.c.do(ctx, http.MethodPost, "/permissions/"+url.PathEscape(req.ID), server.PermissionResponse{Action: action}, nil)
+	if err != nil {
+		logging.ErrorPersist(fmt.Sprintf("Failed to answer permission request: %v", err))
+		return
+	}
+	s.decisions.Publish(pubsub.CreatedEvent, permission.PermissionDecision{Request: req, Granted: granted})
+}
+
+func (s *PermissionService) GrantPersistant(req permission.PermissionRequest) {
+	s.respond(req, "allow_session", true)
+}
+
+func (s *PermissionService) Grant(req permission.PermissionRequest) {
+	s.respond(req, "allow", true)
+}
+
+func (s *PermissionService) Deny(req permission.PermissionRequest) {
+	s.respond(req, "deny", false)
+}
+
+// Request always fails, tools never run in the client.
+func (s *PermissionService) Request(opts permission.CreatePermissionRequest) bool {
+	return false
+}
+
+func (s *PermissionService) AutoApproveSession(sessionID string) {}
+
+// AgentService implements agent.Service over the daemon API.
+type AgentService struct {
+	*pubsub.Broker[agent.AgentEvent]
+	c *Client
+
+	mu      sync.Mutex
+	cached  server.Status
+	refresh chan struct{}
+}
+
+var _ agent.Service = (*AgentService)(nil)
+
+func newAgentService(c *Client) *AgentService {
+	return &AgentService{
+		Broker:  pubsub.NewBroker[agent.AgentEvent](),
+		c:       c,
+		refresh: make(chan struct{}, 1),
+	}
+}
+
+func (s *AgentService) publish(eventType string, data []byte) error {
+	var e server.AgentEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	event, err := e.ToAgentEvent()
+	if err != nil {
+		return err
+	}
+	s.invalidate()
+	s.Publish(pubsub.EventType(eventType), event)
+	return nil
+}
+
+// fetchStatus fetches the daemon status and caches it.
+func (s *AgentService) fetchStatus(ctx context.Context) error {
+	var status server.Status
+	if err := s.c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return err
+	}
+	s.setStatus(status)
+	return nil
+}
+
+func (s *AgentService) setStatus(status server.Status) {
+	s.mu.Lock()
+	s.cached = status
+	s.mu.Unlock()
+}
+
+// pollStatus keeps the cached status fresh so the TUI, which checks it on
+// every render, never waits on the daemon.
+func (s *AgentService) pollStatus(ctx context.Context) {
+	defer s.c.wg.Done()
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.refresh:
+		}
+		if err := s.fetchStatus(ctx); err != nil && ctx.Err() == nil {
+			logging.Debug("Failed to fetch daemon status", "error", err)
+		}
+	}
+}
+
+// invalidate asks pollStatus to refresh the status without waiting for it.
+func (s *AgentService) invalidate() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *AgentService) cachedStatus() server.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cached
+}
+
+func (s *AgentService) Model() models.Model {
+	return models.SupportedModels[models.ModelID(s.cachedStatus().Model)]
+}
+
+// Run sends the prompt to the daemon and returns a channel delivering the
+// final event of the run, which the daemon also broadcasts to every client.
+func (s *AgentService) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan agent.AgentEvent, error) {
+	// Attachments are sent by content, pasted ones have no file and the
+	// daemon may not see the same files
+	req := server.PromptRequest{Prompt: content}
+	for _, attachment := range attachments {
+		req.Files = append(req.Files, server.NewAttachment(attachment))
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	sub := s.Subscribe(subCtx)
+	err := s.c.do(ctx, http.MethodPost, "/sessions/"+url.PathEscape(sessionID)+"/messages", req, nil)
+	s.invalidate()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	events := make(chan agent.AgentEvent, 1)
+	go func() {
+		defer cancel()
+		defer close(events)
+		for {
+			select {
+			case <-subCtx.Done():
+				events <- agent.AgentEvent{Type: agent.AgentEventTypeError, SessionID: sessionID, Error: subCtx.Err()}
+				return
+			case e, ok := <-sub:
+				if !ok {
+					return
+				}
+				event := e.Payload
+				if event.SessionID != sessionID || event.Type == agent.AgentEventTypeSummarize {
+					continue
+				}
+				if event.Done || event.Type == agent.AgentEventTypeError {
+					events <- event
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (s *AgentService) Cancel(sessionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	err := s.c.do(ctx, http.MethodPost, "/sessions/"+url.PathEscape(sessionID)+"/cancel", nil, nil)
+	if err != nil {
+		logging.ErrorPersist(fmt.Sprintf("Failed to cancel the request: %v", err))
+	}
+	s.invalidate()
+}
+
+func (s *AgentService) IsSessionBusy(sessionID string) bool {
+	for _, id := range s.cachedStatus().BusySessions {
+		if id == sessionID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AgentService) IsBusy() bool {
+	return s.cachedStatus().Busy
+}
+
+func (s *AgentService) BusySessions() []string {
+	return s.cachedStatus().BusySessions
+}
+
+func (s *AgentService) Update(agentName config.AgentName, modelID models.ModelID) (models.Model, error) {
+	var status server.Status
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	err := s.c.do(ctx, http.MethodPost, "/agent/model", server.ModelRequest{Agent: string(agentName), Model: string(modelID)}, &status)
+	if err != nil {
+		return models.Model{}, err
+	}
+	s.setStatus(status)
+	return models.SupportedModels[models.ModelID(status.Model)], nil
+}
+
+// Close does nothing, the providers belong to the daemon.
+func (s *AgentService) Close() {}
+
+func (s *AgentService) Summarize(ctx context.Context, sessionID string) error {
+	return s.c.do(ctx, http.MethodPost, "/sessions/"+url.PathEscape(sessionID)+"/summarize", nil, nil)
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// Topics of the event stream, one per broker.
+const (
+	TopicSessions    = "sessions"
+	TopicMessages    = "messages"
+	TopicFiles       = "files"
+	TopicAgent       = "agent"
+	TopicPermissions = "permissions"
+	TopicLogs        = "logs"
+)
+
+// Topics lists every topic of the event stream.
+var Topics = []string{TopicSessions, TopicMessages, TopicFiles, TopicAgent, TopicPermissions, TopicLogs}
+
+const keepAliveInterval = 15 * time.Second
+
+// event is a single server-sent event. Name is "<topic>.<event type>", e.g.
+// "messages.updated".
+type event struct {
+	Name string
+	Data any
+}
+
+// parseTopics parses a comma separated list of topics. An empty list selects
+// every topic.
+func parseTopics(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return Topics, nil
+	}
+	var topics []string
+	for _, topic := range strings.Split(s, ",") {
+		topic = strings.TrimSpace(topic)
+		if !slices.Contains(Topics, topic) {
+			return nil, fmt.Errorf("unknown topic %q, expected one of %s", topic, strings.Join(Topics, ", "))
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// handleEvents streams broker events as server-sent events. The "topics"
+// query parameter selects brokers and "session" limits events to a single
+// session; session events also include its child sessions.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	topics, err := parseTopics(r.URL.Query().Get("topics"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	sessionID := r.URL.Query().Get("session")
+
+	ctx, cancel := context.WithCancel(r.Context())
+	events := make(chan event)
+	var wg sync.WaitGroup
+	s.subscribe(ctx, &wg, topics, sessionID, events)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e := <-events:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				logging.Warn("Failed to encode server event", "event", e.Name, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) subscribe(ctx context.Context, wg *sync.WaitGroup, topics []string, sessionID string, out chan<- event) {
+	inSession := func(id, parentID string) bool {
+		return sessionID == "" || id == sessionID || parentID == sessionID
+	}
+
+	for _, topic := range topics {
+		switch topic {
+		case TopicSessions:
+			forward(ctx, wg, topic, s.app.Sessions.Subscribe, out, func(sess session.Session) (any, bool) {
+				return newSession(sess), inSession(sess.ID, sess.ParentSessionID)
+			})
+		case TopicMessages:
+			forward(ctx, wg, topic, s.app.Messages.Subscribe, out, func(msg message.Message) (any, bool) {
+				if !inSession(msg.SessionID, "") {
+					return nil, false
+				}
+				m, err := newMessage(msg)
+				if err != nil {
+					logging.Warn("Failed to encode message event", "error", err)
+					return nil, false
+				}
+				return m, true
+			})
+		case TopicFiles:
+			forward(ctx, wg, topic, s.app.History.Subscribe, out, func(f history.File) (any, bool) {
+				return newFile(f), inSession(f.SessionID, "")
+			})
+		case TopicAgent:
+			forward(ctx, wg, topic, s.app.CoderAgent.Subscribe, out, func(e agent.AgentEvent) (any, bool) {
+				ae, err := newAgentEvent(e)
+				if err != nil {
+					logging.Warn("Failed to encode agent event", "error", err)
+					return nil, false
+				}
+				return ae, inSession(ae.SessionID, "")
+			})
+		case TopicPermissions:
+			forward(ctx, wg, topic, s.app.Permissions.Subscribe, out, func(req permission.PermissionRequest) (any, bool) {
+				return req, inSession(req.SessionID, "")
+			})
+		case TopicLogs:
+			forward(ctx, wg, topic, logging.Subscribe, out, func(l logging.LogMessage) (any, bool) {
+				return newLogMessage(l), true
+			})
+		}
+	}
+}
+
+// forward relays events from a broker subscription to out until ctx is
+// done. convert returns false for events that should be skipped.
+func forward[T any](
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	topic string,
+	subscribe func(context.Context) <-chan pubsub.Event[T],
+	out chan<- event,
+	convert func(T) (any, bool),
+) {
+	sub := subscribe(ctx)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer logging.RecoverPanic("server-events-"+topic, nil)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-sub:
+				if !ok {
+					return
+				}
+				data, ok := convert(e.Payload)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- event{Name: topic + "." + string(e.Type), Data: data}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
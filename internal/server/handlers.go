@@ -0,0 +1,414 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/version"
+)
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	cwd, _ := os.Getwd()
+	writeJSON(w, http.StatusOK, Status{
+		Version:      version.Version,
+		Model:        string(s.app.CoderAgent.Model().ID),
+		Cwd:          cwd,
+		Busy:         s.app.CoderAgent.IsBusy(),
+		BusySessions: s.app.CoderAgent.BusySessions(),
+	})
+}
+
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+	go s.shutdown()
+}
+
+// handleListSessions lists the top-level sessions, or with "all" set every
+// session including task and title sessions.
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	list := s.app.Sessions.List
+	if r.URL.Query().Get("all") == "true" {
+		list = s.app.Sessions.ListAll
+	}
+	sessions, err := list(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]Session, len(sessions))
+	for i, sess := range sessions {
+		out[i] = newSession(sess)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleSessionSizes(w http.ResponseWriter, r *http.Request) {
+	sizes, err := s.app.Sessions.Sizes(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sizes)
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req CreateSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Title == "" {
+		req.Title = "New Session"
+	}
+	sess, err := s.app.Sessions.Create(r.Context(), req.Title)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newSession(sess))
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.app.Sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSession(sess))
+}
+
+func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
+	var req UpdateSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("title must not be empty"))
+		return
+	}
+	sess, err := s.app.Sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	sess.Title = req.Title
+	sess, err = s.app.Sessions.Save(r.Context(), sess)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSession(sess))
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if s.app.CoderAgent.IsSessionBusy(id) {
+		writeError(w, http.StatusConflict, agent.ErrSessionBusy)
+		return
+	}
+	all, err := s.app.Sessions.ListAll(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = app.DeleteSessionTree(r.Context(), s.app.Sessions, s.app.Messages, s.app.History, app.ChildSessions(all), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	msgs, err := s.app.Messages.List(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m, err := newMessage(msg)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		out = append(out, m)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGetMessage(w http.ResponseWriter, r *http.Request) {
+	msg, err := s.app.Messages.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	m, err := newMessage(msg)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handlePrompt starts an agent run. The run continues in the background and
+// its progress is published on the event stream; with "wait" set the request
+// blocks until the final response.
+func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req PromptRequest
+	if err := decodeJSONLimit(r, &req, maxPromptSize); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt must not be empty"))
+		return
+	}
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	attachments, err := s.app.LoadAttachments(req.Attachments)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	for _, file := range req.Files {
+		attachment := file.ToAttachment()
+		if err := s.checkAttachment(attachment); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	done, err := s.app.CoderAgent.Run(s.ctx, id, req.Prompt, attachments...)
+	if err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !req.Wait {
+		go func() {
+			defer logging.RecoverPanic("server-run", nil)
+			<-done
+		}()
+		writeJSON(w, http.StatusAccepted, PromptResponse{SessionID: id})
+		return
+	}
+
+	select {
+	case result := <-done:
+		if result.Error != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("agent processing failed: %w", result.Error))
+			return
+		}
+		msg, err := newMessage(result.Message)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, PromptResponse{SessionID: id, Message: &msg})
+	case <-r.Context().Done():
+		// The client went away; let the run finish on its own.
+		go func() {
+			defer logging.RecoverPanic("server-run", nil)
+			<-done
+		}()
+	}
+}
+
+// checkAttachment applies the limits LoadAttachments applies to files to an
+// attachment sent by content. Text is sent to every model.
+func (s *Server) checkAttachment(a message.Attachment) error {
+	if int64(len(a.Content)) > message.MaxAttachmentSize {
+		return fmt.Errorf("attachment %s too large (%d bytes), max %d bytes", a.FileName, len(a.Content), message.MaxAttachmentSize)
+	}
+	if a.IsText() {
+		return nil
+	}
+	if model := s.app.CoderAgent.Model(); !model.SupportsAttachments {
+		return fmt.Errorf("model %s doesn't support attachments", model.Name)
+	}
+	return nil
+}
+
+// handleDeleteMessages deletes the message given by the "from" query
+// parameter and every later message of the session.
+func (s *Server) handleDeleteMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		writeError(w, http.StatusBadRequest, errors.New("the from query parameter is required"))
+		return
+	}
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if s.app.CoderAgent.IsSessionBusy(id) {
+		writeError(w, http.StatusConflict, agent.ErrSessionBusy)
+		return
+	}
+	if err := s.app.Messages.DeleteFromID(r.Context(), id, from); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSummarize(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if err := s.app.CoderAgent.Summarize(s.ctx, id); err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleUpdateModel(w http.ResponseWriter, r *http.Request) {
+	var req ModelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Agent == "" {
+		req.Agent = string(config.AgentCoder)
+	}
+	if config.AgentName(req.Agent) != config.AgentCoder {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("only the %s agent can be changed", config.AgentCoder))
+		return
+	}
+	if _, ok := models.SupportedModels[models.ModelID(req.Model)]; !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown model %q", req.Model))
+		return
+	}
+	if _, err := s.app.CoderAgent.Update(config.AgentCoder, models.ModelID(req.Model)); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	s.handleStatus(w, r)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	s.app.CoderAgent.Cancel(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	list := s.app.History.ListBySession
+	if r.URL.Query().Get("latest") == "true" {
+		list = s.app.History.ListLatestSessionFiles
+	}
+	files, err := list(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]File, len(files))
+	for i, f := range files {
+		out[i] = newFile(f)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	f, err := s.app.History.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newFile(f))
+}
+
+func (s *Server) handleListPermissions(w http.ResponseWriter, r *http.Request) {
+	s.pendingMu.Lock()
+	out := make([]permission.PermissionRequest, 0, len(s.pending))
+	for _, req := range s.pending {
+		out = append(out, req)
+	}
+	s.pendingMu.Unlock()
+	slices.SortFunc(out, func(a, b permission.PermissionRequest) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleRespondPermission answers a pending permission request with one of
+// "allow", "allow_session" or "deny", matching the TUI permission dialog.
+func (s *Server) handleRespondPermission(w http.ResponseWriter, r *http.Request) {
+	var req PermissionResponse
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var respond func(permission.PermissionRequest)
+	switch req.Action {
+	case "allow":
+		respond = s.app.Permissions.Grant
+	case "allow_session":
+		respond = s.app.Permissions.GrantPersistant
+	case "deny":
+		respond = s.app.Permissions.Deny
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown action %q, expected allow, allow_session or deny", req.Action))
+		return
+	}
+
+	s.pendingMu.Lock()
+	pending, ok := s.pending[r.PathValue("id")]
+	delete(s.pending, pending.ID)
+	s.pendingMu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no pending permission request with this ID"))
+		return
+	}
+
+	respond(pending)
+	w.WriteHeader(http.StatusNoContent)
+}
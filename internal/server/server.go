@@ -0,0 +1,235 @@
+// Package server exposes a running App over a local HTTP API so editor
+// plugins and other front-ends can drive the agent without the TUI.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/httpcheck"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/permission"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Token, when set, must be sent as a bearer token with every request.
+	Token string
+	// Hosts are the names accepted in the Host header besides localhost and
+	// loopback addresses, so other sites can't reach the server through DNS
+	// rebinding.
+	Hosts []string
+	// Shutdown, when set, is called by POST /shutdown to stop the process.
+	Shutdown func()
+}
+
+// Server serves the HTTP API of an App.
+type Server struct {
+	app      *app.App
+	token    string
+	hosts    []string
+	shutdown func()
+	mux      *http.ServeMux
+
+	// ctx outlives individual requests so agent runs started over the API
+	// keep going after the request that started them returns.
+	ctx context.Context
+
+	pendingMu sync.Mutex
+	pending   map[string]permission.PermissionRequest
+}
+
+// New creates a server for the app. Agent runs and subscriptions are tied
+// to ctx.
+func New(ctx context.Context, a *app.App, opts Options) *Server {
+	s := &Server{
+		app:      a,
+		token:    opts.Token,
+		hosts:    opts.Hosts,
+		shutdown: opts.Shutdown,
+		mux:      http.NewServeMux(),
+		ctx:      ctx,
+		pending:  make(map[string]permission.PermissionRequest),
+	}
+	s.routes()
+	go s.trackPermissions()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	if s.shutdown != nil {
+		s.mux.HandleFunc("POST /shutdown", s.handleShutdown)
+	}
+
+	s.mux.HandleFunc("GET /sessions", s.handleListSessions)
+	s.mux.HandleFunc("GET /sessions/sizes", s.handleSessionSizes)
+	s.mux.HandleFunc("POST /sessions", s.handleCreateSession)
+	s.mux.HandleFunc("GET /sessions/{id}", s.handleGetSession)
+	s.mux.HandleFunc("PATCH /sessions/{id}", s.handleUpdateSession)
+	s.mux.HandleFunc("DELETE /sessions/{id}", s.handleDeleteSession)
+	s.mux.HandleFunc("GET /sessions/{id}/messages", s.handleListMessages)
+	s.mux.HandleFunc("POST /sessions/{id}/messages", s.handlePrompt)
+	s.mux.HandleFunc("DELETE /sessions/{id}/messages", s.handleDeleteMessages)
+	s.mux.HandleFunc("POST /sessions/{id}/cancel", s.handleCancel)
+	s.mux.HandleFunc("POST /sessions/{id}/summarize", s.handleSummarize)
+	s.mux.HandleFunc("GET /sessions/{id}/files", s.handleListFiles)
+	s.mux.HandleFunc("GET /messages/{id}", s.handleGetMessage)
+	s.mux.HandleFunc("GET /files/{id}", s.handleGetFile)
+
+	s.mux.HandleFunc("POST /agent/model", s.handleUpdateModel)
+
+	s.mux.HandleFunc("GET /permissions", s.handleListPermissions)
+	s.mux.HandleFunc("POST /permissions/{id}", s.handleRespondPermission)
+
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+}
+
+// Handler returns the HTTP handler of the API.
+func (s *Server) Handler() http.Handler {
+	return s.checkRequest(s.authenticate(s.mux))
+}
+
+// Serve accepts connections on ln until ctx is cancelled, then shuts the
+// server down gracefully.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logging.Warn("Failed to shut down server gracefully", "error", err)
+			return srv.Close()
+		}
+		return nil
+	}
+}
+
+// checkRequest rejects requests web pages can make: requests from a
+// browser, which sends an Origin, requests for a host other than the
+// server, which DNS rebinding makes, and bodies other than JSON, which
+// simple requests can send without a preflight.
+func (s *Server) checkRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, err := httpcheck.Request(r, s.hosts); err != nil {
+			writeError(w, status, err)
+			return
+		}
+		if r.ContentLength != 0 && r.Method != http.MethodGet {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errors.New("the body must be application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate rejects requests without the configured token. The event
+// stream also takes it as a query parameter because EventSource clients
+// can't set headers.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" && r.URL.Path == "/events" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trackPermissions keeps the list of permission requests that are waiting
+// for a response.
+func (s *Server) trackPermissions() {
+	defer logging.RecoverPanic("server-permissions", nil)
+
+	requests := s.app.Permissions.Subscribe(s.ctx)
+	decisions := s.app.Permissions.SubscribeDecisions(s.ctx)
+	for {
+		select {
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			s.pendingMu.Lock()
+			s.pending[event.Payload.ID] = event.Payload
+			s.pendingMu.Unlock()
+		case event, ok := <-decisions:
+			if !ok {
+				return
+			}
+			s.pendingMu.Lock()
+			delete(s.pending, event.Payload.Request.ID)
+			s.pendingMu.Unlock()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Warn("Failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// writeLookupError reports a failed lookup, mapping missing rows to 404.
+func writeLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+const (
+	maxBodySize = 1 << 20
+	// maxPromptSize is larger as prompts carry the content of their
+	// attachments, base64 encoded.
+	maxPromptSize = 64 << 20
+)
+
+func decodeJSON(r *http.Request, v any) error {
+	return decodeJSONLimit(r, v, maxBodySize)
+}
+
+func decodeJSONLimit(r *http.Request, v any, limit int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, limit))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{token: "secret"}
+	handler := s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{name: "missing token", target: "/status", want: http.StatusUnauthorized},
+		{name: "wrong token", target: "/status", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "bearer token", target: "/status", header: "Bearer secret", want: http.StatusNoContent},
+		{name: "query token", target: "/events?token=secret", want: http.StatusNoContent},
+		{name: "query token outside events", target: "/status?token=secret", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{hosts: []string{"devbox"}}
+	handler := s.checkRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{name: "localhost", method: http.MethodGet, host: "localhost:4096", want: http.StatusNoContent},
+		{name: "loopback", method: http.MethodGet, host: "127.0.0.1", want: http.StatusNoContent},
+		{name: "ipv6 loopback", method: http.MethodGet, host: "[::1]:4096", want: http.StatusNoContent},
+		{name: "allowed host", method: http.MethodGet, host: "devbox:4096", want: http.StatusNoContent},
+		{name: "foreign host", method: http.MethodGet, host: "evil.example", want: http.StatusForbidden},
+		{name: "origin", method: http.MethodGet, host: "localhost", origin: "http://localhost:3000", want: http.StatusForbidden},
+		{name: "json body", method: http.MethodPost, host: "localhost", contentType: "application/json; charset=utf-8", body: "{}", want: http.StatusNoContent},
+		{name: "text body", method: http.MethodPost, host: "localhost", contentType: "text/plain", body: "{}", want: http.StatusUnsupportedMediaType},
+		{name: "untyped body", method: http.MethodPost, host: "localhost", body: "{}", want: http.StatusUnsupportedMediaType},
+		{name: "no body", method: http.MethodPost, host: "localhost", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/sessions", strings.NewReader(tt.body))
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestParseTopics(t *testing.T) {
+	t.Parallel()
+
+	topics, err := parseTopics("")
+	require.NoError(t, err)
+	assert.Equal(t, Topics, topics)
+
+	topics, err = parseTopics("messages, agent")
+	require.NoError(t, err)
+	assert.Equal(t, []string{TopicMessages, TopicAgent}, topics)
+
+	_, err = parseTopics("messages,unknown")
+	assert.Error(t, err)
+}
+
+func TestAgentEventRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	original := agent.AgentEvent{
+		Type: agent.AgentEventTypeResponse,
+		Message: message.Message{
+			ID:        "msg",
+			SessionID: "session",
+			Role:      message.Assistant,
+			Parts:     []message.ContentPart{message.TextContent{Text: "hello"}},
+		},
+		Done: true,
+	}
+	encoded, err := newAgentEvent(original)
+	require.NoError(t, err)
+	decoded, err := encoded.ToAgentEvent()
+	require.NoError(t, err)
+	assert.Equal(t, "session", decoded.SessionID)
+	assert.Equal(t, original.Message.Parts, decoded.Message.Parts)
+	assert.True(t, decoded.Done)
+
+	encoded, err = newAgentEvent(agent.AgentEvent{Type: agent.AgentEventTypeError, SessionID: "session", Error: agent.ErrRequestCancelled})
+	require.NoError(t, err)
+	decoded, err = encoded.ToAgentEvent()
+	require.NoError(t, err)
+	assert.ErrorIs(t, decoded.Error, agent.ErrRequestCancelled)
+
+	encoded, err = newAgentEvent(agent.AgentEvent{
+		Type:      agent.AgentEventTypeFallback,
+		SessionID: "session",
+		Fallback: &provider.Fallback{
+			From:   models.SupportedModels[models.Claude4Sonnet],
+			To:     models.SupportedModels[models.GPT41],
+			Reason: config.FallbackOnOverloaded,
+		},
+	})
+	require.NoError(t, err)
+	decoded, err = encoded.ToAgentEvent()
+	require.NoError(t, err)
+	assert.Equal(t, models.GPT41, decoded.Fallback.To.ID)
+	assert.Equal(t, config.FallbackOnOverloaded, decoded.Fallback.Reason)
+
+	encoded, err = newAgentEvent(agent.AgentEvent{
+		Type:      agent.AgentEventTypeRetry,
+		SessionID: "session",
+		Retry: &provider.Retry{
+			Attempt:    3,
+			MaxRetries: 8,
+			Delay:      8 * time.Second,
+			Reason:     config.FallbackOnRateLimit,
+			Err:        errors.New("429 Too Many Requests"),
+		},
+	})
+	require.NoError(t, err)
+	decoded, err = encoded.ToAgentEvent()
+	require.NoError(t, err)
+	assert.Equal(t, "Rate limited, retrying in 8s (3/8)", decoded.Retry.String())
+}
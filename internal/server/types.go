@@ -0,0 +1,362 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// Session is the JSON representation of a session.
+type Session struct {
+	ID               string  `json:"id"`
+	ParentSessionID  string  `json:"parent_session_id,omitempty"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+}
+
+// Message is the JSON representation of a message. Parts use the same
+// tagged encoding as the database and session exports.
+type Message struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	Role      string          `json:"role"`
+	Model     string          `json:"model,omitempty"`
+	Hidden    bool            `json:"hidden,omitempty"`
+	Parts     json.RawMessage `json:"parts"`
+	Finished  bool            `json:"finished"`
+	CreatedAt int64           `json:"created_at"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// File is the JSON representation of a file history entry.
+type File struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Version   string `json:"version"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// AgentEvent is the JSON representation of an agent event.
+type AgentEvent struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"session_id,omitempty"`
+	Message   *Message  `json:"message,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Progress  string    `json:"progress,omitempty"`
+	Done      bool      `json:"done"`
+	Fallback  *Fallback `json:"fallback,omitempty"`
+	Retry     *Retry    `json:"retry,omitempty"`
+}
+
+// Fallback is the JSON representation of a switch to another model of a
+// fallback chain.
+type Fallback struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Retry is the JSON representation of a failed request about to be sent
+// again.
+type Retry struct {
+	Attempt    int    `json:"attempt"`
+	MaxRetries int    `json:"max_retries"`
+	DelayMs    int64  `json:"delay_ms"`
+	Reason     string `json:"reason,omitempty"`
+	Error      string `json:"error"`
+}
+
+// LogMessage is the JSON representation of a log entry.
+type LogMessage struct {
+	ID         string            `json:"id"`
+	Time       time.Time         `json:"time"`
+	Level      string            `json:"level"`
+	Message    string            `json:"message"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Status describes the running server.
+type Status struct {
+	Version      string   `json:"version"`
+	Model        string   `json:"model"`
+	Cwd          string   `json:"cwd"`
+	Busy         bool     `json:"busy"`
+	BusySessions []string `json:"busy_sessions"`
+}
+
+// CreateSessionRequest is the body of POST /sessions.
+type CreateSessionRequest struct {
+	Title string `json:"title"`
+}
+
+// UpdateSessionRequest is the body of PATCH /sessions/{id}.
+type UpdateSessionRequest struct {
+	Title string `json:"title"`
+}
+
+// PromptRequest is the body of POST /sessions/{id}/messages.
+type PromptRequest struct {
+	Prompt string `json:"prompt"`
+	// Attachments are paths of files the daemon reads itself.
+	Attachments []string `json:"attachments,omitempty"`
+	// Files are attachments sent with their content, like pasted images
+	// that don't exist on disk.
+	Files []Attachment `json:"files,omitempty"`
+	// Wait makes the request block until the agent has responded.
+	Wait bool `json:"wait,omitempty"`
+}
+
+// Attachment is the JSON representation of an attachment sent by content.
+// Content is base64 encoded.
+type Attachment struct {
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	Content  []byte `json:"content"`
+}
+
+// PromptResponse is returned by POST /sessions/{id}/messages. Message is
+// only set when the request waited for the response.
+type PromptResponse struct {
+	SessionID string   `json:"session_id"`
+	Message   *Message `json:"message,omitempty"`
+}
+
+// PermissionResponse is the body of POST /permissions/{id}.
+type PermissionResponse struct {
+	Action string `json:"action"`
+}
+
+// ModelRequest is the body of POST /agent/model.
+type ModelRequest struct {
+	Agent string `json:"agent"`
+	Model string `json:"model"`
+}
+
+// ErrorResponse is returned by failed requests.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func newSession(s session.Session) Session {
+	return Session{
+		ID:               s.ID,
+		ParentSessionID:  s.ParentSessionID,
+		Title:            s.Title,
+		MessageCount:     s.MessageCount,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		Cost:             s.Cost,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
+
+func newMessage(m message.Message) (Message, error) {
+	parts, err := message.MarshalParts(m.Parts)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		ID:        m.ID,
+		SessionID: m.SessionID,
+		Role:      string(m.Role),
+		Model:     string(m.Model),
+		Hidden:    m.Hidden,
+		Parts:     parts,
+		Finished:  m.IsFinished(),
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
+
+func newFile(f history.File) File {
+	return File{
+		ID:        f.ID,
+		SessionID: f.SessionID,
+		Path:      f.Path,
+		Content:   f.Content,
+		Version:   f.Version,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+// NewAttachment converts an attachment to the JSON representation clients
+// send it in.
+func NewAttachment(a message.Attachment) Attachment {
+	return Attachment{
+		FileName: a.FileName,
+		MimeType: a.MimeType,
+		Content:  a.Content,
+	}
+}
+
+func newAgentEvent(e agent.AgentEvent) (AgentEvent, error) {
+	event := AgentEvent{
+		Type:      string(e.Type),
+		SessionID: e.SessionID,
+		Progress:  e.Progress,
+		Done:      e.Done,
+	}
+	if e.Error != nil {
+		event.Error = e.Error.Error()
+	}
+	if e.Fallback != nil {
+		event.Fallback = &Fallback{
+			From:   string(e.Fallback.From.ID),
+			To:     string(e.Fallback.To.ID),
+			Reason: string(e.Fallback.Reason),
+		}
+	}
+	if e.Retry != nil {
+		event.Retry = &Retry{
+			Attempt:    e.Retry.Attempt,
+			MaxRetries: e.Retry.MaxRetries,
+			DelayMs:    e.Retry.Delay.Milliseconds(),
+			Reason:     string(e.Retry.Reason),
+			Error:      e.Retry.Err.Error(),
+		}
+	}
+	if e.Message.ID != "" {
+		msg, err := newMessage(e.Message)
+		if err != nil {
+			return AgentEvent{}, err
+		}
+		event.Message = &msg
+		if event.SessionID == "" {
+			event.SessionID = msg.SessionID
+		}
+	}
+	return event, nil
+}
+
+func newLogMessage(l logging.LogMessage) LogMessage {
+	msg := LogMessage{
+		ID:      l.ID,
+		Time:    l.Time,
+		Level:   l.Level,
+		Message: l.Message,
+	}
+	if len(l.Attributes) > 0 {
+		msg.Attributes = make(map[string]string, len(l.Attributes))
+		for _, attr := range l.Attributes {
+			msg.Attributes[attr.Key] = attr.Value
+		}
+	}
+	return msg
+}
+
+// ToSession converts the JSON representation back to a session.
+func (s Session) ToSession() session.Session {
+	return session.Session{
+		ID:               s.ID,
+		ParentSessionID:  s.ParentSessionID,
+		Title:            s.Title,
+		MessageCount:     s.MessageCount,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		Cost:             s.Cost,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
+
+// ToMessage converts the JSON representation back to a message.
+func (m Message) ToMessage() (message.Message, error) {
+	parts, err := message.UnmarshalParts(m.Parts)
+	if err != nil {
+		return message.Message{}, err
+	}
+	return message.Message{
+		ID:        m.ID,
+		SessionID: m.SessionID,
+		Role:      message.MessageRole(m.Role),
+		Model:     models.ModelID(m.Model),
+		Hidden:    m.Hidden,
+		Parts:     parts,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
+
+// ToFile converts the JSON representation back to a file history entry.
+func (f File) ToFile() history.File {
+	return history.File{
+		ID:        f.ID,
+		SessionID: f.SessionID,
+		Path:      f.Path,
+		Content:   f.Content,
+		Version:   f.Version,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+// ToAttachment converts the JSON representation back to an attachment.
+func (a Attachment) ToAttachment() message.Attachment {
+	return message.Attachment{
+		FileName: a.FileName,
+		MimeType: a.MimeType,
+		Content:  a.Content,
+	}
+}
+
+// ToAgentEvent converts the JSON representation back to an agent event.
+// Errors the agent defines are restored so callers can match them.
+func (e AgentEvent) ToAgentEvent() (agent.AgentEvent, error) {
+	event := agent.AgentEvent{
+		Type:      agent.AgentEventType(e.Type),
+		SessionID: e.SessionID,
+		Progress:  e.Progress,
+		Done:      e.Done,
+	}
+	if e.Error != "" {
+		event.Error = errors.New(e.Error)
+		for _, known := range []error{agent.ErrRequestCancelled, agent.ErrSessionBusy} {
+			if e.Error == known.Error() {
+				event.Error = known
+			}
+		}
+	}
+	if e.Fallback != nil {
+		event.Fallback = &provider.Fallback{
+			From:   models.SupportedModels[models.ModelID(e.Fallback.From)],
+			To:     models.SupportedModels[models.ModelID(e.Fallback.To)],
+			Reason: config.FallbackCondition(e.Fallback.Reason),
+		}
+	}
+	if e.Retry != nil {
+		event.Retry = &provider.Retry{
+			Attempt:    e.Retry.Attempt,
+			MaxRetries: e.Retry.MaxRetries,
+			Delay:      time.Duration(e.Retry.DelayMs) * time.Millisecond,
+			Reason:     config.FallbackCondition(e.Retry.Reason),
+			Err:        errors.New(e.Retry.Error),
+		}
+	}
+	if e.Message != nil {
+		msg, err := e.Message.ToMessage()
+		if err != nil {
+			return agent.AgentEvent{}, err
+		}
+		event.Message = msg
+	}
+	return event, nil
+}
@@ -29,6 +29,8 @@ type Service interface {
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
+	ListAll(ctx context.Context) ([]Session, error)
+	Sizes(ctx context.Context) (map[string]int64, error)
 	Save(ctx context.Context, session Session) (Session, error)
 	Delete(ctx context.Context, id string) error
 }
@@ -132,6 +134,34 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 	return sessions, nil
 }
 
+// ListAll returns every session, including task and title sessions that
+// belong to a parent session.
+func (s *service) ListAll(ctx context.Context) ([]Session, error) {
+	dbSessions, err := s.q.ListAllSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, len(dbSessions))
+	for i, dbSession := range dbSessions {
+		sessions[i] = s.fromDBItem(dbSession)
+	}
+	return sessions, nil
+}
+
+// Sizes returns the number of bytes stored for each session's messages and
+// file history, keyed by session ID.
+func (s *service) Sizes(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.q.ListSessionSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		sizes[row.ID] = row.Size
+	}
+	return sizes, nil
+}
+
 func (s service) fromDBItem(item db.Session) Session {
 	return Session{
 		ID:               item.ID,
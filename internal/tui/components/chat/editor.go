@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"slices"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -13,6 +14,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/llm/tokenizer"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/session"
@@ -31,6 +33,25 @@ type editorCmp struct {
 	textarea    textarea.Model
 	attachments []message.Attachment
 	deleteMode  bool
+	// draft is the text being counted, draftTokens the estimated tokens of
+	// the text last counted
+	draft       string
+	draftTokens int
+}
+
+// draftCountDelay is how long the draft is left unchanged before its tokens
+// are counted.
+const draftCountDelay = 200 * time.Millisecond
+
+// countDraftMsg counts the tokens of the draft if it is still the same.
+type countDraftMsg struct {
+	draft string
+}
+
+// draftCountedMsg carries the estimated tokens of a draft.
+type draftCountedMsg struct {
+	draft  string
+	tokens int
 }
 
 type EditorKeyMaps struct {
@@ -139,8 +160,28 @@ func (m *editorCmp) send() tea.Cmd {
 }
 
 func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	countCmd := m.countDraft()
+	m.updateHeight()
+	return model, tea.Batch(cmd, countCmd)
+}
+
+func (m *editorCmp) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
+	case countDraftMsg:
+		if msg.draft != m.draft {
+			return m, nil
+		}
+		model := m.app.CoderAgent.Model()
+		return m, func() tea.Msg {
+			return draftCountedMsg{draft: msg.draft, tokens: tokenizer.For(model).Count(msg.draft)}
+		}
+	case draftCountedMsg:
+		if msg.draft == m.draft {
+			m.draftTokens = msg.tokens
+		}
+		return m, nil
 	case SetEditorContentMsg:
 		m.textarea.SetValue(msg.Content)
 		m.textarea.SetCursor(len(msg.Content))
@@ -217,6 +258,33 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// countDraft schedules counting the tokens of the draft once it has been
+// left unchanged for a moment, counting long drafts on every key stroke
+// would slow down typing.
+func (m *editorCmp) countDraft() tea.Cmd {
+	value := m.textarea.Value()
+	if value == m.draft {
+		return nil
+	}
+	m.draft = value
+	if strings.TrimSpace(value) == "" {
+		m.draftTokens = 0
+		return nil
+	}
+	return tea.Tick(draftCountDelay, func(time.Time) tea.Msg {
+		return countDraftMsg{draft: value}
+	})
+}
+
+// updateHeight leaves a line to the header when it has something to show.
+func (m *editorCmp) updateHeight() {
+	if len(m.attachments) == 0 && m.draftTokens == 0 {
+		m.textarea.SetHeight(m.height)
+	} else {
+		m.textarea.SetHeight(m.height - 1)
+	}
+}
+
 func (m *editorCmp) View() string {
 	t := theme.CurrentTheme()
 
@@ -226,22 +294,41 @@ func (m *editorCmp) View() string {
 		Bold(true).
 		Foreground(t.Primary())
 
-	if len(m.attachments) == 0 {
+	if len(m.attachments) == 0 && m.draftTokens == 0 {
 		return lipgloss.JoinHorizontal(lipgloss.Top, style.Render(">"), m.textarea.View())
 	}
-	m.textarea.SetHeight(m.height - 1)
 	return lipgloss.JoinVertical(lipgloss.Top,
-		m.attachmentsContent(),
+		m.headerContent(),
 		lipgloss.JoinHorizontal(lipgloss.Top, style.Render(">"),
 			m.textarea.View()),
 	)
 }
 
+// headerContent shows the attachments, and the estimated tokens of the
+// draft on the right, in warning colors when it would about fill the
+// context window.
+func (m *editorCmp) headerContent() string {
+	attachments := m.attachmentsContent()
+	if m.draftTokens == 0 {
+		return attachments
+	}
+	t := theme.CurrentTheme()
+	tokensStyle := styles.BaseStyle().Foreground(t.TextMuted()).PaddingRight(1)
+	contextWindow := m.app.CoderAgent.Model().ContextWindow
+	used := m.session.PromptTokens + m.session.CompletionTokens + int64(m.draftTokens)
+	if contextWindow > 0 && float64(used) >= 0.95*float64(contextWindow) {
+		tokensStyle = tokensStyle.Foreground(t.Warning())
+	}
+	tokens := tokensStyle.Render(fmt.Sprintf("~%d tokens", m.draftTokens))
+	gap := max(m.width-lipgloss.Width(attachments)-lipgloss.Width(tokens), 1)
+	return attachments + styles.BaseStyle().Width(gap).Render("") + tokens
+}
+
 func (m *editorCmp) SetSize(width, height int) tea.Cmd {
 	m.width = width
 	m.height = height
 	m.textarea.SetWidth(width - 3) // account for the prompt and padding right
-	m.textarea.SetHeight(height)
+	m.updateHeight()
 	m.textarea.SetWidth(width)
 	return nil
 }
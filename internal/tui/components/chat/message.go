@@ -15,6 +15,7 @@ import (
 	"github.com/opencode-ai/opencode/internal/llm/models"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
 	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/tui/image"
 	"github.com/opencode-ai/opencode/internal/tui/styles"
 	"github.com/opencode-ai/opencode/internal/tui/theme"
 )
@@ -27,6 +28,9 @@ const (
 	toolMessageType
 
 	maxResultHeight = 10
+	// maxImagePreviewWidth keeps images returned by tools from filling the
+	// chat.
+	maxImagePreviewWidth = 60
 )
 
 type uiMessage struct {
@@ -138,6 +142,11 @@ func renderAssistantMessage(
 	t := theme.CurrentTheme()
 	baseStyle := styles.BaseStyle()
 
+	modelName := models.SupportedModels[msg.Model].Name
+	if fallback := msg.FallbackPart(); fallback != nil {
+		modelName += ", fallback from " + models.SupportedModels[fallback.From].Name
+	}
+
 	// Add finish info if available
 	if finished {
 		switch finishData.Reason {
@@ -146,25 +155,25 @@ func renderAssistantMessage(
 			info = append(info, baseStyle.
 				Width(width-1).
 				Foreground(t.TextMuted()).
-				Render(fmt.Sprintf(" %s (%s)", models.SupportedModels[msg.Model].Name, took)),
+				Render(fmt.Sprintf(" %s (%s)", modelName, took)),
 			)
 		case message.FinishReasonCanceled:
 			info = append(info, baseStyle.
 				Width(width-1).
 				Foreground(t.TextMuted()).
-				Render(fmt.Sprintf(" %s (%s)", models.SupportedModels[msg.Model].Name, "canceled")),
+				Render(fmt.Sprintf(" %s (%s)", modelName, "canceled")),
 			)
 		case message.FinishReasonError:
 			info = append(info, baseStyle.
 				Width(width-1).
 				Foreground(t.TextMuted()).
-				Render(fmt.Sprintf(" %s (%s)", models.SupportedModels[msg.Model].Name, "error")),
+				Render(fmt.Sprintf(" %s (%s)", modelName, "error")),
 			)
 		case message.FinishReasonPermissionDenied:
 			info = append(info, baseStyle.
 				Width(width-1).
 				Foreground(t.TextMuted()).
-				Render(fmt.Sprintf(" %s (%s)", models.SupportedModels[msg.Model].Name, "permission denied")),
+				Render(fmt.Sprintf(" %s (%s)", modelName, "permission denied")),
 			)
 		}
 	}
@@ -487,6 +496,30 @@ func renderToolResponse(toolCall message.ToolCall, response message.ToolResult,
 	}
 }
 
+// renderToolAttachments shows the images a tool returned and names the
+// resources it embedded.
+func renderToolAttachments(response message.ToolResult, width int) string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+
+	var parts []string
+	for _, attachment := range response.Attachments {
+		if attachment.IsText() {
+			parts = append(parts, baseStyle.Width(width).Foreground(t.TextMuted()).
+				Render(ansi.Truncate("Resource: "+attachment.Path, width, "...")))
+			continue
+		}
+		preview, err := image.DataPreview(min(width, maxImagePreviewWidth), attachment.Data)
+		if err != nil {
+			parts = append(parts, baseStyle.Width(width).Foreground(t.TextMuted()).
+				Render(fmt.Sprintf("Image (%s) can't be shown: %s", attachment.MIMEType, err)))
+			continue
+		}
+		parts = append(parts, strings.TrimSuffix(preview, "\n"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
 func renderToolMessage(
 	toolCall message.ToolCall,
 	allMessages []message.Message,
@@ -579,6 +612,9 @@ func renderToolMessage(
 	if responseContent != "" && !nested {
 		parts = append(parts, responseContent)
 	}
+	if response != nil && len(response.Attachments) > 0 && !nested {
+		parts = append(parts, renderToolAttachments(*response, width-2))
+	}
 
 	content := style.Render(
 		lipgloss.JoinVertical(
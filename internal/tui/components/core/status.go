@@ -8,6 +8,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/llm/models"
 	"github.com/opencode-ai/opencode/internal/lsp"
 	"github.com/opencode-ai/opencode/internal/lsp/protocol"
@@ -29,6 +30,10 @@ type statusCmp struct {
 	messageTTL time.Duration
 	lspClients map[string]*lsp.Client
 	session    session.Session
+	// fallback is the model answering instead of the model of the coder
+	// agent, fallbackFor, which failed.
+	fallback    models.Model
+	fallbackFor models.ModelID
 }
 
 // clearMessageCmd is a command that clears status messages after a timeout
@@ -66,6 +71,11 @@ func (m statusCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.clearMessageCmd(ttl)
 	case util.ClearStatusMsg:
 		m.info = util.InfoMsg{}
+	case pubsub.Event[agent.AgentEvent]:
+		if fallback := msg.Payload.Fallback; fallback != nil {
+			m.fallback = fallback.To
+			m.fallbackFor = config.Get().Agents[config.AgentCoder].Model
+		}
 	}
 	return m, nil
 }
@@ -118,8 +128,7 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 
 func (m statusCmp) View() string {
 	t := theme.CurrentTheme()
-	modelID := config.Get().Agents[config.AgentCoder].Model
-	model := models.SupportedModels[modelID]
+	model, _ := m.activeModel()
 
 	// Initialize the help widget
 	status := getHelpWidget()
@@ -271,11 +280,16 @@ func (m statusCmp) model() string {
 
 	cfg := config.Get()
 
-	coder, ok := cfg.Agents[config.AgentCoder]
-	if !ok {
+	if _, ok := cfg.Agents[config.AgentCoder]; !ok {
 		return "Unknown"
 	}
-	model := models.SupportedModels[coder.Model]
+	model, fallback := m.activeModel()
+	if fallback {
+		return styles.Padded().
+			Background(t.Warning()).
+			Foreground(t.Background()).
+			Render("↪ " + model.Name)
+	}
 
 	return styles.Padded().
 		Background(t.Secondary()).
@@ -283,6 +297,16 @@ func (m statusCmp) model() string {
 		Render(model.Name)
 }
 
+// activeModel returns the model answering for the coder agent, and whether
+// it is a fallback.
+func (m statusCmp) activeModel() (models.Model, bool) {
+	coder := config.Get().Agents[config.AgentCoder].Model
+	if m.fallback.ID != "" && m.fallback.ID != coder && m.fallbackFor == coder {
+		return m.fallback, true
+	}
+	return models.SupportedModels[coder], false
+}
+
 func NewStatusCmp(lspClients map[string]*lsp.Client) StatusCmp {
 	helpWidget = getHelpWidget()
 
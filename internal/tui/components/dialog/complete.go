@@ -6,6 +6,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
 	utilComponents "github.com/opencode-ai/opencode/internal/tui/components/util"
 	"github.com/opencode-ai/opencode/internal/tui/layout"
 	"github.com/opencode-ai/opencode/internal/tui/styles"
@@ -65,6 +66,12 @@ type CompletionProvider interface {
 	GetChildEntries(query string) ([]CompletionItemI, error)
 }
 
+// CompletionAttachment is implemented by completion items that attach
+// content to the message instead of inserting their value.
+type CompletionAttachment interface {
+	LoadAttachment() (message.Attachment, error)
+}
+
 type CompletionSelectedMsg struct {
 	SearchString    string
 	CompletionValue string
@@ -84,7 +91,7 @@ type CompletionDialog interface {
 
 type completionDialogCmp struct {
 	query                string
-	completionProvider   CompletionProvider
+	completionProviders  []CompletionProvider
 	width                int
 	height               int
 	pseudoSearchTextArea textarea.Model
@@ -116,6 +123,23 @@ func (c *completionDialogCmp) complete(item CompletionItemI) tea.Cmd {
 		return nil
 	}
 
+	if attachment, ok := item.(CompletionAttachment); ok {
+		return tea.Batch(
+			util.CmdHandler(CompletionSelectedMsg{
+				SearchString:    value,
+				CompletionValue: "",
+			}),
+			c.close(),
+			func() tea.Msg {
+				loaded, err := attachment.LoadAttachment()
+				if err != nil {
+					return util.ReportError(err)()
+				}
+				return AttachmentAddedMsg{loaded}
+			},
+		)
+	}
+
 	return tea.Batch(
 		util.CmdHandler(CompletionSelectedMsg{
 			SearchString:    value,
@@ -153,12 +177,7 @@ func (c *completionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				if query != c.query {
 					logging.Info("Query", query)
-					items, err := c.completionProvider.GetChildEntries(query)
-					if err != nil {
-						logging.Error("Failed to get child entries", err)
-					}
-
-					c.listView.SetItems(items)
+					c.listView.SetItems(c.childEntries(query))
 					c.query = query
 				}
 
@@ -187,12 +206,7 @@ func (c *completionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			return c, tea.Batch(cmds...)
 		} else {
-			items, err := c.completionProvider.GetChildEntries("")
-			if err != nil {
-				logging.Error("Failed to get child entries", err)
-			}
-
-			c.listView.SetItems(items)
+			c.listView.SetItems(c.childEntries(""))
 			c.pseudoSearchTextArea.SetValue(msg.String())
 			return c, c.pseudoSearchTextArea.Focus()
 		}
@@ -232,6 +246,20 @@ func (c *completionDialogCmp) View() string {
 		Render(c.listView.View())
 }
 
+// childEntries returns the entries of every provider matching query.
+func (c *completionDialogCmp) childEntries(query string) []CompletionItemI {
+	var items []CompletionItemI
+	for _, provider := range c.completionProviders {
+		entries, err := provider.GetChildEntries(query)
+		if err != nil {
+			logging.Error("Failed to get child entries", "provider", provider.GetId(), "error", err)
+			continue
+		}
+		items = append(items, entries...)
+	}
+	return items
+}
+
 func (c *completionDialogCmp) SetWidth(width int) {
 	c.width = width
 }
@@ -240,25 +268,21 @@ func (c *completionDialogCmp) BindingKeys() []key.Binding {
 	return layout.KeyMapToSlice(completionDialogKeys)
 }
 
-func NewCompletionDialogCmp(completionProvider CompletionProvider) CompletionDialog {
+// NewCompletionDialogCmp creates a completion dialog listing the entries of
+// all the given providers.
+func NewCompletionDialogCmp(completionProviders ...CompletionProvider) CompletionDialog {
 	ti := textarea.New()
 
-	items, err := completionProvider.GetChildEntries("")
-	if err != nil {
-		logging.Error("Failed to get child entries", err)
+	c := &completionDialogCmp{
+		query:                "",
+		completionProviders:  completionProviders,
+		pseudoSearchTextArea: ti,
 	}
-
-	li := utilComponents.NewSimpleList(
-		items,
+	c.listView = utilComponents.NewSimpleList(
+		c.childEntries(""),
 		7,
-		"No file matches found",
+		"No matches found",
 		false,
 	)
-
-	return &completionDialogCmp{
-		query:                "",
-		completionProvider:   completionProvider,
-		pseudoSearchTextArea: ti,
-		listView:             li,
-	}
+	return c
 }
@@ -2,7 +2,6 @@ package dialog
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
@@ -25,9 +24,8 @@ import (
 )
 
 const (
-	maxAttachmentSize = int64(5 * 1024 * 1024) // 5MB
-	downArrow         = "down"
-	upArrow           = "up"
+	downArrow = "down"
+	upArrow   = "up"
 )
 
 type FilePrickerKeyMap struct {
@@ -234,7 +232,7 @@ func (f *filepickerCmp) addAttachmentToMessage() (tea.Model, tea.Cmd) {
 		return f, nil
 	}
 
-	isFileLarge, err := image.ValidateFileSize(selectedFilePath, maxAttachmentSize)
+	isFileLarge, err := image.ValidateFileSize(selectedFilePath, message.MaxAttachmentSize)
 	if err != nil {
 		logging.ErrorPersist("unable to read the image")
 		return f, nil
@@ -244,16 +242,11 @@ func (f *filepickerCmp) addAttachmentToMessage() (tea.Model, tea.Cmd) {
 		return f, nil
 	}
 
-	content, err := os.ReadFile(selectedFilePath)
+	attachment, err := message.LoadAttachment(selectedFilePath, message.MaxAttachmentSize)
 	if err != nil {
 		logging.ErrorPersist("Unable read selected file")
 		return f, nil
 	}
-
-	mimeBufferSize := min(512, len(content))
-	mimeType := http.DetectContentType(content[:mimeBufferSize])
-	fileName := filepath.Base(selectedFilePath)
-	attachment := message.Attachment{FilePath: selectedFilePath, FileName: fileName, MimeType: mimeType, Content: content}
 	f.selectedFile = ""
 	return f, util.CmdHandler(AttachmentAddedMsg{attachment})
 }
@@ -466,6 +459,5 @@ func IsHidden(file string) (bool, error) {
 }
 
 func isExtSupported(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	return (ext == ".jpg" || ext == ".jpeg" || ext == ".webp" || ext == ".png")
+	return message.IsAttachmentSupported(path)
 }
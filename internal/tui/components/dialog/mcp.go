@@ -0,0 +1,247 @@
+package dialog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/tui/layout"
+	"github.com/opencode-ai/opencode/internal/tui/styles"
+	"github.com/opencode-ai/opencode/internal/tui/theme"
+	"github.com/opencode-ai/opencode/internal/tui/util"
+)
+
+// mcpStderrLines is how many lines of standard error the dialog shows.
+const mcpStderrLines = 8
+
+// ShowMCPDialogMsg is sent to open the MCP servers dialog
+type ShowMCPDialogMsg struct{}
+
+// CloseMCPDialogMsg is sent when the MCP servers dialog is closed
+type CloseMCPDialogMsg struct{}
+
+// MCPDialog shows the state of the configured MCP servers and lets the user
+// enable or disable them for the session
+type MCPDialog interface {
+	tea.Model
+	layout.Bindings
+	SetSize(width, height int)
+}
+
+type mcpDialogCmp struct {
+	manager     *mcpclient.Manager
+	servers     []mcpclient.ServerStatus
+	selectedIdx int
+	width       int
+	height      int
+}
+
+type mcpKeyMap struct {
+	Up     key.Binding
+	Down   key.Binding
+	Toggle key.Binding
+	Escape key.Binding
+}
+
+var mcpKeys = mcpKeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "previous server"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "next server"),
+	),
+	Toggle: key.NewBinding(
+		key.WithKeys("enter", " "),
+		key.WithHelp("enter", "enable/disable for this session"),
+	),
+	Escape: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "close"),
+	),
+}
+
+func (m *mcpDialogCmp) Init() tea.Cmd {
+	m.servers = m.manager.Status()
+	m.selectedIdx = min(m.selectedIdx, max(len(m.servers)-1, 0))
+	return nil
+}
+
+func (m *mcpDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case pubsub.Event[mcpclient.ServerStatus]:
+		return m, m.Init()
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, mcpKeys.Up):
+			if m.selectedIdx > 0 {
+				m.selectedIdx--
+			}
+		case key.Matches(msg, mcpKeys.Down):
+			if m.selectedIdx < len(m.servers)-1 {
+				m.selectedIdx++
+			}
+		case key.Matches(msg, mcpKeys.Toggle):
+			if len(m.servers) > 0 {
+				return m, m.toggle(m.servers[m.selectedIdx])
+			}
+		case key.Matches(msg, mcpKeys.Escape):
+			return m, util.CmdHandler(CloseMCPDialogMsg{})
+		}
+	case tea.WindowSizeMsg:
+		m.SetSize(msg.Width, msg.Height)
+	}
+	return m, nil
+}
+
+// toggle enables a disabled server and disables a server in any other
+// state. Disconnecting
+// waits for the server to exit, so it runs in the background.
+func (m *mcpDialogCmp) toggle(server mcpclient.ServerStatus) tea.Cmd {
+	manager := m.manager
+	return func() tea.Msg {
+		if server.State == mcpclient.StateDisabled {
+			if err := manager.Enable(server.Name); err != nil {
+				return util.ReportError(err)()
+			}
+			return util.ReportInfo(fmt.Sprintf("Connecting to MCP server %s", server.Name))()
+		}
+		if err := manager.Disable(server.Name); err != nil {
+			return util.ReportError(err)()
+		}
+		return util.ReportInfo(fmt.Sprintf("Disabled MCP server %s for this session", server.Name))()
+	}
+}
+
+func (m *mcpDialogCmp) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m *mcpDialogCmp) stateColor(state mcpclient.State) lipgloss.AdaptiveColor {
+	t := theme.CurrentTheme()
+	switch state {
+	case mcpclient.StateConnected:
+		return t.Success()
+	case mcpclient.StateConnecting:
+		return t.Warning()
+	case mcpclient.StateFailed:
+		return t.Error()
+	default:
+		return t.TextMuted()
+	}
+}
+
+func (m *mcpDialogCmp) View() string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+	width := max(40, min(80, m.width-10))
+
+	title := baseStyle.
+		Foreground(t.Primary()).
+		Bold(true).
+		Width(width).
+		Padding(0, 1).
+		Render("MCP Servers")
+
+	if len(m.servers) == 0 {
+		return baseStyle.Padding(1, 2).
+			Border(lipgloss.RoundedBorder()).
+			BorderBackground(t.Background()).
+			BorderForeground(t.TextMuted()).
+			Render(lipgloss.JoinVertical(lipgloss.Left, title, "", baseStyle.Width(width).Padding(0, 1).Render("No MCP servers configured")))
+	}
+
+	nameWidth := 0
+	for _, server := range m.servers {
+		nameWidth = max(nameWidth, lipgloss.Width(server.Name))
+	}
+	nameWidth = min(nameWidth, width/2)
+
+	rows := make([]string, 0, len(m.servers))
+	for i, server := range m.servers {
+		name := fmt.Sprintf("%-*s", nameWidth, ansi.Truncate(server.Name, nameWidth, "…"))
+		state := string(server.State)
+		if server.State == mcpclient.StateConnected {
+			state = fmt.Sprintf("%s, %d tools", state, server.Tools)
+		}
+
+		rowStyle := baseStyle.Width(width).Padding(0, 1)
+		if i == m.selectedIdx {
+			rowStyle = rowStyle.
+				Background(t.Primary()).
+				Foreground(t.Background()).
+				Bold(true)
+			rows = append(rows, rowStyle.Render(name+"  "+state))
+			continue
+		}
+		stateText := baseStyle.Foreground(m.stateColor(server.State)).Render(state)
+		rows = append(rows, rowStyle.Render(name+baseStyle.Render("  ")+stateText))
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		baseStyle.Width(width).Render(""),
+		lipgloss.JoinVertical(lipgloss.Left, rows...),
+		baseStyle.Width(width).Render(""),
+		m.details(m.servers[m.selectedIdx], width),
+		baseStyle.Width(width).Render(""),
+		baseStyle.Foreground(t.TextMuted()).Width(width).Padding(0, 1).Render("enter enable/disable for this session · esc close"),
+	)
+
+	return baseStyle.Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(t.Background()).
+		BorderForeground(t.TextMuted()).
+		Width(lipgloss.Width(content) + 4).
+		Render(content)
+}
+
+// details renders the protocol, catalog, last error and standard error of a
+// server.
+func (m *mcpDialogCmp) details(server mcpclient.ServerStatus, width int) string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+	labelStyle := baseStyle.Foreground(t.TextMuted())
+	lineStyle := baseStyle.Width(width).Padding(0, 1)
+
+	field := func(label, value string) string {
+		return lineStyle.Render(labelStyle.Render(fmt.Sprintf("%-10s", label)) + baseStyle.Render(value))
+	}
+
+	lines := []string{field("Type", string(server.Type))}
+	if server.State == mcpclient.StateConnected {
+		lines = append(lines,
+			field("Protocol", server.ProtocolVersion),
+			field("Server", strings.TrimSpace(server.ServerInfo.Name+" "+server.ServerInfo.Version)),
+			field("Offers", fmt.Sprintf("%d tools, %d resources, %d prompts", server.Tools, server.Resources, server.Prompts)),
+		)
+	}
+	if server.Err != nil {
+		lines = append(lines, lineStyle.Foreground(t.Error()).Render("Error     "+server.Err.Error()))
+	}
+	if len(server.Stderr) > 0 {
+		lines = append(lines, lineStyle.Render(labelStyle.Render("Stderr")))
+		stderr := server.Stderr[max(len(server.Stderr)-mcpStderrLines, 0):]
+		for _, line := range stderr {
+			lines = append(lines, lineStyle.Foreground(t.TextMuted()).Render(ansi.Truncate(line, width-2, "…")))
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m *mcpDialogCmp) BindingKeys() []key.Binding {
+	return layout.KeyMapToSlice(mcpKeys)
+}
+
+// NewMCPDialogCmp creates a dialog showing the servers of manager
+func NewMCPDialogCmp(manager *mcpclient.Manager) MCPDialog {
+	return &mcpDialogCmp{manager: manager}
+}
@@ -0,0 +1,92 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/opencode-ai/opencode/internal/mcpclient"
+	"github.com/opencode-ai/opencode/internal/tui/util"
+)
+
+// MCPCommandPrefix is the prefix of the commands running MCP prompts.
+const MCPCommandPrefix = "mcp:"
+
+const getPromptTimeout = 30 * time.Second
+
+// LoadMCPPromptCommands returns a command for every prompt of the connected
+// MCP servers. Prompts with arguments ask for them in the multi-arguments
+// dialog first.
+func LoadMCPPromptCommands(manager *mcpclient.Manager) []Command {
+	var commands []Command
+	for _, prompt := range manager.Prompts() {
+		id := MCPCommandPrefix + prompt.Server + ":" + prompt.Prompt.Name
+		description := prompt.Prompt.Description
+		if description == "" {
+			description = fmt.Sprintf("Prompt from the %s MCP server", prompt.Server)
+		}
+
+		commands = append(commands, Command{
+			ID:          id,
+			Title:       id,
+			Description: description,
+			Handler: func(cmd Command) tea.Cmd {
+				if len(prompt.Prompt.Arguments) > 0 {
+					argNames := make([]string, len(prompt.Prompt.Arguments))
+					for i, arg := range prompt.Prompt.Arguments {
+						argNames[i] = arg.Name
+					}
+					return util.CmdHandler(ShowMultiArgumentsDialogMsg{
+						CommandID: cmd.ID,
+						ArgNames:  argNames,
+					})
+				}
+				return RunMCPPrompt(manager, cmd.ID, nil)
+			},
+		})
+	}
+	return commands
+}
+
+// RunMCPPrompt renders the prompt of an MCP command with the given arguments
+// and runs it like a custom command.
+func RunMCPPrompt(manager *mcpclient.Manager, commandID string, args map[string]string) tea.Cmd {
+	serverName, promptName, ok := strings.Cut(strings.TrimPrefix(commandID, MCPCommandPrefix), ":")
+	if !ok {
+		return util.ReportError(fmt.Errorf("invalid MCP command %q", commandID))
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), getPromptTimeout)
+		defer cancel()
+
+		result, err := manager.GetPrompt(ctx, serverName, promptName, args)
+		if err != nil {
+			return util.ReportError(fmt.Errorf("failed to get prompt %s: %w", promptName, err))()
+		}
+		content := promptText(result)
+		if content == "" {
+			return util.ReportWarn(fmt.Sprintf("Prompt %s is empty", promptName))()
+		}
+		return CommandRunCustomMsg{Content: content}
+	}
+}
+
+// promptText joins the text of the prompt messages. Images can't be sent
+// with a command, so they are left out.
+func promptText(result *mcp.GetPromptResult) string {
+	var parts []string
+	for _, msg := range result.Messages {
+		switch content := msg.Content.(type) {
+		case mcp.TextContent:
+			parts = append(parts, content.Text)
+		case mcp.EmbeddedResource:
+			if text, ok := content.Resource.(mcp.TextResourceContents); ok {
+				parts = append(parts, text.Text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -1,6 +1,7 @@
 package image
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"os"
@@ -70,3 +71,12 @@ func ImagePreview(width int, filename string) (string, error) {
 
 	return imageString, nil
 }
+
+// DataPreview renders encoded image data, like ImagePreview does for a file.
+func DataPreview(width int, data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return ToString(width, img), nil
+}
@@ -310,8 +310,11 @@ func (p *chatPage) BindingKeys() []key.Binding {
 }
 
 func NewChatPage(app *app.App) tea.Model {
-	cg := completions.NewFileAndFolderContextGroup()
-	completionDialog := dialog.NewCompletionDialogCmp(cg)
+	providers := []dialog.CompletionProvider{completions.NewFileAndFolderContextGroup()}
+	if app.MCP != nil {
+		providers = append(providers, completions.NewMCPResourcesContextGroup(app.MCP))
+	}
+	completionDialog := dialog.NewCompletionDialogCmp(providers...)
 	rewindDialog := dialog.NewRewindDialogCmp()
 
 	messagesContainer := layout.NewContainer(
@@ -3,12 +3,16 @@ package tui
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/archive"
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/logging"
@@ -37,6 +41,17 @@ type keyMap struct {
 
 type startCompactSessionMsg struct{}
 
+type exportSessionMsg struct {
+	format archive.Format
+}
+
+// sessionExportedMsg reports the result of an export, which runs in the
+// background.
+type sessionExportedMsg struct {
+	path string
+	err  error
+}
+
 const (
 	quitKey = "q"
 )
@@ -136,6 +151,9 @@ type appModel struct {
 	showMultiArgumentsDialog bool
 	multiArgumentsDialog     dialog.MultiArgumentsDialogCmp
 
+	showMCPDialog bool
+	mcpDialog     dialog.MCPDialog
+
 	isCompacting      bool
 	compactingMessage string
 
@@ -230,6 +248,9 @@ func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, filepickerCmd)
 
 		a.initDialog.SetSize(msg.Width, msg.Height)
+		if a.showMCPDialog {
+			a.mcpDialog.SetSize(msg.Width, msg.Height)
+		}
 
 		if a.showMultiArgumentsDialog {
 			a.multiArgumentsDialog.SetSize(msg.Width, msg.Height)
@@ -337,8 +358,36 @@ func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return nil
 		}
 
+	case exportSessionMsg:
+		if a.selectedSession.ID == "" {
+			return a, util.ReportWarn("No active session to export")
+		}
+		sessionID := a.selectedSession.ID
+		return a, func() tea.Msg {
+			path, err := a.exportSession(sessionID, msg.format)
+			return sessionExportedMsg{path: path, err: err}
+		}
+
+	case sessionExportedMsg:
+		if msg.err != nil {
+			return a, util.ReportError(msg.err)
+		}
+		return a, util.ReportInfo("Session exported to " + msg.path)
+
 	case pubsub.Event[agent.AgentEvent]:
 		payload := msg.Payload
+		if payload.Type == agent.AgentEventTypeFallback {
+			s, _ := a.status.Update(msg)
+			a.status = s.(core.StatusCmp)
+			return a, nil
+		}
+		if payload.Type == agent.AgentEventTypeRetry {
+			return a, util.CmdHandler(util.InfoMsg{
+				Type: util.InfoTypeWarn,
+				Msg:  payload.Retry.String(),
+				TTL:  payload.Retry.Delay,
+			})
+		}
 		if payload.Error != nil {
 			a.isCompacting = false
 			return a, util.ReportError(payload.Error)
@@ -364,6 +413,16 @@ func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.showThemeDialog = false
 		return a, nil
 
+	case dialog.ShowMCPDialogMsg:
+		a.mcpDialog = dialog.NewMCPDialogCmp(a.app.MCP)
+		a.mcpDialog.SetSize(a.width, a.height)
+		a.showMCPDialog = true
+		return a, a.mcpDialog.Init()
+
+	case dialog.CloseMCPDialogMsg:
+		a.showMCPDialog = false
+		return a, nil
+
 	case dialog.ThemeChangedMsg:
 		a.pages[a.currentPage], cmd = a.pages[a.currentPage].Update(msg)
 		a.showThemeDialog = false
@@ -441,6 +500,11 @@ func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Close multi-arguments dialog
 		a.showMultiArgumentsDialog = false
 
+		// MCP prompts are rendered by their server with the arguments
+		if msg.Submit && strings.HasPrefix(msg.CommandID, dialog.MCPCommandPrefix) && a.app.MCP != nil {
+			return a, dialog.RunMCPPrompt(a.app.MCP, msg.CommandID, msg.Args)
+		}
+
 		// If submitted, replace all named arguments and run the command
 		if msg.Submit {
 			content := msg.Content
@@ -509,10 +573,15 @@ func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keys.Commands):
 			if a.currentPage == page.ChatPage && !a.showQuit && !a.showPermissions && !a.showSessionDialog && !a.showThemeDialog && !a.showFilepicker {
 				// Show commands dialog
-				if len(a.commands) == 0 {
+				commands := a.commands
+				if a.app.MCP != nil {
+					// MCP servers connect in the background and may change their prompts
+					commands = append(slices.Clip(commands), dialog.LoadMCPPromptCommands(a.app.MCP)...)
+				}
+				if len(commands) == 0 {
 					return a, util.ReportWarn("No commands available")
 				}
-				a.commandDialog.SetCommands(a.commands)
+				a.commandDialog.SetCommands(commands)
 				a.showCommandDialog = true
 				return a, nil
 			}
@@ -673,6 +742,16 @@ func (a appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	if a.showMCPDialog {
+		d, mcpCmd := a.mcpDialog.Update(msg)
+		a.mcpDialog = d.(dialog.MCPDialog)
+		cmds = append(cmds, mcpCmd)
+		// Only block key messages send all other messages down
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return a, tea.Batch(cmds...)
+		}
+	}
+
 	s, _ := a.status.Update(msg)
 	a.status = s.(core.StatusCmp)
 	a.pages[a.currentPage], cmd = a.pages[a.currentPage].Update(msg)
@@ -685,6 +764,31 @@ func (a *appModel) RegisterCommand(cmd dialog.Command) {
 	a.commands = append(a.commands, cmd)
 }
 
+// exportSession writes the session to the exports folder of the data
+// directory and returns the path of the created file.
+func (a *appModel) exportSession(sessionID string, format archive.Format) (string, error) {
+	exported, err := archive.Export(context.Background(), a.app.Sessions, a.app.Messages, a.app.History, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(config.Get().Data.Directory, "exports")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s.%s", sessionID, format))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer f.Close()
+
+	if err := exported.Write(f, format); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
 func (a *appModel) findCommand(id string) (dialog.Command, bool) {
 	for _, cmd := range a.commands {
 		if cmd.ID == id {
@@ -897,6 +1001,21 @@ func (a appModel) View() string {
 		)
 	}
 
+	if a.showMCPDialog {
+		overlay := a.mcpDialog.View()
+		row := lipgloss.Height(appView) / 2
+		row -= lipgloss.Height(overlay) / 2
+		col := lipgloss.Width(appView) / 2
+		col -= lipgloss.Width(overlay) / 2
+		appView = layout.PlaceOverlay(
+			col,
+			row,
+			overlay,
+			appView,
+			true,
+		)
+	}
+
 	if a.showMultiArgumentsDialog {
 		overlay := a.multiArgumentsDialog.View()
 		row := lipgloss.Height(appView) / 2
@@ -970,6 +1089,36 @@ If there are Cursor rules (in .cursor/rules/ or .cursorrules) or Copilot rules (
 			}
 		},
 	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "export_markdown",
+		Title:       "Export Session (Markdown)",
+		Description: "Save a readable transcript of the current session",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(exportSessionMsg{format: archive.Markdown})
+		},
+	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "export_json",
+		Title:       "Export Session (JSON)",
+		Description: "Save the current session so it can be imported with `opencode session import`",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(exportSessionMsg{format: archive.JSON})
+		},
+	})
+
+	if app.MCP != nil {
+		model.RegisterCommand(dialog.Command{
+			ID:          "mcp_servers",
+			Title:       "MCP Servers",
+			Description: "Show the state of the MCP servers and enable or disable them",
+			Handler: func(cmd dialog.Command) tea.Cmd {
+				return util.CmdHandler(dialog.ShowMCPDialogMsg{})
+			},
+		})
+	}
+
 	// Load custom commands
 	customCommands, err := dialog.LoadCustomCommands()
 	if err != nil {